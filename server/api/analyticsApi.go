@@ -0,0 +1,152 @@
+package api
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/repository"
+)
+
+type analyticsCacheKey struct {
+	pollID   uuid.UUID
+	interval repository.AnalyticsInterval
+}
+
+type analyticsCacheEntry struct {
+	key       analyticsCacheKey
+	analytics *repository.PollAnalytics
+	expiresAt time.Time
+}
+
+const (
+	// analyticsCacheCapacity is the most analytics kept; the least recently
+	// used are evicted first.
+	analyticsCacheCapacity = 1000
+	// analyticsCacheTTL is how long analytics are kept, so that memory is
+	// given back once a poll is no longer looked at.
+	analyticsCacheTTL = time.Hour
+)
+
+// analyticsCache holds the analytics of closed polls. A closed poll can no
+// longer receive votes, so its time series never changes once computed.
+type analyticsCache struct {
+	capacity int
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[analyticsCacheKey]*list.Element
+}
+
+func newAnalyticsCache(capacity int, ttl time.Duration) *analyticsCache {
+	return &analyticsCache{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[analyticsCacheKey]*list.Element),
+	}
+}
+
+func (c *analyticsCache) get(pollID uuid.UUID, interval repository.AnalyticsInterval) (*repository.PollAnalytics, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[analyticsCacheKey{pollID, interval}]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*analyticsCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, entry.key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.analytics, true
+}
+
+func (c *analyticsCache) set(analytics *repository.PollAnalytics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := analyticsCacheKey{analytics.PollID, analytics.Interval}
+	entry := &analyticsCacheEntry{key: key, analytics: analytics, expiresAt: time.Now().Add(c.ttl)}
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*analyticsCacheEntry).key)
+	}
+}
+
+func (c *analyticsCache) invalidate(pollID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.entries {
+		if key.pollID == pollID {
+			c.order.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (api *API) GetPollAnalytics(w http.ResponseWriter, r *http.Request) {
+	pollID, err := uuid.Parse(chi.URLParam(r, "pollID"))
+	if err != nil || uuid.Nil == pollID {
+		writeProblem(w, r, problemInvalidPollID.new(""))
+		return
+	}
+
+	interval := repository.AnalyticsIntervalHour
+	if value := r.URL.Query().Get("interval"); value != "" {
+		interval = repository.AnalyticsInterval(value)
+	}
+
+	if !interval.Valid() {
+		writeProblem(w, r, validationProblem(map[string][]string{
+			"interval": {"Interval must be one of minute, hour or day"},
+		}))
+		return
+	}
+
+	if _, err := api.requirePollManager(r.Context(), pollID, ResolveUserID(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	analytics, cached := api.analyticsCache.get(pollID, interval)
+	if !cached {
+		analytics, err = api.repository.GetPollAnalytics(r.Context(), repository.GetPollAnalyticsParams{
+			PollID:   pollID,
+			Interval: interval,
+		})
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	if !cached && analytics.Closed(time.Now()) {
+		api.analyticsCache.set(analytics)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(analytics)
+}
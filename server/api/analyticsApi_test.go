@@ -0,0 +1,47 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/repository"
+)
+
+func TestAnalyticsCache(t *testing.T) {
+	c := newAnalyticsCache(2, 50*time.Millisecond)
+
+	analytics := func() *repository.PollAnalytics {
+		return &repository.PollAnalytics{PollID: uuid.New(), Interval: repository.AnalyticsIntervalHour}
+	}
+	cached := func(a *repository.PollAnalytics) bool {
+		got, ok := c.get(a.PollID, a.Interval)
+		return ok && got == a
+	}
+
+	first, second, third := analytics(), analytics(), analytics()
+	c.set(first)
+	c.set(second)
+
+	// Using the first makes the second the least recently used.
+	if !cached(first) {
+		t.Fatal("first: not cached")
+	}
+	c.set(third)
+	if cached(second) || !cached(first) || !cached(third) {
+		t.Fatal("set beyond capacity: want the least recently used evicted")
+	}
+
+	c.invalidate(first.PollID)
+	if cached(first) || !cached(third) {
+		t.Fatal("invalidate: want only the poll dropped")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if cached(third) {
+		t.Fatal("expired: still cached")
+	}
+	if len(c.entries) != 0 || c.order.Len() != 0 {
+		t.Fatalf("expired: %d entries left", len(c.entries))
+	}
+}
@@ -1,13 +1,128 @@
 package api
 
-import "github.com/toramanomer/polly/repository"
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/blob"
+	"github.com/toramanomer/polly/cache"
+	"github.com/toramanomer/polly/config"
+	"github.com/toramanomer/polly/mail"
+	"github.com/toramanomer/polly/metrics"
+	"github.com/toramanomer/polly/repository"
+	"github.com/toramanomer/polly/webhook"
+)
+
+// VoteRecorder records a single vote. It is implemented by the repository and
+// by the buffered ingest.Ingester.
+type VoteRecorder interface {
+	RecordVote(ctx context.Context, vote *repository.Vote) error
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
 
 type API struct {
-	repository *repository.Repository
+	config         *config.Config
+	repository     repository.Store
+	votes          VoteRecorder
+	polls          cache.PollCache
+	analyticsCache *analyticsCache
+	passcodes      *passcodeGuard
+	metrics        *metrics.Metrics
+	mailer         mail.Mailer
+	blobs          blob.Store
+	// webhooks sends test deliveries to webhooks.
+	webhooks *webhook.Sender
+	// httpClient is used for outbound calls, e.g. Turnstile verification.
+	httpClient *http.Client
+	// background tracks the work done after responding, e.g. recording the
+	// votes of Slack interactions.
+	background sync.WaitGroup
 }
 
-func NewAPI(repository *repository.Repository) *API {
+func NewAPI(
+	config *config.Config,
+	repository repository.Store,
+	votes VoteRecorder,
+	polls cache.PollCache,
+	metrics *metrics.Metrics,
+	mailer mail.Mailer,
+	blobs blob.Store,
+	webhooks *webhook.Sender,
+) *API {
 	return &API{
-		repository: repository,
+		config:         config,
+		repository:     repository,
+		votes:          votes,
+		polls:          polls,
+		analyticsCache: newAnalyticsCache(analyticsCacheCapacity, analyticsCacheTTL),
+		passcodes:      newPasscodeGuard(runtime.NumCPU()),
+		metrics:        metrics,
+		mailer:         mailer,
+		blobs:          blobs,
+		webhooks:       webhooks,
+		httpClient:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Close waits until the work done after responding is finished or ctx is done.
+// It is called once the server stopped handling requests.
+func (api *API) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		api.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getPollWithOptions reads a poll through the poll cache. Cache failures are
+// logged and fall back to the repository.
+func (api *API) getPollWithOptions(ctx context.Context, pollID uuid.UUID) (*repository.Poll, error) {
+	poll, found, err := api.polls.Get(ctx, pollID)
+	if err != nil {
+		logger(ctx).Warn("error reading poll from cache", "poll_id", pollID, "error", err)
 	}
+	if found {
+		return poll, nil
+	}
+
+	poll, err = api.repository.GetPollWithOptions(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := api.polls.Set(ctx, poll); err != nil {
+		logger(ctx).Warn("error caching poll", "poll_id", pollID, "error", err)
+	}
+
+	return poll, nil
+}
+
+// invalidatePoll drops every cached view of a poll after it changed.
+func (api *API) invalidatePoll(ctx context.Context, pollID uuid.UUID) {
+	if err := api.polls.Delete(ctx, pollID); err != nil {
+		logger(ctx).Warn("error invalidating cached poll", "poll_id", pollID, "error", err)
+	}
+	api.analyticsCache.invalidate(pollID)
+}
+
+// decodeJSON decodes the request body into v, reading at most the configured
+// maximum body size.
+func (api *API) decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, api.config.MaxBodyBytes)
+	return json.NewDecoder(r.Body).Decode(v)
 }
@@ -2,11 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/toramanomer/polly/metrics"
 	"github.com/toramanomer/polly/primitives"
 	"github.com/toramanomer/polly/repository"
 )
@@ -42,23 +43,13 @@ func (req *signupRequest) validate() map[string][]string {
 func (api *API) Signup(w http.ResponseWriter, r *http.Request) {
 	var request signupRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]any{
-			"type":  "invalid_request_body",
-			"title": "The request body is invalid.",
-		})
+	if err := api.decodeJSON(w, r, &request); err != nil {
+		writeProblem(w, r, decodeProblem(err))
 		return
 	}
 
 	if errors := request.validate(); errors != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		json.NewEncoder(w).Encode(map[string]any{
-			"type":   "validation_error",
-			"errors": errors,
-		})
+		writeProblem(w, r, validationProblem(errors))
 		return
 	}
 
@@ -69,29 +60,7 @@ func (api *API) Signup(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err := api.repository.CreateUser(r.Context(), user); err != nil {
-		switch err {
-		case repository.ErrEmailAlreadyExists:
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			json.NewEncoder(w).Encode(map[string]any{
-				"type":   "email_already_exists",
-				"errors": map[string][]string{"email": {"Email already exists"}},
-			})
-		case repository.ErrUsernameAlreadyExists:
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			json.NewEncoder(w).Encode(map[string]any{
-				"type":   "username_already_exists",
-				"errors": map[string][]string{"username": {"Username already exists"}},
-			})
-		default:
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]any{
-				"type":  "internal_server_error",
-				"title": "We could not process your request.",
-			})
-		}
+		writeError(w, r, err)
 		return
 	}
 
@@ -100,6 +69,10 @@ func (api *API) Signup(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
+type tokenResponse struct {
+	Token string `json:"token"`
+}
+
 type signinRequest struct {
 	Email    primitives.Email    `json:"email"`
 	Password primitives.Password `json:"password"`
@@ -125,44 +98,31 @@ func (req *signinRequest) validate() map[string][]string {
 
 func (api *API) Signin(w http.ResponseWriter, r *http.Request) {
 	var request signinRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]any{
-			"type":  "invalid_request_body",
-			"title": "The request body is invalid.",
-		})
+	if err := api.decodeJSON(w, r, &request); err != nil {
+		writeProblem(w, r, decodeProblem(err))
 		return
 	}
 
 	if errors := request.validate(); errors != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		json.NewEncoder(w).Encode(map[string]any{
-			"type":   "validation_error",
-			"errors": errors,
-		})
+		writeProblem(w, r, validationProblem(errors))
 		return
 	}
 
 	user, err := api.repository.GetUserByEmail(r.Context(), request.Email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		api.metrics.Signin(metrics.SigninFailed)
+		writeProblem(w, r, problemInvalidCredentials.new(""))
+		return
+	}
+
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]any{
-			"type":  "invalid_credentials",
-			"title": "Invalid email or password.",
-		})
+		writeError(w, r, err)
 		return
 	}
 
 	if !user.VerifyPassword(request.Password) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]any{
-			"type":  "invalid_credentials",
-			"title": "Invalid email or password.",
-		})
+		api.metrics.Signin(metrics.SigninFailed)
+		writeProblem(w, r, problemInvalidCredentials.new(""))
 		return
 	}
 
@@ -171,17 +131,14 @@ func (api *API) Signin(w http.ResponseWriter, r *http.Request) {
 		"exp": time.Now().Add(time.Hour * 24).Unix(),
 	})
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SYMMETRIC_KEY")))
+	tokenString, err := token.SignedString([]byte(api.config.JWTSymmetricKey))
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]any{
-			"type":  "internal_server_error",
-			"title": "We could not process your request.",
-		})
+		writeError(w, r, err)
 		return
 	}
 
+	api.metrics.Signin(metrics.SigninSucceeded)
+
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
 		Value:    tokenString,
@@ -194,15 +151,13 @@ func (api *API) Signin(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"token": tokenString,
-	})
+	json.NewEncoder(w).Encode(tokenResponse{Token: tokenString})
 }
 
 func (api *API) Me(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("token")
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		writeProblem(w, r, problemUnauthorized.new(""))
 		return
 	}
 
@@ -210,11 +165,11 @@ func (api *API) Me(w http.ResponseWriter, r *http.Request) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, jwt.ErrSignatureInvalid
 		}
-		return []byte(os.Getenv("JWT_SYMMETRIC_KEY")), nil
+		return []byte(api.config.JWTSymmetricKey), nil
 	})
 
 	if err != nil || !token.Valid {
-		w.WriteHeader(http.StatusUnauthorized)
+		writeProblem(w, r, problemUnauthorized.new(""))
 		return
 	}
 
@@ -230,9 +185,7 @@ func (api *API) Me(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]any{
-		"token": cookie.Value,
-	})
+	json.NewEncoder(w).Encode(tokenResponse{Token: cookie.Value})
 }
 
 func (api *API) Signout(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/mail"
+	"github.com/toramanomer/polly/primitives"
+	"github.com/toramanomer/polly/repository"
+)
+
+// ballotDelivery is how the tokens of new ballots reach their recipients.
+type ballotDelivery string
+
+const (
+	// ballotDeliveryLink returns the voting links to the poll manager, who
+	// hands them out.
+	ballotDeliveryLink ballotDelivery = "link"
+	// ballotDeliveryEmail emails every recipient their voting link.
+	ballotDeliveryEmail ballotDelivery = "email"
+)
+
+const maxBallotRecipients = 500
+
+type createBallotsRequest struct {
+	// Recipients are email addresses or usernames.
+	Recipients []string `json:"recipients"`
+	// Delivery defaults to link.
+	Delivery ballotDelivery `json:"delivery,omitempty"`
+
+	emails    []primitives.Email
+	usernames []primitives.Username
+}
+
+func (req *createBallotsRequest) validate() map[string][]string {
+	errs := make(map[string][]string)
+
+	if req.Delivery == "" {
+		req.Delivery = ballotDeliveryLink
+	}
+
+	if req.Delivery != ballotDeliveryLink && req.Delivery != ballotDeliveryEmail {
+		errs["delivery"] = append(errs["delivery"], "Delivery must be one of link or email")
+	}
+
+	if len(req.Recipients) == 0 {
+		errs["recipients"] = append(errs["recipients"], "At least 1 recipient is required")
+	}
+
+	if len(req.Recipients) > maxBallotRecipients {
+		errs["recipients"] = append(errs["recipients"],
+			fmt.Sprintf("A maximum of %d recipients are allowed", maxBallotRecipients))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for _, recipient := range req.Recipients {
+		if strings.Contains(recipient, "@") {
+			email := primitives.Email(recipient)
+			for _, message := range email.Validate() {
+				errs["recipients"] = append(errs["recipients"], fmt.Sprintf("%s: %s", recipient, message))
+			}
+			if !slices.Contains(req.emails, email) {
+				req.emails = append(req.emails, email)
+			}
+			continue
+		}
+
+		username := primitives.Username(recipient)
+		if usernameErrors := username.Validate(); usernameErrors != nil {
+			errs["recipients"] = append(errs["recipients"], usernameErrors...)
+		}
+		req.usernames = append(req.usernames, username)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+type issuedBallot struct {
+	ID        uuid.UUID `json:"id"`
+	Recipient string    `json:"recipient"`
+	// Link is the personal voting link of the recipient. It is only returned
+	// for link delivery, or if the email could not be sent.
+	Link    string `json:"link,omitempty"`
+	Emailed bool   `json:"emailed"`
+}
+
+type ballotsResponse struct {
+	Ballots []issuedBallot `json:"ballots"`
+	// Skipped are the recipients who already had a ballot for the poll.
+	Skipped []string `json:"skipped"`
+}
+
+// requirePollManager returns the poll if the user may manage it: the owner of
+// a personal poll and the admins of the organization owning an organization
+// poll may. The creator of an organization poll manages it only through their
+// current role, so members who lost it also lose their polls.
+func (api *API) requirePollManager(ctx context.Context, pollID, userID uuid.UUID) (*repository.Poll, error) {
+	poll, err := api.getPollWithOptions(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	if poll.OrganizationID == nil {
+		if poll.UserID != userID {
+			return nil, repository.ErrNotPollOwner
+		}
+		return poll, nil
+	}
+
+	role, err := api.repository.GetOrganizationRole(ctx, *poll.OrganizationID, userID)
+	if err != nil || !role.AtLeast(repository.OrganizationRoleAdmin) {
+		return nil, repository.ErrNotPollOwner
+	}
+
+	return poll, nil
+}
+
+// pollLink is the web app address of a poll.
+func (api *API) pollLink(pollID uuid.UUID) string {
+	return fmt.Sprintf("%s/polls/%s", strings.TrimSuffix(api.config.PublicURL, "/"), pollID)
+}
+
+// ballotLink is the web app address a ballot is used at.
+func (api *API) ballotLink(pollID uuid.UUID, token string) string {
+	return api.pollLink(pollID) + "?ballot=" + url.QueryEscape(token)
+}
+
+// CreateBallots issues a single-use ballot to every recipient of a ballot poll
+// who does not have one yet.
+func (api *API) CreateBallots(w http.ResponseWriter, r *http.Request) {
+	pollID, err := uuid.Parse(chi.URLParam(r, "pollID"))
+	if err != nil || uuid.Nil == pollID {
+		writeProblem(w, r, problemInvalidPollID.new(""))
+		return
+	}
+
+	var request createBallotsRequest
+	if err := api.decodeJSON(w, r, &request); err != nil {
+		writeProblem(w, r, decodeProblem(err))
+		return
+	}
+
+	if errs := request.validate(); errs != nil {
+		writeProblem(w, r, validationProblem(errs))
+		return
+	}
+
+	poll, err := api.requirePollManager(r.Context(), pollID, ResolveUserID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if poll.Visibility != repository.PollVisibilityBallot {
+		writeProblem(w, r, problemNotBallotPoll.new("Only polls with ballot visibility take ballots."))
+		return
+	}
+
+	users, err := api.resolveUsers(r.Context(), "recipients", request.usernames)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var (
+		ballots = make([]*repository.Ballot, 0, len(request.emails)+len(users))
+		tokens  = make(map[uuid.UUID]string, cap(ballots))
+	)
+	for _, email := range request.emails {
+		ballot, token := repository.NewBallot(pollID, string(email), string(email))
+		ballots, tokens[ballot.ID] = append(ballots, ballot), token
+	}
+	for _, user := range users {
+		ballot, token := repository.NewBallot(pollID, string(user.Username), string(user.Email))
+		ballots, tokens[ballot.ID] = append(ballots, ballot), token
+	}
+
+	created, err := api.repository.CreateBallots(r.Context(), ballots)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	response := ballotsResponse{
+		Ballots: make([]issuedBallot, 0, len(created)),
+		Skipped: make([]string, 0),
+	}
+
+	for _, ballot := range created {
+		issued := issuedBallot{ID: ballot.ID, Recipient: ballot.Recipient}
+		link := api.ballotLink(pollID, tokens[ballot.ID])
+
+		if request.Delivery == ballotDeliveryEmail {
+			err := api.mailer.Send(r.Context(), mail.Message{
+				To:      ballot.Email,
+				Subject: "You are invited to vote: " + string(poll.Question),
+				Body: fmt.Sprintf("You are invited to vote on \"%s\".\n\n%s\n\n"+
+					"The link is personal and can be used to vote once.\n", poll.Question, link),
+			})
+			// Without an SMTP server the link is returned instead.
+			if err != nil && !errors.Is(err, mail.ErrNotConfigured) {
+				logger(r.Context()).Warn("error emailing ballot", "poll_id", pollID, "ballot_id", ballot.ID, "error", err)
+			}
+			issued.Emailed = err == nil
+		}
+
+		if !issued.Emailed {
+			issued.Link = link
+		}
+
+		response.Ballots = append(response.Ballots, issued)
+	}
+
+	for _, ballot := range ballots {
+		if !slices.ContainsFunc(created, func(c *repository.Ballot) bool { return c.ID == ballot.ID }) {
+			response.Skipped = append(response.Skipped, ballot.Recipient)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetPollTurnout lists the ballots of a poll and how many were used.
+func (api *API) GetPollTurnout(w http.ResponseWriter, r *http.Request) {
+	pollID, err := uuid.Parse(chi.URLParam(r, "pollID"))
+	if err != nil || uuid.Nil == pollID {
+		writeProblem(w, r, problemInvalidPollID.new(""))
+		return
+	}
+
+	if _, err := api.requirePollManager(r.Context(), pollID, ResolveUserID(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	turnout, err := api.repository.GetPollTurnout(r.Context(), pollID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(turnout)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// ReadinessCheck reports whether a dependency is ready to serve traffic.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Healthz reports that the process is alive. It does not check dependencies,
+// so a database outage does not get the process restarted.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "ok",
+	})
+}
+
+const readinessTimeout = 2 * time.Second
+
+// Readyz runs every check and responds 503 unless all of them pass. Only
+// whether each check passed is reported; why one failed is logged, as the
+// error may tell the address or user of a dependency.
+func Readyz(checks ...ReadinessCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		var (
+			status  = http.StatusOK
+			results = make(map[string]string, len(checks))
+		)
+
+		for _, check := range checks {
+			if err := check.Check(ctx); err != nil {
+				logger(ctx).Warn("readiness check failed", "check", check.Name, "error", err)
+				status = http.StatusServiceUnavailable
+				results[check.Name] = "fail"
+				continue
+			}
+			results[check.Name] = "pass"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": http.StatusText(status),
+			"checks": results,
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/repository"
+)
+
+// UploadImage stores the image in the request body for the options of the
+// user's polls, within the quota of the user. The format is sniffed from the
+// content; the Content-Type header is ignored. Images no poll option uses are
+// deleted after a while.
+func (api *API) UploadImage(w http.ResponseWriter, r *http.Request) {
+	image := repository.NewImage(ResolveUserID(r))
+
+	object, err := api.blobs.Put(r.Context(), image.BlobKey(), r.Body)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	image.ContentType, image.Size = object.ContentType, object.Size
+
+	if err := api.repository.CreateImage(r.Context(), repository.CreateImageParams{
+		Image: image,
+		Quota: api.config.MaxUserImageBytes,
+	}); err != nil {
+		if err := api.blobs.Delete(r.Context(), image.BlobKey()); err != nil {
+			logger(r.Context()).Warn("error deleting orphaned image", "image_id", image.ID, "error", err)
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(image)
+}
+
+// GetImage serves an uploaded image. Images never change, so they may be
+// cached for good.
+func (api *API) GetImage(w http.ResponseWriter, r *http.Request) {
+	imageID, err := uuid.Parse(chi.URLParam(r, "imageID"))
+	if err != nil || uuid.Nil == imageID {
+		writeProblem(w, r, problemInvalidImageID.new(""))
+		return
+	}
+
+	image, err := api.repository.GetImage(r.Context(), imageID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	content, err := api.blobs.Open(r.Context(), image.BlobKey())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", image.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(image.Size, 10))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, content); err != nil {
+		logger(r.Context()).Warn("error writing image", "image_id", imageID, "error", err)
+	}
+}
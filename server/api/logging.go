@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/toramanomer/polly/requestid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// logger returns the default logger annotated with the request ID and, when
+// the request is sampled, the trace ID of ctx.
+func logger(ctx context.Context) *slog.Logger {
+	l := slog.Default()
+	if id := requestid.FromContext(ctx); id != "" {
+		l = l.With("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsSampled() {
+		l = l.With("trace_id", sc.TraceID().String())
+	}
+	return l
+}
+
+// errorChain lists the messages of err and of every error it wraps, outermost
+// first, so the log shows where along the chain the failure originated.
+func errorChain(err error) []string {
+	var chain []string
+
+	for err != nil {
+		chain = append(chain, err.Error())
+
+		switch wrapped := err.(type) {
+		case interface{ Unwrap() error }:
+			err = wrapped.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, inner := range wrapped.Unwrap() {
+				chain = append(chain, errorChain(inner)...)
+			}
+			err = nil
+		default:
+			err = nil
+		}
+	}
+
+	return chain
+}
+
+// logServerError logs the cause of a 5xx response.
+func logServerError(r *http.Request, err error) {
+	if err == nil {
+		err = errors.New("unknown error")
+	}
+
+	logger(r.Context()).Error("request failed",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"error", err.Error(),
+		"error_chain", errorChain(err),
+	)
+}
+
+// RequestLogger writes a structured access log entry for every request. It
+// must run after requestid.Middleware to include the request ID.
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			start = time.Now()
+			ww    = middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		route := ""
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+
+		logger(r.Context()).Log(r.Context(), level, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", route,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote_addr", r.RemoteAddr,
+			"user_agent", r.UserAgent(),
+		)
+	})
+}
@@ -3,13 +3,18 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
-	"os"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/toramanomer/polly/metrics"
+	"github.com/toramanomer/polly/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type contextKey string
@@ -18,45 +23,65 @@ const (
 	ctxKeyUserID contextKey = "userID"
 )
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("token")
-		if err != nil {
-			http.Error(w, "not authorized", http.StatusUnauthorized)
-			return
+// authenticate returns the user of the session cookie, if it holds a valid
+// token.
+func (api *API) authenticate(r *http.Request) (uuid.UUID, bool) {
+	cookie, err := r.Cookie("token")
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
 		}
+		return []byte(api.config.JWTSymmetricKey), nil
+	})
 
-		token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (any, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(os.Getenv("JWT_SYMMETRIC_KEY")), nil
-		})
+	if err != nil {
+		return uuid.Nil, false
+	}
 
-		if err != nil {
-			http.Error(w, "not authorized", http.StatusUnauthorized)
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return uuid.Nil, false
+	}
+
+	maybeUserID, ok := claims["sub"].(string)
+	if !ok {
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(maybeUserID)
+	if err != nil || uuid.Nil == userID {
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+func (api *API) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := api.authenticate(r)
+		if !ok {
+			writeProblem(w, r, problemUnauthorized.new(""))
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			maybeUserID, ok := claims["sub"].(string)
-			if !ok {
-				http.Error(w, "not authorized", http.StatusUnauthorized)
-				return
-			}
-
-			userID, err := uuid.Parse(maybeUserID)
-			if err != nil || uuid.Nil == userID {
-				http.Error(w, "not authorized", http.StatusUnauthorized)
-				return
-			}
-
-			ctx := context.WithValue(r.Context(), ctxKeyUserID, userID)
-			next.ServeHTTP(w, r.WithContext(ctx))
-			return
+		ctx := context.WithValue(r.Context(), ctxKeyUserID, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// OptionalAuthMiddleware resolves the user of routes that anonymous users may
+// call too. A missing or invalid token leaves the request anonymous.
+func (api *API) OptionalAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID, ok := api.authenticate(r); ok {
+			r = r.WithContext(context.WithValue(r.Context(), ctxKeyUserID, userID))
 		}
 
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		next.ServeHTTP(w, r)
 	})
 }
 
@@ -64,6 +89,12 @@ func ResolveUserID(r *http.Request) uuid.UUID {
 	return r.Context().Value(ctxKeyUserID).(uuid.UUID)
 }
 
+// OptionalUserID returns the user behind OptionalAuthMiddleware, if any.
+func OptionalUserID(r *http.Request) (uuid.UUID, bool) {
+	userID, ok := r.Context().Value(ctxKeyUserID).(uuid.UUID)
+	return userID, ok
+}
+
 type turnstileResponse struct {
 	Success     bool     `json:"success"`
 	ErrorCodes  []string `json:"error-codes"`
@@ -71,23 +102,51 @@ type turnstileResponse struct {
 	Hostname    string   `json:"hostname"`
 }
 
-var TURNSTILE_SECRET_KEY = os.Getenv("TURNSTILE_SECRET_KEY")
+const turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
+// verifyTurnstile calls the Turnstile siteverify endpoint in its own span. The
+// trace context is deliberately not propagated to Cloudflare.
+func (api *API) verifyTurnstile(ctx context.Context, form url.Values) (_ *turnstileResponse, err error) {
+	ctx, span := tracing.Start(ctx, "turnstile.verify",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("server.address", "challenges.cloudflare.com")),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, turnstileVerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := api.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	var turnstileResp turnstileResponse
+	if err := json.NewDecoder(resp.Body).Decode(&turnstileResp); err != nil {
+		return nil, fmt.Errorf("error parsing Turnstile response: %w", err)
+	}
+
+	span.SetAttributes(attribute.Bool("turnstile.success", turnstileResp.Success))
+	return &turnstileResp, nil
+}
 
-func WithTurnstileProtection(next http.Handler) http.Handler {
+func (api *API) WithTurnstileProtection(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		token := r.Header.Get("X-CF-Turnstile-Token")
 		if token == "" {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{
-				"type":  "missing_turnstile_token",
-				"title": "Turnstile token is required",
-			})
+			api.metrics.TurnstileVerification(metrics.TurnstileMissingToken)
+			writeProblem(w, r, problemTurnstileMissing.new(""))
 			return
 		}
 
 		requestBody := url.Values{
-			"secret":   {TURNSTILE_SECRET_KEY},
+			"secret":   {api.config.TurnstileSecretKey},
 			"response": {token},
 		}
 
@@ -95,40 +154,21 @@ func WithTurnstileProtection(next http.Handler) http.Handler {
 			requestBody.Set("remoteip", host)
 		}
 
-		resp, err := http.PostForm("https://challenges.cloudflare.com/turnstile/v0/siteverify", requestBody)
+		turnstileResp, err := api.verifyTurnstile(r.Context(), requestBody)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"type":  "turnstile_verification_failed",
-				"title": "Failed to verify Turnstile token",
-			})
-			return
-		}
-		defer resp.Body.Close()
-
-		var turnstileResp turnstileResponse
-		if err := json.NewDecoder(resp.Body).Decode(&turnstileResp); err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"type":  "turnstile_verification_failed",
-				"title": "Failed to parse Turnstile response",
-			})
+			api.metrics.TurnstileVerification(metrics.TurnstileError)
+			logServerError(r, err)
+			writeProblem(w, r, problemTurnstileUnavailable.new(""))
 			return
 		}
 
 		if !turnstileResp.Success {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]any{
-				"type":        "turnstile_verification_failed",
-				"title":       "Turnstile verification failed",
-				"error_codes": turnstileResp.ErrorCodes,
-			})
+			api.metrics.TurnstileVerification(metrics.TurnstileFailure)
+			writeProblem(w, r, problemTurnstileFailed.new("").with("errorCodes", turnstileResp.ErrorCodes))
 			return
 		}
 
+		api.metrics.TurnstileVerification(metrics.TurnstileSuccess)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,472 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/toramanomer/polly/blob"
+	"github.com/toramanomer/polly/openapi"
+	"github.com/toramanomer/polly/repository"
+)
+
+// OpenAPIPath is where the API document is served. It is not described by
+// the document itself.
+const OpenAPIPath = "/api/openapi.json"
+
+const cookieAuth = "cookieAuth"
+
+// operation describes one route. request and response are zero values of the
+// types the handler decodes and encodes, nil if it has no body.
+type operation struct {
+	method   string
+	path     string
+	id       string
+	summary  string
+	tag      string
+	auth     bool
+	params   []openapi.Parameter
+	request  any
+	status   int
+	response any
+	problems []int
+	// upload and download are the media types of a binary request or
+	// response body, described instead of request or response.
+	upload   []string
+	download []string
+	// conditional operations answer If-None-Match and If-Modified-Since.
+	conditional bool
+}
+
+var pollIDParam = openapi.Parameter{
+	Name:     "pollID",
+	In:       openapi.InPath,
+	Required: true,
+	Schema:   &openapi.Schema{Type: "string", Format: "uuid"},
+}
+
+var (
+	imageIDParam = openapi.Parameter{
+		Name:     "imageID",
+		In:       openapi.InPath,
+		Required: true,
+		Schema:   &openapi.Schema{Type: "string", Format: "uuid"},
+	}
+	organizationIDParam = openapi.Parameter{
+		Name:     "organizationID",
+		In:       openapi.InPath,
+		Required: true,
+		Schema:   &openapi.Schema{Type: "string", Format: "uuid"},
+	}
+	webhookIDParam = openapi.Parameter{
+		Name:     "webhookID",
+		In:       openapi.InPath,
+		Required: true,
+		Schema:   &openapi.Schema{Type: "string", Format: "uuid"},
+	}
+	usernameParam = openapi.Parameter{
+		Name:     "username",
+		In:       openapi.InPath,
+		Required: true,
+		Schema:   &openapi.Schema{Type: "string"},
+	}
+)
+
+var (
+	passcodeParam = openapi.Parameter{
+		Name:        passcodeHeader,
+		In:          openapi.InHeader,
+		Description: "Passcode of a passcode protected poll.",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+	ballotParam = openapi.Parameter{
+		Name:        ballotHeader,
+		In:          openapi.InHeader,
+		Description: "Ballot token of a ballot poll.",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+)
+
+var (
+	limitParam = openapi.Parameter{Name: "limit", In: openapi.InQuery, Description: "Page size.", Schema: &openapi.Schema{
+		Type: "integer", Minimum: ptr(1.0), Maximum: ptr(float64(maxPollsPageSize)), Default: defaultPollsPageSize,
+	}}
+	cursorParam = openapi.Parameter{
+		Name: "cursor", In: openapi.InQuery, Description: "nextCursor of the previous page.", Schema: &openapi.Schema{Type: "string"},
+	}
+)
+
+func enum(values ...string) *openapi.Schema {
+	return &openapi.Schema{Type: "string", Enum: values}
+}
+
+var operations = []operation{
+	{
+		method: http.MethodPost, path: "/api/auth/signup", id: "signup", tag: "auth",
+		summary: "Create an account",
+		request: signupRequest{}, status: http.StatusCreated, response: repository.User{},
+		problems: []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodPost, path: "/api/auth/signin", id: "signin", tag: "auth",
+		summary: "Sign in and receive the session cookie",
+		request: signinRequest{}, status: http.StatusOK, response: tokenResponse{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodPost, path: "/api/auth/signout", id: "signout", tag: "auth",
+		summary: "Clear the session cookie",
+		status:  http.StatusOK,
+	},
+	{
+		method: http.MethodGet, path: "/api/auth/me", id: "me", tag: "auth",
+		summary: "Refresh the session cookie",
+		status:  http.StatusOK, response: tokenResponse{},
+		problems: []int{http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodPost, path: "/api/polls", id: "createPoll", tag: "polls", auth: true,
+		summary: "Create a poll",
+		request: createPollRequest{}, status: http.StatusCreated, response: repository.Poll{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+			http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/polls", id: "getUserPolls", tag: "polls", auth: true,
+		summary: "List the polls of the signed in user",
+		params: []openapi.Parameter{
+			limitParam,
+			{Name: "status", In: openapi.InQuery, Schema: enum(
+				string(repository.PollStatusActive), string(repository.PollStatusExpired))},
+			{Name: "sort", In: openapi.InQuery, Schema: enum(
+				string(repository.PollSortCreatedAtDesc), string(repository.PollSortCreatedAtAsc),
+				string(repository.PollSortExpiresAtDesc), string(repository.PollSortExpiresAtAsc))},
+			{Name: "createdAfter", In: openapi.InQuery, Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{Name: "createdBefore", In: openapi.InQuery, Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			cursorParam,
+			{Name: "q", In: openapi.InQuery, Description: "Search in the question.", Schema: &openapi.Schema{Type: "string"}},
+			{
+				Name: "organizationID", In: openapi.InQuery,
+				Description: "Only list the polls of this organization. Without it the polls of every organization of the user are included.",
+				Schema:      &openapi.Schema{Type: "string", Format: "uuid"},
+			},
+		},
+		status: http.StatusOK, response: repository.PollPage{},
+		problems: []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/polls/limits", id: "getPollLimits", tag: "polls", auth: true,
+		summary: "Get the limits polls of the signed in user are created within",
+		status:  http.StatusOK, response: pollLimits{},
+		problems: []int{http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodGet, path: "/api/polls/public", id: "listPublicPolls", tag: "polls",
+		summary: "List the active public polls, newest first",
+		params:  []openapi.Parameter{limitParam, cursorParam},
+		status:  http.StatusOK, response: repository.PollPage{},
+		problems: []int{http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/polls/{pollID}", id: "getPoll", tag: "polls",
+		summary: "Get a poll with its options and vote counts",
+		params:  []openapi.Parameter{pollIDParam, passcodeParam, ballotParam},
+		status:  http.StatusOK, response: pollDetails{}, conditional: true,
+		problems: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests},
+	},
+	{
+		method: http.MethodDelete, path: "/api/polls/{pollID}", id: "deletePoll", tag: "polls", auth: true,
+		summary: "Delete a poll of the user or of an organization the user administers",
+		params:  []openapi.Parameter{pollIDParam},
+		status:  http.StatusOK, response: messageResponse{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/polls/{pollID}/analytics", id: "getPollAnalytics", tag: "polls", auth: true,
+		summary: "Get the vote time series of a poll",
+		params: []openapi.Parameter{
+			pollIDParam,
+			{Name: "interval", In: openapi.InQuery, Schema: enum(
+				string(repository.AnalyticsIntervalMinute), string(repository.AnalyticsIntervalHour),
+				string(repository.AnalyticsIntervalDay))},
+		},
+		status: http.StatusOK, response: repository.PollAnalytics{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodPost, path: "/api/polls/{pollID}/vote", id: "voteOnPoll", tag: "polls",
+		summary: "Vote for an option of a poll",
+		params: []openapi.Parameter{
+			pollIDParam,
+			{Name: "X-CF-Turnstile-Token", In: openapi.InHeader, Required: true, Schema: &openapi.Schema{Type: "string"}},
+			passcodeParam,
+			ballotParam,
+		},
+		request: voteOnPollRequest{}, status: http.StatusOK, response: messageResponse{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusRequestEntityTooLarge,
+			http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusBadGateway},
+	},
+	{
+		method: http.MethodPost, path: "/api/polls/{pollID}/ballots", id: "createBallots", tag: "polls", auth: true,
+		summary: "Issue single-use ballots for a ballot poll, as links or by email",
+		params:  []openapi.Parameter{pollIDParam},
+		request: createBallotsRequest{}, status: http.StatusCreated, response: ballotsResponse{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+			http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/polls/{pollID}/turnout", id: "getPollTurnout", tag: "polls", auth: true,
+		summary: "Get the ballots of a poll and how many of them were used",
+		params:  []openapi.Parameter{pollIDParam},
+		status:  http.StatusOK, response: repository.PollTurnout{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/polls/{pollID}/voters", id: "getPollVoters", tag: "polls", auth: true,
+		summary: "Export who voted on a poll and, if it is attributed, for what",
+		params:  []openapi.Parameter{pollIDParam},
+		status:  http.StatusOK, response: repository.PollVoters{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/polls/{pollID}/other-answers", id: "getOtherAnswers", tag: "polls", auth: true,
+		summary: "List the free-text answers given with votes for the Other option",
+		params: []openapi.Parameter{
+			pollIDParam,
+			{Name: "includeHidden", In: openapi.InQuery, Description: "Also list hidden answers.", Schema: &openapi.Schema{Type: "boolean"}},
+		},
+		status: http.StatusOK, response: otherAnswersResponse{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+			http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodPost, path: "/api/polls/{pollID}/other-answers/moderate", id: "moderateOtherAnswers",
+		tag: "polls", auth: true,
+		summary: "Hide, show or merge answers of the Other option into an existing option",
+		params:  []openapi.Parameter{pollIDParam},
+		request: moderateOtherAnswersRequest{}, status: http.StatusOK, response: moderateOtherAnswersResponse{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+			http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodPost, path: "/api/images", id: "uploadImage", tag: "images", auth: true,
+		summary: "Upload an image for the options of the user's polls",
+		upload:  blob.ImageContentTypes, status: http.StatusCreated, response: repository.Image{},
+		problems: []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType},
+	},
+	{
+		method: http.MethodGet, path: "/api/images/{imageID}", id: "getImage", tag: "images",
+		summary: "Download an uploaded image",
+		params:  []openapi.Parameter{imageIDParam},
+		status:  http.StatusOK, download: blob.ImageContentTypes,
+		problems: []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/api/organizations", id: "createOrganization", tag: "organizations", auth: true,
+		summary: "Create an organization owned by the signed in user",
+		request: createOrganizationRequest{}, status: http.StatusCreated, response: repository.OrganizationMembership{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/organizations", id: "getUserOrganizations", tag: "organizations", auth: true,
+		summary: "List the organizations of the signed in user with the user's role",
+		status:  http.StatusOK, response: organizationsResponse{},
+		problems: []int{http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodGet, path: "/api/organizations/{organizationID}/members", id: "getOrganizationMembers",
+		tag: "organizations", auth: true,
+		summary: "List the members of an organization",
+		params:  []openapi.Parameter{organizationIDParam},
+		status:  http.StatusOK, response: membersResponse{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPut, path: "/api/organizations/{organizationID}/members/{username}", id: "setOrganizationMember",
+		tag: "organizations", auth: true,
+		summary: "Add a member to an organization or change the role of a member",
+		params:  []openapi.Parameter{organizationIDParam, usernameParam},
+		request: setOrganizationMemberRequest{}, status: http.StatusOK, response: repository.OrganizationMember{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+			http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodDelete, path: "/api/organizations/{organizationID}/members/{username}", id: "removeOrganizationMember",
+		tag: "organizations", auth: true,
+		summary: "Remove a member from an organization, or leave it",
+		params:  []openapi.Parameter{organizationIDParam, usernameParam},
+		status:  http.StatusOK, response: messageResponse{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodPost, path: "/api/webhooks", id: "createWebhook", tag: "webhooks", auth: true,
+		summary: "Subscribe a URL to events of the polls of the signed in user, or of a single poll. " +
+			"The secret signing the payloads is only returned here",
+		request: createWebhookRequest{}, status: http.StatusCreated, response: createdWebhook{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+			http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/webhooks", id: "getUserWebhooks", tag: "webhooks", auth: true,
+		summary: "List the webhooks of the signed in user",
+		status:  http.StatusOK, response: webhooksResponse{},
+		problems: []int{http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodDelete, path: "/api/webhooks/{webhookID}", id: "deleteWebhook", tag: "webhooks", auth: true,
+		summary: "Delete a webhook of the signed in user",
+		params:  []openapi.Parameter{webhookIDParam},
+		status:  http.StatusOK, response: messageResponse{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/webhooks/{webhookID}/deliveries", id: "getWebhookDeliveries",
+		tag: "webhooks", auth: true,
+		summary: "List the latest deliveries of a webhook of the signed in user, newest first",
+		params:  []openapi.Parameter{webhookIDParam},
+		status:  http.StatusOK, response: webhookDeliveriesResponse{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/api/webhooks/{webhookID}/test", id: "testWebhook", tag: "webhooks", auth: true,
+		summary: "Send a webhook.test event to a webhook of the signed in user and return the delivery",
+		params:  []openapi.Parameter{webhookIDParam},
+		status:  http.StatusOK, response: repository.WebhookDelivery{},
+		problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// problemSchema describes Problem, whose JSON encoding is custom.
+var problemSchema = &openapi.Schema{
+	Type: "object",
+	Properties: map[string]*openapi.Schema{
+		"type":     {Type: "string", Description: "URI reference identifying the problem type."},
+		"title":    {Type: "string"},
+		"status":   {Type: "integer"},
+		"detail":   {Type: "string"},
+		"instance": {Type: "string"},
+		"errors": {
+			Type:                 "object",
+			Description:          "Validation errors by request field.",
+			AdditionalProperties: &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}},
+		},
+		"requestId":  {Type: "string"},
+		"errorCodes": {Type: "array", Items: &openapi.Schema{Type: "string"}},
+	},
+	Required: []string{"type", "title", "status"},
+}
+
+func jsonContent(contentType string, schema *openapi.Schema) map[string]openapi.MediaType {
+	return map[string]openapi.MediaType{contentType: {Schema: schema}}
+}
+
+func binaryContent(contentTypes []string) map[string]openapi.MediaType {
+	content := make(map[string]openapi.MediaType, len(contentTypes))
+	for _, contentType := range contentTypes {
+		content[contentType] = openapi.MediaType{Schema: &openapi.Schema{Type: "string", Format: "binary"}}
+	}
+	return content
+}
+
+func buildOpenAPI() *openapi.Document {
+	var (
+		reflector = openapi.NewReflector()
+		doc       = &openapi.Document{
+			OpenAPI: openapi.Version,
+			Info: openapi.Info{
+				Title:   "Polly",
+				Version: "1.0.0",
+				Description: "Failed requests are answered with RFC 7807 problem details " +
+					"(application/problem+json).",
+			},
+			Paths: make(map[string]*openapi.PathItem),
+		}
+	)
+
+	reflector.Define(Problem{}, problemSchema)
+
+	for _, op := range operations {
+		operation := &openapi.Operation{
+			OperationID: op.id,
+			Summary:     op.summary,
+			Tags:        []string{op.tag},
+			Parameters:  op.params,
+			Responses:   make(map[string]*openapi.Response),
+		}
+
+		if op.auth {
+			operation.Security = []map[string][]string{{cookieAuth: {}}}
+		}
+
+		if op.request != nil {
+			operation.RequestBody = &openapi.RequestBody{
+				Required: true,
+				Content:  jsonContent("application/json", reflector.SchemaOf(op.request)),
+			}
+		}
+		if len(op.upload) > 0 {
+			operation.RequestBody = &openapi.RequestBody{Required: true, Content: binaryContent(op.upload)}
+		}
+
+		success := &openapi.Response{Description: http.StatusText(op.status)}
+		if op.response != nil {
+			success.Content = jsonContent("application/json", reflector.SchemaOf(op.response))
+		}
+		if len(op.download) > 0 {
+			success.Content = binaryContent(op.download)
+		}
+		operation.Responses[strconv.Itoa(op.status)] = success
+
+		if op.conditional {
+			operation.Responses[strconv.Itoa(http.StatusNotModified)] = &openapi.Response{
+				Description: "The representation matching If-None-Match or If-Modified-Since is current.",
+			}
+		}
+
+		problems := append([]int{http.StatusInternalServerError}, op.problems...)
+		sort.Ints(problems)
+		for _, status := range problems {
+			operation.Responses[strconv.Itoa(status)] = &openapi.Response{
+				Description: http.StatusText(status),
+				Content:     jsonContent(problemContentType, reflector.SchemaOf(Problem{})),
+			}
+		}
+
+		item, ok := doc.Paths[op.path]
+		if !ok {
+			item = &openapi.PathItem{}
+			doc.Paths[op.path] = item
+		}
+		(*item)[strings.ToLower(op.method)] = operation
+	}
+
+	doc.Components = openapi.Components{
+		Schemas: reflector.Schemas(),
+		SecuritySchemes: map[string]*openapi.SecurityScheme{
+			cookieAuth: {Type: "apiKey", In: "cookie", Name: "token"},
+		},
+	}
+
+	return doc
+}
+
+// OpenAPI returns the document describing the /api routes.
+var OpenAPI = sync.OnceValue(buildOpenAPI)
+
+// OpenAPIHandler serves the document returned by OpenAPI.
+func OpenAPIHandler() http.Handler {
+	return openapi.Handler(OpenAPI())
+}
+
+// CheckRoutes reports any difference between the /api routes of router and
+// the document returned by OpenAPI.
+func CheckRoutes(router chi.Routes) error {
+	return openapi.CheckRoutes(OpenAPI(), router, "/api/", OpenAPIPath)
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/primitives"
+	"github.com/toramanomer/polly/repository"
+)
+
+type createOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+func (req *createOrganizationRequest) validate() map[string][]string {
+	errs := make(map[string][]string)
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		errs["name"] = append(errs["name"], "Name is required")
+	}
+
+	if utf8.RuneCountInString(req.Name) > 100 {
+		errs["name"] = append(errs["name"], "Name cannot be longer than 100 characters")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// CreateOrganization creates an organization owned by the signed in user.
+func (api *API) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var request createOrganizationRequest
+
+	if err := api.decodeJSON(w, r, &request); err != nil {
+		writeProblem(w, r, decodeProblem(err))
+		return
+	}
+
+	if errs := request.validate(); errs != nil {
+		writeProblem(w, r, validationProblem(errs))
+		return
+	}
+
+	organization := repository.NewOrganization(request.Name)
+	if err := api.repository.CreateOrganization(r.Context(), organization, ResolveUserID(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(repository.OrganizationMembership{
+		Organization: *organization,
+		Role:         repository.OrganizationRoleOwner,
+	})
+}
+
+type organizationsResponse struct {
+	Organizations []repository.OrganizationMembership `json:"organizations"`
+}
+
+// GetUserOrganizations lists the organizations of the signed in user.
+func (api *API) GetUserOrganizations(w http.ResponseWriter, r *http.Request) {
+	memberships, err := api.repository.GetUserOrganizations(r.Context(), ResolveUserID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(organizationsResponse{Organizations: memberships})
+}
+
+// requireOrganizationRole returns the role of the user in an organization if
+// it grants the permissions of role. Organizations the user is not a member of
+// are not found.
+func (api *API) requireOrganizationRole(ctx context.Context, organizationID, userID uuid.UUID, role repository.OrganizationRole) (repository.OrganizationRole, error) {
+	actual, err := api.repository.GetOrganizationRole(ctx, organizationID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if !actual.AtLeast(role) {
+		return "", problemOrganizationRole.new("This requires the " + string(role) + " role.")
+	}
+
+	return actual, nil
+}
+
+func parseOrganizationID(r *http.Request) (uuid.UUID, bool) {
+	organizationID, err := uuid.Parse(chi.URLParam(r, "organizationID"))
+	return organizationID, err == nil && uuid.Nil != organizationID
+}
+
+type membersResponse struct {
+	Members []repository.OrganizationMember `json:"members"`
+}
+
+// GetOrganizationMembers lists the members of an organization to its members.
+func (api *API) GetOrganizationMembers(w http.ResponseWriter, r *http.Request) {
+	organizationID, ok := parseOrganizationID(r)
+	if !ok {
+		writeProblem(w, r, problemInvalidOrganizationID.new(""))
+		return
+	}
+
+	_, err := api.requireOrganizationRole(r.Context(), organizationID, ResolveUserID(r), repository.OrganizationRoleViewer)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	members, err := api.repository.GetOrganizationMembers(r.Context(), organizationID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(membersResponse{Members: members})
+}
+
+// resolveMember looks up the user named by the username path parameter and
+// the role the user has in the organization, empty if none.
+func (api *API) resolveMember(r *http.Request, organizationID uuid.UUID) (*repository.User, repository.OrganizationRole, error) {
+	username := primitives.Username(chi.URLParam(r, "username"))
+	if errs := username.Validate(); errs != nil {
+		return nil, "", problemUserNotFound.new("")
+	}
+
+	users, err := api.repository.GetUsersByUsernames(r.Context(), []primitives.Username{username})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(users) == 0 {
+		return nil, "", problemUserNotFound.new("")
+	}
+
+	role, err := api.repository.GetOrganizationRole(r.Context(), organizationID, users[0].ID)
+	if err != nil && !errors.Is(err, repository.ErrNotOrganizationMember) {
+		return nil, "", err
+	}
+
+	return &users[0], role, nil
+}
+
+type setOrganizationMemberRequest struct {
+	Role repository.OrganizationRole `json:"role"`
+}
+
+func (req *setOrganizationMemberRequest) validate() map[string][]string {
+	errs := make(map[string][]string)
+
+	if !req.Role.Valid() {
+		errs["role"] = append(errs["role"], "Role must be one of owner, admin, member or viewer")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// SetOrganizationMember adds a user to an organization or changes the role of
+// a member. Admins manage members below owner; only owners grant or revoke the
+// owner role.
+func (api *API) SetOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	organizationID, ok := parseOrganizationID(r)
+	if !ok {
+		writeProblem(w, r, problemInvalidOrganizationID.new(""))
+		return
+	}
+
+	var request setOrganizationMemberRequest
+	if err := api.decodeJSON(w, r, &request); err != nil {
+		writeProblem(w, r, decodeProblem(err))
+		return
+	}
+
+	if errs := request.validate(); errs != nil {
+		writeProblem(w, r, validationProblem(errs))
+		return
+	}
+
+	role, err := api.requireOrganizationRole(r.Context(), organizationID, ResolveUserID(r), repository.OrganizationRoleAdmin)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user, current, err := api.resolveMember(r, organizationID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if (current == repository.OrganizationRoleOwner || request.Role == repository.OrganizationRoleOwner) &&
+		role != repository.OrganizationRoleOwner {
+		writeProblem(w, r, problemOrganizationRole.new("Only owners grant or revoke the owner role."))
+		return
+	}
+
+	err = api.repository.SetOrganizationMember(r.Context(), repository.SetOrganizationMemberParams{
+		OrganizationID: organizationID,
+		UserID:         user.ID,
+		Role:           request.Role,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(repository.OrganizationMember{
+		UserID:   user.ID,
+		Username: user.Username,
+		Role:     request.Role,
+	})
+}
+
+// RemoveOrganizationMember removes a member from an organization. Members may
+// always leave; removing others takes the same role as changing theirs.
+func (api *API) RemoveOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	organizationID, ok := parseOrganizationID(r)
+	if !ok {
+		writeProblem(w, r, problemInvalidOrganizationID.new(""))
+		return
+	}
+
+	userID := ResolveUserID(r)
+
+	role, err := api.requireOrganizationRole(r.Context(), organizationID, userID, repository.OrganizationRoleViewer)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user, current, err := api.resolveMember(r, organizationID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if user.ID != userID {
+		switch {
+		case !role.AtLeast(repository.OrganizationRoleAdmin):
+			writeProblem(w, r, problemOrganizationRole.new("This requires the admin role."))
+			return
+		case current == repository.OrganizationRoleOwner && role != repository.OrganizationRoleOwner:
+			writeProblem(w, r, problemOrganizationRole.new("Only owners remove owners."))
+			return
+		}
+	}
+
+	if err := api.repository.RemoveOrganizationMember(r.Context(), organizationID, user.ID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(messageResponse{Message: "Member removed successfully"})
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/repository"
+)
+
+type otherAnswersResponse struct {
+	Answers []repository.OtherAnswer `json:"answers"`
+}
+
+// requireOtherOption returns the Other option of the poll.
+func requireOtherOption(poll *repository.Poll) (*repository.PollOption, error) {
+	for i := range poll.Options {
+		if poll.Options[i].Other {
+			return &poll.Options[i], nil
+		}
+	}
+
+	return nil, problemNoOtherOption.new("Only polls created with allowOther take free-text answers.")
+}
+
+// GetOtherAnswers lists the answers given with votes for the Other option of a
+// poll to the users who manage it. Hidden answers are only listed with
+// includeHidden.
+func (api *API) GetOtherAnswers(w http.ResponseWriter, r *http.Request) {
+	pollID, err := uuid.Parse(chi.URLParam(r, "pollID"))
+	if err != nil || uuid.Nil == pollID {
+		writeProblem(w, r, problemInvalidPollID.new(""))
+		return
+	}
+
+	var includeHidden bool
+	if value := r.URL.Query().Get("includeHidden"); value != "" {
+		if includeHidden, err = strconv.ParseBool(value); err != nil {
+			writeProblem(w, r, validationProblem(map[string][]string{
+				"includeHidden": {"Include hidden must be either true or false"},
+			}))
+			return
+		}
+	}
+
+	poll, err := api.requirePollManager(r.Context(), pollID, ResolveUserID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, err := requireOtherOption(poll); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	answers, err := api.repository.GetOtherAnswers(r.Context(), repository.GetOtherAnswersParams{
+		PollID:        pollID,
+		IncludeHidden: includeHidden,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(otherAnswersResponse{Answers: answers})
+}
+
+// otherAnswerAction is what moderating does to answers of the Other option.
+type otherAnswerAction string
+
+const (
+	// otherAnswerActionHide hides the answers from the default listing.
+	otherAnswerActionHide otherAnswerAction = "hide"
+	// otherAnswerActionShow lists hidden answers again.
+	otherAnswerActionShow otherAnswerAction = "show"
+	// otherAnswerActionMerge moves the votes to an existing option, which
+	// counts them from then on.
+	otherAnswerActionMerge otherAnswerAction = "merge"
+)
+
+const maxModeratedAnswers = 100
+
+type moderateOtherAnswersRequest struct {
+	VoteIDs []uuid.UUID       `json:"voteIDs"`
+	Action  otherAnswerAction `json:"action"`
+	// OptionID is the option merged answers move to.
+	OptionID *uuid.UUID `json:"optionID,omitempty"`
+}
+
+func (req *moderateOtherAnswersRequest) validate() map[string][]string {
+	errs := make(map[string][]string)
+
+	switch req.Action {
+	case otherAnswerActionHide, otherAnswerActionShow:
+		if req.OptionID != nil {
+			errs["optionID"] = append(errs["optionID"], "An option is only allowed to merge answers into")
+		}
+	case otherAnswerActionMerge:
+		if req.OptionID == nil || *req.OptionID == uuid.Nil {
+			errs["optionID"] = append(errs["optionID"], "An option is required to merge answers into")
+		}
+	default:
+		errs["action"] = append(errs["action"], "Action must be one of hide, show or merge")
+	}
+
+	if len(req.VoteIDs) == 0 {
+		errs["voteIDs"] = append(errs["voteIDs"], "At least 1 vote ID is required")
+	}
+
+	if len(req.VoteIDs) > maxModeratedAnswers {
+		errs["voteIDs"] = append(errs["voteIDs"],
+			fmt.Sprintf("A maximum of %d vote IDs are allowed", maxModeratedAnswers))
+	}
+
+	if slices.Contains(req.VoteIDs, uuid.Nil) {
+		errs["voteIDs"] = append(errs["voteIDs"], "Vote IDs must be valid")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+type moderateOtherAnswersResponse struct {
+	// Moderated is how many of the votes were for the Other option and had
+	// the action applied.
+	Moderated int64 `json:"moderated"`
+}
+
+// ModerateOtherAnswers hides, shows or merges answers of the Other option of a
+// poll. Votes that are not for the Other option are left alone.
+func (api *API) ModerateOtherAnswers(w http.ResponseWriter, r *http.Request) {
+	pollID, err := uuid.Parse(chi.URLParam(r, "pollID"))
+	if err != nil || uuid.Nil == pollID {
+		writeProblem(w, r, problemInvalidPollID.new(""))
+		return
+	}
+
+	var request moderateOtherAnswersRequest
+	if err := api.decodeJSON(w, r, &request); err != nil {
+		writeProblem(w, r, decodeProblem(err))
+		return
+	}
+
+	if errs := request.validate(); errs != nil {
+		writeProblem(w, r, validationProblem(errs))
+		return
+	}
+
+	poll, err := api.requirePollManager(r.Context(), pollID, ResolveUserID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	other, err := requireOtherOption(poll)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var moderated int64
+	switch request.Action {
+	case otherAnswerActionMerge:
+		if *request.OptionID == other.ID {
+			writeProblem(w, r, validationProblem(map[string][]string{
+				"optionID": {"Answers cannot be merged into the Other option"},
+			}))
+			return
+		}
+
+		moderated, err = api.repository.MergeOtherAnswers(r.Context(), repository.MergeOtherAnswersParams{
+			PollID:   pollID,
+			VoteIDs:  request.VoteIDs,
+			OptionID: *request.OptionID,
+		})
+		if err == nil && moderated > 0 {
+			api.invalidatePoll(r.Context(), pollID)
+		}
+	default:
+		moderated, err = api.repository.HideOtherAnswers(r.Context(), repository.HideOtherAnswersParams{
+			PollID:  pollID,
+			VoteIDs: request.VoteIDs,
+			Hidden:  request.Action == otherAnswerActionHide,
+		})
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(moderateOtherAnswersResponse{Moderated: moderated})
+}
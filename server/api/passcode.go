@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/primitives"
+	"github.com/toramanomer/polly/repository"
+)
+
+// Passcodes are checked with argon2id on every read of and vote on a
+// passcode poll, by anyone. The passcode guard keeps that from being used to
+// guess them or to pin the CPU and memory of the server: failed attempts are
+// limited per client address and per poll, and only a few checks run at once.
+const (
+	passcodeFailuresPerAddress = 10
+	passcodeFailuresPerPoll    = 100
+	passcodeFailureWindow      = time.Minute
+	// maxPasscodeFailureKeys is how many addresses and polls with failed
+	// attempts are tracked before the expired ones are dropped.
+	maxPasscodeFailureKeys = 10_000
+)
+
+type passcodeGuard struct {
+	// slots holds a token for every check running.
+	slots chan struct{}
+
+	mu       sync.Mutex
+	failures map[string]*passcodeFailures
+	now      func() time.Time
+}
+
+// passcodeFailures counts the failed attempts of a window that began with
+// the first of them.
+type passcodeFailures struct {
+	count int
+	since time.Time
+}
+
+// newPasscodeGuard returns a guard running up to concurrency checks at once.
+func newPasscodeGuard(concurrency int) *passcodeGuard {
+	return &passcodeGuard{
+		slots:    make(chan struct{}, concurrency),
+		failures: make(map[string]*passcodeFailures),
+		now:      time.Now,
+	}
+}
+
+// check verifies the passcode of a poll tried from the client address. It is
+// answered with a problem once the address or the poll had too many failed
+// attempts, without checking the passcode.
+func (g *passcodeGuard) check(ctx context.Context, address string, pollID uuid.UUID, access *repository.PollAccess, passcode primitives.Passcode) error {
+	keys := []string{"address:" + address, "poll:" + pollID.String()}
+
+	if wait := g.blocked(keys); wait > 0 {
+		seconds := int(wait.Round(time.Second) / time.Second)
+		return problemTooManyPasscodeAttempts.new("").with("retryAfter", max(seconds, 1))
+	}
+
+	select {
+	case g.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	valid := access.VerifyPasscode(passcode)
+	<-g.slots
+
+	if !valid {
+		g.fail(keys)
+		return problemInvalidPasscode.new("")
+	}
+
+	return nil
+}
+
+// blocked returns how long until the first of keys that reached its limit may
+// be tried again, 0 if none did.
+func (g *passcodeGuard) blocked(keys []string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+
+	var wait time.Duration
+	for _, key := range keys {
+		failures, ok := g.failures[key]
+		if !ok || now.Sub(failures.since) >= passcodeFailureWindow {
+			continue
+		}
+		if failures.count >= passcodeFailureLimit(key) {
+			wait = max(wait, failures.since.Add(passcodeFailureWindow).Sub(now))
+		}
+	}
+
+	return wait
+}
+
+// fail records a failed attempt for each of keys.
+func (g *passcodeGuard) fail(keys []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+
+	if len(g.failures) >= maxPasscodeFailureKeys {
+		for key, failures := range g.failures {
+			if now.Sub(failures.since) >= passcodeFailureWindow {
+				delete(g.failures, key)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		failures, ok := g.failures[key]
+		if !ok || now.Sub(failures.since) >= passcodeFailureWindow {
+			failures = &passcodeFailures{since: now}
+			g.failures[key] = failures
+		}
+		failures.count++
+	}
+}
+
+func passcodeFailureLimit(key string) int {
+	if strings.HasPrefix(key, "poll:") {
+		return passcodeFailuresPerPoll
+	}
+	return passcodeFailuresPerAddress
+}
+
+// clientAddress is the address r came from, without the port.
+func clientAddress(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/primitives"
+	"github.com/toramanomer/polly/repository"
+)
+
+func TestPasscodeGuard(t *testing.T) {
+	ctx := context.Background()
+	guard := newPasscodeGuard(1)
+
+	now := time.Now()
+	guard.now = func() time.Time { return now }
+
+	passcode := primitives.Passcode("open sesame")
+	access := &repository.PollAccess{PasscodeHash: passcode.Hash()}
+	pollID := uuid.New()
+
+	status := func(err error) int {
+		var problem *Problem
+		if err == nil {
+			return http.StatusOK
+		}
+		if !errors.As(err, &problem) {
+			t.Fatalf("got %v, want a problem", err)
+		}
+		return problem.Status
+	}
+
+	for range passcodeFailuresPerAddress {
+		if got := status(guard.check(ctx, "192.0.2.1", pollID, access, "wrong")); got != http.StatusForbidden {
+			t.Fatalf("wrong passcode: got status %d, want 403", got)
+		}
+	}
+
+	// The address is blocked, even with the right passcode, but others are
+	// not.
+	err := guard.check(ctx, "192.0.2.1", pollID, access, passcode)
+	if got := status(err); got != http.StatusTooManyRequests {
+		t.Fatalf("blocked address: got status %d, want 429", got)
+	}
+	if retryAfter := problemFor(err).Extensions["retryAfter"]; retryAfter != 60 {
+		t.Fatalf("blocked address: got retryAfter %v, want 60", retryAfter)
+	}
+	if got := status(guard.check(ctx, "192.0.2.2", pollID, access, passcode)); got != http.StatusOK {
+		t.Fatalf("other address: got status %d, want 200", got)
+	}
+
+	now = now.Add(passcodeFailureWindow)
+	if got := status(guard.check(ctx, "192.0.2.1", pollID, access, passcode)); got != http.StatusOK {
+		t.Fatalf("after the window: got status %d, want 200", got)
+	}
+
+	// Guesses spread over many addresses are limited by poll.
+	for i := range passcodeFailuresPerPoll {
+		guard.check(ctx, fmt.Sprintf("198.51.100.%d", i%250), pollID, access, "wrong")
+	}
+	if got := status(guard.check(ctx, "192.0.2.3", pollID, access, passcode)); got != http.StatusTooManyRequests {
+		t.Fatalf("blocked poll: got status %d, want 429", got)
+	}
+	if got := status(guard.check(ctx, "192.0.2.3", uuid.New(), access, passcode)); got != http.StatusOK {
+		t.Fatalf("other poll: got status %d, want 200", got)
+	}
+}
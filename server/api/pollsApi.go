@@ -1,36 +1,327 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/fnv"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/toramanomer/polly/metrics"
 	"github.com/toramanomer/polly/primitives"
 	"github.com/toramanomer/polly/repository"
 )
 
+// createPollOption is an option of a new poll. A plain string is accepted as
+// an option with only a text.
+type createPollOption struct {
+	Text        primitives.OptionText `json:"text"`
+	Description string                `json:"description,omitempty"`
+	// ImageID is an image the user uploaded to /api/images.
+	ImageID *uuid.UUID `json:"imageID,omitempty"`
+	// URL links to more about the option.
+	URL string `json:"url,omitempty"`
+}
+
+func (o *createPollOption) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*o = createPollOption{Text: primitives.OptionText(text)}
+		return nil
+	}
+
+	type plain createPollOption
+	return json.Unmarshal(data, (*plain)(o))
+}
+
+const (
+	maxOptionDescriptionLength = 500
+	maxOptionURLLength         = 2048
+)
+
+func (o *createPollOption) validate() []string {
+	errs := o.Text.Validate()
+
+	o.Description = strings.TrimSpace(o.Description)
+	if utf8.RuneCountInString(o.Description) > maxOptionDescriptionLength {
+		errs = append(errs, fmt.Sprintf("Description cannot be longer than %d characters", maxOptionDescriptionLength))
+	}
+
+	if o.ImageID != nil && *o.ImageID == uuid.Nil {
+		errs = append(errs, "Image ID is not valid")
+	}
+
+	o.URL = strings.TrimSpace(o.URL)
+	if o.URL != "" {
+		u, err := url.Parse(o.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, "URL must be an absolute http or https URL")
+		}
+		if len(o.URL) > maxOptionURLLength {
+			errs = append(errs, fmt.Sprintf("URL cannot be longer than %d characters", maxOptionURLLength))
+		}
+	}
+
+	return errs
+}
+
+// pollDuration is a preset for how long a poll runs from its creation.
+type pollDuration string
+
+const (
+	pollDurationHour pollDuration = "1h"
+	pollDurationDay  pollDuration = "1d"
+	pollDurationWeek pollDuration = "1w"
+)
+
+// pollDurations are the presets, shortest first.
+var pollDurations = []pollDuration{pollDurationHour, pollDurationDay, pollDurationWeek}
+
+// duration reports how long the preset runs, if it is one.
+func (d pollDuration) duration() (time.Duration, bool) {
+	switch d {
+	case pollDurationHour:
+		return time.Hour, true
+	case pollDurationDay:
+		return 24 * time.Hour, true
+	case pollDurationWeek:
+		return 7 * 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// minPollDuration is the shortest a poll may run, so it can be voted on at
+// all.
+const minPollDuration = time.Minute
+
+// describeDuration spells d out in the largest whole unit, e.g. 90 days.
+func describeDuration(d time.Duration) string {
+	unit, name := d, ""
+	switch {
+	case d%(24*time.Hour) == 0:
+		unit, name = d/(24*time.Hour), "day"
+	case d%time.Hour == 0:
+		unit, name = d/time.Hour, "hour"
+	case d%time.Minute == 0:
+		unit, name = d/time.Minute, "minute"
+	default:
+		return d.String()
+	}
+
+	if unit == 1 {
+		return "1 " + name
+	}
+	return fmt.Sprintf("%d %ss", unit, name)
+}
+
 type createPollRequest struct {
-	Question  primitives.Question `json:"question"`
-	Options   []string            `json:"options"`
-	ExpiresAt time.Time           `json:"expiresAt"`
+	Question primitives.Question `json:"question"`
+	Options  []createPollOption  `json:"options"`
+	// ExpiresAt or Duration sets when the poll closes; exactly one of them
+	// is required.
+	ExpiresAt time.Time    `json:"expiresAt,omitempty"`
+	Duration  pollDuration `json:"duration,omitempty"`
+	// TimeZone is the IANA time zone the times of the poll are displayed
+	// in, e.g. Europe/Istanbul. It defaults to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+	// Visibility defaults to unlisted.
+	Visibility repository.PollVisibility `json:"visibility,omitempty"`
+	// Attribution defaults to anonymous.
+	Attribution repository.PollAttribution `json:"attribution,omitempty"`
+	// Invitees are the usernames invited to a private poll.
+	Invitees []primitives.Username `json:"invitees,omitempty"`
+	// Passcode protects a passcode poll.
+	Passcode primitives.Passcode `json:"passcode,omitempty"`
+	// OrganizationID makes the poll owned by an organization the user is at
+	// least a member of.
+	OrganizationID *uuid.UUID `json:"organizationID,omitempty"`
+	// AllowOther adds an Other option, voted for with a free-text answer,
+	// after the options.
+	AllowOther bool `json:"allowOther,omitempty"`
 }
 
-func (req *createPollRequest) validate() map[string][]string {
+const maxPollInvitees = 100
+
+// pollLimits bounds the polls a user creates. The number of options
+// includes the Other option.
+type pollLimits struct {
+	MinOptions int `json:"minOptions"`
+	MaxOptions int `json:"maxOptions"`
+	// MaxDurationSeconds is the longest a poll may run.
+	MaxDurationSeconds int64 `json:"maxDurationSeconds"`
+	// Durations are the presets within MaxDurationSeconds.
+	Durations []pollDuration `json:"durations"`
+
+	maxDuration time.Duration
+}
+
+// pollLimits returns the limits of the plan the user is on.
+func (api *API) pollLimits(ctx context.Context, userID uuid.UUID) (pollLimits, error) {
+	user, err := api.repository.GetUserByID(ctx, userID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return pollLimits{}, problemUnauthorized.new("")
+	}
+	if err != nil {
+		return pollLimits{}, err
+	}
+
+	limits := pollLimits{
+		MinOptions:         api.config.MinPollOptions,
+		MaxOptions:         api.config.MaxPollOptionsFor(user.Plan),
+		MaxDurationSeconds: int64(api.config.MaxPollDuration.Seconds()),
+		Durations:          make([]pollDuration, 0, len(pollDurations)),
+		maxDuration:        api.config.MaxPollDuration,
+	}
+
+	for _, preset := range pollDurations {
+		if duration, _ := preset.duration(); duration <= limits.maxDuration {
+			limits.Durations = append(limits.Durations, preset)
+		}
+	}
+
+	return limits, nil
+}
+
+func (req *createPollRequest) validate(limits pollLimits, now time.Time) map[string][]string {
 	errs := make(map[string][]string)
 
+	switch {
+	case req.Duration != "" && !req.ExpiresAt.IsZero():
+		errs["expiresAt"] = append(errs["expiresAt"], "Only one of expiration time and duration is allowed")
+	case req.Duration != "":
+		duration, ok := req.Duration.duration()
+		if !ok {
+			errs["duration"] = append(errs["duration"], "Duration must be one of 1h, 1d or 1w")
+			break
+		}
+		req.ExpiresAt = now.Add(duration)
+	case req.ExpiresAt.IsZero():
+		errs["expiresAt"] = append(errs["expiresAt"], "Expiration time is required")
+	}
+
+	if !req.ExpiresAt.IsZero() {
+		switch {
+		case !req.ExpiresAt.After(now):
+			errs["expiresAt"] = append(errs["expiresAt"], "Expiration time must be in the future")
+		case req.ExpiresAt.Before(now.Add(minPollDuration)):
+			errs["expiresAt"] = append(errs["expiresAt"],
+				fmt.Sprintf("Expiration time must be at least %s away", describeDuration(minPollDuration)))
+		case req.ExpiresAt.After(now.Add(limits.maxDuration)):
+			errs["expiresAt"] = append(errs["expiresAt"],
+				fmt.Sprintf("Polls cannot run for more than %s", describeDuration(limits.maxDuration)))
+		}
+	}
+
+	if req.TimeZone == "" {
+		req.TimeZone = repository.DefaultTimeZone
+	}
+
+	if _, err := time.LoadLocation(req.TimeZone); err != nil || req.TimeZone == "Local" {
+		errs["timeZone"] = append(errs["timeZone"], "Time zone must be an IANA time zone, e.g. Europe/Istanbul")
+	}
+
 	if questionErrors := req.Question.Validate(); questionErrors != nil {
 		errs["question"] = questionErrors
 	}
 
-	if len(req.Options) < 2 {
-		errs["options"] = append(errs["options"], "At least 2 options are required")
+	if req.Visibility == "" {
+		req.Visibility = repository.PollVisibilityUnlisted
+	}
+
+	if !req.Visibility.Valid() {
+		errs["visibility"] = append(errs["visibility"],
+			"Visibility must be one of public, unlisted, private, passcode or ballot")
+	}
+
+	if req.Attribution == "" {
+		req.Attribution = repository.PollAttributionAnonymous
+	}
+
+	if !req.Attribution.Valid() {
+		errs["attribution"] = append(errs["attribution"],
+			"Attribution must be one of anonymous, attributed or verified")
+	}
+
+	if req.Visibility == repository.PollVisibilityPrivate {
+		if len(req.Invitees) > maxPollInvitees {
+			errs["invitees"] = append(errs["invitees"],
+				fmt.Sprintf("A maximum of %d invitees are allowed", maxPollInvitees))
+		}
+		for i := range req.Invitees {
+			if usernameErrors := req.Invitees[i].Validate(); usernameErrors != nil {
+				errs["invitees"] = append(errs["invitees"], usernameErrors...)
+			}
+		}
+	} else if len(req.Invitees) > 0 {
+		errs["invitees"] = append(errs["invitees"], "Invitees are only allowed for private polls")
+	}
+
+	if req.Visibility == repository.PollVisibilityPasscode {
+		if passcodeErrors := req.Passcode.Validate(); passcodeErrors != nil {
+			errs["passcode"] = passcodeErrors
+		}
+	} else if req.Passcode != "" {
+		errs["passcode"] = append(errs["passcode"], "A passcode is only allowed for passcode polls")
 	}
 
-	if len(req.Options) > 6 {
-		errs["options"] = append(errs["options"], "A maximum of 6 options are allowed")
+	optionCount := len(req.Options)
+	if req.AllowOther {
+		optionCount++
+	}
+
+	if optionCount < limits.MinOptions {
+		errs["options"] = append(errs["options"],
+			fmt.Sprintf("At least %d options are required", limits.MinOptions))
+	}
+
+	if optionCount > limits.MaxOptions {
+		if req.AllowOther {
+			errs["options"] = append(errs["options"],
+				fmt.Sprintf("A maximum of %d options are allowed with an Other option", limits.MaxOptions-1))
+		} else {
+			errs["options"] = append(errs["options"],
+				fmt.Sprintf("A maximum of %d options are allowed", limits.MaxOptions))
+		}
+	}
+
+	// seen maps the key of every option text to its option number, the
+	// Other option being 0.
+	seen := make(map[string]int, optionCount)
+	if req.AllowOther {
+		seen[repository.OtherOptionText.Key()] = 0
+	}
+
+	for i := range req.Options {
+		optionErrors := req.Options[i].validate()
+		for _, message := range optionErrors {
+			errs["options"] = append(errs["options"], fmt.Sprintf("Option %d: %s", i+1, message))
+		}
+		if len(optionErrors) > 0 {
+			continue
+		}
+
+		key := req.Options[i].Text.Key()
+		if duplicate, ok := seen[key]; ok {
+			if duplicate == 0 {
+				errs["options"] = append(errs["options"],
+					fmt.Sprintf("Option %d: Option text duplicates the Other option", i+1))
+			} else {
+				errs["options"] = append(errs["options"],
+					fmt.Sprintf("Option %d: Option text duplicates option %d", i+1, duplicate))
+			}
+			continue
+		}
+		seen[key] = i + 1
 	}
 
 	if len(errs) > 0 {
@@ -43,40 +334,60 @@ func (req *createPollRequest) validate() map[string][]string {
 func (api *API) CreatePoll(w http.ResponseWriter, r *http.Request) {
 	var request createPollRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"type":  "invalid_request_body",
-			"title": "The request body is invalid.",
-		})
+	if err := api.decodeJSON(w, r, &request); err != nil {
+		writeProblem(w, r, decodeProblem(err))
 		return
 	}
 
-	if errs := request.validate(); errs != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		json.NewEncoder(w).Encode(map[string]any{
-			"type":   "validation_error",
-			"errors": errs,
-		})
+	userID := ResolveUserID(r)
+
+	limits, err := api.pollLimits(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if errs := request.validate(limits, time.Now()); errs != nil {
+		writeProblem(w, r, validationProblem(errs))
+		return
+	}
+
+	if request.OrganizationID != nil {
+		_, err := api.requireOrganizationRole(r.Context(), *request.OrganizationID, userID, repository.OrganizationRoleMember)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	invitees, err := api.resolveInvitees(r.Context(), request.Invitees)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	options, err := api.resolveOptions(r.Context(), userID, request.Options)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	poll := repository.NewPoll(repository.NewPollParams{
-		UserID:    ResolveUserID(r),
-		Question:  request.Question,
-		ExpiresAt: request.ExpiresAt,
-		Options:   request.Options,
+		UserID:         userID,
+		OrganizationID: request.OrganizationID,
+		Question:       request.Question,
+		ExpiresAt:      request.ExpiresAt,
+		Options:        options,
+		Visibility:     request.Visibility,
+		Attribution:    request.Attribution,
+		Passcode:       request.Passcode,
+		Invitees:       invitees,
+		AllowOther:     request.AllowOther,
+		TimeZone:       request.TimeZone,
 	})
 
 	if err := api.repository.CreatePollWithOptions(r.Context(), poll); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"type":  "internal_server_error",
-			"title": "An internal server error occurred.",
-		})
+		writeError(w, r, err)
 		return
 	}
 
@@ -85,15 +396,112 @@ func (api *API) CreatePoll(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(poll)
 }
 
+// GetPollLimits returns the limits polls of the user are created within.
+func (api *API) GetPollLimits(w http.ResponseWriter, r *http.Request) {
+	limits, err := api.pollLimits(r.Context(), ResolveUserID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(limits)
+}
+
+// resolveOptions checks that the images of the options were uploaded by the
+// user. Other images are a validation error.
+func (api *API) resolveOptions(ctx context.Context, userID uuid.UUID, options []createPollOption) ([]repository.NewPollOption, error) {
+	var (
+		resolved = make([]repository.NewPollOption, 0, len(options))
+		errs     []string
+	)
+
+	for i, option := range options {
+		if option.ImageID != nil {
+			image, err := api.repository.GetImage(ctx, *option.ImageID)
+			if err != nil && !errors.Is(err, repository.ErrImageNotFound) {
+				return nil, err
+			}
+			if err != nil || image.UserID != userID {
+				errs = append(errs, fmt.Sprintf("Option %d: Image not found", i+1))
+			}
+		}
+
+		resolved = append(resolved, repository.NewPollOption{
+			Text:        option.Text,
+			Description: option.Description,
+			ImageID:     option.ImageID,
+			URL:         option.URL,
+		})
+	}
+
+	if len(errs) > 0 {
+		return nil, validationProblem(map[string][]string{"options": errs})
+	}
+
+	return resolved, nil
+}
+
+// resolveInvitees looks up the users invited to a private poll. Unknown
+// usernames are a validation error.
+func (api *API) resolveInvitees(ctx context.Context, usernames []primitives.Username) ([]uuid.UUID, error) {
+	users, err := api.resolveUsers(ctx, "invitees", usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	invitees := make([]uuid.UUID, 0, len(users))
+	for _, user := range users {
+		invitees = append(invitees, user.ID)
+	}
+
+	return invitees, nil
+}
+
+// resolveUsers looks up the users with the given usernames, once each and in
+// order. Unknown usernames are a validation error of field.
+func (api *API) resolveUsers(ctx context.Context, field string, usernames []primitives.Username) ([]repository.User, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	users, err := api.repository.GetUsersByUsernames(ctx, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[primitives.Username]repository.User, len(users))
+	for _, user := range users {
+		found[user.Username] = user
+	}
+
+	var (
+		resolved = make([]repository.User, 0, len(users))
+		unknown  []string
+	)
+	for _, username := range usernames {
+		user, ok := found[username]
+		if !ok {
+			unknown = append(unknown, fmt.Sprintf("User %s does not exist", username))
+			continue
+		}
+		if !slices.ContainsFunc(resolved, func(u repository.User) bool { return u.ID == user.ID }) {
+			resolved = append(resolved, user)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return nil, validationProblem(map[string][]string{field: unknown})
+	}
+
+	return resolved, nil
+}
+
 func (api *API) DeletePoll(w http.ResponseWriter, r *http.Request) {
 	pollID, err := uuid.Parse(chi.URLParam(r, "pollID"))
 	if err != nil || uuid.Nil == pollID {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"type":  "invalid_request",
-			"title": "Poll ID is not a valid",
-		})
+		writeProblem(w, r, problemInvalidPollID.new(""))
 		return
 	}
 
@@ -101,69 +509,195 @@ func (api *API) DeletePoll(w http.ResponseWriter, r *http.Request) {
 		PollID: pollID,
 		UserID: ResolveUserID(r),
 	}); err != nil {
-		switch err {
-		case repository.ErrPollNotFound:
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{
-				"type":  "not_found",
-				"title": "Poll not found",
-			})
-		case repository.ErrNotPollOwner:
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusForbidden)
-			json.NewEncoder(w).Encode(map[string]string{
-				"type":  "forbidden",
-				"title": "You are not the owner of this poll",
-			})
-		default:
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"type":  "internal_server_error",
-				"title": "An internal server error occurred.",
-			})
-		}
+		writeError(w, r, err)
 		return
 	}
 
+	api.invalidatePoll(r.Context(), pollID)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Poll deleted successfully",
-	})
+	json.NewEncoder(w).Encode(messageResponse{Message: "Poll deleted successfully"})
 }
 
 func (api *API) GetPollByID(w http.ResponseWriter, r *http.Request) {
 	pollID, err := uuid.Parse(chi.URLParam(r, "pollID"))
 	if err != nil || uuid.Nil == pollID {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"type":  "invalid_request",
-			"title": "Poll ID is not a valid",
-		})
+		writeProblem(w, r, problemInvalidPollID.new(""))
 		return
 	}
 
-	poll, err := api.repository.GetPollWithOptions(r.Context(), pollID)
+	poll, err := api.getPollWithOptions(r.Context(), pollID)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"type":  "internal_server_error",
-			"title": "An internal server error occurred.",
-		})
+		writeError(w, r, err)
+		return
+	}
+
+	if err := api.checkPollAccess(r, poll); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
+	// The definition may come from the cache, the counts never do.
+	tally, err := api.repository.GetPollTally(r.Context(), pollID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	poll = poll.WithTally(tally)
+
+	body, err := json.Marshal(newPollDetails(poll))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	lastModified := poll.UpdatedAt
+	if tally.LastVoteAt != nil && tally.LastVoteAt.After(lastModified) {
+		lastModified = *tally.LastVoteAt
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(poll)
+	w.Header().Set("ETag", pollETag(poll, tally))
+	w.Header().Set("Cache-Control", pollCacheControl(poll, time.Now()))
+
+	// ServeContent answers If-None-Match and If-Modified-Since with 304.
+	http.ServeContent(w, r, "", lastModified, bytes.NewReader(body))
+}
+
+// pollDetails is a poll as it is shown to voters.
+type pollDetails struct {
+	*repository.Poll
+	Display pollDisplay `json:"display"`
+}
+
+// pollDisplay is how the times of a poll read in its time zone.
+type pollDisplay struct {
+	CreatedAt string `json:"createdAt"`
+	ExpiresAt string `json:"expiresAt"`
+	// TimeZoneAbbreviation, e.g. CET, and Offset from UTC, e.g. +01:00,
+	// are those in effect when the poll expires.
+	TimeZoneAbbreviation string `json:"timeZoneAbbreviation"`
+	Offset               string `json:"offset"`
+}
+
+func newPollDetails(poll *repository.Poll) pollDetails {
+	// The time zone was validated when the poll was created; should the
+	// time zone database lack it now, the times are shown in UTC.
+	location, err := time.LoadLocation(poll.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	expiresAt := poll.ExpiresAt.In(location)
+	abbreviation, _ := expiresAt.Zone()
+
+	return pollDetails{
+		Poll: poll,
+		Display: pollDisplay{
+			CreatedAt:            poll.CreatedAt.In(location).Format(time.RFC3339),
+			ExpiresAt:            expiresAt.Format(time.RFC3339),
+			TimeZoneAbbreviation: abbreviation,
+			Offset:               expiresAt.Format("-07:00"),
+		},
+	}
+}
+
+// expiredPollMaxAge is how long caches may serve the results of an expired
+// poll before revalidating them. Their votes are final, but the poll can still
+// be deleted and its Other answers moderated.
+const expiredPollMaxAge = time.Minute
+
+// pollETag identifies a representation of a poll by its definition version,
+// its vote counts and its latest vote.
+func pollETag(poll *repository.Poll, tally *repository.PollTally) string {
+	hash := fnv.New64a()
+
+	fmt.Fprintf(hash, "%s/%d", poll.ID, poll.Version)
+	for _, option := range poll.Options {
+		fmt.Fprintf(hash, "/%s=%d", option.ID, option.Count)
+	}
+	if tally.LastVoteAt != nil {
+		fmt.Fprintf(hash, "/%d", tally.LastVoteAt.UnixMicro())
+	}
+
+	return fmt.Sprintf(`"%d-%x"`, poll.Version, hash.Sum64())
+}
+
+// pollCacheControl lets caches keep the results of an expired poll for a short
+// while and then revalidate them with their ETag, and makes them revalidate
+// those of an active poll on every use.
+// Polls that are not public or unlisted depend on the user, so shared caches
+// must not store them at all.
+func pollCacheControl(poll *repository.Poll, now time.Time) string {
+	switch {
+	case poll.Visibility != repository.PollVisibilityPublic && poll.Visibility != repository.PollVisibilityUnlisted:
+		return "private, no-cache"
+	case poll.ExpiresAt.After(now):
+		return "no-cache"
+	}
+	return fmt.Sprintf("public, max-age=%d, must-revalidate", int(expiredPollMaxAge.Seconds()))
+}
+
+const (
+	// passcodeHeader carries the passcode of a passcode poll.
+	passcodeHeader = "X-Poll-Passcode"
+	// ballotHeader carries the ballot token of a ballot poll.
+	ballotHeader = "X-Ballot-Token"
+)
+
+// checkPollAccess returns an error unless the user of r may see and vote on
+// poll. The owner and the members of the organization owning it always may
+// see it. Private polls are not found by users who are not invited, so their
+// existence does not leak. Ballot polls are seen with a ballot, used or not;
+// whether it still allows a vote is up to RecordVote.
+func (api *API) checkPollAccess(r *http.Request, poll *repository.Poll) error {
+	userID, signedIn := OptionalUserID(r)
+	if signedIn && userID == poll.UserID {
+		return nil
+	}
+
+	if poll.Visibility == repository.PollVisibilityPublic || poll.Visibility == repository.PollVisibilityUnlisted {
+		return nil
+	}
+
+	access, err := api.repository.GetPollAccess(r.Context(), poll.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	if access.OrganizationRole.Valid() {
+		return nil
+	}
+
+	switch poll.Visibility {
+	case repository.PollVisibilityPrivate:
+		if !access.Invited {
+			return problemPollNotFound.new("")
+		}
+		return nil
+	case repository.PollVisibilityBallot:
+		token := r.Header.Get(ballotHeader)
+		if token == "" {
+			return repository.ErrBallotRequired
+		}
+		_, err := api.repository.GetBallot(r.Context(), poll.ID, token)
+		return err
+	}
+
+	passcode := primitives.Passcode(r.Header.Get(passcodeHeader))
+	if passcode == "" {
+		return problemPasscodeRequired.new(fmt.Sprintf("Send the passcode in the %s header.", passcodeHeader))
+	}
+	return api.passcodes.check(r.Context(), clientAddress(r), poll.ID, access, passcode)
 }
 
 type voteOnPollRequest struct {
 	OptionID uuid.UUID `json:"optionID"`
+	// OtherText is the answer of a vote for the Other option, and only
+	// allowed with it.
+	OtherText primitives.AnswerText `json:"otherText,omitempty"`
 }
 
 func (req *voteOnPollRequest) validate() map[string][]string {
@@ -183,87 +717,261 @@ func (req *voteOnPollRequest) validate() map[string][]string {
 func (api *API) VoteOnPoll(w http.ResponseWriter, r *http.Request) {
 	pollID, err := uuid.Parse(chi.URLParam(r, "pollID"))
 	if err != nil || uuid.Nil == pollID {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"type":  "invalid_request",
-			"title": "Poll ID is not a valid",
-		})
+		api.metrics.VoteRejected(metrics.VoteRejectedInvalidRequest)
+		writeProblem(w, r, problemInvalidPollID.new(""))
 		return
 	}
 
 	var request voteOnPollRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"type":  "invalid_request",
-			"title": "Request body is not valid",
-		})
+	if err := api.decodeJSON(w, r, &request); err != nil {
+		api.metrics.VoteRejected(metrics.VoteRejectedInvalidRequest)
+		writeProblem(w, r, decodeProblem(err))
 		return
 	}
 
 	if errs := request.validate(); errs != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		json.NewEncoder(w).Encode(map[string]any{
-			"type":   "validation_error",
-			"errors": errs,
-		})
+		api.metrics.VoteRejected(metrics.VoteRejectedInvalidRequest)
+		writeProblem(w, r, validationProblem(errs))
+		return
+	}
+
+	poll, err := api.getPollWithOptions(r.Context(), pollID)
+	if err == nil {
+		err = api.checkPollAccess(r, poll)
+	}
+	if err != nil {
+		api.metrics.VoteRejected(voteRejectionReason(err))
+		writeError(w, r, err)
+		return
+	}
+
+	if errs := validateOtherText(poll, &request); errs != nil {
+		api.metrics.VoteRejected(metrics.VoteRejectedInvalidRequest)
+		writeProblem(w, r, validationProblem(errs))
 		return
 	}
 
 	vote := repository.NewVote(pollID, request.OptionID)
-	if err := api.repository.RecordVote(r.Context(), vote); err != nil {
-		switch {
-		case errors.Is(err, repository.ErrPollNotFound):
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{
-				"type":  "not_found",
-				"title": "Poll not found",
-			})
-		case errors.Is(err, repository.ErrOptionBelongsToPoll):
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{
-				"type":  "invalid_request",
-				"title": "Option does not belong to the poll",
-			})
-
-		default:
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"type":  "internal_server_error",
-				"title": "An internal server error occurred.",
-			})
-		}
+	vote.OtherText = request.OtherText
+	vote.BallotToken = r.Header.Get(ballotHeader)
+	if userID, signedIn := OptionalUserID(r); signedIn && poll.Attribution != repository.PollAttributionAnonymous {
+		vote.VoterID = &userID
+	}
+	if err := api.votes.RecordVote(r.Context(), vote); err != nil {
+		api.metrics.VoteRejected(voteRejectionReason(err))
+		writeError(w, r, err)
 		return
 	}
 
+	api.metrics.VoteRecorded()
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Vote recorded successfully",
+	json.NewEncoder(w).Encode(messageResponse{Message: "Vote recorded successfully"})
+}
+
+// validateOtherText requires an answer with votes for the Other option of the
+// poll and rejects it with any other. Unknown options are left to RecordVote.
+func validateOtherText(poll *repository.Poll, req *voteOnPollRequest) map[string][]string {
+	other := slices.ContainsFunc(poll.Options, func(option repository.PollOption) bool {
+		return option.Other && option.ID == req.OptionID
 	})
+
+	switch {
+	case other:
+		if answerErrors := req.OtherText.Validate(); answerErrors != nil {
+			return map[string][]string{"otherText": answerErrors}
+		}
+	case req.OtherText != "":
+		return map[string][]string{"otherText": {"An answer is only allowed for the Other option"}}
+	}
+
+	return nil
+}
+
+// voteRejectionReason classifies the error a vote was rejected with, by the
+// access check or by VoteRecorder.RecordVote, for the votes rejected metric.
+func voteRejectionReason(err error) string {
+	var problem *Problem
+	if errors.As(err, &problem) && problem.Status == http.StatusForbidden {
+		return metrics.VoteRejectedForbidden
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrPollNotFound),
+		errors.As(err, &problem) && problem.Type == problemPollNotFound.uri():
+		return metrics.VoteRejectedPollNotFound
+	case errors.Is(err, repository.ErrPollExpired):
+		return metrics.VoteRejectedPollExpired
+	case errors.Is(err, repository.ErrOptionBelongsToPoll):
+		return metrics.VoteRejectedOptionNotFound
+	case errors.Is(err, repository.ErrBallotRequired), errors.Is(err, repository.ErrBallotNotFound):
+		return metrics.VoteRejectedForbidden
+	case errors.Is(err, repository.ErrBallotUsed):
+		return metrics.VoteRejectedBallotUsed
+	case errors.Is(err, repository.ErrVoterRequired):
+		return metrics.VoteRejectedSignInRequired
+	case errors.Is(err, repository.ErrAlreadyVoted):
+		return metrics.VoteRejectedAlreadyVoted
+	default:
+		return metrics.VoteRejectedInternalError
+	}
+}
+
+const (
+	defaultPollsPageSize = 20
+	maxPollsPageSize     = 100
+)
+
+type getUserPollsRequest struct {
+	params repository.GetUserPollsParams
+	errs   map[string][]string
+}
+
+func parseGetUserPollsRequest(r *http.Request) *getUserPollsRequest {
+	var (
+		query = r.URL.Query()
+		req   = &getUserPollsRequest{
+			params: repository.GetUserPollsParams{
+				UserID: ResolveUserID(r),
+				Search: strings.TrimSpace(query.Get("q")),
+				Sort:   repository.PollSortCreatedAtDesc,
+			},
+			errs: make(map[string][]string),
+		}
+	)
+
+	if value := query.Get("status"); value != "" {
+		req.params.Status = repository.PollStatus(value)
+		if req.params.Status != repository.PollStatusActive && req.params.Status != repository.PollStatusExpired {
+			req.errs["status"] = append(req.errs["status"], "Status must be either active or expired")
+		}
+	}
+
+	if value := query.Get("sort"); value != "" {
+		req.params.Sort = repository.PollSort(value)
+		if !req.params.Sort.Valid() {
+			req.errs["sort"] = append(req.errs["sort"],
+				"Sort must be one of createdAt, -createdAt, expiresAt or -expiresAt")
+		}
+	}
+
+	for name, target := range map[string]**time.Time{
+		"createdAfter":  &req.params.CreatedAfter,
+		"createdBefore": &req.params.CreatedBefore,
+	} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			req.errs[name] = append(req.errs[name], "Must be an RFC 3339 timestamp")
+			continue
+		}
+		*target = &t
+	}
+
+	if value := query.Get("organizationID"); value != "" {
+		organizationID, err := uuid.Parse(value)
+		if err != nil || uuid.Nil == organizationID {
+			req.errs["organizationID"] = append(req.errs["organizationID"], "Organization ID is not valid")
+		}
+		req.params.OrganizationID = &organizationID
+	}
+
+	req.params.Limit, req.params.Cursor = parsePageParams(r, req.params.Sort, req.errs)
+
+	return req
+}
+
+func (req *getUserPollsRequest) validate() map[string][]string {
+	if len(req.errs) > 0 {
+		return req.errs
+	}
+
+	return nil
 }
 
 func (api *API) GetUserPolls(w http.ResponseWriter, r *http.Request) {
-	userID := ResolveUserID(r)
+	request := parseGetUserPollsRequest(r)
+
+	if errs := request.validate(); errs != nil {
+		writeProblem(w, r, validationProblem(errs))
+		return
+	}
 
-	polls, err := api.repository.GetUserPollsWithStats(r.Context(), userID)
+	if organizationID := request.params.OrganizationID; organizationID != nil {
+		_, err := api.requireOrganizationRole(r.Context(), *organizationID, request.params.UserID, repository.OrganizationRoleViewer)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	page, err := api.repository.GetUserPollsWithStats(r.Context(), request.params)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"type":  "internal_server_error",
-			"title": "An internal server error occurred.",
-		})
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+}
+
+// parsePageParams reads the limit and cursor query parameters of a listing
+// sorted by sort.
+func parsePageParams(r *http.Request, sort repository.PollSort, errs map[string][]string) (int, *repository.PollCursor) {
+	var (
+		query  = r.URL.Query()
+		limit  = defaultPollsPageSize
+		cursor *repository.PollCursor
+	)
+
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPollsPageSize {
+			errs["limit"] = append(errs["limit"],
+				fmt.Sprintf("Limit must be a number between 1 and %d", maxPollsPageSize))
+		}
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		var err error
+		cursor, err = repository.DecodePollCursor(value)
+		if err != nil || cursor.Sort != sort {
+			errs["cursor"] = append(errs["cursor"], "Cursor is not valid for this query")
+		}
+	}
+
+	return limit, cursor
+}
+
+// ListPublicPolls lists the active public polls, newest first.
+func (api *API) ListPublicPolls(w http.ResponseWriter, r *http.Request) {
+	var (
+		errs          = make(map[string][]string)
+		limit, cursor = parsePageParams(r, repository.PollSortCreatedAtDesc, errs)
+	)
+
+	if len(errs) > 0 {
+		writeProblem(w, r, validationProblem(errs))
+		return
+	}
+
+	page, err := api.repository.ListPublicPolls(r.Context(), repository.ListPublicPollsParams{
+		Cursor: cursor,
+		Limit:  limit,
+	})
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(polls)
+	json.NewEncoder(w).Encode(page)
 }
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/toramanomer/polly/blob"
+	"github.com/toramanomer/polly/repository"
+	"github.com/toramanomer/polly/requestid"
+)
+
+// Problem is an RFC 7807 problem details object. Every failed request is
+// answered with one, served as application/problem+json.
+type Problem struct {
+	// Type identifies the kind of problem. Clients branch on it rather than
+	// on Title, which is meant for humans and may change.
+	Type   string
+	Title  string
+	Status int
+	// Detail explains this occurrence of the problem.
+	Detail string
+	// Instance is the path of the request that failed.
+	Instance string
+	// Errors lists the validation errors by request field.
+	Errors map[string][]string
+	// Extensions are additional members specific to the problem type.
+	Extensions map[string]any
+}
+
+const problemContentType = "application/problem+json"
+
+// problemTypeBase prefixes every problem type. Type URIs are relative
+// references, resolved against the API origin, and are part of the API: they
+// must not change once published.
+const problemTypeBase = "/problems/"
+
+type problemType struct {
+	slug   string
+	title  string
+	status int
+}
+
+var (
+	problemInvalidBody             = problemType{"invalid-request-body", "The request body is invalid.", http.StatusBadRequest}
+	problemBodyTooLarge            = problemType{"request-body-too-large", "The request body is too large.", http.StatusRequestEntityTooLarge}
+	problemInvalidPollID           = problemType{"invalid-poll-id", "Poll ID is not valid.", http.StatusBadRequest}
+	problemValidation              = problemType{"validation-error", "The request is not valid.", http.StatusUnprocessableEntity}
+	problemUnauthorized            = problemType{"unauthorized", "Authentication is required.", http.StatusUnauthorized}
+	problemInvalidCredentials      = problemType{"invalid-credentials", "Invalid email or password.", http.StatusUnauthorized}
+	problemEmailTaken              = problemType{"email-already-exists", "Email already exists.", http.StatusUnprocessableEntity}
+	problemUsernameTaken           = problemType{"username-already-exists", "Username already exists.", http.StatusUnprocessableEntity}
+	problemPollNotFound            = problemType{"poll-not-found", "Poll not found.", http.StatusNotFound}
+	problemNotPollOwner            = problemType{"not-poll-owner", "You are not the owner of this poll.", http.StatusForbidden}
+	problemOptionNotInPoll         = problemType{"option-not-in-poll", "Option does not belong to the poll.", http.StatusNotFound}
+	problemPollClosed              = problemType{"poll-closed", "The poll no longer accepts votes.", http.StatusConflict}
+	problemPasscodeRequired        = problemType{"passcode-required", "The poll is protected by a passcode.", http.StatusForbidden}
+	problemInvalidPasscode         = problemType{"invalid-passcode", "The passcode is not valid.", http.StatusForbidden}
+	problemTooManyPasscodeAttempts = problemType{"too-many-passcode-attempts", "Too many wrong passcodes were tried.", http.StatusTooManyRequests}
+	problemBallotRequired          = problemType{"ballot-required", "The poll requires a ballot.", http.StatusForbidden}
+	problemInvalidBallot           = problemType{"invalid-ballot", "The ballot is not valid.", http.StatusForbidden}
+	problemBallotUsed              = problemType{"ballot-used", "The ballot has already been used.", http.StatusConflict}
+	problemAlreadyVoted            = problemType{"already-voted", "You have already voted on this poll.", http.StatusConflict}
+	problemNotBallotPoll           = problemType{"not-ballot-poll", "The poll does not use ballots.", http.StatusConflict}
+	problemInvalidImageID          = problemType{"invalid-image-id", "Image ID is not valid.", http.StatusBadRequest}
+	problemImageNotFound           = problemType{"image-not-found", "Image not found.", http.StatusNotFound}
+	problemUnsupportedImage        = problemType{"unsupported-image", "The image format is not supported.", http.StatusUnsupportedMediaType}
+	problemImageQuotaExceeded      = problemType{"image-quota-exceeded", "Your images take up all the space you have.", http.StatusForbidden}
+	problemNoOtherOption           = problemType{"no-other-option", "The poll has no Other option.", http.StatusConflict}
+	problemInvalidOrganizationID   = problemType{"invalid-organization-id", "Organization ID is not valid.", http.StatusBadRequest}
+	problemOrganizationNotFound    = problemType{"organization-not-found", "Organization not found.", http.StatusNotFound}
+	problemOrganizationRole        = problemType{"insufficient-organization-role", "Your role in the organization does not allow this.", http.StatusForbidden}
+	problemLastOrganizationOwner   = problemType{"last-organization-owner", "An organization must keep at least one owner.", http.StatusConflict}
+	problemInvalidWebhookID        = problemType{"invalid-webhook-id", "Webhook ID is not valid.", http.StatusBadRequest}
+	problemWebhookNotFound         = problemType{"webhook-not-found", "Webhook not found.", http.StatusNotFound}
+	problemUserNotFound            = problemType{"user-not-found", "User not found.", http.StatusNotFound}
+	problemTurnstileMissing        = problemType{"turnstile-token-missing", "Turnstile token is required.", http.StatusBadRequest}
+	problemTurnstileFailed         = problemType{"turnstile-verification-failed", "Turnstile verification failed.", http.StatusBadRequest}
+	problemTurnstileUnavailable    = problemType{"turnstile-unavailable", "Turnstile token could not be verified.", http.StatusBadGateway}
+	problemRouteNotFound           = problemType{"route-not-found", "No route matches the request path.", http.StatusNotFound}
+	problemMethodNotAllowed        = problemType{"method-not-allowed", "The method is not allowed for this path.", http.StatusMethodNotAllowed}
+	problemInternal                = problemType{"internal-server-error", "An internal server error occurred.", http.StatusInternalServerError}
+)
+
+func (t problemType) uri() string {
+	return problemTypeBase + t.slug
+}
+
+// new returns a problem of this type. detail may be empty.
+func (t problemType) new(detail string) *Problem {
+	return &Problem{
+		Type:   t.uri(),
+		Title:  t.title,
+		Status: t.status,
+		Detail: detail,
+	}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Title + " " + p.Detail
+	}
+	return p.Title
+}
+
+// withErrors sets the field-level validation errors.
+func (p *Problem) withErrors(errs map[string][]string) *Problem {
+	p.Errors = errs
+	return p
+}
+
+// with sets an extension member.
+func (p *Problem) with(name string, value any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any)
+	}
+	p.Extensions[name] = value
+	return p
+}
+
+// MarshalJSON flattens the extensions next to the standard members. An
+// extension never replaces a standard member.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+6)
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	if len(p.Errors) > 0 {
+		members["errors"] = p.Errors
+	}
+
+	return json.Marshal(members)
+}
+
+// validationProblem reports field-level validation errors.
+func validationProblem(errs map[string][]string) *Problem {
+	return problemValidation.new("").withErrors(errs)
+}
+
+// decodeProblem is the problem for an error of decodeJSON.
+func decodeProblem(err error) *Problem {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return problemBodyTooLarge.new("")
+	}
+	return problemInvalidBody.new("")
+}
+
+// problemFor maps an error to the problem it is answered with. Problems are
+// returned as is and repository sentinel errors get their own problem type;
+// anything else is an internal error.
+func problemFor(err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrPollNotFound):
+		return problemPollNotFound.new("")
+	case errors.Is(err, repository.ErrNotPollOwner):
+		return problemNotPollOwner.new("")
+	case errors.Is(err, repository.ErrOptionBelongsToPoll):
+		return problemOptionNotInPoll.new("")
+	case errors.Is(err, repository.ErrPollExpired):
+		return problemPollClosed.new("")
+	case errors.Is(err, repository.ErrBallotRequired):
+		return problemBallotRequired.new("Send the ballot token in the " + ballotHeader + " header.")
+	case errors.Is(err, repository.ErrBallotNotFound):
+		return problemInvalidBallot.new("")
+	case errors.Is(err, repository.ErrBallotUsed):
+		return problemBallotUsed.new("")
+	case errors.Is(err, repository.ErrVoterRequired):
+		return problemUnauthorized.new("Sign in to vote on this poll.")
+	case errors.Is(err, repository.ErrAlreadyVoted):
+		return problemAlreadyVoted.new("")
+	case errors.Is(err, repository.ErrImageNotFound), errors.Is(err, blob.ErrNotFound):
+		return problemImageNotFound.new("")
+	case errors.Is(err, blob.ErrTooLarge):
+		return problemBodyTooLarge.new("")
+	case errors.Is(err, repository.ErrImageQuotaExceeded):
+		return problemImageQuotaExceeded.new("Images no poll option uses are deleted after a while.")
+	case errors.Is(err, blob.ErrContentType):
+		return problemUnsupportedImage.new("Images must be PNG, JPEG, GIF or WebP.")
+	case errors.Is(err, repository.ErrOrganizationNotFound), errors.Is(err, repository.ErrNotOrganizationMember):
+		return problemOrganizationNotFound.new("")
+	case errors.Is(err, repository.ErrLastOrganizationOwner):
+		return problemLastOrganizationOwner.new("")
+	case errors.Is(err, repository.ErrWebhookNotFound):
+		return problemWebhookNotFound.new("")
+	case errors.Is(err, repository.ErrInvalidCursor):
+		return validationProblem(map[string][]string{"cursor": {"Cursor is not valid for this query"}})
+	case errors.Is(err, repository.ErrEmailAlreadyExists):
+		return problemEmailTaken.new("").withErrors(map[string][]string{"email": {"Email already exists"}})
+	case errors.Is(err, repository.ErrUsernameAlreadyExists):
+		return problemUsernameTaken.new("").withErrors(map[string][]string{"username": {"Username already exists"}})
+	default:
+		return problemInternal.new("")
+	}
+}
+
+// writeError answers the request with the problem for err, logging err if it
+// is a server error.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := problemFor(err)
+	if problem.Status >= http.StatusInternalServerError {
+		logServerError(r, err)
+	}
+	writeProblem(w, r, problem)
+}
+
+// writeProblem answers the request with problem. The request ID is included
+// so a user can quote it when reporting the failure.
+func writeProblem(w http.ResponseWriter, r *http.Request, problem *Problem) {
+	if problem.Instance == "" {
+		problem.Instance = r.URL.Path
+	}
+	if id := requestid.FromContext(r.Context()); id != "" {
+		problem.with("requestId", id)
+	}
+
+	if seconds, ok := problem.Extensions["retryAfter"].(int); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// NotFound answers requests that match no route.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, problemRouteNotFound.new(""))
+}
+
+// MethodNotAllowed answers requests whose path matches a route registered
+// only for other methods.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, problemMethodNotAllowed.new(""))
+}
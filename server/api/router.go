@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/toramanomer/polly/requestid"
+	"github.com/toramanomer/polly/tracing"
+)
+
+// NewMetricsRouter serves the metrics of the API at /metrics. It is meant
+// for a listener of its own, reached by the Prometheus server only.
+func NewMetricsRouter(api *API) *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Method(http.MethodGet, "/metrics", api.metrics.Handler())
+
+	return r
+}
+
+// NewRouter routes every endpoint of the server to the API: the /api routes
+// described by OpenAPI, the health checks and the Slack app. The metrics are
+// served apart, see NewMetricsRouter.
+// readiness are the checks run by /readyz.
+func NewRouter(api *API, readiness ...ReadinessCheck) *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Use(requestid.Middleware)
+	r.Use(tracing.Middleware)
+	r.Use(RequestLogger)
+	r.Use(api.metrics.Middleware)
+
+	r.NotFound(NotFound)
+	r.MethodNotAllowed(MethodNotAllowed)
+
+	r.Get("/healthz", Healthz)
+	r.Get("/readyz", Readyz(readiness...))
+
+	// Slack speaks its own protocol, so its endpoints are not part of the API.
+	r.Route("/slack", func(r chi.Router) {
+		r.Post("/commands", api.SlackCommand)
+		r.Post("/interactions", api.SlackInteraction)
+	})
+
+	r.Route("/api", func(r chi.Router) {
+		r.Method(http.MethodGet, "/openapi.json", OpenAPIHandler())
+
+		r.Route("/auth", func(r chi.Router) {
+			r.Post("/signup", api.Signup)
+			r.Post("/signin", api.Signin)
+			r.Post("/signout", api.Signout)
+			r.Get("/me", api.Me)
+		})
+		r.Route("/polls", func(r chi.Router) {
+			withAuth := r.With(api.AuthMiddleware)
+			withAuth.Post("/", api.CreatePoll)
+			withAuth.Get("/", api.GetUserPolls)
+			withAuth.Get("/limits", api.GetPollLimits)
+			withAuth.Delete("/{pollID}", api.DeletePoll)
+			withAuth.Get("/{pollID}/analytics", api.GetPollAnalytics)
+			withAuth.Post("/{pollID}/ballots", api.CreateBallots)
+			withAuth.Get("/{pollID}/turnout", api.GetPollTurnout)
+			withAuth.Get("/{pollID}/voters", api.GetPollVoters)
+			withAuth.Get("/{pollID}/other-answers", api.GetOtherAnswers)
+			withAuth.Post("/{pollID}/other-answers/moderate", api.ModerateOtherAnswers)
+
+			r.Get("/public", api.ListPublicPolls)
+
+			withOptionalAuth := r.With(api.OptionalAuthMiddleware)
+			withOptionalAuth.Get("/{pollID}", api.GetPollByID)
+			withOptionalAuth.With(api.WithTurnstileProtection).Post("/{pollID}/vote", api.VoteOnPoll)
+		})
+		r.Route("/images", func(r chi.Router) {
+			r.With(api.AuthMiddleware).Post("/", api.UploadImage)
+			r.Get("/{imageID}", api.GetImage)
+		})
+		r.Route("/organizations", func(r chi.Router) {
+			r.Use(api.AuthMiddleware)
+			r.Post("/", api.CreateOrganization)
+			r.Get("/", api.GetUserOrganizations)
+			r.Get("/{organizationID}/members", api.GetOrganizationMembers)
+			r.Put("/{organizationID}/members/{username}", api.SetOrganizationMember)
+			r.Delete("/{organizationID}/members/{username}", api.RemoveOrganizationMember)
+		})
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(api.AuthMiddleware)
+			r.Post("/", api.CreateWebhook)
+			r.Get("/", api.GetUserWebhooks)
+			r.Delete("/{webhookID}", api.DeleteWebhook)
+			r.Get("/{webhookID}/deliveries", api.GetWebhookDeliveries)
+			r.Post("/{webhookID}/test", api.TestWebhook)
+		})
+	})
+
+	return r
+}
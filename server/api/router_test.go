@@ -0,0 +1,208 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/api"
+	"github.com/toramanomer/polly/blob"
+	"github.com/toramanomer/polly/cache"
+	"github.com/toramanomer/polly/client"
+	"github.com/toramanomer/polly/config"
+	"github.com/toramanomer/polly/mail"
+	"github.com/toramanomer/polly/metrics"
+	"github.com/toramanomer/polly/repository"
+	"github.com/toramanomer/polly/repository/memory"
+	"github.com/toramanomer/polly/webhook"
+)
+
+const (
+	testJWTKey         = "test-key"
+	slackSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+)
+
+// newTestAPI returns an API backed by a memory store.
+func newTestAPI(t *testing.T) (*api.API, *memory.Store) {
+	t.Helper()
+
+	cfg := &config.Config{
+		JWTSymmetricKey:    testJWTKey,
+		TurnstileSecretKey: "test-secret",
+		MaxBodyBytes:       1 << 20,
+		MinPollOptions:     2,
+		MaxPollOptions:     6,
+		MaxPollDuration:    90 * 24 * time.Hour,
+		PublicURL:          "https://polly.example.com",
+		SlackSigningSecret: slackSigningSecret,
+	}
+
+	blobs, err := blob.NewFS(t.TempDir(), blob.ImageLimits(1<<20))
+	if err != nil {
+		t.Fatalf("NewFS: %v", err)
+	}
+
+	store := memory.New()
+	a := api.NewAPI(cfg, store, store, cache.NewLRU(100, time.Minute), metrics.New(nil),
+		mail.Log{}, blobs, webhook.NewSender(time.Second, true))
+
+	return a, store
+}
+
+func TestRouterMatchesOpenAPI(t *testing.T) {
+	a, _ := newTestAPI(t)
+
+	if err := api.CheckRoutes(api.NewRouter(a)); err != nil {
+		t.Fatalf("CheckRoutes: %v", err)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	a, _ := newTestAPI(t)
+	router := api.NewRouter(a,
+		api.ReadinessCheck{Name: "database", Check: func(context.Context) error { return nil }},
+		api.ReadinessCheck{Name: "migrations", Check: func(context.Context) error {
+			return errors.New("dial tcp 10.0.0.5:5432: connection refused")
+		}},
+	)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if strings.Contains(w.Body.String(), "10.0.0.5") {
+		t.Fatalf("readyz: got %s, want no error details", w.Body)
+	}
+
+	var body struct {
+		Checks map[string]string `json:"checks"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if w.Code != http.StatusServiceUnavailable || body.Checks["database"] != "pass" || body.Checks["migrations"] != "fail" {
+		t.Fatalf("readyz: got %d %v, want 503 with the database passing and the migrations failing", w.Code, body.Checks)
+	}
+}
+
+func TestMetricsRouter(t *testing.T) {
+	a, _ := newTestAPI(t)
+
+	w := httptest.NewRecorder()
+	api.NewRouter(a).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("metrics on the API router: got %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	w = httptest.NewRecorder()
+	api.NewMetricsRouter(a).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "go_goroutines") {
+		t.Fatalf("metrics on the metrics router: got %d, want the metrics", w.Code)
+	}
+}
+
+// sessionToken returns a session token of the user, signed as by Signin.
+func sessionToken(t *testing.T, userID uuid.UUID) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": userID,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(testJWTKey))
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	return token
+}
+
+// expectProblem checks that err is a problem of the given type and status.
+func expectProblem(t *testing.T, name string, err error, slug string, status int) {
+	t.Helper()
+
+	var problem *client.Problem
+	if !errors.As(err, &problem) || !strings.HasSuffix(problem.Type, slug) || problem.Status != status {
+		t.Fatalf("%s: got %v, want a %d %s problem", name, err, status, slug)
+	}
+}
+
+func TestClient(t *testing.T) {
+	a, store := newTestAPI(t)
+	server := httptest.NewServer(api.NewRouter(a))
+	defer server.Close()
+
+	ctx := context.Background()
+	c := client.New(server.URL, nil)
+
+	_, err := c.Me(ctx)
+	expectProblem(t, "Me signed out", err, "unauthorized", http.StatusUnauthorized)
+
+	// Signing up and in check the mail server of the address, so the user
+	// is created directly.
+	user := repository.NewUser(repository.NewUserParams{
+		Username: "alice",
+		Email:    "alice@example.com",
+		Password: "correct-horse",
+	})
+	if err := store.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	c.Token = sessionToken(t, user.ID)
+
+	if _, err := c.Me(ctx); err != nil {
+		t.Fatalf("Me: %v", err)
+	}
+
+	poll, err := c.CreatePoll(ctx, client.CreatePollRequest{
+		Question:  "Lunch?",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Options:   []client.CreatePollOption{{Text: "Pizza"}, {Text: "Sushi"}},
+	})
+	if err != nil {
+		t.Fatalf("CreatePoll: %v", err)
+	}
+	if poll.UserID != user.ID || poll.Question != "Lunch?" || len(poll.Options) != 2 {
+		t.Fatalf("CreatePoll: got %+v", poll)
+	}
+
+	details, err := c.GetPoll(ctx, poll.ID, nil)
+	if err != nil {
+		t.Fatalf("GetPoll: %v", err)
+	}
+	if details.ID != poll.ID || len(details.Options) != 2 {
+		t.Fatalf("GetPoll: got %+v, want %s", details, poll.ID)
+	}
+
+	page, err := c.GetUserPolls(ctx, nil)
+	if err != nil {
+		t.Fatalf("GetUserPolls: %v", err)
+	}
+	if len(page.Polls) != 1 || page.Polls[0].ID != poll.ID {
+		t.Fatalf("GetUserPolls: got %+v, want only %s", page.Polls, poll.ID)
+	}
+
+	analytics, err := c.GetPollAnalytics(ctx, poll.ID, nil)
+	if err != nil {
+		t.Fatalf("GetPollAnalytics: %v", err)
+	}
+	if analytics.TotalVotes != 0 {
+		t.Fatalf("GetPollAnalytics: got %d votes, want 0", analytics.TotalVotes)
+	}
+
+	// Votes are not verified without a Turnstile token.
+	_, err = c.VoteOnPoll(ctx, poll.ID, nil, client.VoteOnPollRequest{OptionID: poll.Options[0].ID})
+	expectProblem(t, "VoteOnPoll without token", err, "turnstile-token-missing", http.StatusBadRequest)
+
+	if _, err := c.DeletePoll(ctx, poll.ID); err != nil {
+		t.Fatalf("DeletePoll: %v", err)
+	}
+
+	_, err = c.GetPoll(ctx, poll.ID, nil)
+	expectProblem(t, "GetPoll deleted", err, "poll-not-found", http.StatusNotFound)
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/primitives"
+	"github.com/toramanomer/polly/repository"
+	"github.com/toramanomer/polly/slack"
+)
+
+// slackPollDuration is how long the polls created in Slack run, unless polls
+// may not run that long.
+const slackPollDuration = 24 * time.Hour
+
+const slackUsage = `Usage: /polly "Question" "Option 1" "Option 2"`
+
+// slackResponseTimeout bounds recording the votes of an interaction and
+// posting their outcome, which happens after Slack was answered.
+const slackResponseTimeout = 10 * time.Second
+
+// readSlackRequest verifies the signature of a request from Slack and returns
+// the form it posted.
+func (api *API) readSlackRequest(w http.ResponseWriter, r *http.Request) (url.Values, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, api.config.MaxBodyBytes)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, decodeProblem(err)
+	}
+
+	if err := slack.Verify(api.config.SlackSigningSecret, r.Header, body, time.Now()); err != nil {
+		return nil, problemUnauthorized.new("The Slack request signature is not valid.")
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, problemInvalidBody.new("")
+	}
+
+	return form, nil
+}
+
+func writeSlackMessage(w http.ResponseWriter, message slack.Message) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(message)
+}
+
+// SlackCommand answers the /polly slash command: it creates a poll of the
+// question and options given and posts it to the channel with a vote button
+// per option. The poll is owned by the user standing in for the Slack user.
+// Mistakes are only shown to the Slack user.
+func (api *API) SlackCommand(w http.ResponseWriter, r *http.Request) {
+	form, err := api.readSlackRequest(w, r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	command := slack.ParseCommand(form)
+	if command.TeamID == "" || command.UserID == "" {
+		writeProblem(w, r, problemInvalidBody.new(""))
+		return
+	}
+
+	args, err := slack.Arguments(command.Text)
+	if err != nil {
+		writeSlackMessage(w, slack.Ephemeral("A quote is not closed.\n"+slackUsage))
+		return
+	}
+	if len(args) == 0 || (len(args) == 1 && strings.EqualFold(args[0], "help")) {
+		writeSlackMessage(w, slack.Ephemeral(slackUsage))
+		return
+	}
+
+	user, err := api.repository.GetOrCreateChatUser(r.Context(), repository.ChatAccount{
+		Provider: repository.ChatProviderSlack,
+		TeamID:   command.TeamID,
+		UserID:   command.UserID,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	limits, err := api.pollLimits(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	request := createPollRequest{
+		Question:    primitives.Question(args[0]),
+		ExpiresAt:   now.Add(min(slackPollDuration, limits.maxDuration)),
+		Attribution: repository.PollAttributionVerified,
+	}
+	for _, text := range args[1:] {
+		request.Options = append(request.Options, createPollOption{Text: primitives.OptionText(text)})
+	}
+
+	if errs := request.validate(limits, now); errs != nil {
+		writeSlackMessage(w, slack.Ephemeral(describeErrors(errs)+"\n"+slackUsage))
+		return
+	}
+
+	options := make([]repository.NewPollOption, 0, len(request.Options))
+	for _, option := range request.Options {
+		options = append(options, repository.NewPollOption{Text: option.Text})
+	}
+
+	poll := repository.NewPoll(repository.NewPollParams{
+		UserID:      user.ID,
+		Question:    request.Question,
+		ExpiresAt:   request.ExpiresAt,
+		Options:     options,
+		Visibility:  request.Visibility,
+		Attribution: request.Attribution,
+		TimeZone:    request.TimeZone,
+	})
+
+	if err := api.repository.CreatePollWithOptions(r.Context(), poll); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeSlackMessage(w, slack.PollMessage(poll, api.pollLink(poll.ID)))
+}
+
+// describeErrors lists validation errors one per line, ordered by field.
+func describeErrors(errs map[string][]string) string {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	var lines []string
+	for _, field := range fields {
+		lines = append(lines, errs[field]...)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// SlackInteraction records the votes of clicked vote buttons, one per Slack
+// user and poll. Slack wants interactions answered within 3 seconds and
+// ignores the answer, so they are acknowledged first; the votes are recorded
+// afterwards and their outcome is posted to the response URL, which is
+// trusted as the request is signed.
+func (api *API) SlackInteraction(w http.ResponseWriter, r *http.Request) {
+	form, err := api.readSlackRequest(w, r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	interaction, err := slack.ParseInteraction(form)
+	if err != nil || interaction.Team.ID == "" || interaction.User.ID == "" {
+		writeProblem(w, r, problemInvalidBody.new(""))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if interaction.Type != slack.InteractionBlockActions {
+		return
+	}
+
+	// The votes outlive the request, but keep its values, e.g. its logger.
+	ctx := context.WithoutCancel(r.Context())
+
+	api.background.Add(1)
+	go func() {
+		defer api.background.Done()
+
+		ctx, cancel := context.WithTimeout(ctx, slackResponseTimeout)
+		defer cancel()
+
+		for _, action := range interaction.Actions {
+			pollID, optionID, ok := slack.ParseVote(action)
+			if !ok {
+				continue
+			}
+
+			message := api.recordSlackVote(ctx, interaction, pollID, optionID)
+			if err := slack.Respond(ctx, api.httpClient, interaction.ResponseURL, message); err != nil {
+				logger(ctx).Warn("error responding to slack interaction", "poll_id", pollID, "error", err)
+			}
+		}
+	}()
+}
+
+// recordSlackVote records the vote of the user standing in for the Slack user
+// and returns the message telling them how it went.
+func (api *API) recordSlackVote(ctx context.Context, interaction *slack.Interaction, pollID, optionID uuid.UUID) slack.Message {
+	user, err := api.repository.GetOrCreateChatUser(ctx, repository.ChatAccount{
+		Provider: repository.ChatProviderSlack,
+		TeamID:   interaction.Team.ID,
+		UserID:   interaction.User.ID,
+	})
+	if err != nil {
+		logger(ctx).Error("error resolving slack user", "error", err)
+		return slack.Ephemeral("Your vote could not be recorded. Please try again.")
+	}
+
+	poll, err := api.getPollWithOptions(ctx, pollID)
+	if err == nil && poll.Visibility != repository.PollVisibilityPublic && poll.Visibility != repository.PollVisibilityUnlisted {
+		err = repository.ErrPollNotFound
+	}
+	if err != nil {
+		api.metrics.VoteRejected(voteRejectionReason(err))
+		return slackVoteMessage(ctx, err, "")
+	}
+
+	index := slices.IndexFunc(poll.Options, func(option repository.PollOption) bool {
+		return option.ID == optionID
+	})
+	if index == -1 || poll.Options[index].Other {
+		api.metrics.VoteRejected(voteRejectionReason(repository.ErrOptionBelongsToPoll))
+		return slackVoteMessage(ctx, repository.ErrOptionBelongsToPoll, "")
+	}
+
+	vote := repository.NewVote(pollID, optionID)
+	if poll.Attribution != repository.PollAttributionAnonymous {
+		vote.VoterID = &user.ID
+	}
+	if err := api.votes.RecordVote(ctx, vote); err != nil {
+		api.metrics.VoteRejected(voteRejectionReason(err))
+		return slackVoteMessage(ctx, err, "")
+	}
+
+	api.metrics.VoteRecorded()
+
+	return slackVoteMessage(ctx, nil, string(poll.Options[index].Text))
+}
+
+// slackVoteMessage tells the Slack user the outcome of their vote for the
+// option.
+func slackVoteMessage(ctx context.Context, err error, option string) slack.Message {
+	switch {
+	case err == nil:
+		return slack.Ephemeral(fmt.Sprintf("You voted for *%s*.", slack.Escape(option)))
+	case errors.Is(err, repository.ErrAlreadyVoted):
+		return slack.Ephemeral("You have already voted on this poll.")
+	case errors.Is(err, repository.ErrPollExpired):
+		return slack.Ephemeral("The poll is closed.")
+	case errors.Is(err, repository.ErrPollNotFound):
+		return slack.Ephemeral("The poll no longer exists.")
+	case errors.Is(err, repository.ErrOptionBelongsToPoll):
+		return slack.Ephemeral("The option is not part of the poll.")
+	default:
+		logger(ctx).Error("error recording slack vote", "error", err)
+		return slack.Ephemeral("Your vote could not be recorded. Please try again.")
+	}
+}
@@ -0,0 +1,205 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/toramanomer/polly/api"
+	"github.com/toramanomer/polly/slack"
+)
+
+// slackCommandForm is a slash command as Slack posts it.
+func slackCommandForm(text string) string {
+	return url.Values{
+		"token":         {"gIkuvaNzQIHg97ATvDxqgjtO"},
+		"team_id":       {"T0001"},
+		"team_domain":   {"example"},
+		"channel_id":    {"C2147483705"},
+		"channel_name":  {"test"},
+		"user_id":       {"U2147483697"},
+		"user_name":     {"Steve"},
+		"command":       {"/polly"},
+		"text":          {text},
+		"api_app_id":    {"A123456"},
+		"response_url":  {"https://hooks.slack.com/commands/1234/5678"},
+		"trigger_id":    {"13345224609.738474920.8088930838d88f008e0"},
+		"is_enterprise": {"false"},
+	}.Encode()
+}
+
+// slackInteractionForm is a click of the button as Slack posts it.
+func slackInteractionForm(t *testing.T, userID, responseURL string, block slack.Block, button slack.Button) string {
+	t.Helper()
+
+	payload, err := json.Marshal(map[string]any{
+		"type":       slack.InteractionBlockActions,
+		"user":       map[string]any{"id": userID, "username": "bob", "team_id": "T0001"},
+		"api_app_id": "A123456",
+		"token":      "9s8d9as89d8as9d8as989",
+		"container": map[string]any{
+			"type": "message", "message_ts": "1548261231.000200", "channel_id": "C2147483705",
+		},
+		"trigger_id":   "12321423423.333649436676.d8c1bb837935619ccad0f624c448ffb3",
+		"team":         map[string]any{"id": "T0001", "domain": "example"},
+		"channel":      map[string]any{"id": "C2147483705", "name": "test"},
+		"response_url": responseURL,
+		"actions": []map[string]any{{
+			"action_id": button.ActionID,
+			"block_id":  block.BlockID,
+			"text":      button.Text,
+			"value":     button.Value,
+			"type":      "button",
+			"action_ts": "1548426417.840180",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	return url.Values{"payload": {string(payload)}}.Encode()
+}
+
+// postSlack posts a form signed with secret as of sentAt.
+func postSlack(t *testing.T, server *httptest.Server, path, form, secret string, sentAt time.Time) (int, []byte) {
+	t.Helper()
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(form))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set(slack.TimestampHeader, strconv.FormatInt(sentAt.Unix(), 10))
+	request.Header.Set(slack.SignatureHeader, slack.Sign(secret, sentAt, []byte(form)))
+
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("reading %s response: %v", path, err)
+	}
+
+	return response.StatusCode, body
+}
+
+// responseURL collects the messages posted to it.
+type responseURL struct {
+	mu       sync.Mutex
+	messages []slack.Message
+}
+
+func (u *responseURL) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var message slack.Message
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.messages = append(u.messages, message)
+}
+
+func TestSlackCommandAndVote(t *testing.T) {
+	a, store := newTestAPI(t)
+	server := httptest.NewServer(api.NewRouter(a))
+	defer server.Close()
+
+	responses := &responseURL{}
+	responseServer := httptest.NewServer(responses)
+	defer responseServer.Close()
+
+	status, body := postSlack(t, server, "/slack/commands",
+		slackCommandForm(`“Where do we eat?” “Pizza & pasta” Sushi`), slackSigningSecret, time.Now())
+	if status != http.StatusOK {
+		t.Fatalf("command: got status %d, want 200: %s", status, body)
+	}
+
+	var message slack.Message
+	if err := json.Unmarshal(body, &message); err != nil {
+		t.Fatalf("command: decoding message: %v", err)
+	}
+	if message.ResponseType != slack.ResponseInChannel || message.Text != "Where do we eat?" ||
+		len(message.Blocks) != 2 || len(message.Blocks[1].Elements) != 2 {
+		t.Fatalf("command: got %+v, want the poll with 2 buttons", message)
+	}
+
+	block, button := message.Blocks[1], message.Blocks[1].Elements[0]
+	pollID, optionID, ok := slack.ParseVote(slack.Action{ActionID: button.ActionID, Value: button.Value})
+	if !ok {
+		t.Fatalf("command: button %+v is not a vote", button)
+	}
+
+	status, body = postSlack(t, server, "/slack/interactions",
+		slackInteractionForm(t, "U1", responseServer.URL, block, button), slackSigningSecret, time.Now())
+	if status != http.StatusOK {
+		t.Fatalf("interaction: got status %d, want 200: %s", status, body)
+	}
+
+	// The vote is recorded after the interaction was acknowledged.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	responses.mu.Lock()
+	messages := responses.messages
+	responses.mu.Unlock()
+	if len(messages) != 1 || messages[0].ResponseType != slack.ResponseEphemeral ||
+		messages[0].Text != "You voted for *Pizza &amp; pasta*." {
+		t.Fatalf("interaction: got responses %+v, want the vote for Pizza & pasta", messages)
+	}
+
+	tally, err := store.GetPollTally(context.Background(), pollID)
+	if err != nil {
+		t.Fatalf("GetPollTally: %v", err)
+	}
+	total := 0
+	for _, count := range tally.Counts {
+		total += count
+	}
+	if tally.Counts[optionID] != 1 || total != 1 {
+		t.Fatalf("GetPollTally: got %v, want only a vote for %s", tally.Counts, optionID)
+	}
+}
+
+func TestSlackRejectsUnverifiedRequests(t *testing.T) {
+	a, _ := newTestAPI(t)
+	server := httptest.NewServer(api.NewRouter(a))
+	defer server.Close()
+
+	form := slackCommandForm(`"Lunch?" Pizza Sushi`)
+
+	tests := []struct {
+		name   string
+		secret string
+		sentAt time.Time
+	}{
+		{"bad signature", "another-secret", time.Now()},
+		{"stale timestamp", slackSigningSecret, time.Now().Add(-slack.Tolerance - time.Minute)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, path := range []string{"/slack/commands", "/slack/interactions"} {
+				status, body := postSlack(t, server, path, form, tc.secret, tc.sentAt)
+				if status != http.StatusUnauthorized {
+					t.Fatalf("%s: got status %d, want 401: %s", path, status, body)
+				}
+			}
+		})
+	}
+}
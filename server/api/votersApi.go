@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// GetPollVoters exports who voted on a poll to the users who manage it. For
+// attributed polls it includes what each voter picked; verified polls only
+// reveal who voted and anonymous polls nobody.
+func (api *API) GetPollVoters(w http.ResponseWriter, r *http.Request) {
+	pollID, err := uuid.Parse(chi.URLParam(r, "pollID"))
+	if err != nil || uuid.Nil == pollID {
+		writeProblem(w, r, problemInvalidPollID.new(""))
+		return
+	}
+
+	if _, err := api.requirePollManager(r.Context(), pollID, ResolveUserID(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	voters, err := api.repository.GetPollVoters(r.Context(), pollID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(voters)
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/events"
+	"github.com/toramanomer/polly/repository"
+	"github.com/toramanomer/polly/webhook"
+)
+
+const (
+	maxWebhooksPerUser  = 20
+	maxWebhookURLLength = 2048
+	// webhookDeliveriesLimit is how many of the latest deliveries of a
+	// webhook are listed.
+	webhookDeliveriesLimit = 100
+)
+
+type createWebhookRequest struct {
+	URL string `json:"url"`
+	// Events are the event types to subscribe to.
+	Events []events.Type `json:"events"`
+	// PollID limits the webhook to a single poll. Without it, the webhook
+	// gets the events of every poll of the user. Webhooks of a single poll
+	// are deleted with the poll, before its poll.deleted event.
+	PollID *uuid.UUID `json:"pollID,omitempty"`
+}
+
+func (req *createWebhookRequest) validate() map[string][]string {
+	errs := make(map[string][]string)
+
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL == "" {
+		errs["url"] = append(errs["url"], "URL is required")
+	} else if len(req.URL) > maxWebhookURLLength {
+		errs["url"] = append(errs["url"], fmt.Sprintf("URL cannot be longer than %d characters", maxWebhookURLLength))
+	} else if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs["url"] = append(errs["url"], "URL must be an absolute http or https URL")
+	} else if u.User != nil {
+		errs["url"] = append(errs["url"], "URL cannot contain credentials")
+	}
+
+	if len(req.Events) == 0 {
+		errs["events"] = append(errs["events"], "At least one event is required")
+	}
+	for i, eventType := range req.Events {
+		if !eventType.Subscribable() {
+			errs["events"] = append(errs["events"], fmt.Sprintf("Event %d: Unknown event %q", i+1, eventType))
+		}
+	}
+
+	if req.PollID != nil && uuid.Nil == *req.PollID {
+		errs["pollID"] = append(errs["pollID"], "Poll ID is not valid")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	slices.Sort(req.Events)
+	req.Events = slices.Compact(req.Events)
+
+	return nil
+}
+
+// createdWebhook is a new webhook with its secret. The secret is only ever
+// returned here.
+type createdWebhook struct {
+	*repository.Webhook
+	Secret string `json:"secret"`
+}
+
+type webhooksResponse struct {
+	Webhooks []repository.Webhook `json:"webhooks"`
+}
+
+type webhookDeliveriesResponse struct {
+	Deliveries []repository.WebhookDelivery `json:"deliveries"`
+}
+
+// CreateWebhook subscribes a URL to events of the polls of the signed in
+// user, or of a single poll the user manages.
+func (api *API) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var request createWebhookRequest
+
+	if err := api.decodeJSON(w, r, &request); err != nil {
+		writeProblem(w, r, decodeProblem(err))
+		return
+	}
+
+	if errs := request.validate(); errs != nil {
+		writeProblem(w, r, validationProblem(errs))
+		return
+	}
+
+	userID := ResolveUserID(r)
+
+	if request.PollID != nil {
+		if _, err := api.requirePollManager(r.Context(), *request.PollID, userID); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	webhooks, err := api.repository.GetUserWebhooks(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if len(webhooks) >= maxWebhooksPerUser {
+		writeProblem(w, r, validationProblem(map[string][]string{
+			"url": {fmt.Sprintf("A maximum of %d webhooks are allowed", maxWebhooksPerUser)},
+		}))
+		return
+	}
+
+	eventTypes := make([]string, 0, len(request.Events))
+	for _, eventType := range request.Events {
+		eventTypes = append(eventTypes, string(eventType))
+	}
+
+	created := repository.NewWebhook(repository.NewWebhookParams{
+		UserID: userID,
+		PollID: request.PollID,
+		URL:    request.URL,
+		Events: eventTypes,
+	})
+
+	if err := api.repository.CreateWebhook(r.Context(), created); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createdWebhook{Webhook: created, Secret: created.Secret})
+}
+
+// GetUserWebhooks lists the webhooks of the signed in user.
+func (api *API) GetUserWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := api.repository.GetUserWebhooks(r.Context(), ResolveUserID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(webhooksResponse{Webhooks: webhooks})
+}
+
+func (api *API) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookID"))
+	if err != nil || uuid.Nil == webhookID {
+		writeProblem(w, r, problemInvalidWebhookID.new(""))
+		return
+	}
+
+	if err := api.repository.DeleteWebhook(r.Context(), webhookID, ResolveUserID(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(messageResponse{Message: "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries lists the latest deliveries of a webhook of the signed
+// in user, newest first.
+func (api *API) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookID"))
+	if err != nil || uuid.Nil == webhookID {
+		writeProblem(w, r, problemInvalidWebhookID.new(""))
+		return
+	}
+
+	if _, err := api.requireWebhookOwner(r, webhookID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	deliveries, err := api.repository.GetWebhookDeliveries(r.Context(), webhookID, webhookDeliveriesLimit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(webhookDeliveriesResponse{Deliveries: deliveries})
+}
+
+// TestWebhook sends a webhook.test event to a webhook of the signed in user
+// right away and returns the delivery. Test deliveries are not retried.
+func (api *API) TestWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookID"))
+	if err != nil || uuid.Nil == webhookID {
+		writeProblem(w, r, problemInvalidWebhookID.new(""))
+		return
+	}
+
+	subscription, err := api.requireWebhookOwner(r, webhookID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var pollID uuid.UUID
+	if subscription.PollID != nil {
+		pollID = *subscription.PollID
+	}
+
+	event := events.New(events.WebhookTest, pollID, subscription.UserID, nil, events.WebhookTestData{
+		WebhookID: subscription.ID,
+	})
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	delivery := repository.NewWebhookDelivery(subscription.ID, event.ID, string(event.Type), string(payload))
+
+	result := api.webhooks.Send(r.Context(), webhook.Message{
+		URL:        subscription.URL,
+		Secret:     subscription.Secret,
+		DeliveryID: delivery.ID.String(),
+		EventType:  delivery.EventType,
+		Payload:    payload,
+	})
+
+	// The delivery is only logged once it was attempted, so that the
+	// dispatcher never picks it up.
+	attemptedAt := time.Now()
+	delivery.Status = repository.WebhookDeliverySucceeded
+	delivery.Attempts = 1
+	delivery.LastAttemptAt = &attemptedAt
+	delivery.ResponseStatus = result.ResponseStatus
+	if result.Err != nil {
+		delivery.Status = repository.WebhookDeliveryFailed
+		delivery.LastError = result.Err.Error()
+	}
+
+	if err := api.repository.CreateWebhookDeliveries(r.Context(), []*repository.WebhookDelivery{delivery}); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// requireWebhookOwner returns the webhook if it belongs to the signed in
+// user. The webhooks of other users are not found.
+func (api *API) requireWebhookOwner(r *http.Request, webhookID uuid.UUID) (*repository.Webhook, error) {
+	subscription, err := api.repository.GetWebhook(r.Context(), webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscription.UserID != ResolveUserID(r) {
+		return nil, repository.ErrWebhookNotFound
+	}
+
+	return subscription, nil
+}
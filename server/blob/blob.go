@@ -0,0 +1,80 @@
+// Package blob stores binary objects, e.g. the images of poll options.
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+)
+
+var (
+	ErrNotFound    = errors.New("blob not found")
+	ErrTooLarge    = errors.New("blob is too large")
+	ErrContentType = errors.New("blob content type is not allowed")
+	ErrInvalidKey  = errors.New("blob key is not valid")
+)
+
+// Object describes a stored blob.
+type Object struct {
+	Key string
+	// ContentType is sniffed from the content, never taken from the client.
+	ContentType string
+	Size        int64
+}
+
+// Store keeps blobs by key. Keys are chosen by the caller and are single path
+// segments, e.g. a UUID.
+type Store interface {
+	// Put stores the content of r under key, replacing any blob with the
+	// same key, within the limits of the store.
+	Put(ctx context.Context, key string, r io.Reader) (*Object, error)
+	// Open returns the content of the blob, ErrNotFound if there is none.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Limits bound what a store accepts.
+type Limits struct {
+	// MaxSize is the largest accepted blob in bytes.
+	MaxSize int64
+	// ContentTypes are the accepted media types. Any is accepted if empty.
+	ContentTypes []string
+}
+
+// ImageContentTypes are the image formats browsers display. SVG is left out
+// as it may carry scripts.
+var ImageContentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// ImageLimits accept images of up to maxSize bytes.
+func ImageLimits(maxSize int64) Limits {
+	return Limits{MaxSize: maxSize, ContentTypes: ImageContentTypes}
+}
+
+// Read reads all of r within the limits and returns it with its sniffed
+// media type.
+func (l Limits) Read(r io.Reader) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(r, l.MaxSize+1)); err != nil {
+		return nil, "", err
+	}
+
+	if int64(buf.Len()) > l.MaxSize {
+		return nil, "", ErrTooLarge
+	}
+
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(buf.Bytes()))
+	if err != nil {
+		return nil, "", ErrContentType
+	}
+
+	if len(l.ContentTypes) > 0 && !slices.Contains(l.ContentTypes, contentType) {
+		return nil, "", ErrContentType
+	}
+
+	return buf.Bytes(), contentType, nil
+}
@@ -0,0 +1,117 @@
+package blob_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/toramanomer/polly/blob"
+)
+
+// png is the start of a PNG file, enough for its type to be sniffed.
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+func newFS(t *testing.T, maxSize int64) (*blob.FS, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	store, err := blob.NewFS(dir, blob.ImageLimits(maxSize))
+	if err != nil {
+		t.Fatalf("NewFS: %v", err)
+	}
+
+	return store, dir
+}
+
+func TestFSRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newFS(t, 1024)
+
+	object, err := store.Put(ctx, "image", bytes.NewReader(png))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if object.Key != "image" || object.ContentType != "image/png" || object.Size != int64(len(png)) {
+		t.Fatalf("Put: got %+v, want a PNG of %d bytes", object, len(png))
+	}
+
+	file, err := store.Open(ctx, "image")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	content, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || !bytes.Equal(content, png) {
+		t.Fatalf("Open: got %q, %v, want the content put", content, err)
+	}
+
+	if err := store.Delete(ctx, "image"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Open(ctx, "image"); !errors.Is(err, blob.ErrNotFound) {
+		t.Fatalf("Open after Delete: got %v, want %v", err, blob.ErrNotFound)
+	}
+	if err := store.Delete(ctx, "image"); err != nil {
+		t.Fatalf("Delete of a missing blob: %v", err)
+	}
+}
+
+func TestFSLimits(t *testing.T) {
+	ctx := context.Background()
+	store, dir := newFS(t, 64)
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    error
+	}{
+		{"exactly the largest", append(bytes.Clone(png), make([]byte, 64-len(png))...), nil},
+		{"too large", append(bytes.Clone(png), make([]byte, 65-len(png))...), blob.ErrTooLarge},
+		{"SVG", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), blob.ErrContentType},
+		{"HTML", []byte(`<html><script>alert(1)</script></html>`), blob.ErrContentType},
+		{"text", []byte("hello"), blob.ErrContentType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := store.Put(ctx, "image", bytes.NewReader(tt.content))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Put: got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Rejected blobs leave no temporary files behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			t.Fatalf("ReadDir: got %s, want no temporary files", entry.Name())
+		}
+	}
+}
+
+func TestFSInvalidKey(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newFS(t, 1024)
+
+	for _, key := range []string{"", ".", "..", "../image", ".put-x", "a/b", `a\b`, "/image"} {
+		t.Run(key, func(t *testing.T) {
+			if _, err := store.Put(ctx, key, bytes.NewReader(png)); !errors.Is(err, blob.ErrInvalidKey) {
+				t.Errorf("Put: got %v, want %v", err, blob.ErrInvalidKey)
+			}
+			if _, err := store.Open(ctx, key); !errors.Is(err, blob.ErrInvalidKey) {
+				t.Errorf("Open: got %v, want %v", err, blob.ErrInvalidKey)
+			}
+			if err := store.Delete(ctx, key); !errors.Is(err, blob.ErrInvalidKey) {
+				t.Errorf("Delete: got %v, want %v", err, blob.ErrInvalidKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FS is a Store keeping every blob in a file named by its key in a single
+// directory.
+type FS struct {
+	dir    string
+	limits Limits
+}
+
+// NewFS returns a store in dir, which is created if it does not exist.
+func NewFS(dir string, limits Limits) (*FS, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("error creating blob directory: %w", err)
+	}
+
+	return &FS{dir: dir, limits: limits}, nil
+}
+
+func (s *FS) path(key string) (string, error) {
+	// Temporary files start with a dot, so keys may not.
+	if !filepath.IsLocal(key) || strings.ContainsAny(key, `/\`) || strings.HasPrefix(key, ".") {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(s.dir, key), nil
+}
+
+func (s *FS) Put(_ context.Context, key string, r io.Reader) (*Object, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	content, contentType, err := s.limits.Read(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// The content is written to a temporary file first, so a blob is never
+	// read half written.
+	tmp, err := os.CreateTemp(s.dir, ".put-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating blob file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("error writing blob file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("error writing blob file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("error renaming blob file: %w", err)
+	}
+
+	return &Object{Key: key, ContentType: contentType, Size: int64(len(content))}, nil
+}
+
+func (s *FS) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening blob file: %w", err)
+	}
+
+	return file, nil
+}
+
+func (s *FS) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error removing blob file: %w", err)
+	}
+
+	return nil
+}
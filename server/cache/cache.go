@@ -0,0 +1,26 @@
+// Package cache provides read-through caches for poll definitions. Cached
+// polls are shared between readers and must not be modified.
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/repository"
+)
+
+type PollCache interface {
+	// Get returns the cached poll and whether it was found.
+	Get(ctx context.Context, pollID uuid.UUID) (*repository.Poll, bool, error)
+	// Set caches the poll, unless it was deleted within tombstoneTTL.
+	Set(ctx context.Context, poll *repository.Poll) error
+	// Delete drops the poll and keeps it from being cached again for
+	// tombstoneTTL.
+	Delete(ctx context.Context, pollID uuid.UUID) error
+}
+
+// tombstoneTTL is how long a poll is not cached after it was deleted from the
+// cache. A reader may have fetched the poll before it changed and only cache
+// it after it was deleted; it would keep serving the old poll otherwise.
+const tombstoneTTL = 10 * time.Second
@@ -0,0 +1,368 @@
+package cache_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/cache"
+	"github.com/toramanomer/polly/repository"
+)
+
+const testTTL = 50 * time.Millisecond
+
+func TestPollCache(t *testing.T) {
+	caches := []struct {
+		name string
+		new  func(t *testing.T) cache.PollCache
+	}{
+		{"LRU", func(*testing.T) cache.PollCache {
+			return cache.NewLRU(10, testTTL)
+		}},
+		{"Redis", func(*testing.T) cache.PollCache {
+			return cache.NewRedis(cache.NewMemoryRedis(), "test:", testTTL)
+		}},
+		{"NetRedis", func(t *testing.T) cache.PollCache {
+			client := cache.NewNetRedis(serveRedis(t, cache.NewMemoryRedis()), 2, time.Second)
+			t.Cleanup(func() { client.Close() })
+			return cache.NewRedis(client, "test:", testTTL)
+		}},
+	}
+
+	for _, c := range caches {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			polls := c.new(t)
+
+			poll := &repository.Poll{ID: uuid.New(), Question: "Lunch?"}
+			if err := polls.Set(ctx, poll); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+
+			got, found, err := polls.Get(ctx, poll.ID)
+			if err != nil || !found || got.ID != poll.ID || got.Question != poll.Question {
+				t.Fatalf("Get hit: got %+v, %t, %v, want %+v", got, found, err, poll)
+			}
+
+			_, found, err = polls.Get(ctx, uuid.New())
+			if err != nil || found {
+				t.Fatalf("Get miss: got %t, %v, want not found", found, err)
+			}
+
+			if err := polls.Delete(ctx, poll.ID); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			_, found, err = polls.Get(ctx, poll.ID)
+			if err != nil || found {
+				t.Fatalf("Get deleted: got %t, %v, want not found", found, err)
+			}
+
+			if err := polls.Delete(ctx, poll.ID); err != nil {
+				t.Fatalf("Delete missing: %v", err)
+			}
+
+			// A reader that fetched the poll before it was deleted does not
+			// cache it again.
+			if err := polls.Set(ctx, poll); err != nil {
+				t.Fatalf("Set deleted: %v", err)
+			}
+			_, found, err = polls.Get(ctx, poll.ID)
+			if err != nil || found {
+				t.Fatalf("Get deleted after Set: got %t, %v, want not found", found, err)
+			}
+
+			other := &repository.Poll{ID: uuid.New(), Question: "Dinner?"}
+			if err := polls.Set(ctx, other); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			time.Sleep(2 * testTTL)
+			_, found, err = polls.Get(ctx, other.ID)
+			if err != nil || found {
+				t.Fatalf("Get expired: got %t, %v, want not found", found, err)
+			}
+		})
+	}
+}
+
+func TestNetRedis(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("error replies keep the connection", func(t *testing.T) {
+		addr, accepted := serveScript(t, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", "$-1\r\n")
+		client := newNetRedis(t, addr)
+
+		if _, _, err := client.Get(ctx, "key"); err == nil || !strings.Contains(err.Error(), "WRONGTYPE") {
+			t.Fatalf("Get: got %v, want the error of the server", err)
+		}
+		if _, found, err := client.Get(ctx, "key"); err != nil || found {
+			t.Fatalf("Get after an error: got %t, %v, want not found", found, err)
+		}
+		if n := accepted.Load(); n != 1 {
+			t.Fatalf("got %d connections, want 1", n)
+		}
+	})
+
+	t.Run("nil and empty bulk strings", func(t *testing.T) {
+		addr, _ := serveScript(t, "$-1\r\n", "$0\r\n\r\n", "$-1\r\n", "+OK\r\n")
+		client := newNetRedis(t, addr)
+
+		if value, found, err := client.Get(ctx, "key"); err != nil || found || value != nil {
+			t.Fatalf("Get nil: got %q, %t, %v, want not found", value, found, err)
+		}
+		if value, found, err := client.Get(ctx, "key"); err != nil || !found || len(value) != 0 {
+			t.Fatalf("Get empty: got %q, %t, %v, want an empty value", value, found, err)
+		}
+		if stored, err := client.SetNX(ctx, "key", []byte("value"), time.Second); err != nil || stored {
+			t.Fatalf("SetNX of an existing key: got %t, %v, want not stored", stored, err)
+		}
+		if stored, err := client.SetNX(ctx, "key", []byte("value"), time.Second); err != nil || !stored {
+			t.Fatalf("SetNX of a new key: got %t, %v, want stored", stored, err)
+		}
+	})
+
+	t.Run("reconnects after the server closed an idle connection", func(t *testing.T) {
+		addr, accepted := serveScript(t, "+OK\r\n", hangUp, "$5\r\nvalue\r\n")
+		client := newNetRedis(t, addr)
+
+		if err := client.Set(ctx, "key", []byte("value"), 0); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if value, found, err := client.Get(ctx, "key"); err != nil || !found || string(value) != "value" {
+			t.Fatalf("Get: got %q, %t, %v, want the value", value, found, err)
+		}
+		if n := accepted.Load(); n != 2 {
+			t.Fatalf("got %d connections, want 2", n)
+		}
+	})
+
+	t.Run("malformed replies drop the connection", func(t *testing.T) {
+		addr, accepted := serveScript(t, "?\r\n", "$five\r\n", ":1\r\n")
+		client := newNetRedis(t, addr)
+
+		if _, _, err := client.Get(ctx, "key"); err == nil {
+			t.Fatal("Get of an unknown reply type: got no error")
+		}
+		if _, _, err := client.Get(ctx, "key"); err == nil {
+			t.Fatal("Get of a malformed bulk string: got no error")
+		}
+		if err := client.Del(ctx, "key"); err != nil {
+			t.Fatalf("Del: %v", err)
+		}
+		if n := accepted.Load(); n != 3 {
+			t.Fatalf("got %d connections, want 3", n)
+		}
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		addr, _ := serveScript(t, noReply)
+		client := cache.NewNetRedis(addr, 2, 20*time.Millisecond)
+		t.Cleanup(func() { client.Close() })
+
+		if _, _, err := client.Get(ctx, "key"); !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("Get: got %v, want a timeout", err)
+		}
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen: %v", err)
+		}
+		listener.Close()
+
+		if _, _, err := newNetRedis(t, listener.Addr().String()).Get(ctx, "key"); err == nil {
+			t.Fatal("Get: got no error")
+		}
+	})
+}
+
+func newNetRedis(t *testing.T, addr string) *cache.NetRedis {
+	client := cache.NewNetRedis(addr, 2, time.Second)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// Scripted replies closing the connection instead of replying, and reading
+// the next command without replying.
+const (
+	hangUp  = "hang up"
+	noReply = "no reply"
+)
+
+// serveScript answers the commands sent to it with the replies in order, on
+// whichever connection they arrive, and returns its address and the number of
+// connections it accepted.
+func serveScript(t *testing.T, replies ...string) (string, *atomic.Int32) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var (
+		accepted atomic.Int32
+		mu       sync.Mutex
+	)
+
+	next := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(replies) == 0 {
+			return "-ERR unexpected command\r\n"
+		}
+		reply := replies[0]
+		replies = replies[1:]
+		return reply
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+
+			go func() {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+				for {
+					if _, err := readCommand(reader); err != nil {
+						return
+					}
+
+					switch reply := next(); reply {
+					case hangUp:
+						return
+					case noReply:
+					default:
+						if _, err := io.WriteString(conn, reply); err != nil {
+							return
+						}
+					}
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), &accepted
+}
+
+// serveRedis serves the GET, SET and DEL commands of the Redis protocol from
+// store and returns its address.
+func serveRedis(t *testing.T, store *cache.MemoryRedis) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveRedisConn(conn, store)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func serveRedisConn(conn net.Conn, store *cache.MemoryRedis) {
+	defer conn.Close()
+
+	ctx := context.Background()
+	reader := bufio.NewReader(conn)
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			value, found, _ := store.Get(ctx, args[1])
+			if found {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "SET":
+			var (
+				ttl time.Duration
+				nx  bool
+			)
+			for i := 3; i < len(args); i++ {
+				switch strings.ToUpper(args[i]) {
+				case "PX":
+					i++
+					ms, _ := strconv.Atoi(args[i])
+					ttl = time.Duration(ms) * time.Millisecond
+				case "NX":
+					nx = true
+				}
+			}
+
+			reply = "+OK\r\n"
+			if !nx {
+				store.Set(ctx, args[1], []byte(args[2]), ttl)
+			} else if stored, _ := store.SetNX(ctx, args[1], []byte(args[2]), ttl); !stored {
+				reply = "$-1\r\n"
+			}
+		case "DEL":
+			store.Del(ctx, args[1])
+			reply = ":1\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	var count int
+	if _, err := fmt.Fscanf(reader, "*%d\r\n", &count); err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		var size int
+		if _, err := fmt.Fscanf(reader, "$%d\r\n", &size); err != nil {
+			return nil, err
+		}
+
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+
+	return args, nil
+}
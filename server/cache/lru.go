@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/repository"
+)
+
+type lruEntry struct {
+	pollID uuid.UUID
+	// poll is nil in the tombstone of a deleted poll.
+	poll      *repository.Poll
+	expiresAt time.Time
+}
+
+// LRU is an in-memory PollCache holding at most capacity polls, each for at
+// most ttl. The least recently used poll is evicted first. Tombstones of
+// deleted polls take up room like polls.
+type LRU struct {
+	capacity int
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[uuid.UUID]*list.Element
+}
+
+func NewLRU(capacity int, ttl time.Duration) *LRU {
+	return &LRU{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[uuid.UUID]*list.Element, capacity),
+	}
+}
+
+func (c *LRU) Get(_ context.Context, pollID uuid.UUID) (*repository.Poll, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[pollID]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, pollID)
+		return nil, false, nil
+	}
+	if entry.poll == nil {
+		return nil, false, nil
+	}
+
+	c.order.MoveToFront(element)
+	return entry.poll, true, nil
+}
+
+func (c *LRU) Set(_ context.Context, poll *repository.Poll) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[poll.ID]; ok {
+		entry := element.Value.(*lruEntry)
+		if entry.poll == nil && time.Now().Before(entry.expiresAt) {
+			return nil
+		}
+	}
+
+	c.put(&lruEntry{pollID: poll.ID, poll: poll, expiresAt: time.Now().Add(c.ttl)})
+
+	return nil
+}
+
+func (c *LRU) Delete(_ context.Context, pollID uuid.UUID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.put(&lruEntry{pollID: pollID, expiresAt: time.Now().Add(tombstoneTTL)})
+
+	return nil
+}
+
+// put stores the entry as the most recently used one, evicting the least
+// recently used ones beyond capacity.
+func (c *LRU) put(entry *lruEntry) {
+	if element, ok := c.entries[entry.pollID]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[entry.pollID] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).pollID)
+	}
+}
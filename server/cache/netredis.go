@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// NetRedis is a RedisClient speaking the Redis protocol to a server over TCP.
+// It keeps up to poolSize idle connections for reuse.
+type NetRedis struct {
+	addr    string
+	timeout time.Duration
+	idle    chan *redisConn
+}
+
+var _ RedisClient = (*NetRedis)(nil)
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewNetRedis returns a client of the Redis server at addr, a host:port.
+// Connections are made on demand; every command is given at most timeout,
+// unless its context ends sooner.
+func NewNetRedis(addr string, poolSize int, timeout time.Duration) *NetRedis {
+	return &NetRedis{
+		addr:    addr,
+		timeout: timeout,
+		idle:    make(chan *redisConn, poolSize),
+	}
+}
+
+func (c *NetRedis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch reply := reply.(type) {
+	case nil:
+		return nil, false, nil
+	case []byte:
+		return reply, true, nil
+	default:
+		return nil, false, fmt.Errorf("unexpected redis reply to GET: %v", reply)
+	}
+}
+
+func (c *NetRedis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := c.do(ctx, setArgs(key, value, ttl)...)
+	return err
+}
+
+func (c *NetRedis) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	reply, err := c.do(ctx, append(setArgs(key, value, ttl), "NX")...)
+	if err != nil {
+		return false, err
+	}
+
+	// The server answers OK if the value was stored and nil if not.
+	return reply != nil, nil
+}
+
+func setArgs(key string, value []byte, ttl time.Duration) []string {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	return args
+}
+
+func (c *NetRedis) Del(ctx context.Context, key string) error {
+	_, err := c.do(ctx, "DEL", key)
+	return err
+}
+
+// Close closes the idle connections. Connections in use are closed as they
+// are returned.
+func (c *NetRedis) Close() error {
+	var errs []error
+	for {
+		select {
+		case conn := <-c.idle:
+			errs = append(errs, conn.conn.Close())
+		default:
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// redisError is an error reply of the server. It leaves the connection
+// usable.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// do sends a command and reads its reply: a string, an integer, a []byte for
+// bulk strings or nil for a missing value. A command failing on an idle
+// connection, which the server may have closed meanwhile, is sent again on
+// another one.
+func (c *NetRedis) do(ctx context.Context, args ...string) (any, error) {
+	for {
+		conn, reused, err := c.conn(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to redis: %w", err)
+		}
+
+		deadline := time.Now().Add(c.timeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		conn.conn.SetDeadline(deadline)
+
+		reply, err := conn.roundTrip(args)
+
+		var replyErr redisError
+		if err != nil && !errors.As(err, &replyErr) {
+			// The connection may be left mid-reply; it is not reused.
+			conn.conn.Close()
+			if reused && !errors.Is(err, os.ErrDeadlineExceeded) && ctx.Err() == nil {
+				continue
+			}
+			return nil, fmt.Errorf("error running redis %s: %w", args[0], err)
+		}
+
+		select {
+		case c.idle <- conn:
+		default:
+			conn.conn.Close()
+		}
+
+		return reply, err
+	}
+}
+
+// conn returns an idle connection, reused, or a new one.
+func (c *NetRedis) conn(ctx context.Context) (conn *redisConn, reused bool, err error) {
+	select {
+	case conn := <-c.idle:
+		return conn, true, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: c.timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &redisConn{conn: netConn, reader: bufio.NewReader(netConn)}, false, nil
+}
+
+func (c *redisConn) roundTrip(args []string) (any, error) {
+	// Commands are sent as arrays of bulk strings.
+	command := make([]byte, 0, 64)
+	command = fmt.Appendf(command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		command = fmt.Appendf(command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	if _, err := c.conn.Write(command); err != nil {
+		return nil, err
+	}
+
+	return c.readReply()
+}
+
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("malformed bulk string length %q", body)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, value); err != nil {
+			return nil, err
+		}
+		return value[:size], nil
+	default:
+		return nil, fmt.Errorf("unsupported reply type %q", kind)
+	}
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/repository"
+)
+
+// RedisClient is the subset of Redis commands the Redis cache needs. It is
+// small enough to be adapted from any Redis-compatible client library.
+type RedisClient interface {
+	// Get returns the value of key and whether the key exists.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key and expires it after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX is Set unless key exists, and reports whether value was stored.
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Del(ctx context.Context, key string) error
+}
+
+// Redis is a PollCache storing polls as JSON in a Redis-compatible store, so
+// that the cache is shared between server instances. Deleted polls are
+// replaced by a tombstone, which Set does not overwrite.
+type Redis struct {
+	client RedisClient
+	prefix string
+	ttl    time.Duration
+}
+
+func NewRedis(client RedisClient, prefix string, ttl time.Duration) *Redis {
+	return &Redis{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+// tombstone is stored in place of deleted polls. Polls are JSON objects, so
+// none is mistaken for it.
+var tombstone = []byte("deleted")
+
+func (c *Redis) key(pollID uuid.UUID) string {
+	return c.prefix + "poll:" + pollID.String()
+}
+
+func (c *Redis) Get(ctx context.Context, pollID uuid.UUID) (*repository.Poll, bool, error) {
+	value, found, err := c.client.Get(ctx, c.key(pollID))
+	if err != nil || !found || bytes.Equal(value, tombstone) {
+		return nil, false, err
+	}
+
+	var poll repository.Poll
+	if err := json.Unmarshal(value, &poll); err != nil {
+		return nil, false, fmt.Errorf("error decoding cached poll: %w", err)
+	}
+
+	return &poll, true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, poll *repository.Poll) error {
+	value, err := json.Marshal(poll)
+	if err != nil {
+		return fmt.Errorf("error encoding poll: %w", err)
+	}
+
+	// A poll that is cached already is the same or newer.
+	_, err = c.client.SetNX(ctx, c.key(poll.ID), value, c.ttl)
+	return err
+}
+
+func (c *Redis) Delete(ctx context.Context, pollID uuid.UUID) error {
+	return c.client.Set(ctx, c.key(pollID), tombstone, tombstoneTTL)
+}
+
+type memoryRedisEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryRedis is an in-memory RedisClient honoring expirations. It stands in
+// for a real Redis server in development and tests.
+type MemoryRedis struct {
+	mu      sync.Mutex
+	entries map[string]memoryRedisEntry
+}
+
+func NewMemoryRedis() *MemoryRedis {
+	return &MemoryRedis{
+		entries: make(map[string]memoryRedisEntry),
+	}
+}
+
+func (m *MemoryRedis) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+
+	return entry.value, true, nil
+}
+
+func (m *MemoryRedis) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(key, value, ttl)
+
+	return nil
+}
+
+func (m *MemoryRedis) set(key string, value []byte, ttl time.Duration) {
+	entry := memoryRedisEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	m.entries[key] = entry
+}
+
+func (m *MemoryRedis) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.entries[key]; ok && (entry.expiresAt.IsZero() || time.Now().Before(entry.expiresAt)) {
+		return false, nil
+	}
+
+	m.set(key, value, ttl)
+
+	return true, nil
+}
+
+func (m *MemoryRedis) Del(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+
+	return nil
+}
@@ -0,0 +1,720 @@
+// Code generated by genclient. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Ballot struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Email     string    `json:"email,omitempty"`
+	ID        uuid.UUID `json:"id"`
+	PollID    uuid.UUID `json:"pollID"`
+	Recipient string    `json:"recipient"`
+	Used      bool      `json:"used"`
+}
+
+type BallotsResponse struct {
+	Ballots []IssuedBallot `json:"ballots"`
+	Skipped []string       `json:"skipped"`
+}
+
+type CreateBallotsRequest struct {
+	Delivery   string   `json:"delivery,omitempty"`
+	Recipients []string `json:"recipients"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type CreatePollOption struct {
+	Description string     `json:"description,omitempty"`
+	ImageID     *uuid.UUID `json:"imageID,omitempty"`
+	Text        string     `json:"text"`
+	URL         string     `json:"url,omitempty"`
+}
+
+type CreatePollRequest struct {
+	AllowOther     bool               `json:"allowOther,omitempty"`
+	Attribution    string             `json:"attribution,omitempty"`
+	Duration       string             `json:"duration,omitempty"`
+	ExpiresAt      time.Time          `json:"expiresAt,omitempty"`
+	Invitees       []string           `json:"invitees,omitempty"`
+	Options        []CreatePollOption `json:"options"`
+	OrganizationID *uuid.UUID         `json:"organizationID,omitempty"`
+	Passcode       string             `json:"passcode,omitempty"`
+	Question       string             `json:"question"`
+	TimeZone       string             `json:"timeZone,omitempty"`
+	Visibility     string             `json:"visibility,omitempty"`
+}
+
+type CreateWebhookRequest struct {
+	Events []string   `json:"events"`
+	PollID *uuid.UUID `json:"pollID,omitempty"`
+	URL    string     `json:"url"`
+}
+
+type CreatedWebhook struct {
+	CreatedAt time.Time  `json:"createdAt"`
+	Events    []string   `json:"events"`
+	ID        uuid.UUID  `json:"id"`
+	PollID    *uuid.UUID `json:"pollID"`
+	Secret    string     `json:"secret"`
+	URL       string     `json:"url"`
+	UserID    uuid.UUID  `json:"userID"`
+}
+
+type Image struct {
+	ContentType string    `json:"contentType"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ID          uuid.UUID `json:"id"`
+	Size        int       `json:"size"`
+	UserID      uuid.UUID `json:"userID"`
+}
+
+type IssuedBallot struct {
+	Emailed   bool      `json:"emailed"`
+	ID        uuid.UUID `json:"id"`
+	Link      string    `json:"link,omitempty"`
+	Recipient string    `json:"recipient"`
+}
+
+type MembersResponse struct {
+	Members []OrganizationMember `json:"members"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type ModerateOtherAnswersRequest struct {
+	Action   string      `json:"action"`
+	OptionID *uuid.UUID  `json:"optionID,omitempty"`
+	VoteIDs  []uuid.UUID `json:"voteIDs"`
+}
+
+type ModerateOtherAnswersResponse struct {
+	Moderated int `json:"moderated"`
+}
+
+type OptionTimeSeries struct {
+	Buckets  []VoteBucket `json:"buckets"`
+	OptionID uuid.UUID    `json:"optionID"`
+	Position int          `json:"position"`
+	Text     string       `json:"text"`
+	Total    int          `json:"total"`
+}
+
+type OrganizationMember struct {
+	Role     string    `json:"role"`
+	UserID   uuid.UUID `json:"userID"`
+	Username string    `json:"username"`
+}
+
+type OrganizationMembership struct {
+	CreatedAt time.Time `json:"createdAt"`
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+}
+
+type OrganizationsResponse struct {
+	Organizations []OrganizationMembership `json:"organizations"`
+}
+
+type OtherAnswer struct {
+	Hidden  bool      `json:"hidden"`
+	Text    string    `json:"text"`
+	VoteID  uuid.UUID `json:"voteID"`
+	VotedAt time.Time `json:"votedAt"`
+}
+
+type OtherAnswersResponse struct {
+	Answers []OtherAnswer `json:"answers"`
+}
+
+type PeakRate struct {
+	Count          int       `json:"count"`
+	Start          time.Time `json:"start"`
+	VotesPerMinute float64   `json:"votesPerMinute"`
+}
+
+type Poll struct {
+	Attribution    string       `json:"attribution"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	ExpiresAt      time.Time    `json:"expiresAt"`
+	ID             uuid.UUID    `json:"id"`
+	Options        []PollOption `json:"options"`
+	OrganizationID *uuid.UUID   `json:"organizationID"`
+	Question       string       `json:"question"`
+	TimeZone       string       `json:"timeZone"`
+	UpdatedAt      time.Time    `json:"updatedAt"`
+	UserID         uuid.UUID    `json:"userID"`
+	Version        int          `json:"version"`
+	Visibility     string       `json:"visibility"`
+}
+
+type PollAnalytics struct {
+	FirstVoteAt *time.Time         `json:"firstVoteAt"`
+	Interval    string             `json:"interval"`
+	LastVoteAt  *time.Time         `json:"lastVoteAt"`
+	Options     []OptionTimeSeries `json:"options"`
+	Peak        *PeakRate          `json:"peak"`
+	PollID      uuid.UUID          `json:"pollID"`
+	TotalVotes  int                `json:"totalVotes"`
+	Totals      []VoteBucket       `json:"totals"`
+}
+
+type PollDetails struct {
+	Attribution    string       `json:"attribution"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	Display        PollDisplay  `json:"display"`
+	ExpiresAt      time.Time    `json:"expiresAt"`
+	ID             uuid.UUID    `json:"id"`
+	Options        []PollOption `json:"options"`
+	OrganizationID *uuid.UUID   `json:"organizationID"`
+	Question       string       `json:"question"`
+	TimeZone       string       `json:"timeZone"`
+	UpdatedAt      time.Time    `json:"updatedAt"`
+	UserID         uuid.UUID    `json:"userID"`
+	Version        int          `json:"version"`
+	Visibility     string       `json:"visibility"`
+}
+
+type PollDisplay struct {
+	CreatedAt            string `json:"createdAt"`
+	ExpiresAt            string `json:"expiresAt"`
+	Offset               string `json:"offset"`
+	TimeZoneAbbreviation string `json:"timeZoneAbbreviation"`
+}
+
+type PollLimits struct {
+	Durations          []string `json:"durations"`
+	MaxDurationSeconds int      `json:"maxDurationSeconds"`
+	MaxOptions         int      `json:"maxOptions"`
+	MinOptions         int      `json:"minOptions"`
+}
+
+type PollOption struct {
+	Count       int        `json:"count"`
+	Description string     `json:"description,omitempty"`
+	ID          uuid.UUID  `json:"id"`
+	ImageID     *uuid.UUID `json:"imageID,omitempty"`
+	Other       bool       `json:"other"`
+	PollID      uuid.UUID  `json:"pollID"`
+	Position    int        `json:"position"`
+	Text        string     `json:"text"`
+	URL         string     `json:"url,omitempty"`
+}
+
+type PollPage struct {
+	NextCursor string `json:"nextCursor,omitempty"`
+	Polls      []Poll `json:"polls"`
+}
+
+type PollTurnout struct {
+	Ballots []Ballot  `json:"ballots"`
+	Invited int       `json:"invited"`
+	PollID  uuid.UUID `json:"pollID"`
+	Turnout float64   `json:"turnout"`
+	Voted   int       `json:"voted"`
+}
+
+type PollVoter struct {
+	OptionID *uuid.UUID `json:"optionID"`
+	UserID   uuid.UUID  `json:"userID"`
+	Username string     `json:"username"`
+	VotedAt  *time.Time `json:"votedAt"`
+}
+
+type PollVoters struct {
+	Attribution string      `json:"attribution"`
+	PollID      uuid.UUID   `json:"pollID"`
+	Voters      []PollVoter `json:"voters"`
+}
+
+type Problem struct {
+	Detail     string              `json:"detail,omitempty"`
+	ErrorCodes []string            `json:"errorCodes,omitempty"`
+	Errors     map[string][]string `json:"errors,omitempty"`
+	Instance   string              `json:"instance,omitempty"`
+	RequestID  string              `json:"requestId,omitempty"`
+	Status     int                 `json:"status"`
+	Title      string              `json:"title"`
+	Type       string              `json:"type"`
+}
+
+type SetOrganizationMemberRequest struct {
+	Role string `json:"role"`
+}
+
+type SigninRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type SignupRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Username string `json:"username"`
+}
+
+type TokenResponse struct {
+	Token string `json:"token"`
+}
+
+type User struct {
+	Email    string    `json:"email"`
+	ID       uuid.UUID `json:"id"`
+	Plan     string    `json:"plan"`
+	Username string    `json:"username"`
+}
+
+type VoteBucket struct {
+	Count      int       `json:"count"`
+	Cumulative int       `json:"cumulative"`
+	Start      time.Time `json:"start"`
+}
+
+type VoteOnPollRequest struct {
+	OptionID  uuid.UUID `json:"optionID"`
+	OtherText string    `json:"otherText,omitempty"`
+}
+
+type Webhook struct {
+	CreatedAt time.Time  `json:"createdAt"`
+	Events    []string   `json:"events"`
+	ID        uuid.UUID  `json:"id"`
+	PollID    *uuid.UUID `json:"pollID"`
+	URL       string     `json:"url"`
+	UserID    uuid.UUID  `json:"userID"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+type WebhookDelivery struct {
+	Attempts       int        `json:"attempts"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	EventID        uuid.UUID  `json:"eventID"`
+	EventType      string     `json:"eventType"`
+	ID             uuid.UUID  `json:"id"`
+	LastAttemptAt  *time.Time `json:"lastAttemptAt"`
+	LastError      string     `json:"lastError,omitempty"`
+	NextAttemptAt  time.Time  `json:"nextAttemptAt"`
+	Payload        string     `json:"payload"`
+	ResponseStatus *int       `json:"responseStatus"`
+	Status         string     `json:"status"`
+	WebhookID      uuid.UUID  `json:"webhookID"`
+}
+
+type WebhooksResponse struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// Me calls GET /api/auth/me: Refresh the session cookie.
+func (c *Client) Me(ctx context.Context) (*TokenResponse, error) {
+	req := request{method: "GET", path: "/api/auth/me"}
+	var out TokenResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Signin calls POST /api/auth/signin: Sign in and receive the session cookie.
+func (c *Client) Signin(ctx context.Context, body SigninRequest) (*TokenResponse, error) {
+	req := request{method: "POST", path: "/api/auth/signin", body: body}
+	var out TokenResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Signout calls POST /api/auth/signout: Clear the session cookie.
+func (c *Client) Signout(ctx context.Context) error {
+	req := request{method: "POST", path: "/api/auth/signout"}
+	return c.do(ctx, req, nil)
+}
+
+// Signup calls POST /api/auth/signup: Create an account.
+func (c *Client) Signup(ctx context.Context, body SignupRequest) (*User, error) {
+	req := request{method: "POST", path: "/api/auth/signup", body: body}
+	var out User
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UploadImage calls POST /api/images: Upload an image for the options of the user's polls.
+func (c *Client) UploadImage(ctx context.Context, body io.Reader, contentType string) (*Image, error) {
+	req := request{method: "POST", path: "/api/images", body: body, contentType: contentType}
+	var out Image
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetImage calls GET /api/images/{imageID}: Download an uploaded image.
+func (c *Client) GetImage(ctx context.Context, imageID uuid.UUID) ([]byte, error) {
+	req := request{method: "GET", path: "/api/images/" + url.PathEscape(formatParam(imageID))}
+	var out []byte
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetUserOrganizations calls GET /api/organizations: List the organizations of the signed in user with the user's role.
+func (c *Client) GetUserOrganizations(ctx context.Context) (*OrganizationsResponse, error) {
+	req := request{method: "GET", path: "/api/organizations"}
+	var out OrganizationsResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateOrganization calls POST /api/organizations: Create an organization owned by the signed in user.
+func (c *Client) CreateOrganization(ctx context.Context, body CreateOrganizationRequest) (*OrganizationMembership, error) {
+	req := request{method: "POST", path: "/api/organizations", body: body}
+	var out OrganizationMembership
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetOrganizationMembers calls GET /api/organizations/{organizationID}/members: List the members of an organization.
+func (c *Client) GetOrganizationMembers(ctx context.Context, organizationID uuid.UUID) (*MembersResponse, error) {
+	req := request{method: "GET", path: "/api/organizations/" + url.PathEscape(formatParam(organizationID)) + "/members"}
+	var out MembersResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveOrganizationMember calls DELETE /api/organizations/{organizationID}/members/{username}: Remove a member from an organization, or leave it.
+func (c *Client) RemoveOrganizationMember(ctx context.Context, organizationID uuid.UUID, username string) (*MessageResponse, error) {
+	req := request{method: "DELETE", path: "/api/organizations/" + url.PathEscape(formatParam(organizationID)) + "/members/" + url.PathEscape(formatParam(username))}
+	var out MessageResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetOrganizationMember calls PUT /api/organizations/{organizationID}/members/{username}: Add a member to an organization or change the role of a member.
+func (c *Client) SetOrganizationMember(ctx context.Context, organizationID uuid.UUID, username string, body SetOrganizationMemberRequest) (*OrganizationMember, error) {
+	req := request{method: "PUT", path: "/api/organizations/" + url.PathEscape(formatParam(organizationID)) + "/members/" + url.PathEscape(formatParam(username)), body: body}
+	var out OrganizationMember
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUserPollsParams are the query and header parameters of GetUserPolls.
+type GetUserPollsParams struct {
+	// Page size.
+	Limit         *int
+	Status        *string
+	Sort          *string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// nextCursor of the previous page.
+	Cursor *string
+	// Search in the question.
+	Q *string
+	// Only list the polls of this organization. Without it the polls of every organization of the user are included.
+	OrganizationID *uuid.UUID
+}
+
+// GetUserPolls calls GET /api/polls: List the polls of the signed in user.
+func (c *Client) GetUserPolls(ctx context.Context, params *GetUserPollsParams) (*PollPage, error) {
+	req := request{method: "GET", path: "/api/polls"}
+	if params != nil {
+		if params.Limit != nil {
+			req.setQuery("limit", *params.Limit)
+		}
+		if params.Status != nil {
+			req.setQuery("status", *params.Status)
+		}
+		if params.Sort != nil {
+			req.setQuery("sort", *params.Sort)
+		}
+		if params.CreatedAfter != nil {
+			req.setQuery("createdAfter", *params.CreatedAfter)
+		}
+		if params.CreatedBefore != nil {
+			req.setQuery("createdBefore", *params.CreatedBefore)
+		}
+		if params.Cursor != nil {
+			req.setQuery("cursor", *params.Cursor)
+		}
+		if params.Q != nil {
+			req.setQuery("q", *params.Q)
+		}
+		if params.OrganizationID != nil {
+			req.setQuery("organizationID", *params.OrganizationID)
+		}
+	}
+	var out PollPage
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreatePoll calls POST /api/polls: Create a poll.
+func (c *Client) CreatePoll(ctx context.Context, body CreatePollRequest) (*Poll, error) {
+	req := request{method: "POST", path: "/api/polls", body: body}
+	var out Poll
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPollLimits calls GET /api/polls/limits: Get the limits polls of the signed in user are created within.
+func (c *Client) GetPollLimits(ctx context.Context) (*PollLimits, error) {
+	req := request{method: "GET", path: "/api/polls/limits"}
+	var out PollLimits
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListPublicPollsParams are the query and header parameters of ListPublicPolls.
+type ListPublicPollsParams struct {
+	// Page size.
+	Limit *int
+	// nextCursor of the previous page.
+	Cursor *string
+}
+
+// ListPublicPolls calls GET /api/polls/public: List the active public polls, newest first.
+func (c *Client) ListPublicPolls(ctx context.Context, params *ListPublicPollsParams) (*PollPage, error) {
+	req := request{method: "GET", path: "/api/polls/public"}
+	if params != nil {
+		if params.Limit != nil {
+			req.setQuery("limit", *params.Limit)
+		}
+		if params.Cursor != nil {
+			req.setQuery("cursor", *params.Cursor)
+		}
+	}
+	var out PollPage
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeletePoll calls DELETE /api/polls/{pollID}: Delete a poll of the user or of an organization the user administers.
+func (c *Client) DeletePoll(ctx context.Context, pollID uuid.UUID) (*MessageResponse, error) {
+	req := request{method: "DELETE", path: "/api/polls/" + url.PathEscape(formatParam(pollID))}
+	var out MessageResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPollParams are the query and header parameters of GetPoll.
+type GetPollParams struct {
+	// Passcode of a passcode protected poll.
+	XPollPasscode *string
+	// Ballot token of a ballot poll.
+	XBallotToken *string
+}
+
+// GetPoll calls GET /api/polls/{pollID}: Get a poll with its options and vote counts.
+func (c *Client) GetPoll(ctx context.Context, pollID uuid.UUID, params *GetPollParams) (*PollDetails, error) {
+	req := request{method: "GET", path: "/api/polls/" + url.PathEscape(formatParam(pollID))}
+	if params != nil {
+		if params.XPollPasscode != nil {
+			req.setHeader("X-Poll-Passcode", *params.XPollPasscode)
+		}
+		if params.XBallotToken != nil {
+			req.setHeader("X-Ballot-Token", *params.XBallotToken)
+		}
+	}
+	var out PollDetails
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPollAnalyticsParams are the query and header parameters of GetPollAnalytics.
+type GetPollAnalyticsParams struct {
+	Interval *string
+}
+
+// GetPollAnalytics calls GET /api/polls/{pollID}/analytics: Get the vote time series of a poll.
+func (c *Client) GetPollAnalytics(ctx context.Context, pollID uuid.UUID, params *GetPollAnalyticsParams) (*PollAnalytics, error) {
+	req := request{method: "GET", path: "/api/polls/" + url.PathEscape(formatParam(pollID)) + "/analytics"}
+	if params != nil {
+		if params.Interval != nil {
+			req.setQuery("interval", *params.Interval)
+		}
+	}
+	var out PollAnalytics
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateBallots calls POST /api/polls/{pollID}/ballots: Issue single-use ballots for a ballot poll, as links or by email.
+func (c *Client) CreateBallots(ctx context.Context, pollID uuid.UUID, body CreateBallotsRequest) (*BallotsResponse, error) {
+	req := request{method: "POST", path: "/api/polls/" + url.PathEscape(formatParam(pollID)) + "/ballots", body: body}
+	var out BallotsResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetOtherAnswersParams are the query and header parameters of GetOtherAnswers.
+type GetOtherAnswersParams struct {
+	// Also list hidden answers.
+	IncludeHidden *bool
+}
+
+// GetOtherAnswers calls GET /api/polls/{pollID}/other-answers: List the free-text answers given with votes for the Other option.
+func (c *Client) GetOtherAnswers(ctx context.Context, pollID uuid.UUID, params *GetOtherAnswersParams) (*OtherAnswersResponse, error) {
+	req := request{method: "GET", path: "/api/polls/" + url.PathEscape(formatParam(pollID)) + "/other-answers"}
+	if params != nil {
+		if params.IncludeHidden != nil {
+			req.setQuery("includeHidden", *params.IncludeHidden)
+		}
+	}
+	var out OtherAnswersResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ModerateOtherAnswers calls POST /api/polls/{pollID}/other-answers/moderate: Hide, show or merge answers of the Other option into an existing option.
+func (c *Client) ModerateOtherAnswers(ctx context.Context, pollID uuid.UUID, body ModerateOtherAnswersRequest) (*ModerateOtherAnswersResponse, error) {
+	req := request{method: "POST", path: "/api/polls/" + url.PathEscape(formatParam(pollID)) + "/other-answers/moderate", body: body}
+	var out ModerateOtherAnswersResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPollTurnout calls GET /api/polls/{pollID}/turnout: Get the ballots of a poll and how many of them were used.
+func (c *Client) GetPollTurnout(ctx context.Context, pollID uuid.UUID) (*PollTurnout, error) {
+	req := request{method: "GET", path: "/api/polls/" + url.PathEscape(formatParam(pollID)) + "/turnout"}
+	var out PollTurnout
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// VoteOnPollParams are the query and header parameters of VoteOnPoll.
+type VoteOnPollParams struct {
+	XCFTurnstileToken string
+	// Passcode of a passcode protected poll.
+	XPollPasscode *string
+	// Ballot token of a ballot poll.
+	XBallotToken *string
+}
+
+// VoteOnPoll calls POST /api/polls/{pollID}/vote: Vote for an option of a poll.
+func (c *Client) VoteOnPoll(ctx context.Context, pollID uuid.UUID, params *VoteOnPollParams, body VoteOnPollRequest) (*MessageResponse, error) {
+	req := request{method: "POST", path: "/api/polls/" + url.PathEscape(formatParam(pollID)) + "/vote", body: body}
+	if params != nil {
+		req.setHeader("X-CF-Turnstile-Token", params.XCFTurnstileToken)
+		if params.XPollPasscode != nil {
+			req.setHeader("X-Poll-Passcode", *params.XPollPasscode)
+		}
+		if params.XBallotToken != nil {
+			req.setHeader("X-Ballot-Token", *params.XBallotToken)
+		}
+	}
+	var out MessageResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPollVoters calls GET /api/polls/{pollID}/voters: Export who voted on a poll and, if it is attributed, for what.
+func (c *Client) GetPollVoters(ctx context.Context, pollID uuid.UUID) (*PollVoters, error) {
+	req := request{method: "GET", path: "/api/polls/" + url.PathEscape(formatParam(pollID)) + "/voters"}
+	var out PollVoters
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUserWebhooks calls GET /api/webhooks: List the webhooks of the signed in user.
+func (c *Client) GetUserWebhooks(ctx context.Context) (*WebhooksResponse, error) {
+	req := request{method: "GET", path: "/api/webhooks"}
+	var out WebhooksResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateWebhook calls POST /api/webhooks: Subscribe a URL to events of the polls of the signed in user, or of a single poll. The secret signing the payloads is only returned here.
+func (c *Client) CreateWebhook(ctx context.Context, body CreateWebhookRequest) (*CreatedWebhook, error) {
+	req := request{method: "POST", path: "/api/webhooks", body: body}
+	var out CreatedWebhook
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteWebhook calls DELETE /api/webhooks/{webhookID}: Delete a webhook of the signed in user.
+func (c *Client) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) (*MessageResponse, error) {
+	req := request{method: "DELETE", path: "/api/webhooks/" + url.PathEscape(formatParam(webhookID))}
+	var out MessageResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetWebhookDeliveries calls GET /api/webhooks/{webhookID}/deliveries: List the latest deliveries of a webhook of the signed in user, newest first.
+func (c *Client) GetWebhookDeliveries(ctx context.Context, webhookID uuid.UUID) (*WebhookDeliveriesResponse, error) {
+	req := request{method: "GET", path: "/api/webhooks/" + url.PathEscape(formatParam(webhookID)) + "/deliveries"}
+	var out WebhookDeliveriesResponse
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TestWebhook calls POST /api/webhooks/{webhookID}/test: Send a webhook.test event to a webhook of the signed in user and return the delivery.
+func (c *Client) TestWebhook(ctx context.Context, webhookID uuid.UUID) (*WebhookDelivery, error) {
+	req := request{method: "POST", path: "/api/webhooks/" + url.PathEscape(formatParam(webhookID)) + "/test"}
+	var out WebhookDelivery
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,174 @@
+// Package client is a typed client of the Polly API. The types and methods in
+// client.gen.go are generated from the OpenAPI document of the server; run
+// go generate after changing the API.
+package client
+
+//go:generate go run ../cmd/genclient -o client.gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the API at a base URL such as https://polly.example.com.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	// Token is sent as the session cookie of authenticated requests. Signin
+	// and Me return it.
+	Token string
+}
+
+// New returns a client of the API at baseURL. httpClient may be nil to use
+// http.DefaultClient.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return fmt.Sprintf("%s (%d %s): %s", p.Title, p.Status, p.Type, p.Detail)
+	}
+	return fmt.Sprintf("%s (%d %s)", p.Title, p.Status, p.Type)
+}
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	// body is encoded as JSON, unless it is an io.Reader, which is sent as is
+	// with contentType.
+	body        any
+	contentType string
+}
+
+func (r *request) setQuery(name string, value any) {
+	if r.query == nil {
+		r.query = make(url.Values)
+	}
+	r.query.Set(name, formatParam(value))
+}
+
+func (r *request) setHeader(name string, value any) {
+	if r.header == nil {
+		r.header = make(http.Header)
+	}
+	r.header.Set(name, formatParam(value))
+}
+
+func formatParam(value any) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// do sends req and decodes a successful response into out, which may be nil.
+// An error response is returned as a *Problem.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	var (
+		body        io.Reader
+		contentType = "application/json"
+	)
+	switch b := req.body.(type) {
+	case nil:
+	case io.Reader:
+		body, contentType = b, req.contentType
+	default:
+		data, err := json.Marshal(req.body)
+		if err != nil {
+			return fmt.Errorf("error encoding request body: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return err
+	}
+
+	for name, values := range req.header {
+		httpReq.Header[name] = values
+	}
+	httpReq.Header.Set("Accept", "application/json, application/problem+json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != "" {
+		httpReq.AddCookie(&http.Cookie{Name: "token", Value: c.Token})
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeProblem(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	// Binary responses are returned as read.
+	if raw, ok := out.(*[]byte); ok {
+		if *raw, err = io.ReadAll(resp.Body); err != nil {
+			return fmt.Errorf("error reading %s %s response: %w", req.method, req.path, err)
+		}
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding %s %s response: %w", req.method, req.path, err)
+	}
+
+	return nil
+}
+
+// decodeProblem reads the problem details of an error response. Responses
+// that are not problem details, e.g. from a proxy, are described by their
+// status.
+func decodeProblem(resp *http.Response) error {
+	problem := &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(resp.StatusCode),
+		Status: resp.StatusCode,
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" {
+		if err := json.NewDecoder(resp.Body).Decode(problem); err != nil {
+			return fmt.Errorf("error decoding %d response: %w", resp.StatusCode, err)
+		}
+	}
+
+	return problem
+}
@@ -0,0 +1,451 @@
+// Command genclient generates the typed Go client in package client from the
+// OpenAPI document of the server.
+//
+//	go run ./cmd/genclient -o client/client.gen.go
+//
+// By default the document is built from package api; -spec reads a JSON
+// document instead, e.g. one downloaded from /api/openapi.json.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/toramanomer/polly/api"
+	"github.com/toramanomer/polly/openapi"
+)
+
+func main() {
+	var (
+		output   = flag.String("o", "client.gen.go", "output file")
+		spec     = flag.String("spec", "", "OpenAPI document to read instead of the built-in one")
+		pkg      = flag.String("package", "client", "package name of the generated file")
+		doc      = api.OpenAPI()
+		err      error
+		contents []byte
+	)
+	flag.Parse()
+
+	if *spec != "" {
+		doc, err = readDocument(*spec)
+		if err != nil {
+			log.Fatalf("Error reading %s: %v", *spec, err)
+		}
+	}
+
+	contents, err = generate(doc, *pkg)
+	if err != nil {
+		log.Fatalf("Error generating client: %v", err)
+	}
+
+	if err := os.WriteFile(*output, contents, 0o644); err != nil {
+		log.Fatalf("Error writing %s: %v", *output, err)
+	}
+}
+
+func readDocument(path string) (*openapi.Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc openapi.Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+type generator struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func generate(doc *openapi.Document, pkg string) ([]byte, error) {
+	g := &generator{imports: map[string]bool{"context": true}}
+
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := g.typeDecl(name, doc.Components.Schemas[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		item := *doc.Paths[path]
+
+		methods := make([]string, 0, len(item))
+		for method := range item {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			if err := g.operation(path, strings.ToUpper(method), item[method]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "// Code generated by genclient. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+
+	// Standard library imports first, like goimports.
+	imports := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Slice(imports, func(i, j int) bool {
+		iStd, jStd := !strings.Contains(imports[i], "."), !strings.Contains(imports[j], ".")
+		if iStd != jStd {
+			return iStd
+		}
+		return imports[i] < imports[j]
+	})
+	for i, path := range imports {
+		if i > 0 && strings.Contains(path, ".") && !strings.Contains(imports[i-1], ".") {
+			file.WriteString("\n")
+		}
+		fmt.Fprintf(&file, "\t%q\n", path)
+	}
+	file.WriteString(")\n")
+	file.Write(g.buf.Bytes())
+
+	return format.Source(file.Bytes())
+}
+
+// exported turns a JSON or operation name into an exported Go identifier,
+// e.g. pollID -> PollID, requestId -> RequestID, url -> URL and
+// X-CF-Turnstile-Token -> XCFTurnstileToken.
+func exported(name string) string {
+	var b strings.Builder
+
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+
+	ident := b.String()
+	for _, initialism := range []string{"ID", "URL"} {
+		mixed := initialism[:1] + strings.ToLower(initialism[1:])
+		if strings.HasSuffix(ident, mixed) {
+			return strings.TrimSuffix(ident, mixed) + initialism
+		}
+	}
+	return ident
+}
+
+// goType returns the Go type of a schema.
+func (g *generator) goType(s *openapi.Schema) (string, error) {
+	if s.Ref != "" {
+		return strings.TrimPrefix(s.Ref, "#/components/schemas/"), nil
+	}
+
+	if len(s.AnyOf) == 2 && len(s.AnyOf[1].Types()) == 1 && s.AnyOf[1].Types()[0] == "null" {
+		inner, err := g.goType(s.AnyOf[0])
+		return "*" + inner, err
+	}
+
+	var (
+		types    = s.Types()
+		nullable = false
+		kind     string
+	)
+	for _, t := range types {
+		if t == "null" {
+			nullable = true
+		} else {
+			kind = t
+		}
+	}
+
+	var (
+		goType string
+		err    error
+	)
+
+	switch kind {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			goType = "time.Time"
+		case "uuid":
+			g.imports["github.com/google/uuid"] = true
+			goType = "uuid.UUID"
+		default:
+			goType = "string"
+		}
+	case "integer":
+		goType = "int"
+	case "number":
+		goType = "float64"
+	case "boolean":
+		goType = "bool"
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("array schema without items")
+		}
+		goType, err = g.goType(s.Items)
+		goType = "[]" + goType
+	case "object":
+		if s.AdditionalProperties == nil {
+			goType = "map[string]any"
+			break
+		}
+		goType, err = g.goType(s.AdditionalProperties)
+		goType = "map[string]" + goType
+	case "":
+		goType = "any"
+	default:
+		return "", fmt.Errorf("unsupported schema type %q", kind)
+	}
+
+	if nullable && goType != "any" {
+		goType = "*" + goType
+	}
+
+	return goType, err
+}
+
+func (g *generator) typeDecl(name string, s *openapi.Schema) error {
+	if len(s.Types()) != 1 || s.Types()[0] != "object" || s.Properties == nil {
+		goType, err := g.goType(s)
+		if err != nil {
+			return fmt.Errorf("schema %s: %w", name, err)
+		}
+		g.printf("\ntype %s %s\n", name, goType)
+		return nil
+	}
+
+	required := make(map[string]bool, len(s.Required))
+	for _, property := range s.Required {
+		required[property] = true
+	}
+
+	properties := make([]string, 0, len(s.Properties))
+	for property := range s.Properties {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+
+	g.printf("\n")
+	if s.Description != "" {
+		g.printf("// %s\n", s.Description)
+	}
+	g.printf("type %s struct {\n", name)
+	for _, property := range properties {
+		goType, err := g.goType(s.Properties[property])
+		if err != nil {
+			return fmt.Errorf("schema %s, property %s: %w", name, property, err)
+		}
+
+		tag := property
+		if !required[property] {
+			tag += ",omitempty"
+		}
+		g.printf("\t%s %s `json:%q`\n", exported(property), goType, tag)
+	}
+	g.printf("}\n")
+
+	return nil
+}
+
+// isBinary reports whether content has only binary media types, e.g. images.
+func isBinary(content map[string]openapi.MediaType) bool {
+	for _, media := range content {
+		if media.Schema == nil || media.Schema.Format != "binary" {
+			return false
+		}
+	}
+	return len(content) > 0
+}
+
+func (g *generator) operation(path, method string, op *openapi.Operation) error {
+	var (
+		name    = exported(op.OperationID)
+		args    = []string{"ctx context.Context"}
+		options []openapi.Parameter
+		pathArg = make(map[string]string)
+	)
+
+	for _, param := range op.Parameters {
+		if param.In != openapi.InPath {
+			options = append(options, param)
+			continue
+		}
+
+		goType, err := g.goType(param.Schema)
+		if err != nil {
+			return fmt.Errorf("operation %s, parameter %s: %w", op.OperationID, param.Name, err)
+		}
+		args = append(args, param.Name+" "+goType)
+		pathArg[param.Name] = param.Name
+	}
+
+	if len(options) > 0 {
+		g.printf("\n// %sParams are the query and header parameters of %s.\n", name, name)
+		g.printf("type %sParams struct {\n", name)
+		for _, param := range options {
+			goType, err := g.goType(param.Schema)
+			if err != nil {
+				return fmt.Errorf("operation %s, parameter %s: %w", op.OperationID, param.Name, err)
+			}
+			if !param.Required {
+				goType = "*" + goType
+			}
+			if param.Description != "" {
+				g.printf("\t// %s\n", param.Description)
+			}
+			g.printf("\t%s %s\n", exported(param.Name), goType)
+		}
+		g.printf("}\n")
+		args = append(args, "params *"+name+"Params")
+	}
+
+	var body string
+	switch {
+	case op.RequestBody == nil:
+	case isBinary(op.RequestBody.Content):
+		// Binary bodies are streamed as given, with their content type.
+		g.imports["io"] = true
+		args = append(args, "body io.Reader", "contentType string")
+		body = "body, contentType: contentType"
+	default:
+		media, ok := op.RequestBody.Content["application/json"]
+		if !ok {
+			return fmt.Errorf("operation %s: request body is not JSON", op.OperationID)
+		}
+		goType, err := g.goType(media.Schema)
+		if err != nil {
+			return fmt.Errorf("operation %s, request body: %w", op.OperationID, err)
+		}
+		args = append(args, "body "+goType)
+		body = "body"
+	}
+
+	var (
+		result string
+		binary bool
+	)
+	for status, response := range op.Responses {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		if isBinary(response.Content) {
+			result, binary = "[]byte", true
+			continue
+		}
+		if media, ok := response.Content["application/json"]; ok {
+			goType, err := g.goType(media.Schema)
+			if err != nil {
+				return fmt.Errorf("operation %s, response: %w", op.OperationID, err)
+			}
+			result = goType
+		}
+	}
+
+	// Build the path expression, escaping every path parameter.
+	var (
+		segments []string
+		literal  string
+	)
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			literal += "/" + segment
+			continue
+		}
+
+		param, ok := pathArg[strings.Trim(segment, "{}")]
+		if !ok {
+			return fmt.Errorf("operation %s: path parameter %s is not described", op.OperationID, segment)
+		}
+
+		g.imports["net/url"] = true
+		segments = append(segments, fmt.Sprintf("%q", literal+"/"), "url.PathEscape(formatParam("+param+"))")
+		literal = ""
+	}
+	if literal != "" {
+		segments = append(segments, fmt.Sprintf("%q", literal))
+	}
+
+	if op.Summary != "" {
+		g.printf("\n// %s calls %s %s: %s.\n", name, method, path, strings.TrimSuffix(op.Summary, "."))
+	} else {
+		g.printf("\n// %s calls %s %s.\n", name, method, path)
+	}
+
+	if binary {
+		g.printf("func (c *Client) %s(%s) ([]byte, error) {\n", name, strings.Join(args, ", "))
+	} else if result != "" {
+		g.printf("func (c *Client) %s(%s) (*%s, error) {\n", name, strings.Join(args, ", "), result)
+	} else {
+		g.printf("func (c *Client) %s(%s) error {\n", name, strings.Join(args, ", "))
+	}
+
+	if body != "" {
+		g.printf("\treq := request{method: %q, path: %s, body: %s}\n", method, strings.Join(segments, " + "), body)
+	} else {
+		g.printf("\treq := request{method: %q, path: %s}\n", method, strings.Join(segments, " + "))
+	}
+
+	if len(options) > 0 {
+		g.printf("\tif params != nil {\n")
+		for _, param := range options {
+			var (
+				field = "params." + exported(param.Name)
+				set   = "setQuery"
+			)
+			if param.In == openapi.InHeader {
+				set = "setHeader"
+			}
+			if param.Required {
+				g.printf("\t\treq.%s(%q, %s)\n", set, param.Name, field)
+			} else {
+				g.printf("\t\tif %s != nil {\n\t\t\treq.%s(%q, *%s)\n\t\t}\n", field, set, param.Name, field)
+			}
+		}
+		g.printf("\t}\n")
+	}
+
+	if binary {
+		g.printf("\tvar out []byte\n")
+		g.printf("\tif err := c.do(ctx, req, &out); err != nil {\n\t\treturn nil, err\n\t}\n")
+		g.printf("\treturn out, nil\n}\n")
+	} else if result != "" {
+		g.printf("\tvar out %s\n", result)
+		g.printf("\tif err := c.do(ctx, req, &out); err != nil {\n\t\treturn nil, err\n\t}\n")
+		g.printf("\treturn &out, nil\n}\n")
+	} else {
+		g.printf("\treturn c.do(ctx, req, nil)\n}\n")
+	}
+
+	return nil
+}
@@ -0,0 +1,558 @@
+// Package config loads the server configuration.
+//
+// Every setting can be given as a command line flag, as an environment
+// variable or in a configuration file, in that order of precedence. The file
+// uses the .env format and is optional unless it is named explicitly.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultFile = ".env"
+
+// maxPollOptions is the most options the schema allows a poll, as
+// repository.MaxPollOptions.
+const maxPollOptions = 50
+
+type Config struct {
+	// Addr is the address the HTTP server listens on.
+	Addr string
+	// MetricsAddr is the address /metrics is served on, apart from Addr so
+	// that it is not reachable wherever the API is. Empty disables it.
+	MetricsAddr string
+	// DatabaseURL is the Postgres connection string.
+	DatabaseURL string
+	// JWTSymmetricKey signs and verifies session tokens.
+	JWTSymmetricKey string
+	// TurnstileSecretKey verifies Cloudflare Turnstile tokens on votes.
+	TurnstileSecretKey string
+
+	// LogLevel is the minimum level of the JSON logs.
+	LogLevel slog.Level
+
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set. The files
+	// are re-read whenever they change on disk.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// ReadTimeout, ReadHeaderTimeout, WriteTimeout and IdleTimeout bound the
+	// phases of a single HTTP request or idle connection.
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout is how long in-flight requests and buffered votes are
+	// given to complete after a termination signal.
+	ShutdownTimeout time.Duration
+	// MaxBodyBytes is the largest accepted request body.
+	MaxBodyBytes int64
+
+	// MinPollOptions and MaxPollOptions bound the number of options of a
+	// poll, the Other option included. PlanMaxPollOptions overrides the
+	// maximum for users on a plan.
+	MinPollOptions     int
+	MaxPollOptions     int
+	PlanMaxPollOptions map[string]int
+	// MaxPollDuration is the longest a poll may run.
+	MaxPollDuration time.Duration
+
+	// BlobDir is the directory uploaded images are stored in.
+	BlobDir string
+	// MaxImageBytes is the largest accepted image upload.
+	MaxImageBytes int64
+	// MaxUserImageBytes is the most the images of a single user may take up
+	// in total.
+	MaxUserImageBytes int64
+	// ImageSweepInterval is the period of the job deleting the images no
+	// poll option uses.
+	ImageSweepInterval time.Duration
+
+	// VoteIngestion enables the buffered vote ingester.
+	VoteIngestion bool
+	// PollCache is where poll definitions are cached: lru keeps them in the
+	// memory of every instance, redis shares them through the Redis server
+	// at RedisAddr.
+	PollCache string
+	RedisAddr string
+	// PollCacheCapacity is the number of poll definitions kept in memory by
+	// the lru cache.
+	PollCacheCapacity int
+	// PollCacheTTL is how long a cached poll definition is served.
+	PollCacheTTL time.Duration
+	// VoteCountReconcileInterval is the period of the vote counter
+	// reconciliation job.
+	VoteCountReconcileInterval time.Duration
+	// PollSweepInterval is the period of the job closing expired polls.
+	PollSweepInterval time.Duration
+
+	// PublicURL is the address the web app is reached at, used in links
+	// sent to users such as ballot invitations.
+	PublicURL string
+	// SMTPAddr is the host:port of the SMTP server emails are sent through.
+	// Without it emails are only logged.
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	// MailFrom is the sender address of emails.
+	MailFrom string
+
+	// WebhookAllowPrivateNetworks lets webhooks reach loopback and private
+	// addresses, e.g. a receiver on the same machine during development.
+	WebhookAllowPrivateNetworks bool
+
+	// SlackSigningSecret verifies the requests of the Slack app. Without it,
+	// every Slack request is rejected.
+	SlackSigningSecret string
+
+	// TracingExporter is where spans are sent: none, stdout or otlp. The
+	// OTLP endpoint is set with the standard OTEL_EXPORTER_OTLP_* variables.
+	TracingExporter string
+	// TracingSampleRatio is the fraction of new traces that are recorded.
+	TracingSampleRatio float64
+
+	// Args are the command line arguments left after the flags, e.g. the
+	// migrate subcommand.
+	Args []string
+}
+
+// setting describes one configuration value and where it can be set.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	// set parses raw into the config.
+	set func(c *Config, raw string) error
+}
+
+func stringSetting(target func(c *Config) *string) func(c *Config, raw string) error {
+	return func(c *Config, raw string) error {
+		*target(c) = raw
+		return nil
+	}
+}
+
+func intSetting(target func(c *Config) *int) func(c *Config, raw string) error {
+	return func(c *Config, raw string) error {
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		*target(c) = value
+		return nil
+	}
+}
+
+func int64Setting(target func(c *Config) *int64) func(c *Config, raw string) error {
+	return func(c *Config, raw string) error {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		*target(c) = value
+		return nil
+	}
+}
+
+func logLevelSetting(target func(c *Config) *slog.Level) func(c *Config, raw string) error {
+	return func(c *Config, raw string) error {
+		if err := target(c).UnmarshalText([]byte(raw)); err != nil {
+			return fmt.Errorf("%q is not one of debug, info, warn or error", raw)
+		}
+		return nil
+	}
+}
+
+func boolSetting(target func(c *Config) *bool) func(c *Config, raw string) error {
+	return func(c *Config, raw string) error {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		*target(c) = value
+		return nil
+	}
+}
+
+func float64Setting(target func(c *Config) *float64) func(c *Config, raw string) error {
+	return func(c *Config, raw string) error {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		*target(c) = value
+		return nil
+	}
+}
+
+// planLimitsSetting parses a list of plan=limit pairs, e.g. pro=20,team=50.
+func planLimitsSetting(target func(c *Config) *map[string]int) func(c *Config, raw string) error {
+	return func(c *Config, raw string) error {
+		limits := make(map[string]int)
+		for _, pair := range strings.Split(raw, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+
+			plan, rawLimit, ok := strings.Cut(pair, "=")
+			plan = strings.TrimSpace(plan)
+			if !ok || plan == "" {
+				return fmt.Errorf("%q is not a plan=limit pair", pair)
+			}
+
+			limit, err := strconv.Atoi(strings.TrimSpace(rawLimit))
+			if err != nil {
+				return fmt.Errorf("limit of plan %q: %q is not a whole number", plan, rawLimit)
+			}
+			limits[plan] = limit
+		}
+		*target(c) = limits
+		return nil
+	}
+}
+
+func durationSetting(target func(c *Config) *time.Duration) func(c *Config, raw string) error {
+	return func(c *Config, raw string) error {
+		value, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", raw)
+		}
+		*target(c) = value
+		return nil
+	}
+}
+
+var settings = []setting{
+	{"addr", "ADDR", "HTTP listen address",
+		stringSetting(func(c *Config) *string { return &c.Addr })},
+	{"metrics-addr", "METRICS_ADDR", "listen address of the Prometheus metrics, disabled if empty",
+		stringSetting(func(c *Config) *string { return &c.MetricsAddr })},
+	{"database-url", "DATABASE_URL", "Postgres connection string",
+		stringSetting(func(c *Config) *string { return &c.DatabaseURL })},
+	{"jwt-symmetric-key", "JWT_SYMMETRIC_KEY", "key signing session tokens",
+		stringSetting(func(c *Config) *string { return &c.JWTSymmetricKey })},
+	{"turnstile-secret-key", "TURNSTILE_SECRET_KEY", "Cloudflare Turnstile secret key",
+		stringSetting(func(c *Config) *string { return &c.TurnstileSecretKey })},
+	{"log-level", "LOG_LEVEL", "minimum log level",
+		logLevelSetting(func(c *Config) *slog.Level { return &c.LogLevel })},
+	{"tls-cert-file", "TLS_CERT_FILE", "TLS certificate file",
+		stringSetting(func(c *Config) *string { return &c.TLSCertFile })},
+	{"tls-key-file", "TLS_KEY_FILE", "TLS private key file",
+		stringSetting(func(c *Config) *string { return &c.TLSKeyFile })},
+	{"read-timeout", "READ_TIMEOUT", "maximum duration for reading a request",
+		durationSetting(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+	{"read-header-timeout", "READ_HEADER_TIMEOUT", "maximum duration for reading request headers",
+		durationSetting(func(c *Config) *time.Duration { return &c.ReadHeaderTimeout })},
+	{"write-timeout", "WRITE_TIMEOUT", "maximum duration for writing a response",
+		durationSetting(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+	{"idle-timeout", "IDLE_TIMEOUT", "maximum duration of an idle keep-alive connection",
+		durationSetting(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+	{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "grace period for draining on shutdown",
+		durationSetting(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{"max-body-bytes", "MAX_BODY_BYTES", "largest accepted request body in bytes",
+		int64Setting(func(c *Config) *int64 { return &c.MaxBodyBytes })},
+	{"min-poll-options", "MIN_POLL_OPTIONS", "fewest options of a poll",
+		intSetting(func(c *Config) *int { return &c.MinPollOptions })},
+	{"max-poll-options", "MAX_POLL_OPTIONS", "most options of a poll",
+		intSetting(func(c *Config) *int { return &c.MaxPollOptions })},
+	{"plan-max-poll-options", "PLAN_MAX_POLL_OPTIONS", "most options of a poll by plan, e.g. pro=20,team=50",
+		planLimitsSetting(func(c *Config) *map[string]int { return &c.PlanMaxPollOptions })},
+	{"max-poll-duration", "MAX_POLL_DURATION", "longest a poll may run",
+		durationSetting(func(c *Config) *time.Duration { return &c.MaxPollDuration })},
+	{"blob-dir", "BLOB_DIR", "directory uploaded images are stored in",
+		stringSetting(func(c *Config) *string { return &c.BlobDir })},
+	{"max-image-bytes", "MAX_IMAGE_BYTES", "largest accepted image upload in bytes",
+		int64Setting(func(c *Config) *int64 { return &c.MaxImageBytes })},
+	{"max-user-image-bytes", "MAX_USER_IMAGE_BYTES", "most bytes the images of a user may take up",
+		int64Setting(func(c *Config) *int64 { return &c.MaxUserImageBytes })},
+	{"image-sweep-interval", "IMAGE_SWEEP_INTERVAL", "period of the job deleting unused images",
+		durationSetting(func(c *Config) *time.Duration { return &c.ImageSweepInterval })},
+	{"vote-ingestion", "VOTE_INGESTION_ENABLED", "buffer votes and write them in batches",
+		boolSetting(func(c *Config) *bool { return &c.VoteIngestion })},
+	{"poll-cache", "POLL_CACHE", "where poll definitions are cached: lru or redis",
+		stringSetting(func(c *Config) *string { return &c.PollCache })},
+	{"redis-addr", "REDIS_ADDR", "Redis server host:port of the redis poll cache",
+		stringSetting(func(c *Config) *string { return &c.RedisAddr })},
+	{"poll-cache-capacity", "POLL_CACHE_CAPACITY", "number of cached poll definitions",
+		intSetting(func(c *Config) *int { return &c.PollCacheCapacity })},
+	{"poll-cache-ttl", "POLL_CACHE_TTL", "lifetime of a cached poll definition",
+		durationSetting(func(c *Config) *time.Duration { return &c.PollCacheTTL })},
+	{"vote-count-reconcile-interval", "VOTE_COUNT_RECONCILE_INTERVAL", "period of the vote counter reconciliation",
+		durationSetting(func(c *Config) *time.Duration { return &c.VoteCountReconcileInterval })},
+	{"poll-sweep-interval", "POLL_SWEEP_INTERVAL", "period of the job closing expired polls",
+		durationSetting(func(c *Config) *time.Duration { return &c.PollSweepInterval })},
+	{"public-url", "PUBLIC_URL", "address the web app is reached at",
+		stringSetting(func(c *Config) *string { return &c.PublicURL })},
+	{"smtp-addr", "SMTP_ADDR", "SMTP server host:port, emails are logged without it",
+		stringSetting(func(c *Config) *string { return &c.SMTPAddr })},
+	{"smtp-username", "SMTP_USERNAME", "SMTP user name",
+		stringSetting(func(c *Config) *string { return &c.SMTPUsername })},
+	{"smtp-password", "SMTP_PASSWORD", "SMTP password",
+		stringSetting(func(c *Config) *string { return &c.SMTPPassword })},
+	{"mail-from", "MAIL_FROM", "sender address of emails",
+		stringSetting(func(c *Config) *string { return &c.MailFrom })},
+	{"webhook-allow-private-networks", "WEBHOOK_ALLOW_PRIVATE_NETWORKS", "let webhooks reach loopback and private addresses",
+		boolSetting(func(c *Config) *bool { return &c.WebhookAllowPrivateNetworks })},
+	{"slack-signing-secret", "SLACK_SIGNING_SECRET", "signing secret of the Slack app",
+		stringSetting(func(c *Config) *string { return &c.SlackSigningSecret })},
+	{"tracing-exporter", "TRACING_EXPORTER", "where spans are sent: none, stdout or otlp",
+		stringSetting(func(c *Config) *string { return &c.TracingExporter })},
+	{"tracing-sample-ratio", "TRACING_SAMPLE_RATIO", "fraction of new traces that are recorded",
+		float64Setting(func(c *Config) *float64 { return &c.TracingSampleRatio })},
+}
+
+func defaults() *Config {
+	return &Config{
+		Addr:                       ":8000",
+		MetricsAddr:                "localhost:9090",
+		ReadTimeout:                10 * time.Second,
+		ReadHeaderTimeout:          5 * time.Second,
+		WriteTimeout:               30 * time.Second,
+		IdleTimeout:                2 * time.Minute,
+		ShutdownTimeout:            30 * time.Second,
+		MaxBodyBytes:               1 << 20,
+		MinPollOptions:             2,
+		MaxPollOptions:             6,
+		MaxPollDuration:            90 * 24 * time.Hour,
+		BlobDir:                    "data/blobs",
+		MaxImageBytes:              2 << 20,
+		MaxUserImageBytes:          50 << 20,
+		ImageSweepInterval:         time.Hour,
+		PollCache:                  "lru",
+		PollCacheCapacity:          10000,
+		PollCacheTTL:               5 * time.Minute,
+		VoteCountReconcileInterval: time.Hour,
+		PollSweepInterval:          time.Minute,
+		PublicURL:                  "http://localhost",
+		TracingExporter:            "none",
+		TracingSampleRatio:         1,
+	}
+}
+
+// Load builds the configuration from the command line arguments (without the
+// program name), the environment and the configuration file, and validates
+// it.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	var (
+		flags      = flag.NewFlagSet("polly", flag.ContinueOnError)
+		configFile = flags.String("config", "", "configuration file (default "+defaultFile+" if present)")
+		flagValues = make(map[string]*string, len(settings))
+	)
+
+	flags.SetOutput(io.Discard)
+	for _, s := range settings {
+		flagValues[s.flag] = flags.String(s.flag, "", s.usage+" ($"+s.env+")")
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	path, required := *configFile, true
+	if path == "" {
+		path, required = defaultFile, false
+	}
+
+	fileValues, err := readFile(path, lookupEnv)
+	if err != nil && (required || !errors.Is(err, os.ErrNotExist)) {
+		return nil, err
+	}
+
+	var (
+		config = defaults()
+		errs   []error
+		setBy  = make(map[string]bool)
+	)
+
+	flags.Visit(func(f *flag.Flag) { setBy[f.Name] = true })
+
+	for _, s := range settings {
+		var (
+			raw    string
+			source string
+		)
+
+		if setBy[s.flag] {
+			raw, source = *flagValues[s.flag], "flag -"+s.flag
+		} else if value, ok := lookupEnv(s.env); ok {
+			raw, source = value, "environment variable "+s.env
+		} else if value, ok := fileValues[s.env]; ok {
+			raw, source = value, s.env+" in "+path
+		} else {
+			continue
+		}
+
+		if err := s.set(config, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source, err))
+		}
+	}
+
+	config.Args = flags.Args()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// MaxPollOptionsFor returns the most options a poll of a user on plan may
+// have.
+func (c *Config) MaxPollOptionsFor(plan string) int {
+	if limit, ok := c.PlanMaxPollOptions[plan]; ok {
+		return limit
+	}
+	return c.MaxPollOptions
+}
+
+// Validate reports every missing or invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.DatabaseURL == "" {
+		errs = append(errs, errors.New("DATABASE_URL is required"))
+	}
+
+	if c.JWTSymmetricKey == "" {
+		errs = append(errs, errors.New("JWT_SYMMETRIC_KEY is required"))
+	}
+
+	if c.TurnstileSecretKey == "" {
+		errs = append(errs, errors.New("TURNSTILE_SECRET_KEY is required"))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("ADDR %q is not a valid listen address", c.Addr))
+	}
+
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			errs = append(errs, fmt.Errorf("METRICS_ADDR %q is not a valid listen address", c.MetricsAddr))
+		} else if c.MetricsAddr == c.Addr {
+			errs = append(errs, errors.New("METRICS_ADDR must differ from ADDR"))
+		}
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"READ_TIMEOUT", c.ReadTimeout},
+		{"READ_HEADER_TIMEOUT", c.ReadHeaderTimeout},
+		{"WRITE_TIMEOUT", c.WriteTimeout},
+		{"IDLE_TIMEOUT", c.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+	} {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", timeout.name))
+		}
+	}
+
+	if c.MaxBodyBytes < 1 {
+		errs = append(errs, errors.New("MAX_BODY_BYTES must be positive"))
+	}
+
+	if c.MinPollOptions < 2 {
+		errs = append(errs, errors.New("MIN_POLL_OPTIONS must be at least 2"))
+	}
+
+	if c.MaxPollOptions < c.MinPollOptions || c.MaxPollOptions > maxPollOptions {
+		errs = append(errs, fmt.Errorf("MAX_POLL_OPTIONS must be between MIN_POLL_OPTIONS and %d", maxPollOptions))
+	}
+
+	for plan, limit := range c.PlanMaxPollOptions {
+		if limit < c.MinPollOptions || limit > maxPollOptions {
+			errs = append(errs, fmt.Errorf("PLAN_MAX_POLL_OPTIONS of plan %q must be between MIN_POLL_OPTIONS and %d", plan, maxPollOptions))
+		}
+	}
+
+	if c.MaxPollDuration < time.Hour {
+		errs = append(errs, errors.New("MAX_POLL_DURATION must be at least 1h"))
+	}
+
+	if c.BlobDir == "" {
+		errs = append(errs, errors.New("BLOB_DIR is required"))
+	}
+
+	if c.MaxImageBytes < 1 {
+		errs = append(errs, errors.New("MAX_IMAGE_BYTES must be positive"))
+	}
+
+	if c.MaxUserImageBytes < c.MaxImageBytes {
+		errs = append(errs, errors.New("MAX_USER_IMAGE_BYTES must be at least MAX_IMAGE_BYTES"))
+	}
+
+	if c.ImageSweepInterval <= 0 {
+		errs = append(errs, errors.New("IMAGE_SWEEP_INTERVAL must be positive"))
+	}
+
+	switch c.PollCache {
+	case "lru":
+	case "redis":
+		if _, _, err := net.SplitHostPort(c.RedisAddr); err != nil {
+			errs = append(errs, fmt.Errorf("REDIS_ADDR %q is not a valid host:port", c.RedisAddr))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("POLL_CACHE %q is not one of lru or redis", c.PollCache))
+	}
+
+	if c.PollCacheCapacity < 1 {
+		errs = append(errs, errors.New("POLL_CACHE_CAPACITY must be positive"))
+	}
+
+	if c.PollCacheTTL <= 0 {
+		errs = append(errs, errors.New("POLL_CACHE_TTL must be positive"))
+	}
+
+	if c.VoteCountReconcileInterval <= 0 {
+		errs = append(errs, errors.New("VOTE_COUNT_RECONCILE_INTERVAL must be positive"))
+	}
+
+	if c.PollSweepInterval <= 0 {
+		errs = append(errs, errors.New("POLL_SWEEP_INTERVAL must be positive"))
+	}
+
+	if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("PUBLIC_URL %q is not an absolute http or https URL", c.PublicURL))
+	}
+
+	if c.SMTPAddr != "" {
+		if _, _, err := net.SplitHostPort(c.SMTPAddr); err != nil {
+			errs = append(errs, fmt.Errorf("SMTP_ADDR %q is not a valid host:port", c.SMTPAddr))
+		}
+		if c.MailFrom == "" {
+			errs = append(errs, errors.New("MAIL_FROM is required with SMTP_ADDR"))
+		}
+	}
+
+	switch c.TracingExporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER %q is not one of none, stdout or otlp", c.TracingExporter))
+	}
+
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		errs = append(errs, errors.New("TRACING_SAMPLE_RATIO must be between 0 and 1"))
+	}
+
+	return errors.Join(errs...)
+}
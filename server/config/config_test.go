@@ -0,0 +1,92 @@
+package config
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, strings.Join([]string{
+		"DATABASE_URL=postgres://file",
+		"JWT_SYMMETRIC_KEY=file-key",
+		"TURNSTILE_SECRET_KEY=file-secret",
+		"ADDR=:1",
+		"READ_TIMEOUT=1s",
+		"LOG_LEVEL=debug",
+	}, "\n"))
+
+	env := map[string]string{
+		"ADDR":         ":2",
+		"READ_TIMEOUT": "2s",
+	}
+
+	config, err := Load([]string{"-config", path, "-addr", ":3", "rest"}, lookupIn(env))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if config.Addr != ":3" {
+		t.Errorf("Addr: got %q, want the flag", config.Addr)
+	}
+	if config.ReadTimeout != 2*time.Second {
+		t.Errorf("ReadTimeout: got %v, want the environment variable", config.ReadTimeout)
+	}
+	if config.DatabaseURL != "postgres://file" || config.LogLevel != slog.LevelDebug {
+		t.Errorf("DatabaseURL and LogLevel: got %q and %v, want the file", config.DatabaseURL, config.LogLevel)
+	}
+	if config.WriteTimeout != defaults().WriteTimeout {
+		t.Errorf("WriteTimeout: got %v, want the default", config.WriteTimeout)
+	}
+	if len(config.Args) != 1 || config.Args[0] != "rest" {
+		t.Errorf("Args: got %q, want the arguments after the flags", config.Args)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	path := writeFile(t, "READ_TIMEOUT=soon\nMAX_BODY_BYTES=0\n")
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "invalid values name their source",
+			args: []string{"-config", path, "-write-timeout", "later"},
+			want: []string{"READ_TIMEOUT in " + path, "flag -write-timeout"},
+		},
+		{
+			name: "every invalid setting is reported",
+			args: []string{"-config", path},
+			env:  map[string]string{"READ_TIMEOUT": "1s"},
+			want: []string{"DATABASE_URL is required", "JWT_SYMMETRIC_KEY is required", "MAX_BODY_BYTES must be positive"},
+		},
+		{
+			name: "missing config file",
+			args: []string{"-config", path + ".missing"},
+			want: []string{"error opening config file"},
+		},
+		{
+			name: "unknown flag",
+			args: []string{"-unknown"},
+			want: []string{"invalid arguments"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.args, lookupIn(tt.env))
+			if err == nil {
+				t.Fatal("Load: got no error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load: got %q, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
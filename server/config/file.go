@@ -0,0 +1,153 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readFile parses a .env style file. Each line holds NAME=value, optionally
+// prefixed with "export". Values may be wrapped in single quotes, taken
+// literally, or double quotes, which understand \n, \t, \", \\ and \$ escapes.
+// ${NAME} in unquoted and double-quoted values expands to a variable defined
+// earlier in the file or, failing that, in the environment; \$ is a literal $
+// in both.
+func readFile(path string, lookupEnv func(string) (string, bool)) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening config file: %w", err)
+	}
+	defer file.Close()
+
+	var (
+		values  = make(map[string]string)
+		scanner = bufio.NewScanner(file)
+		lineNo  = 0
+	)
+
+	lookup := func(name string) (string, bool) {
+		if value, ok := values[name]; ok {
+			return value, true
+		}
+		return lookupEnv(name)
+	}
+
+	for scanner.Scan() {
+		lineNo++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		name, raw, found := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("%s:%d: expected NAME=value", path, lineNo)
+		}
+
+		value, err := parseValue(strings.TrimSpace(raw), lookup)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %w", path, lineNo, name, err)
+		}
+
+		values[name] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	return values, nil
+}
+
+func parseValue(raw string, lookup func(string) (string, bool)) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated single quote")
+		}
+		if rest := strings.TrimSpace(raw[end+2:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected text after closing quote")
+		}
+		return raw[1 : end+1], nil
+
+	case strings.HasPrefix(raw, `"`):
+		var value strings.Builder
+		for i := 1; i < len(raw); i++ {
+			switch c := raw[i]; c {
+			case '\\':
+				if i+1 == len(raw) {
+					return "", fmt.Errorf("unterminated double quote")
+				}
+				i++
+				switch raw[i] {
+				case 'n':
+					value.WriteByte('\n')
+				case 't':
+					value.WriteByte('\t')
+				default:
+					value.WriteByte(raw[i])
+				}
+			case '$':
+				i = expand(raw, i, lookup, &value)
+			case '"':
+				if rest := strings.TrimSpace(raw[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+					return "", fmt.Errorf("unexpected text after closing quote")
+				}
+				return value.String(), nil
+			default:
+				value.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated double quote")
+
+	default:
+		if i := strings.Index(raw, " #"); i != -1 {
+			raw = strings.TrimSpace(raw[:i])
+		}
+
+		var value strings.Builder
+		for i := 0; i < len(raw); i++ {
+			switch c := raw[i]; {
+			case c == '\\' && strings.HasPrefix(raw[i+1:], "$"):
+				i++
+				value.WriteByte('$')
+			case c == '$':
+				i = expand(raw, i, lookup, &value)
+			default:
+				value.WriteByte(c)
+			}
+		}
+		return value.String(), nil
+	}
+}
+
+// expand writes the value of the ${NAME} reference at raw[i] and returns the
+// index of its closing brace. Unknown variables expand to the empty string,
+// like in a shell. A $ starting no reference is written as is.
+func expand(raw string, i int, lookup func(string) (string, bool), value *strings.Builder) int {
+	end := strings.IndexByte(raw[i:], '}')
+	if !strings.HasPrefix(raw[i:], "${") || end == -1 || !validName(raw[i+2:i+end]) {
+		value.WriteByte('$')
+		return i
+	}
+
+	replacement, _ := lookup(raw[i+2 : i+end])
+	value.WriteString(replacement)
+
+	return i + end
+}
+
+func validName(name string) bool {
+	for i, c := range name {
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && (i == 0 || !('0' <= c && c <= '9')) {
+			return false
+		}
+	}
+	return name != ""
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	return path
+}
+
+func lookupIn(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	env := map[string]string{"HOME": "/home/alice", "EMPTY": ""}
+
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"unquoted", `A=hello world`, "hello world"},
+		{"export", `export A=hello`, "hello"},
+		{"spaces around", `  A =  hello  `, "hello"},
+		{"empty", `A=`, ""},
+		{"comment", `A=hello # greeting`, "hello"},
+		{"hash in value", `A=a#b`, "a#b"},
+		{"single quoted", `A='hello # ${HOME} \n'`, `hello # ${HOME} \n`},
+		{"single quoted with comment", `A='hello' # greeting`, "hello"},
+		{"double quoted", `A="hello # world"`, "hello # world"},
+		{"double quoted with comment", `A="hello" # greeting`, "hello"},
+		{"escapes", `A="a\nb\tc\"d\\e"`, "a\nb\tc\"d\\e"},
+		{"unquoted backslash", `A=C:\temp`, `C:\temp`},
+		{"variable", `A=${HOME}/polly`, "/home/alice/polly"},
+		{"double quoted variable", `A="${HOME} and ${HOME}"`, "/home/alice and /home/alice"},
+		{"empty variable", `A=x${EMPTY}y`, "xy"},
+		{"unknown variable", `A=x${UNKNOWN}y`, "xy"},
+		{"escaped variable", `A=\${HOME}`, "${HOME}"},
+		{"double quoted escaped variable", `A="\${HOME}"`, "${HOME}"},
+		{"escaped dollar before variable", `A="\$${HOME}"`, "$/home/alice"},
+		{"expanded value is not expanded again", "B=\\${HOME}\nA=${B}", "${HOME}"},
+		{"plain dollar", `A=$5 and $HOME`, "$5 and $HOME"},
+		{"unterminated variable", `A=${HOME`, "${HOME"},
+		{"not a name", `A="${A B}"`, "${A B}"},
+		{"earlier line", "B=b\nA=${B}c", "bc"},
+		{"earlier line over environment", "HOME=/root\nA=${HOME}", "/root"},
+		{"later line", "A=${B}\nB=b", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := readFile(writeFile(t, "# polly\n\n"+tt.line+"\n"), lookupIn(env))
+			if err != nil {
+				t.Fatalf("readFile: %v", err)
+			}
+			if got := values["A"]; got != tt.want {
+				t.Fatalf("readFile: got A=%q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	for _, line := range []string{
+		`A`,
+		`=value`,
+		`A B=value`,
+		`A='unterminated`,
+		`A="unterminated`,
+		`A="unterminated\"`,
+		`A="closed" text`,
+		`A='closed' text`,
+	} {
+		t.Run(line, func(t *testing.T) {
+			if _, err := readFile(writeFile(t, line+"\n"), lookupIn(nil)); err == nil {
+				t.Fatal("readFile: got no error")
+			}
+		})
+	}
+}
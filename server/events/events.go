@@ -0,0 +1,92 @@
+// Package events announces what happens to polls, e.g. that a poll closed.
+package events
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type names an event.
+type Type string
+
+const (
+	// PollCreated is published when a poll is created.
+	PollCreated Type = "poll.created"
+	// VoteRecorded is published for every vote on a poll, except on polls
+	// that keep their voters apart from their choices: verified and ballot
+	// polls.
+	VoteRecorded Type = "vote.recorded"
+	// PollClosed is published once a poll expired.
+	PollClosed Type = "poll.closed"
+	// PollDeleted is published when a poll is deleted.
+	PollDeleted Type = "poll.deleted"
+	// WebhookTest is only sent to a single webhook on request, to check
+	// that it is reached.
+	WebhookTest Type = "webhook.test"
+)
+
+// Types are the events that can be subscribed to.
+var Types = []Type{PollCreated, VoteRecorded, PollClosed, PollDeleted}
+
+// Subscribable reports whether t can be subscribed to.
+func (t Type) Subscribable() bool {
+	return slices.Contains(Types, t)
+}
+
+// PollCreatedData is the data of a PollCreated event.
+type PollCreatedData struct {
+	Question  string    `json:"question"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// VoteRecordedData is the data of a VoteRecorded event.
+type VoteRecordedData struct {
+	VoteID   uuid.UUID `json:"voteID"`
+	OptionID uuid.UUID `json:"optionID"`
+	VotedAt  time.Time `json:"votedAt"`
+}
+
+// PollClosedData is the data of a PollClosed event.
+type PollClosedData struct {
+	Question  string    `json:"question"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	ClosedAt  time.Time `json:"closedAt"`
+}
+
+// PollDeletedData is the data of a PollDeleted event.
+type PollDeletedData struct {
+	Question string `json:"question"`
+}
+
+// WebhookTestData is the data of a WebhookTest event.
+type WebhookTestData struct {
+	WebhookID uuid.UUID `json:"webhookID"`
+}
+
+type Event struct {
+	ID         uuid.UUID `json:"id"`
+	Type       Type      `json:"type"`
+	OccurredAt time.Time `json:"occurredAt"`
+	PollID     uuid.UUID `json:"pollID,omitzero"`
+	// UserID is the owner of the poll and OrganizationID the organization
+	// owning it, if any. They decide who is told about the event.
+	UserID         uuid.UUID  `json:"-"`
+	OrganizationID *uuid.UUID `json:"-"`
+	// Data is the event specific payload.
+	Data any `json:"data"`
+}
+
+// New returns an event that occurred now.
+func New(eventType Type, pollID, userID uuid.UUID, organizationID *uuid.UUID, data any) Event {
+	return Event{
+		ID:             uuid.New(),
+		Type:           eventType,
+		OccurredAt:     time.Now(),
+		PollID:         pollID,
+		UserID:         userID,
+		OrganizationID: organizationID,
+		Data:           data,
+	}
+}
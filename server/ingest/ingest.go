@@ -0,0 +1,275 @@
+// Package ingest buffers incoming votes in memory and writes them to the
+// database in batches. Votes are validated against a cached snapshot of their
+// poll before they are queued and RecordVote only returns once the batch the
+// vote belongs to has been flushed. Votes on ballot polls and on polls that
+// are not anonymous are written one by one, as each has to use up its ballot
+// or register its voter.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/repository"
+)
+
+var ErrClosed = errors.New("vote ingester is closed")
+
+// Store is the part of repository.Store the ingester reads snapshots from and
+// writes votes to.
+type Store interface {
+	GetPollWithOptions(ctx context.Context, pollID uuid.UUID) (*repository.Poll, error)
+	RecordVote(ctx context.Context, vote *repository.Vote) error
+	RecordVotes(ctx context.Context, votes []*repository.Vote) error
+}
+
+type Config struct {
+	// BatchSize is the maximum number of votes written in a single COPY.
+	BatchSize int
+	// FlushInterval is the longest a vote waits in the buffer before its
+	// batch is flushed.
+	FlushInterval time.Duration
+	// QueueSize bounds the number of buffered votes. RecordVote blocks once
+	// the queue is full.
+	QueueSize int
+	// SnapshotTTL is how long a poll snapshot is used for validation before
+	// it is fetched again.
+	SnapshotTTL time.Duration
+	// FlushTimeout bounds a single flush, including the fallback to
+	// recording the votes of a failed batch one by one.
+	FlushTimeout time.Duration
+}
+
+var DefaultConfig = Config{
+	BatchSize:     500,
+	FlushInterval: 50 * time.Millisecond,
+	QueueSize:     10000,
+	SnapshotTTL:   30 * time.Second,
+	FlushTimeout:  10 * time.Second,
+}
+
+type pendingVote struct {
+	vote   *repository.Vote
+	result chan error
+}
+
+type pollSnapshot struct {
+	expiresAt time.Time
+	options   map[uuid.UUID]struct{}
+	// direct polls take votes that are checked by the store itself.
+	direct    bool
+	fetchedAt time.Time
+}
+
+type Ingester struct {
+	store  Store
+	config Config
+
+	// mu guards closed. Producers hold it for reading while enqueueing so
+	// that the queue is never closed under them.
+	mu     sync.RWMutex
+	closed bool
+	queue  chan *pendingVote
+	done   chan struct{}
+
+	snapshotsMu sync.Mutex
+	snapshots   map[uuid.UUID]*pollSnapshot
+}
+
+func New(store Store, config Config) *Ingester {
+	ingester := &Ingester{
+		store:     store,
+		config:    config,
+		queue:     make(chan *pendingVote, config.QueueSize),
+		done:      make(chan struct{}),
+		snapshots: make(map[uuid.UUID]*pollSnapshot),
+	}
+
+	go ingester.run()
+
+	return ingester
+}
+
+// RecordVote validates the vote against the poll snapshot, queues it and waits
+// until it has been written. It returns the same errors as
+// repository.VoteStore.RecordVote. ctx only bounds the wait for room in the
+// queue: a vote that made it into the queue is reported as written or not,
+// never as canceled.
+func (i *Ingester) RecordVote(ctx context.Context, vote *repository.Vote) error {
+	snapshot, err := i.validate(ctx, vote)
+	if err != nil {
+		return err
+	}
+
+	// Ballots and voters are checked by the store, which a batched COPY does
+	// not do.
+	if snapshot.direct || vote.BallotToken != "" {
+		return i.store.RecordVote(ctx, vote)
+	}
+
+	pending := &pendingVote{vote: vote, result: make(chan error, 1)}
+
+	i.mu.RLock()
+	if i.closed {
+		i.mu.RUnlock()
+		return ErrClosed
+	}
+	select {
+	case i.queue <- pending:
+		i.mu.RUnlock()
+	case <-ctx.Done():
+		i.mu.RUnlock()
+		return ctx.Err()
+	}
+
+	// Once queued the vote is written whether or not ctx is done, so its
+	// result is waited for regardless. That takes at most FlushInterval and
+	// FlushTimeout.
+	return <-pending.result
+}
+
+// Close stops accepting votes and waits until every queued vote has been
+// flushed or ctx is done.
+func (i *Ingester) Close(ctx context.Context) error {
+	i.mu.Lock()
+	if !i.closed {
+		i.closed = true
+		close(i.queue)
+	}
+	i.mu.Unlock()
+
+	select {
+	case <-i.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// InvalidatePoll drops the cached snapshot of a poll, e.g. after it was
+// deleted.
+func (i *Ingester) InvalidatePoll(pollID uuid.UUID) {
+	i.snapshotsMu.Lock()
+	defer i.snapshotsMu.Unlock()
+
+	delete(i.snapshots, pollID)
+}
+
+func (i *Ingester) validate(ctx context.Context, vote *repository.Vote) (*pollSnapshot, error) {
+	snapshot, err := i.snapshot(ctx, vote.PollID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !snapshot.expiresAt.After(vote.VotedAt) {
+		return nil, repository.ErrPollExpired
+	}
+
+	if _, ok := snapshot.options[vote.OptionID]; !ok {
+		return nil, repository.ErrOptionBelongsToPoll
+	}
+
+	return snapshot, nil
+}
+
+func (i *Ingester) snapshot(ctx context.Context, pollID uuid.UUID) (*pollSnapshot, error) {
+	i.snapshotsMu.Lock()
+	snapshot, ok := i.snapshots[pollID]
+	i.snapshotsMu.Unlock()
+
+	if ok && time.Since(snapshot.fetchedAt) < i.config.SnapshotTTL {
+		return snapshot, nil
+	}
+
+	poll, err := i.store.GetPollWithOptions(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot = &pollSnapshot{
+		expiresAt: poll.ExpiresAt,
+		options:   make(map[uuid.UUID]struct{}, len(poll.Options)),
+		direct: poll.Visibility == repository.PollVisibilityBallot ||
+			poll.Attribution != repository.PollAttributionAnonymous,
+		fetchedAt: time.Now(),
+	}
+	for _, option := range poll.Options {
+		snapshot.options[option.ID] = struct{}{}
+	}
+
+	i.snapshotsMu.Lock()
+	i.snapshots[pollID] = snapshot
+	i.snapshotsMu.Unlock()
+
+	return snapshot, nil
+}
+
+func (i *Ingester) run() {
+	defer close(i.done)
+
+	var (
+		batch  = make([]*pendingVote, 0, i.config.BatchSize)
+		ticker = time.NewTicker(i.config.FlushInterval)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case pending, ok := <-i.queue:
+			if !ok {
+				i.flush(batch)
+				return
+			}
+
+			batch = append(batch, pending)
+			if len(batch) >= i.config.BatchSize {
+				i.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			i.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (i *Ingester) flush(batch []*pendingVote) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), i.config.FlushTimeout)
+	defer cancel()
+
+	votes := make([]*repository.Vote, len(batch))
+	for j, pending := range batch {
+		votes[j] = pending.vote
+	}
+
+	err := i.store.RecordVotes(ctx, votes)
+	if err == nil {
+		for _, pending := range batch {
+			pending.result <- nil
+		}
+		return
+	}
+
+	// A single bad vote, e.g. one for a poll deleted after its snapshot was
+	// taken, fails the whole COPY. Record the votes one by one so that each
+	// of them gets its own result.
+	slog.WarnContext(ctx, "error flushing votes, retrying individually", "votes", len(batch), "error", err)
+	for _, pending := range batch {
+		err := i.store.RecordVote(ctx, pending.vote)
+		if errors.Is(err, repository.ErrPollNotFound) {
+			i.InvalidatePoll(pending.vote.PollID)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "error recording buffered vote", "poll_id", pending.vote.PollID, "error", err)
+		}
+		pending.result <- err
+	}
+}
@@ -0,0 +1,421 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/repository"
+)
+
+// fakeStore records the votes written in batches and one by one. A batch
+// fails as a whole, like a COPY, if any of its votes is on an unknown poll.
+type fakeStore struct {
+	mu       sync.Mutex
+	polls    map[uuid.UUID]*repository.Poll
+	gets     int
+	batches  [][]*repository.Vote
+	recorded []*repository.Vote
+
+	// gate, if set, holds every RecordVotes until it is closed. flushing
+	// receives a value as each of them starts.
+	gate     chan struct{}
+	flushing chan struct{}
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		polls:    make(map[uuid.UUID]*repository.Poll),
+		flushing: make(chan struct{}, 10),
+	}
+}
+
+func (s *fakeStore) GetPollWithOptions(_ context.Context, pollID uuid.UUID) (*repository.Poll, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gets++
+
+	poll, ok := s.polls[pollID]
+	if !ok {
+		return nil, repository.ErrPollNotFound
+	}
+
+	copied := *poll
+	copied.Options = slices.Clone(poll.Options)
+	return &copied, nil
+}
+
+func (s *fakeStore) RecordVote(_ context.Context, vote *repository.Vote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.polls[vote.PollID]; !ok {
+		return repository.ErrPollNotFound
+	}
+
+	s.recorded = append(s.recorded, vote)
+	return nil
+}
+
+func (s *fakeStore) RecordVotes(_ context.Context, votes []*repository.Vote) error {
+	s.flushing <- struct{}{}
+	if s.gate != nil {
+		<-s.gate
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, vote := range votes {
+		if _, ok := s.polls[vote.PollID]; !ok {
+			return errors.New("insert or update on table votes violates foreign key constraint")
+		}
+	}
+
+	s.batches = append(s.batches, slices.Clone(votes))
+	return nil
+}
+
+func (s *fakeStore) addPoll(attribution repository.PollAttribution, visibility repository.PollVisibility) *repository.Poll {
+	poll := repository.NewPoll(repository.NewPollParams{
+		UserID:      uuid.New(),
+		Question:    "Lunch?",
+		ExpiresAt:   time.Now().Add(time.Hour),
+		Options:     []repository.NewPollOption{{Text: "Pizza"}, {Text: "Sushi"}},
+		Visibility:  visibility,
+		Attribution: attribution,
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.polls[poll.ID] = poll
+	return poll
+}
+
+func (s *fakeStore) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sizes := make([]int, len(s.batches))
+	for i, batch := range s.batches {
+		sizes[i] = len(batch)
+	}
+	return sizes
+}
+
+func newIngester(t *testing.T, store Store, config Config) *Ingester {
+	t.Helper()
+
+	ingester := New(store, config)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		ingester.Close(ctx)
+	})
+
+	return ingester
+}
+
+func testConfig(batchSize int, flushInterval time.Duration) Config {
+	return Config{
+		BatchSize:     batchSize,
+		FlushInterval: flushInterval,
+		QueueSize:     10,
+		SnapshotTTL:   time.Hour,
+		FlushTimeout:  time.Second,
+	}
+}
+
+type voteResult struct {
+	vote *repository.Vote
+	err  error
+}
+
+// recordVotes records a vote on the first option of every poll, each from its
+// own goroutine, and returns the channel their results are sent to.
+func recordVotes(ingester *Ingester, polls ...*repository.Poll) chan voteResult {
+	results := make(chan voteResult, len(polls))
+	for _, poll := range polls {
+		go func() {
+			vote := repository.NewVote(poll.ID, poll.Options[0].ID)
+			results <- voteResult{vote, ingester.RecordVote(context.Background(), vote)}
+		}()
+	}
+	return results
+}
+
+func expectResults(t *testing.T, results chan voteResult, n int) {
+	t.Helper()
+
+	for range n {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				t.Fatalf("RecordVote: %v", result.err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("RecordVote: timed out")
+		}
+	}
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); !condition(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFlushBySize(t *testing.T) {
+	store := newFakeStore()
+	ingester := newIngester(t, store, testConfig(3, time.Hour))
+	poll := store.addPoll(repository.PollAttributionAnonymous, repository.PollVisibilityPublic)
+
+	expectResults(t, recordVotes(ingester, poll, poll, poll), 3)
+
+	if sizes := store.batchSizes(); !slices.Equal(sizes, []int{3}) {
+		t.Fatalf("RecordVotes: got batches of %v, want one of 3", sizes)
+	}
+}
+
+func TestFlushByInterval(t *testing.T) {
+	store := newFakeStore()
+	ingester := newIngester(t, store, testConfig(100, 10*time.Millisecond))
+	poll := store.addPoll(repository.PollAttributionAnonymous, repository.PollVisibilityPublic)
+
+	expectResults(t, recordVotes(ingester, poll, poll), 2)
+
+	if sizes := store.batchSizes(); !slices.Equal(sizes, []int{2}) && !slices.Equal(sizes, []int{1, 1}) {
+		t.Fatalf("RecordVotes: got batches of %v, want both votes flushed", sizes)
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	store := newFakeStore()
+	store.gate = make(chan struct{})
+	config := testConfig(1, time.Hour)
+	config.QueueSize = 1
+	ingester := newIngester(t, store, config)
+	poll := store.addPoll(repository.PollAttributionAnonymous, repository.PollVisibilityPublic)
+
+	// The first vote is being flushed and the second fills the queue.
+	results := recordVotes(ingester, poll)
+	<-store.flushing
+	results2 := recordVotes(ingester, poll)
+	waitFor(t, "the queue to fill", func() bool { return len(ingester.queue) == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	rejected := repository.NewVote(poll.ID, poll.Options[0].ID)
+	if err := ingester.RecordVote(ctx, rejected); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RecordVote with a full queue: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(store.gate)
+	expectResults(t, results, 1)
+	expectResults(t, results2, 1)
+
+	if sizes := store.batchSizes(); !slices.Equal(sizes, []int{1, 1}) {
+		t.Fatalf("RecordVotes: got batches of %v, want the 2 queued votes only", sizes)
+	}
+}
+
+func TestRecordVoteWaitsForQueuedVote(t *testing.T) {
+	store := newFakeStore()
+	store.gate = make(chan struct{})
+	ingester := newIngester(t, store, testConfig(1, time.Hour))
+	poll := store.addPoll(repository.PollAttributionAnonymous, repository.PollVisibilityPublic)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- ingester.RecordVote(ctx, repository.NewVote(poll.ID, poll.Options[0].ID))
+	}()
+
+	<-store.flushing
+	cancel()
+
+	select {
+	case err := <-errs:
+		t.Fatalf("RecordVote returned %v before its vote was written", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(store.gate)
+	if err := <-errs; err != nil {
+		t.Fatalf("RecordVote: got %v, want the result of the flush", err)
+	}
+}
+
+func TestCloseDrainsQueue(t *testing.T) {
+	store := newFakeStore()
+	store.gate = make(chan struct{})
+	ingester := New(store, testConfig(2, time.Hour))
+	poll := store.addPoll(repository.PollAttributionAnonymous, repository.PollVisibilityPublic)
+
+	// Two votes are being flushed and the third waits in the queue.
+	results := recordVotes(ingester, poll, poll)
+	<-store.flushing
+	results2 := recordVotes(ingester, poll)
+	waitFor(t, "the vote to be queued", func() bool { return len(ingester.queue) == 1 })
+
+	closed := make(chan error, 1)
+	go func() { closed <- ingester.Close(context.Background()) }()
+	waitFor(t, "the ingester to close", func() bool {
+		ingester.mu.RLock()
+		defer ingester.mu.RUnlock()
+		return ingester.closed
+	})
+
+	err := ingester.RecordVote(context.Background(), repository.NewVote(poll.ID, poll.Options[0].ID))
+	if !errors.Is(err, ErrClosed) {
+		t.Fatalf("RecordVote after Close: got %v, want %v", err, ErrClosed)
+	}
+
+	close(store.gate)
+	if err := <-closed; err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	expectResults(t, results, 2)
+	expectResults(t, results2, 1)
+
+	if sizes := store.batchSizes(); !slices.Equal(sizes, []int{2, 1}) {
+		t.Fatalf("RecordVotes: got batches of %v, want the queued vote flushed on Close", sizes)
+	}
+}
+
+func TestFlushFallback(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+	ingester := newIngester(t, store, testConfig(3, time.Hour))
+	poll := store.addPoll(repository.PollAttributionAnonymous, repository.PollVisibilityPublic)
+	deleted := store.addPoll(repository.PollAttributionAnonymous, repository.PollVisibilityPublic)
+
+	// The poll is deleted after its snapshot was taken, so its vote is
+	// queued and fails the batch.
+	if _, err := ingester.snapshot(ctx, deleted.ID); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	store.mu.Lock()
+	delete(store.polls, deleted.ID)
+	store.mu.Unlock()
+
+	results := recordVotes(ingester, poll, deleted, poll)
+	for range 3 {
+		result := <-results
+		want := error(nil)
+		if result.vote.PollID == deleted.ID {
+			want = repository.ErrPollNotFound
+		}
+		if !errors.Is(result.err, want) {
+			t.Fatalf("RecordVote on poll %v: got %v, want %v", result.vote.PollID, result.err, want)
+		}
+	}
+
+	store.mu.Lock()
+	batches, recorded := len(store.batches), len(store.recorded)
+	store.mu.Unlock()
+	if batches != 0 || recorded != 2 {
+		t.Fatalf("got %d batches and %d votes recorded one by one, want 0 and 2", batches, recorded)
+	}
+
+	ingester.snapshotsMu.Lock()
+	_, cached := ingester.snapshots[deleted.ID]
+	ingester.snapshotsMu.Unlock()
+	if cached {
+		t.Fatal("snapshot of the deleted poll: got it cached, want it dropped")
+	}
+}
+
+func TestSnapshotTTL(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+	config := testConfig(1, time.Hour)
+	config.SnapshotTTL = 50 * time.Millisecond
+	ingester := newIngester(t, store, config)
+	poll := store.addPoll(repository.PollAttributionAnonymous, repository.PollVisibilityPublic)
+
+	if err := ingester.RecordVote(ctx, repository.NewVote(poll.ID, poll.Options[0].ID)); err != nil {
+		t.Fatalf("RecordVote: %v", err)
+	}
+
+	// An option added since the snapshot was taken is unknown until it
+	// expires.
+	added := repository.PollOption{ID: uuid.New(), PollID: poll.ID, Text: "Tacos", Position: 2}
+	store.mu.Lock()
+	poll.Options = append(poll.Options, added)
+	store.mu.Unlock()
+
+	err := ingester.RecordVote(ctx, repository.NewVote(poll.ID, added.ID))
+	if !errors.Is(err, repository.ErrOptionBelongsToPoll) {
+		t.Fatalf("RecordVote with a fresh snapshot: got %v, want %v", err, repository.ErrOptionBelongsToPoll)
+	}
+
+	time.Sleep(config.SnapshotTTL)
+
+	if err := ingester.RecordVote(ctx, repository.NewVote(poll.ID, added.ID)); err != nil {
+		t.Fatalf("RecordVote with an expired snapshot: %v", err)
+	}
+
+	store.mu.Lock()
+	gets := store.gets
+	store.mu.Unlock()
+	if gets != 2 {
+		t.Fatalf("GetPollWithOptions: got %d calls, want 2", gets)
+	}
+}
+
+func TestRecordVoteValidates(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+	ingester := newIngester(t, store, testConfig(1, time.Hour))
+	poll := store.addPoll(repository.PollAttributionAnonymous, repository.PollVisibilityPublic)
+
+	late := repository.NewVote(poll.ID, poll.Options[0].ID)
+	late.VotedAt = poll.ExpiresAt
+	if err := ingester.RecordVote(ctx, late); !errors.Is(err, repository.ErrPollExpired) {
+		t.Fatalf("RecordVote on an expired poll: got %v, want %v", err, repository.ErrPollExpired)
+	}
+
+	if err := ingester.RecordVote(ctx, repository.NewVote(uuid.New(), poll.Options[0].ID)); !errors.Is(err, repository.ErrPollNotFound) {
+		t.Fatalf("RecordVote on an unknown poll: got %v, want %v", err, repository.ErrPollNotFound)
+	}
+
+	if sizes := store.batchSizes(); len(sizes) != 0 {
+		t.Fatalf("RecordVotes: got batches of %v, want none", sizes)
+	}
+}
+
+func TestDirectVotes(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+	// Queued votes would wait for an hour.
+	ingester := newIngester(t, store, testConfig(100, time.Hour))
+
+	for _, poll := range []*repository.Poll{
+		store.addPoll(repository.PollAttributionAnonymous, repository.PollVisibilityBallot),
+		store.addPoll(repository.PollAttributionAttributed, repository.PollVisibilityPublic),
+		store.addPoll(repository.PollAttributionVerified, repository.PollVisibilityPublic),
+	} {
+		if err := ingester.RecordVote(ctx, repository.NewVote(poll.ID, poll.Options[0].ID)); err != nil {
+			t.Fatalf("RecordVote on a %s %s poll: %v", poll.Attribution, poll.Visibility, err)
+		}
+	}
+
+	store.mu.Lock()
+	recorded := len(store.recorded)
+	store.mu.Unlock()
+	if recorded != 3 {
+		t.Fatalf("RecordVote: got %d votes recorded one by one, want 3", recorded)
+	}
+}
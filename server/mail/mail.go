@@ -0,0 +1,43 @@
+// Package mail sends plain text emails, e.g. ballot invitations.
+package mail
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// ErrNotConfigured is returned by Log, which cannot send anything.
+var ErrNotConfigured = errors.New("no SMTP server is configured")
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Log is a Mailer that only logs the recipients and subjects of the messages
+// it is given and fails with ErrNotConfigured. It is used when no SMTP server
+// is configured, e.g. in development. Bodies are never logged: they may hold
+// credentials such as ballot links.
+type Log struct {
+	Logger *slog.Logger
+}
+
+func (m Log) Send(ctx context.Context, message Message) error {
+	logger := m.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	logger.InfoContext(ctx, "email not sent, no SMTP server is configured",
+		"to", message.To,
+		"subject", message.Subject,
+	)
+
+	return ErrNotConfigured
+}
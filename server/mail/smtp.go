@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTP is a Mailer delivering through an SMTP server. The connection is
+// upgraded with STARTTLS when the server offers it; credentials are only sent
+// over TLS or to localhost, as enforced by net/smtp.
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP returns a mailer sending from the address from through the server
+// at addr (host:port). Without a username no authentication is attempted.
+func NewSMTP(addr, username, password, from string) (*SMTP, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %q: %w", addr, err)
+	}
+
+	mailer := &SMTP{addr: addr, from: from}
+	if username != "" {
+		mailer.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return mailer, nil
+}
+
+func (m *SMTP) Send(ctx context.Context, message Message) error {
+	// net/smtp takes no context. If ctx ends first, Send returns and the
+	// delivery finishes in the background.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, m.auth, m.from, []string{message.To}, m.format(message))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("error sending email: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// format renders the message in RFC 5322 format.
+func (m *SMTP) format(message Message) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(message.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return b.Bytes()
+}
@@ -1,56 +1,58 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
+	"time"
+	// Poll time zones are resolved without relying on the host's database.
+	_ "time/tzdata"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/toramanomer/polly/api"
+	"github.com/toramanomer/polly/blob"
+	"github.com/toramanomer/polly/cache"
+	"github.com/toramanomer/polly/config"
+	"github.com/toramanomer/polly/ingest"
+	"github.com/toramanomer/polly/mail"
+	"github.com/toramanomer/polly/metrics"
+	"github.com/toramanomer/polly/migrations"
 	"github.com/toramanomer/polly/repository"
+	"github.com/toramanomer/polly/tracing"
+	"github.com/toramanomer/polly/webhook"
 )
 
-func init() {
-	envFile, err := os.Open(".env")
+func main() {
+	// -------------------- Configuration
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
 	if err != nil {
-		log.Fatalf("Failed to read .env file: %v", err)
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
-	defer envFile.Close()
-
-	scanner := bufio.NewScanner(envFile)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
 
-		name, value, found := strings.Cut(line, "=")
-		if !found {
-			log.Fatalf("Invalid line in .env file: %s\n", line)
-		}
-
-		if err := os.Setenv(name, value); err != nil {
-			log.Fatalf("Failed to set %s environment variable: %v\n", name, err)
-		}
-	}
+	// The log package is routed through the JSON handler as well.
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		Level: cfg.LogLevel,
+	})))
+	// --------------------
 
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("Error scanning .env file: %v\n", err)
+	// -------------------- Tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.TracingSampleRatio)
+	if err != nil {
+		log.Fatalf("Error setting up tracing: %v", err)
 	}
-}
+	// --------------------
 
-func main() {
 	// -------------------- DB Setup
-	connString := os.Getenv("DATABASE_URL")
-	poolConfig, err := pgxpool.ParseConfig(connString)
+	poolConfig, err := pgxpool.ParseConfig(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatalf("Error parsing database URL: %v", err)
 	}
+	poolConfig.ConnConfig.Tracer = tracing.QueryTracer{}
 
 	db, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
@@ -63,37 +65,256 @@ func main() {
 	}
 	// --------------------
 
-	// -------------------- API Setup
+	// -------------------- Migrations
+	migrator, err := migrations.New(db)
+	if err != nil {
+		log.Fatalf("Error loading migrations: %v", err)
+	}
+
+	if len(cfg.Args) > 0 && cfg.Args[0] == "migrate" {
+		if err := runMigrateCommand(context.Background(), migrator, cfg.Args[1:]); err != nil {
+			log.Fatalf("Error running migrate command: %v", err)
+		}
+		return
+	}
+
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		log.Fatalf("Error migrating database: %v", err)
+	}
+	for _, migration := range applied {
+		log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+	}
+	// --------------------
+
+	repo := repository.NewRepository(db)
+
+	// -------------------- Vote ingestion
 	var (
-		r = chi.NewRouter()
-		a = api.NewAPI(repository.NewRepository(db))
+		votes    api.VoteRecorder = repo
+		ingester *ingest.Ingester
 	)
 
-	r.Use(middleware.Logger)
-	r.Route("/api", func(r chi.Router) {
-		r.Route("/auth", func(r chi.Router) {
-			r.Post("/signup", a.Signup)
-			r.Post("/signin", a.Signin)
-			r.Post("/signout", a.Signout)
-			r.Get("/me", a.Me)
-		})
-		r.Route("/polls", func(r chi.Router) {
-			withAuth := r.With(api.AuthMiddleware)
-			withAuth.Post("/", a.CreatePoll)
-			withAuth.Get("/", a.GetUserPolls)
-			withAuth.Delete("/{pollID}", a.DeletePoll)
-
-			r.Get("/{pollID}", a.GetPollByID)
-			r.With(api.WithTurnstileProtection).Post("/{pollID}/vote", a.VoteOnPoll)
-		})
-	})
+	if cfg.VoteIngestion {
+		ingester = ingest.New(repo, ingest.DefaultConfig)
+		votes = ingester
+	}
+	// --------------------
+
+	// -------------------- Mail
+	var mailer mail.Mailer = mail.Log{}
+
+	if cfg.SMTPAddr != "" {
+		mailer, err = mail.NewSMTP(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+		if err != nil {
+			log.Fatalf("Error configuring the SMTP mailer: %v", err)
+		}
+	}
+	// --------------------
+
+	// -------------------- Webhooks
+	// The repository queues the deliveries of every event along with the
+	// change it announces; the dispatcher sends them.
+	sender := webhook.NewSender(10*time.Second, cfg.WebhookAllowPrivateNetworks)
+	// --------------------
+
+	// -------------------- Poll cache
+	var polls cache.PollCache = cache.NewLRU(cfg.PollCacheCapacity, cfg.PollCacheTTL)
+
+	if cfg.PollCache == "redis" {
+		redis := cache.NewNetRedis(cfg.RedisAddr, 16, time.Second)
+		defer redis.Close()
 
+		polls = cache.NewRedis(redis, "polly:", cfg.PollCacheTTL)
+	}
+	// --------------------
+
+	// -------------------- Blob storage
+	blobs, err := blob.NewFS(cfg.BlobDir, blob.ImageLimits(cfg.MaxImageBytes))
+	if err != nil {
+		log.Fatalf("Error setting up the blob store: %v", err)
+	}
+	// --------------------
+
+	// -------------------- API Setup
 	var (
-		serverAddr = ":8000"
-		server     = &http.Server{Addr: serverAddr, Handler: r}
+		m = metrics.New(db)
+		a = api.NewAPI(cfg, repo, votes, polls, m, mailer, blobs, sender)
+		r = api.NewRouter(a,
+			api.ReadinessCheck{Name: "database", Check: db.Ping},
+			api.ReadinessCheck{Name: "migrations", Check: migrator.CheckApplied},
+		)
 	)
 
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Error starting the HTTP server: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go reconcileVoteCounts(ctx, repo, cfg.VoteCountReconcileInterval)
+	go closeExpiredPolls(ctx, repo, cfg.PollSweepInterval)
+	go sweepUnusedImages(ctx, repo, blobs, cfg.ImageSweepInterval)
+	go webhook.NewDispatcher(repo, sender, webhook.DefaultConfig).Run(ctx)
+
+	// The API document is built from the same types as the handlers; this
+	// catches routes added or removed without updating it.
+	if err := api.CheckRoutes(r); err != nil {
+		log.Fatalf("The OpenAPI document does not match the router:\n%v", err)
+	}
+
+	server, err := newHTTPServer(cfg, r)
+	if err != nil {
+		log.Fatalf("Error configuring the HTTP server: %v", err)
+	}
+
+	go func() {
+		if err := serve(server); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Error starting the HTTP server: %v", err)
+		}
+	}()
+
+	var metricsServer *http.Server
+	if cfg.MetricsAddr != "" {
+		metricsServer = newMetricsServer(cfg, api.NewMetricsRouter(a))
+
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Error starting the metrics server: %v", err)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	// A second signal terminates the process without waiting for the drain.
+	stop()
+	log.Printf("Shutting down, draining for up to %s", cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down the HTTP server: %v", err)
+	}
+
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down the metrics server: %v", err)
+		}
+	}
+
+	if err := a.Close(shutdownCtx); err != nil {
+		log.Printf("Error waiting for background API work: %v", err)
+	}
+
+	// Votes still buffered by the ingester are flushed only after the server
+	// and the API stopped recording them, so no new ones can arrive
+	// meanwhile.
+	if ingester != nil {
+		if err := ingester.Close(shutdownCtx); err != nil {
+			log.Printf("Error draining the vote ingester: %v", err)
+		}
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Error flushing traces: %v", err)
+	}
+}
+
+// reconcileVoteCounts periodically re-derives the maintained vote counters
+// from the votes table until ctx is done.
+func reconcileVoteCounts(ctx context.Context, repo *repository.Repository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			corrected, err := repo.ReconcileVoteCounts(ctx, nil)
+			if err != nil {
+				log.Printf("Error reconciling vote counts: %v", err)
+				continue
+			}
+			if corrected > 0 {
+				log.Printf("Reconciled vote counts of %d poll options", corrected)
+			}
+		}
+	}
+}
+
+// pollSweepBatchSize is the most polls closed in one statement.
+const pollSweepBatchSize = 100
+
+// closeExpiredPolls periodically closes the polls that expired until ctx is
+// done. The store queues their poll.closed webhook deliveries as it closes
+// them.
+func closeExpiredPolls(ctx context.Context, repo *repository.Repository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			closed, err := repo.CloseExpiredPolls(ctx, repository.CloseExpiredPollsParams{
+				Now:   time.Now(),
+				Limit: pollSweepBatchSize,
+			})
+			if err != nil {
+				log.Printf("Error closing expired polls: %v", err)
+				break
+			}
+
+			if len(closed) < pollSweepBatchSize {
+				break
+			}
+		}
+	}
+}
+
+const (
+	// imageSweepBatchSize is the most images deleted in one statement.
+	imageSweepBatchSize = 100
+	// unusedImageGrace is how long an uploaded image is kept for the poll it
+	// was uploaded for to be created.
+	unusedImageGrace = 24 * time.Hour
+)
+
+// sweepUnusedImages periodically deletes the images no poll option uses, and
+// their content, until ctx is done.
+func sweepUnusedImages(ctx context.Context, repo *repository.Repository, blobs blob.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			deleted, err := repo.DeleteUnusedImages(ctx, repository.DeleteUnusedImagesParams{
+				CreatedBefore: time.Now().Add(-unusedImageGrace),
+				Limit:         imageSweepBatchSize,
+			})
+			if err != nil {
+				log.Printf("Error deleting unused images: %v", err)
+				break
+			}
+
+			for _, image := range deleted {
+				if err := blobs.Delete(ctx, image.BlobKey()); err != nil {
+					log.Printf("Error deleting content of image %s: %v", image.ID, err)
+				}
+			}
+
+			if len(deleted) < imageSweepBatchSize {
+				break
+			}
+		}
 	}
 }
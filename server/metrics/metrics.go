@@ -0,0 +1,153 @@
+// Package metrics defines the Prometheus metrics of the server and serves
+// them in the Prometheus exposition format.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "polly"
+
+// Vote rejection reasons.
+const (
+	VoteRejectedInvalidRequest = "invalid_request"
+	VoteRejectedPollNotFound   = "poll_not_found"
+	VoteRejectedPollExpired    = "poll_expired"
+	VoteRejectedOptionNotFound = "option_not_in_poll"
+	VoteRejectedForbidden      = "forbidden"
+	VoteRejectedBallotUsed     = "ballot_used"
+	VoteRejectedSignInRequired = "sign_in_required"
+	VoteRejectedAlreadyVoted   = "already_voted"
+	VoteRejectedInternalError  = "internal_error"
+)
+
+// Signin outcomes.
+const (
+	SigninSucceeded = "succeeded"
+	SigninFailed    = "failed"
+)
+
+// Turnstile verification outcomes.
+const (
+	TurnstileSuccess      = "success"
+	TurnstileFailure      = "failure"
+	TurnstileMissingToken = "missing_token"
+	TurnstileError        = "error"
+)
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requestDuration *prometheus.HistogramVec
+	votesRecorded   prometheus.Counter
+	votesRejected   *prometheus.CounterVec
+	signins         *prometheus.CounterVec
+	turnstile       *prometheus.CounterVec
+}
+
+// New registers the server metrics along with the Go runtime, process and
+// database pool metrics. db may be nil.
+func New(db *pgxpool.Pool) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests by route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		votesRecorded: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "votes_recorded_total",
+			Help:      "Number of votes recorded.",
+		}),
+		votesRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "votes_rejected_total",
+			Help:      "Number of votes rejected by reason.",
+		}, []string{"reason"}),
+		signins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "signins_total",
+			Help:      "Number of signin attempts by outcome.",
+		}, []string{"outcome"}),
+		turnstile: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "turnstile_verifications_total",
+			Help:      "Number of Turnstile verifications by outcome.",
+		}, []string{"outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestDuration,
+		m.votesRecorded,
+		m.votesRejected,
+		m.signins,
+		m.turnstile,
+	)
+
+	if db != nil {
+		m.registry.MustRegister(newPoolCollector(db))
+	}
+
+	return m
+}
+
+// Handler serves the registered metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware observes the duration of every request, labelled with the chi
+// route pattern rather than the raw path to keep the label set bounded.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			start = time.Now()
+			ww    = middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		m.requestDuration.
+			WithLabelValues(r.Method, route, strconv.Itoa(status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+func (m *Metrics) VoteRecorded() {
+	m.votesRecorded.Inc()
+}
+
+func (m *Metrics) VoteRejected(reason string) {
+	m.votesRejected.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) Signin(outcome string) {
+	m.signins.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) TurnstileVerification(outcome string) {
+	m.turnstile.WithLabelValues(outcome).Inc()
+}
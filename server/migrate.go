@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/toramanomer/polly/migrations"
+)
+
+const migrateUsage = "usage: polly migrate up | down [steps] | status"
+
+// runMigrateCommand implements `polly migrate up|down|status`.
+func runMigrateCommand(ctx context.Context, migrator *migrations.Migrator, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("Applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Printf("Reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+
+	return errors.New(migrateUsage)
+}
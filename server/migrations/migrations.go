@@ -0,0 +1,297 @@
+// Package migrations holds the versioned database schema and applies it.
+//
+// Every change to the schema is a pair of files in the sql directory named
+// NNNN_description.up.sql and NNNN_description.down.sql. Applied versions are
+// recorded in the schema_migrations table. Migrations run under a Postgres
+// advisory lock, so several server instances starting at once apply each
+// migration only once.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey identifies the advisory lock held while migrating.
+const lockKey = 7_301_512_066_418_993
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+type Migrator struct {
+	db         *pgxpool.Pool
+	migrations []Migration
+}
+
+func New(db *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		var (
+			fileName  = entry.Name()
+			base, dir = strings.TrimSuffix(fileName, ".sql"), ""
+		)
+
+		switch {
+		case strings.HasSuffix(base, ".up"):
+			base, dir = strings.TrimSuffix(base, ".up"), "up"
+		case strings.HasSuffix(base, ".down"):
+			base, dir = strings.TrimSuffix(base, ".down"), "down"
+		default:
+			return nil, fmt.Errorf("migration %s is neither an up nor a down migration", fileName)
+		}
+
+		rawVersion, name, found := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(rawVersion, 10, 64)
+		if !found || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s does not start with a version number", fileName)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join("sql", fileName))
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %w", fileName, err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, migration.Name, name)
+		}
+
+		if dir == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+const createSchemaMigrations = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version		BIGINT		PRIMARY KEY,
+		name		TEXT		NOT NULL,
+		applied_at	TIMESTAMPTZ	NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+
+const getAppliedMigrations = `
+	SELECT version, applied_at
+	FROM schema_migrations`
+
+const insertMigration = `
+	INSERT INTO schema_migrations (version, name)
+	VALUES ($1, $2)`
+
+const deleteMigration = `
+	DELETE FROM schema_migrations
+	WHERE version = $1`
+
+// withLock runs fn on a single connection holding the migration advisory lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("error acquiring migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+
+	if _, err := conn.Exec(ctx, createSchemaMigrations); err != nil {
+		return fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func applied(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, getAppliedMigrations)
+	if err != nil {
+		return nil, fmt.Errorf("error querying applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("error scanning applied migration: %w", err)
+		}
+		versions[version] = appliedAt
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating applied migrations: %w", err)
+	}
+
+	return versions, nil
+}
+
+// run executes a migration script and records the change in one transaction.
+func run(ctx context.Context, conn *pgxpool.Conn, script, record string, args ...any) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, script); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, record, args...)
+		return err
+	})
+}
+
+// Up applies every pending migration in order and returns the applied ones.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			if err := run(ctx, conn, migration.Up, insertMigration, migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("error applying migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down reverts the latest steps applied migrations and returns the reverted
+// ones.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+
+			if err := run(ctx, conn, migration.Down, deleteMigration, migration.Version); err != nil {
+				return fmt.Errorf("error reverting migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Status lists every known migration and when it was applied, if it was.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := versions[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+var ErrPending = errors.New("database has pending migrations")
+
+// CheckApplied returns ErrPending unless the latest known migration was
+// applied. Unlike Status it does not wait for the migration lock.
+func (m *Migrator) CheckApplied(ctx context.Context) error {
+	var latest int64
+	err := m.db.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&latest)
+	if err != nil {
+		return fmt.Errorf("error querying schema version: %w", err)
+	}
+
+	if n := len(m.migrations); n > 0 && latest < m.migrations[n-1].Version {
+		return ErrPending
+	}
+
+	return nil
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS votes;
+DROP TABLE IF EXISTS poll_options;
+DROP TABLE IF EXISTS polls;
+DROP TABLE IF EXISTS users;
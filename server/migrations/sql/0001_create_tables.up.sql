@@ -1,4 +1,3 @@
-
 -- Users table to store user information
 CREATE TABLE IF NOT EXISTS users (
 	id              UUID    PRIMARY KEY,
@@ -28,14 +27,14 @@ CREATE TABLE IF NOT EXISTS poll_options (
 );
 
 -- Votes table to store user votes
-CREATE TABLE votes (
+CREATE TABLE IF NOT EXISTS votes (
 	id			UUID			PRIMARY KEY,
 	poll_id		UUID			NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
 	option_id	UUID			NOT NULL REFERENCES poll_options(id) ON DELETE CASCADE,
 	voted_at	TIMESTAMPTZ		NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE INDEX idx_polls_user_id ON polls(user_id);
-CREATE INDEX idx_poll_options_poll_id ON poll_options(poll_id);
-CREATE INDEX idx_votes_poll_id ON votes(poll_id);
-CREATE INDEX idx_votes_option_id ON votes(option_id);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_polls_user_id ON polls(user_id);
+CREATE INDEX IF NOT EXISTS idx_poll_options_poll_id ON poll_options(poll_id);
+CREATE INDEX IF NOT EXISTS idx_votes_poll_id ON votes(poll_id);
+CREATE INDEX IF NOT EXISTS idx_votes_option_id ON votes(option_id);
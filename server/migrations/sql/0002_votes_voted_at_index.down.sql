@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_votes_poll_id_voted_at;
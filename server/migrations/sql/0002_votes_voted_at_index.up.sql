@@ -0,0 +1,2 @@
+-- Vote analytics buckets the votes of a single poll by time
+CREATE INDEX IF NOT EXISTS idx_votes_poll_id_voted_at ON votes(poll_id, voted_at);
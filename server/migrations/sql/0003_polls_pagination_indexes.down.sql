@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_polls_user_id_expires_at;
+DROP INDEX IF EXISTS idx_polls_user_id_created_at;
@@ -0,0 +1,3 @@
+-- Keyset pagination of a user's polls by creation and expiration time
+CREATE INDEX IF NOT EXISTS idx_polls_user_id_created_at ON polls(user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_polls_user_id_expires_at ON polls(user_id, expires_at, id);
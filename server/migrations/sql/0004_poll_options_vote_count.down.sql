@@ -0,0 +1 @@
+ALTER TABLE poll_options DROP COLUMN IF EXISTS vote_count;
@@ -0,0 +1,12 @@
+-- Maintained vote counter, incremented together with every recorded vote
+ALTER TABLE poll_options
+	ADD COLUMN IF NOT EXISTS vote_count INTEGER NOT NULL DEFAULT 0 CHECK (vote_count >= 0);
+
+UPDATE poll_options
+SET vote_count = counts.vote_count
+FROM (
+	SELECT option_id, COUNT(*) AS vote_count
+	FROM votes
+	GROUP BY option_id
+) counts
+WHERE poll_options.id = counts.option_id;
@@ -0,0 +1,10 @@
+-- Poll version and modification time, used for conditional GET of a poll
+ALTER TABLE polls
+	ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1 CHECK (version > 0),
+	ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+
+UPDATE polls SET updated_at = created_at WHERE updated_at IS NULL;
+
+ALTER TABLE polls
+	ALTER COLUMN updated_at SET NOT NULL,
+	ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
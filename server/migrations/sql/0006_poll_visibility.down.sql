@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_polls_public_created_at;
+DROP TABLE IF EXISTS poll_invitees;
+
+ALTER TABLE polls
+	DROP CONSTRAINT IF EXISTS polls_passcode_hash_check,
+	DROP COLUMN IF EXISTS passcode_hash,
+	DROP COLUMN IF EXISTS visibility;
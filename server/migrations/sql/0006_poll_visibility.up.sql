@@ -0,0 +1,19 @@
+-- Who can read and vote on a poll. Passcode polls store the passcode hash,
+-- private polls their invite list.
+ALTER TABLE polls
+	ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'unlisted'
+		CHECK (visibility IN ('public', 'unlisted', 'private', 'passcode')),
+	ADD COLUMN IF NOT EXISTS passcode_hash TEXT,
+	ADD CONSTRAINT polls_passcode_hash_check
+		CHECK ((visibility = 'passcode') = (passcode_hash IS NOT NULL));
+
+CREATE TABLE IF NOT EXISTS poll_invitees (
+	poll_id		UUID	NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+	user_id		UUID	NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+	PRIMARY KEY	(poll_id, user_id)
+);
+
+-- Listing of active public polls, newest first
+CREATE INDEX IF NOT EXISTS idx_polls_public_created_at ON polls(created_at, id)
+	WHERE visibility = 'public';
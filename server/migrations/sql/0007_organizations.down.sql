@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_polls_organization_id_created_at;
+
+ALTER TABLE polls
+	DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
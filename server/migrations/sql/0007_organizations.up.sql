@@ -0,0 +1,23 @@
+-- Organizations share the ownership of their polls among their members
+CREATE TABLE IF NOT EXISTS organizations (
+	id			UUID			PRIMARY KEY,
+	name		TEXT			NOT NULL,
+	created_at	TIMESTAMPTZ		NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+	organization_id	UUID	NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+	user_id			UUID	NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	role			TEXT	NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+
+	PRIMARY KEY		(organization_id, user_id)
+);
+
+-- Organizations of a user
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id);
+
+ALTER TABLE polls
+	ADD COLUMN IF NOT EXISTS organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_polls_organization_id_created_at ON polls(organization_id, created_at, id)
+	WHERE organization_id IS NOT NULL;
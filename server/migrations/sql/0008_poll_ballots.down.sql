@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS poll_ballots;
+
+DELETE FROM polls WHERE visibility = 'ballot';
+
+ALTER TABLE polls
+	DROP CONSTRAINT IF EXISTS polls_visibility_check,
+	ADD CONSTRAINT polls_visibility_check
+		CHECK (visibility IN ('public', 'unlisted', 'private', 'passcode'));
@@ -0,0 +1,19 @@
+-- Ballot polls are open to the holders of a single-use ballot token only
+ALTER TABLE polls
+	DROP CONSTRAINT IF EXISTS polls_visibility_check,
+	ADD CONSTRAINT polls_visibility_check
+		CHECK (visibility IN ('public', 'unlisted', 'private', 'passcode', 'ballot'));
+
+-- Ballots are not linked to the vote cast with them, which stays secret.
+-- Only the SHA-256 hash of a token is stored.
+CREATE TABLE IF NOT EXISTS poll_ballots (
+	id			UUID			PRIMARY KEY,
+	poll_id		UUID			NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+	recipient	TEXT			NOT NULL,
+	email		TEXT,
+	token_hash	BYTEA			NOT NULL UNIQUE,
+	created_at	TIMESTAMPTZ		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	used_at		TIMESTAMPTZ,
+
+	UNIQUE		(poll_id, recipient)
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS poll_voters;
+
+ALTER TABLE votes DROP COLUMN IF EXISTS voter_id;
+
+ALTER TABLE polls DROP COLUMN IF EXISTS attribution;
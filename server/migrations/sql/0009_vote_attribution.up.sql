@@ -0,0 +1,18 @@
+-- Whether votes are linked to their voter. Attributed polls store the voter
+-- of every vote; verified polls only record who voted, not for what.
+ALTER TABLE polls
+	ADD COLUMN IF NOT EXISTS attribution TEXT NOT NULL DEFAULT 'anonymous'
+		CHECK (attribution IN ('anonymous', 'attributed', 'verified'));
+
+ALTER TABLE votes
+	ADD COLUMN IF NOT EXISTS voter_id UUID REFERENCES users(id) ON DELETE SET NULL;
+
+-- One row per user who voted on an attributed or verified poll, which also
+-- limits them to a single vote. There is deliberately no timestamp that could
+-- be matched against votes.voted_at.
+CREATE TABLE IF NOT EXISTS poll_voters (
+	poll_id		UUID	NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+	user_id		UUID	NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+	PRIMARY KEY	(poll_id, user_id)
+);
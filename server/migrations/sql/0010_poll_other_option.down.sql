@@ -0,0 +1,8 @@
+ALTER TABLE votes
+	DROP COLUMN IF EXISTS other_hidden,
+	DROP COLUMN IF EXISTS other_text;
+
+DROP INDEX IF EXISTS idx_poll_options_poll_id_other;
+
+ALTER TABLE poll_options
+	DROP COLUMN IF EXISTS is_other;
@@ -0,0 +1,11 @@
+-- A poll may have one Other option, whose votes carry a free-text answer.
+-- Hidden answers are still counted, but only listed when asked for.
+ALTER TABLE poll_options
+	ADD COLUMN IF NOT EXISTS is_other BOOLEAN NOT NULL DEFAULT false;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_poll_options_poll_id_other ON poll_options(poll_id)
+	WHERE is_other;
+
+ALTER TABLE votes
+	ADD COLUMN IF NOT EXISTS other_text TEXT,
+	ADD COLUMN IF NOT EXISTS other_hidden BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+ALTER TABLE poll_options
+	DROP COLUMN IF EXISTS url,
+	DROP COLUMN IF EXISTS image_id,
+	DROP COLUMN IF EXISTS description;
+
+DROP TABLE IF EXISTS images;
@@ -0,0 +1,17 @@
+-- Images uploaded for poll options. The content is kept in the blob store
+-- under the image ID; the row records who uploaded it and what it is.
+CREATE TABLE IF NOT EXISTS images (
+	id				UUID			PRIMARY KEY,
+	user_id			UUID			NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	content_type	TEXT			NOT NULL,
+	size			BIGINT			NOT NULL,
+	created_at		TIMESTAMPTZ		NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_images_user_id ON images(user_id);
+
+-- Options may describe themselves beyond their text
+ALTER TABLE poll_options
+	ADD COLUMN IF NOT EXISTS description TEXT,
+	ADD COLUMN IF NOT EXISTS image_id UUID REFERENCES images(id) ON DELETE SET NULL,
+	ADD COLUMN IF NOT EXISTS url TEXT;
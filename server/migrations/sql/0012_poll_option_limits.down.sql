@@ -0,0 +1,7 @@
+ALTER TABLE users
+	DROP COLUMN IF EXISTS plan;
+
+-- Fails while a poll has more than 6 options
+ALTER TABLE poll_options
+	DROP CONSTRAINT IF EXISTS poll_options_position_check,
+	ADD CONSTRAINT poll_options_position_check CHECK (position BETWEEN 0 AND 5);
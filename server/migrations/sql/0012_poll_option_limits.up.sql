@@ -0,0 +1,9 @@
+-- The number of options is limited by the server configuration. The schema
+-- only bounds the position by the largest limit that can be configured.
+ALTER TABLE poll_options
+	DROP CONSTRAINT IF EXISTS poll_options_position_check,
+	ADD CONSTRAINT poll_options_position_check CHECK (position BETWEEN 0 AND 49);
+
+-- The plan of a user may override the option limits
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS plan TEXT NOT NULL DEFAULT 'free';
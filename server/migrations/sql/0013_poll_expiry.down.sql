@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_polls_expires_at_not_closed;
+
+ALTER TABLE polls
+	DROP COLUMN IF EXISTS closed_at,
+	DROP COLUMN IF EXISTS time_zone;
@@ -0,0 +1,13 @@
+-- The IANA time zone the times of a poll are displayed in
+ALTER TABLE polls
+	ADD COLUMN IF NOT EXISTS time_zone TEXT NOT NULL DEFAULT 'UTC';
+
+-- Set by the sweeper once it announced that the poll expired
+ALTER TABLE polls
+	ADD COLUMN IF NOT EXISTS closed_at TIMESTAMPTZ;
+
+-- Polls that expired before the sweeper existed are not announced
+UPDATE polls SET closed_at = expires_at WHERE expires_at <= CURRENT_TIMESTAMP;
+
+-- Lets the sweeper find the expired polls it has yet to close
+CREATE INDEX IF NOT EXISTS idx_polls_expires_at_not_closed ON polls(expires_at) WHERE closed_at IS NULL;
@@ -0,0 +1,38 @@
+-- Webhooks subscribe a URL to events of every poll of a user, or of a single
+-- poll if poll_id is set. The secret signs the payloads, so it is kept as is.
+CREATE TABLE IF NOT EXISTS webhooks (
+	id			UUID			PRIMARY KEY,
+	user_id		UUID			NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	poll_id		UUID			REFERENCES polls(id) ON DELETE CASCADE,
+	url			TEXT			NOT NULL,
+	secret		TEXT			NOT NULL,
+	events		TEXT[]			NOT NULL CHECK (cardinality(events) > 0),
+	created_at	TIMESTAMPTZ		NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks(user_id) WHERE poll_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_webhooks_poll_id ON webhooks(poll_id) WHERE poll_id IS NOT NULL;
+
+-- The delivery queue and log. A delivery is pending until it succeeds or
+-- runs out of attempts; next_attempt_at is when it is due again.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id					UUID			PRIMARY KEY,
+	webhook_id			UUID			NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+	event_id			UUID			NOT NULL,
+	event_type			TEXT			NOT NULL,
+	-- The exact bytes that are signed and sent
+	payload				TEXT			NOT NULL,
+	status				TEXT			NOT NULL DEFAULT 'pending'
+		CHECK (status IN ('pending', 'succeeded', 'failed')),
+	attempts			INTEGER			NOT NULL DEFAULT 0,
+	next_attempt_at		TIMESTAMPTZ		NOT NULL,
+	last_attempt_at		TIMESTAMPTZ,
+	response_status		INTEGER,
+	last_error			TEXT,
+	created_at			TIMESTAMPTZ		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at);
@@ -0,0 +1,2 @@
+DELETE FROM users WHERE id IN (SELECT user_id FROM chat_accounts);
+DROP TABLE IF EXISTS chat_accounts;
@@ -0,0 +1,12 @@
+-- Chat accounts link the users of a chat workspace, e.g. of Slack, to the
+-- users standing in for them. The users are created on first use and cannot
+-- sign in.
+CREATE TABLE IF NOT EXISTS chat_accounts (
+	provider		TEXT			NOT NULL,
+	team_id			TEXT			NOT NULL,
+	chat_user_id	TEXT			NOT NULL,
+	user_id			UUID			NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+	created_at		TIMESTAMPTZ		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	PRIMARY KEY		(provider, team_id, chat_user_id)
+);
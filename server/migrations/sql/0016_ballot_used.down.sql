@@ -0,0 +1,3 @@
+ALTER TABLE poll_ballots ADD COLUMN IF NOT EXISTS used_at TIMESTAMPTZ;
+UPDATE poll_ballots SET used_at = created_at WHERE used;
+ALTER TABLE poll_ballots DROP COLUMN IF EXISTS used;
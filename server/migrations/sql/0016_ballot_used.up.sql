@@ -0,0 +1,5 @@
+-- The time a ballot was used matched the time of its vote, which linked the
+-- recipient to the vote. Ballots only record whether they were used.
+ALTER TABLE poll_ballots ADD COLUMN IF NOT EXISTS used BOOLEAN NOT NULL DEFAULT false;
+UPDATE poll_ballots SET used = true WHERE used_at IS NOT NULL;
+ALTER TABLE poll_ballots DROP COLUMN IF EXISTS used_at;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_poll_options_image_id;
@@ -0,0 +1,3 @@
+-- Images no poll option uses are deleted by a periodic sweep, which looks
+-- them up by this index.
+CREATE INDEX IF NOT EXISTS idx_poll_options_image_id ON poll_options(image_id) WHERE image_id IS NOT NULL;
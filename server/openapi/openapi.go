@@ -0,0 +1,128 @@
+// Package openapi describes HTTP APIs as OpenAPI 3.1 documents. Schemas are
+// derived from the Go types that are encoded and decoded on the wire, so the
+// document cannot drift from the handlers.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path by lower case HTTP method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter locations.
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+)
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type string `json:"type"`
+	In   string `json:"in,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// Schema is the subset of JSON Schema used by the documents. Type is a
+// string, or a list of strings for nullable values.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+// Types returns the JSON types the schema allows.
+func (s *Schema) Types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			if name, ok := v.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// Handler serves the document as JSON.
+func Handler(doc *Document) http.Handler {
+	body, err := json.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		panic(err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	})
+}
@@ -0,0 +1,164 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Reflector derives schemas from Go types following the rules of
+// encoding/json for field names, omitempty and "-". Named struct types become
+// component schemas referenced by name.
+type Reflector struct {
+	schemas   map[string]*Schema
+	named     map[reflect.Type]string
+	overrides map[reflect.Type]*Schema
+}
+
+func NewReflector() *Reflector {
+	return &Reflector{
+		schemas: make(map[string]*Schema),
+		named:   make(map[reflect.Type]string),
+		overrides: map[reflect.Type]*Schema{
+			reflect.TypeFor[time.Time](): {Type: "string", Format: "date-time"},
+			reflect.TypeFor[uuid.UUID](): {Type: "string", Format: "uuid"},
+		},
+	}
+}
+
+// Define registers schema as the component schema of the type of v, for types
+// whose JSON encoding is customised.
+func (r *Reflector) Define(v any, schema *Schema) {
+	var (
+		t    = reflect.TypeOf(v)
+		name = schemaName(t)
+	)
+	r.schemas[name] = schema
+	r.named[t] = name
+}
+
+// Schemas returns the component schemas registered so far.
+func (r *Reflector) Schemas() map[string]*Schema {
+	return r.schemas
+}
+
+// SchemaOf returns the schema of the type of v.
+func (r *Reflector) SchemaOf(v any) *Schema {
+	return r.schema(reflect.TypeOf(v))
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{AnyOf: []*Schema{s, {Type: "null"}}}
+	}
+
+	nullable := *s
+	nullable.Type = append(s.Types(), "null")
+	return &nullable
+}
+
+func schemaName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+func (r *Reflector) schema(t reflect.Type) *Schema {
+	if name, ok := r.named[t]; ok {
+		return ref(name)
+	}
+
+	if override, ok := r.overrides[t]; ok {
+		copied := *override
+		return &copied
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(r.schema(t.Elem()))
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.object(t)
+		}
+
+		name := schemaName(t)
+		if _, taken := r.schemas[name]; taken {
+			panic(fmt.Sprintf("openapi: schema name %s is used by more than one type", name))
+		}
+
+		// Register the name before reflecting the fields so recursive types
+		// refer to themselves.
+		r.named[t] = name
+		r.schemas[name] = nil
+		r.schemas[name] = r.object(t)
+		return ref(name)
+	case reflect.Interface:
+		return &Schema{}
+	}
+
+	panic(fmt.Sprintf("openapi: cannot describe %s", t))
+}
+
+func (r *Reflector) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	r.fields(t, schema)
+	return schema
+}
+
+func (r *Reflector) fields(t reflect.Type, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				r.fields(embedded, schema)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = r.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
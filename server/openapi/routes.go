@@ -0,0 +1,69 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CheckRoutes compares the document with the routes of router below prefix.
+// It reports every route the document does not describe and every described
+// operation the router does not serve. Paths in ignore are skipped.
+func CheckRoutes(doc *Document, router chi.Routes, prefix string, ignore ...string) error {
+	var (
+		served    = make(map[string]bool)
+		described = make(map[string]bool)
+		skipped   = make(map[string]bool, len(ignore))
+	)
+
+	for _, path := range ignore {
+		skipped[path] = true
+	}
+
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		if strings.HasPrefix(route, prefix) && !skipped[route] {
+			served[strings.ToUpper(method)+" "+route] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking routes: %w", err)
+	}
+
+	for path, item := range doc.Paths {
+		for method := range *item {
+			described[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	var problems []string
+	for route := range served {
+		if !described[route] {
+			problems = append(problems, route+" is served but not described")
+		}
+	}
+	for route := range described {
+		if !served[route] {
+			problems = append(problems, route+" is described but not served")
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	errs := make([]error, len(problems))
+	for i, problem := range problems {
+		errs[i] = errors.New(problem)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,36 @@
+package primitives
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// AnswerText is a short free-text answer, e.g. to the Other option of a poll.
+type AnswerText string
+
+func (a *AnswerText) Validate() []string {
+	errs := make([]string, 0)
+
+	// Runs of whitespace, including line breaks, are folded into one space.
+	normalizedAnswer := strings.Join(strings.Fields(string(*a)), " ")
+	*a = AnswerText(normalizedAnswer)
+
+	if normalizedAnswer == "" {
+		errs = append(errs, "Answer cannot be empty")
+	}
+
+	if utf8.RuneCountInString(normalizedAnswer) > 100 {
+		errs = append(errs, "Answer cannot be longer than 100 characters")
+	}
+
+	if strings.IndexFunc(normalizedAnswer, unicode.IsControl) != -1 {
+		errs = append(errs, "Answer cannot contain control characters")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package primitives
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// OptionText is the text of a poll option.
+type OptionText string
+
+func (o *OptionText) Validate() []string {
+	errs := make([]string, 0)
+
+	normalizedOption := strings.TrimSpace(string(*o))
+	*o = OptionText(normalizedOption)
+
+	if normalizedOption == "" {
+		errs = append(errs, "Option text cannot be empty")
+	}
+
+	if utf8.RuneCountInString(normalizedOption) > 100 {
+		errs = append(errs, "Option text cannot be longer than 100 characters")
+	}
+
+	if strings.IndexFunc(normalizedOption, unicode.IsControl) != -1 {
+		errs = append(errs, "Option text cannot contain control characters")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Key is the text compared to tell options apart: letter case and whitespace
+// are ignored.
+func (o OptionText) Key() string {
+	return strings.ToLower(strings.Join(strings.Fields(string(o)), " "))
+}
@@ -0,0 +1,48 @@
+package primitives
+
+import (
+	"unicode/utf8"
+
+	"github.com/alexedwards/argon2id"
+)
+
+// Passcode protects a poll. It is checked on every read and vote of the
+// poll, so it is hashed with lighter argon2id parameters than a password.
+type Passcode string
+
+var passcodeParams = &argon2id.Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func (p *Passcode) Validate() []string {
+	errors := make([]string, 0)
+
+	length := utf8.RuneCountInString(string(*p))
+	if length < 4 {
+		errors = append(errors, "Passcode must be at least 4 characters long")
+	}
+
+	if length > 128 {
+		errors = append(errors, "Passcode cannot be longer than 128 characters")
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+
+	return nil
+}
+
+func (p *Passcode) Hash() string {
+	hash, _ := argon2id.CreateHash(string(*p), passcodeParams)
+	return hash
+}
+
+func (p *Passcode) Verify(hash string) bool {
+	match, _ := argon2id.ComparePasswordAndHash(string(*p), hash)
+	return match
+}
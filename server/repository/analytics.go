@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/primitives"
+)
+
+type AnalyticsInterval string
+
+const (
+	AnalyticsIntervalMinute AnalyticsInterval = "minute"
+	AnalyticsIntervalHour   AnalyticsInterval = "hour"
+	AnalyticsIntervalDay    AnalyticsInterval = "day"
+)
+
+func (i AnalyticsInterval) Valid() bool {
+	switch i {
+	case AnalyticsIntervalMinute, AnalyticsIntervalHour, AnalyticsIntervalDay:
+		return true
+	}
+	return false
+}
+
+func (i AnalyticsInterval) Duration() time.Duration {
+	switch i {
+	case AnalyticsIntervalMinute:
+		return time.Minute
+	case AnalyticsIntervalHour:
+		return time.Hour
+	case AnalyticsIntervalDay:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+type VoteBucket struct {
+	Start      time.Time `json:"start"`
+	Count      int       `json:"count"`
+	Cumulative int       `json:"cumulative"`
+}
+
+type OptionTimeSeries struct {
+	OptionID uuid.UUID             `json:"optionID"`
+	Text     primitives.OptionText `json:"text"`
+	Position int                   `json:"position"`
+	Total    int                   `json:"total"`
+	Buckets  []VoteBucket          `json:"buckets"`
+}
+
+type PeakRate struct {
+	Start          time.Time `json:"start"`
+	Count          int       `json:"count"`
+	VotesPerMinute float64   `json:"votesPerMinute"`
+}
+
+type PollAnalytics struct {
+	PollID      uuid.UUID          `json:"pollID"`
+	ExpiresAt   time.Time          `json:"-"`
+	Interval    AnalyticsInterval  `json:"interval"`
+	TotalVotes  int                `json:"totalVotes"`
+	FirstVoteAt *time.Time         `json:"firstVoteAt"`
+	LastVoteAt  *time.Time         `json:"lastVoteAt"`
+	Peak        *PeakRate          `json:"peak"`
+	Totals      []VoteBucket       `json:"totals"`
+	Options     []OptionTimeSeries `json:"options"`
+}
+
+func (a *PollAnalytics) Closed(now time.Time) bool {
+	return !a.ExpiresAt.After(now)
+}
+
+type GetPollAnalyticsParams struct {
+	PollID   uuid.UUID
+	Interval AnalyticsInterval
+}
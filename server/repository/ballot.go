@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrBallotRequired = errors.New("poll requires a ballot")
+	ErrBallotNotFound = errors.New("ballot not found")
+	ErrBallotUsed     = errors.New("ballot already used")
+)
+
+// Ballot entitles its recipient to a single vote on a PollVisibilityBallot
+// poll. The token is handed to the recipient only; the ballot keeps its hash.
+// Only whether a ballot was used is kept, not when, so that it cannot be
+// matched to the time of its vote.
+type Ballot struct {
+	ID     uuid.UUID `json:"id"`
+	PollID uuid.UUID `json:"pollID"`
+	// Recipient is the email address or username the ballot was issued to
+	// and Email where it is delivered, if known.
+	Recipient string    `json:"recipient"`
+	Email     string    `json:"email,omitempty"`
+	TokenHash []byte    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	Used      bool      `json:"used"`
+}
+
+// NewBallot returns a ballot and its token, which is not stored anywhere.
+func NewBallot(pollID uuid.UUID, recipient, email string) (*Ballot, string) {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	return &Ballot{
+		ID:        uuid.New(),
+		PollID:    pollID,
+		Recipient: recipient,
+		Email:     email,
+		TokenHash: HashBallotToken(token),
+		CreatedAt: time.Now(),
+	}, token
+}
+
+// HashBallotToken returns the hash a ballot is looked up by.
+func HashBallotToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+// PollTurnout is how many of the ballots of a poll were used.
+type PollTurnout struct {
+	PollID  uuid.UUID `json:"pollID"`
+	Invited int       `json:"invited"`
+	Voted   int       `json:"voted"`
+	// Turnout is Voted / Invited, 0 without ballots.
+	Turnout float64  `json:"turnout"`
+	Ballots []Ballot `json:"ballots"`
+}
+
+// NewPollTurnout counts how many of the ballots of a poll were used.
+func NewPollTurnout(pollID uuid.UUID, ballots []Ballot) *PollTurnout {
+	turnout := &PollTurnout{PollID: pollID, Invited: len(ballots), Ballots: ballots}
+	for _, ballot := range ballots {
+		if ballot.Used {
+			turnout.Voted++
+		}
+	}
+
+	if turnout.Invited > 0 {
+		turnout.Turnout = float64(turnout.Voted) / float64(turnout.Invited)
+	}
+
+	return turnout
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/primitives"
+)
+
+// ChatProviderSlack is the provider of Slack accounts.
+const ChatProviderSlack = "slack"
+
+// ChatAccount is a user of a chat workspace, e.g. of Slack, as identified by
+// the chat provider.
+type ChatAccount struct {
+	Provider string
+	TeamID   string
+	UserID   string
+}
+
+// NewUser returns the user standing in for the account. Its username keeps
+// the case of the chat IDs and its email is in the .invalid domain, neither
+// of which a user signing up can pick. It has no password, so it cannot sign
+// in.
+func (a ChatAccount) NewUser() *User {
+	return &User{
+		ID:       uuid.New(),
+		Username: primitives.Username(a.Provider + ":" + a.TeamID + ":" + a.UserID),
+		Email:    primitives.Email(strings.ToLower(a.UserID + "@" + a.TeamID + "." + a.Provider + ".invalid")),
+		Plan:     DefaultPlan,
+	}
+}
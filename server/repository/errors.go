@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/toramanomer/polly/requestid"
+)
+
+// Error wraps an unexpected database failure with the operation that failed
+// and the ID of the request it failed in, if any.
+type Error struct {
+	Op        string
+	RequestID string
+	Err       error
+}
+
+func (e *Error) Error() string {
+	if e.RequestID == "" {
+		return e.Op + ": " + e.Err.Error()
+	}
+	return e.Op + " (request " + e.RequestID + "): " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func wrapError(ctx context.Context, op string, err error) error {
+	return &Error{
+		Op:        op,
+		RequestID: requestid.FromContext(ctx),
+		Err:       err,
+	}
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrImageNotFound      = errors.New("image not found")
+	ErrImageQuotaExceeded = errors.New("image quota exceeded")
+)
+
+// Image is an uploaded image, e.g. of a poll option. Its content is kept in
+// the blob store under the image ID.
+type Image struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"userID"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// NewImage returns an image uploaded by the user. Its content type and size
+// are known once the content is stored.
+func NewImage(userID uuid.UUID) *Image {
+	return &Image{
+		ID:        uuid.New(),
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	}
+}
+
+// BlobKey is the key the content of the image is stored under.
+func (i *Image) BlobKey() string {
+	return i.ID.String()
+}
+
+type CreateImageParams struct {
+	Image *Image
+	// Quota is the most bytes the images of the user may take up, the new
+	// one included.
+	Quota int64
+}
+
+type DeleteUnusedImagesParams struct {
+	// CreatedBefore spares the images uploaded since, which may be about to
+	// be used by a poll being created.
+	CreatedBefore time.Time
+	Limit         int
+}
@@ -0,0 +1,1396 @@
+// Package memory implements repository.Store in memory. It mirrors the error
+// semantics of the Postgres repository and is meant for tests and local
+// development.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/events"
+	"github.com/toramanomer/polly/primitives"
+	"github.com/toramanomer/polly/repository"
+)
+
+type pollRecord struct {
+	poll         repository.Poll
+	passcodeHash string
+	invitees     map[uuid.UUID]bool
+	ballots      []*repository.Ballot
+	voters       map[uuid.UUID]bool
+	hidden       map[uuid.UUID]bool
+	counts       map[uuid.UUID]int
+	votes        []repository.Vote
+	closedAt     *time.Time
+}
+
+type organizationRecord struct {
+	organization repository.Organization
+	members      map[uuid.UUID]repository.OrganizationRole
+}
+
+type Store struct {
+	mu            sync.RWMutex
+	users         map[uuid.UUID]repository.User
+	polls         map[uuid.UUID]*pollRecord
+	organizations map[uuid.UUID]*organizationRecord
+	images        map[uuid.UUID]repository.Image
+	webhooks      map[uuid.UUID]repository.Webhook
+	deliveries    []*repository.WebhookDelivery
+	chatAccounts  map[repository.ChatAccount]uuid.UUID
+}
+
+var _ repository.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		users:         make(map[uuid.UUID]repository.User),
+		polls:         make(map[uuid.UUID]*pollRecord),
+		organizations: make(map[uuid.UUID]*organizationRecord),
+		images:        make(map[uuid.UUID]repository.Image),
+		webhooks:      make(map[uuid.UUID]repository.Webhook),
+		chatAccounts:  make(map[repository.ChatAccount]uuid.UUID),
+	}
+}
+
+// truncate drops the sub-microsecond part of t, as a TIMESTAMPTZ column does.
+func truncate(t time.Time) time.Time {
+	return t.Truncate(time.Microsecond)
+}
+
+func (s *Store) CreateUser(_ context.Context, user *repository.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		switch {
+		case existing.ID == user.ID:
+			return errors.New("user id already exists")
+		case existing.Email == user.Email:
+			return repository.ErrEmailAlreadyExists
+		case existing.Username == user.Username:
+			return repository.ErrUsernameAlreadyExists
+		}
+	}
+
+	s.users[user.ID] = *user
+
+	return nil
+}
+
+func (s *Store) GetUserByEmail(_ context.Context, email primitives.Email) (*repository.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+
+	return nil, repository.ErrUserNotFound
+}
+
+func (s *Store) GetUserByID(_ context.Context, userID uuid.UUID) (*repository.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+
+	return &user, nil
+}
+
+func (s *Store) GetOrCreateChatUser(_ context.Context, account repository.ChatAccount) (*repository.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if userID, ok := s.chatAccounts[account]; ok {
+		user := s.users[userID]
+		return &user, nil
+	}
+
+	user := account.NewUser()
+	for _, existing := range s.users {
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return nil, repository.ErrUserNotFound
+		}
+	}
+
+	s.users[user.ID] = *user
+	s.chatAccounts[account] = user.ID
+
+	return user, nil
+}
+
+func (s *Store) GetUsersByUsernames(_ context.Context, usernames []primitives.Username) ([]repository.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]repository.User, 0, len(usernames))
+	for _, user := range s.users {
+		if slices.Contains(usernames, user.Username) {
+			users = append(users, user)
+		}
+	}
+
+	return users, nil
+}
+
+func (s *Store) CreatePollWithOptions(_ context.Context, poll *repository.Poll) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[poll.UserID]; !ok {
+		return errors.New("error inserting poll: user does not exist")
+	}
+
+	if _, ok := s.polls[poll.ID]; ok {
+		return errors.New("error inserting poll: poll id already exists")
+	}
+
+	if poll.OrganizationID != nil {
+		if _, ok := s.organizations[*poll.OrganizationID]; !ok {
+			return errors.New("error inserting poll: organization does not exist")
+		}
+	}
+
+	if !poll.Visibility.Valid() {
+		return fmt.Errorf("error inserting poll: invalid visibility %q", poll.Visibility)
+	}
+
+	if !poll.Attribution.Valid() {
+		return fmt.Errorf("error inserting poll: invalid attribution %q", poll.Attribution)
+	}
+
+	if (poll.Visibility == repository.PollVisibilityPasscode) != (poll.PasscodeHash != "") {
+		return errors.New("error inserting poll: passcode hash does not match the visibility")
+	}
+
+	record := &pollRecord{
+		poll: repository.Poll{
+			ID:             poll.ID,
+			UserID:         poll.UserID,
+			Question:       poll.Question,
+			Visibility:     poll.Visibility,
+			Attribution:    poll.Attribution,
+			OrganizationID: poll.OrganizationID,
+			Version:        poll.Version,
+			CreatedAt:      truncate(poll.CreatedAt),
+			UpdatedAt:      truncate(poll.UpdatedAt),
+			ExpiresAt:      truncate(poll.ExpiresAt),
+			TimeZone:       poll.TimeZone,
+			Options:        make([]repository.PollOption, len(poll.Options)),
+		},
+		passcodeHash: poll.PasscodeHash,
+		invitees:     make(map[uuid.UUID]bool, len(poll.Invitees)),
+		voters:       make(map[uuid.UUID]bool),
+		hidden:       make(map[uuid.UUID]bool),
+		counts:       make(map[uuid.UUID]int, len(poll.Options)),
+	}
+
+	for _, userID := range poll.Invitees {
+		if _, ok := s.users[userID]; !ok {
+			return errors.New("error inserting poll invitees: user does not exist")
+		}
+		record.invitees[userID] = true
+	}
+
+	var (
+		positions = make(map[int]bool, len(poll.Options))
+		other     = false
+	)
+	for i, option := range poll.Options {
+		if option.Position < 0 || option.Position >= repository.MaxPollOptions || positions[option.Position] {
+			return fmt.Errorf("error inserting poll options: invalid position %d", option.Position)
+		}
+		positions[option.Position] = true
+
+		if option.ImageID != nil {
+			if _, ok := s.images[*option.ImageID]; !ok {
+				return errors.New("error inserting poll options: image does not exist")
+			}
+		}
+
+		if option.Other && other {
+			return errors.New("error inserting poll options: poll already has an other option")
+		}
+		other = other || option.Other
+
+		option.Count = 0
+		record.poll.Options[i] = option
+		record.counts[option.ID] = 0
+	}
+
+	slices.SortFunc(record.poll.Options, func(a, b repository.PollOption) int {
+		return a.Position - b.Position
+	})
+
+	s.polls[poll.ID] = record
+
+	return s.queueEvent(repository.PollCreatedEvent(poll))
+}
+
+func (s *Store) DeletePoll(_ context.Context, arg repository.DeletePollParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.polls[arg.PollID]
+	switch {
+	case !ok:
+		return repository.ErrPollNotFound
+	case record.poll.OrganizationID == nil && record.poll.UserID != arg.UserID,
+		record.poll.OrganizationID != nil &&
+			!s.organizationRole(record.poll.OrganizationID, arg.UserID).AtLeast(repository.OrganizationRoleAdmin):
+		return repository.ErrNotPollOwner
+	}
+
+	delete(s.polls, arg.PollID)
+
+	for _, webhook := range s.webhooks {
+		if webhook.PollID != nil && *webhook.PollID == arg.PollID {
+			s.deleteWebhook(webhook.ID)
+		}
+	}
+
+	poll := record.poll
+	return s.queueEvent(repository.PollDeletedEvent(poll.ID, poll.UserID, poll.OrganizationID, poll.Question))
+}
+
+func (s *Store) CloseExpiredPolls(_ context.Context, arg repository.CloseExpiredPollsParams) ([]repository.ClosedPoll, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []*pollRecord
+	for _, record := range s.polls {
+		if record.closedAt == nil && !record.poll.ExpiresAt.After(arg.Now) {
+			expired = append(expired, record)
+		}
+	}
+
+	slices.SortFunc(expired, func(a, b *pollRecord) int {
+		return a.poll.ExpiresAt.Compare(b.poll.ExpiresAt)
+	})
+
+	if len(expired) > arg.Limit {
+		expired = expired[:arg.Limit]
+	}
+
+	var (
+		closedAt = truncate(arg.Now)
+		closed   []repository.ClosedPoll
+	)
+	for _, record := range expired {
+		record.closedAt = &closedAt
+		closed = append(closed, repository.ClosedPoll{
+			PollID:         record.poll.ID,
+			UserID:         record.poll.UserID,
+			OrganizationID: record.poll.OrganizationID,
+			Question:       record.poll.Question,
+			ExpiresAt:      record.poll.ExpiresAt,
+			ClosedAt:       closedAt,
+		})
+	}
+
+	webhookIDs := make(map[uuid.UUID][]uuid.UUID)
+	for _, poll := range closed {
+		webhookIDs[poll.PollID] = s.eventWebhookIDs(poll.UserID, poll.PollID, events.PollClosed)
+	}
+
+	deliveries, err := repository.ClosedPollDeliveries(closed, webhookIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.createWebhookDeliveries(deliveries); err != nil {
+		return nil, err
+	}
+
+	return closed, nil
+}
+
+// clonePoll copies the poll of a record, with vote counts if withCounts is set.
+func clonePoll(record *pollRecord, withCounts bool) *repository.Poll {
+	poll := record.poll
+	poll.Options = slices.Clone(record.poll.Options)
+
+	if withCounts {
+		for i := range poll.Options {
+			poll.Options[i].Count = record.counts[poll.Options[i].ID]
+		}
+	}
+
+	return &poll
+}
+
+func (s *Store) GetPollWithOptions(_ context.Context, pollID uuid.UUID) (*repository.Poll, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.polls[pollID]
+	if !ok {
+		return nil, repository.ErrPollNotFound
+	}
+
+	return clonePoll(record, false), nil
+}
+
+func (s *Store) GetPollTally(_ context.Context, pollID uuid.UUID) (*repository.PollTally, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.polls[pollID]
+	if !ok {
+		return nil, repository.ErrPollNotFound
+	}
+
+	tally := &repository.PollTally{Counts: maps.Clone(record.counts)}
+	for _, vote := range record.votes {
+		if tally.LastVoteAt == nil || vote.VotedAt.After(*tally.LastVoteAt) {
+			votedAt := vote.VotedAt
+			tally.LastVoteAt = &votedAt
+		}
+	}
+
+	return tally, nil
+}
+
+func (s *Store) GetPollAccess(_ context.Context, pollID, userID uuid.UUID) (*repository.PollAccess, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.polls[pollID]
+	if !ok {
+		return nil, repository.ErrPollNotFound
+	}
+
+	return &repository.PollAccess{
+		PasscodeHash:     record.passcodeHash,
+		Invited:          record.invitees[userID],
+		OrganizationRole: s.organizationRole(record.poll.OrganizationID, userID),
+	}, nil
+}
+
+// organizationRole returns the role of a user in an organization, empty if
+// organizationID is nil or the user is not a member. s.mu must be held.
+func (s *Store) organizationRole(organizationID *uuid.UUID, userID uuid.UUID) repository.OrganizationRole {
+	if organizationID == nil {
+		return ""
+	}
+
+	record, ok := s.organizations[*organizationID]
+	if !ok {
+		return ""
+	}
+
+	return record.members[userID]
+}
+
+func (s *Store) GetUserPollsWithStats(_ context.Context, arg repository.GetUserPollsParams) (*repository.PollPage, error) {
+	if !arg.Sort.Valid() {
+		arg.Sort = repository.PollSortCreatedAtDesc
+	}
+
+	if arg.Cursor != nil && arg.Cursor.Sort != arg.Sort {
+		return nil, repository.ErrInvalidCursor
+	}
+
+	var (
+		now    = time.Now()
+		search = strings.ToLower(arg.Search)
+	)
+
+	return s.pollPage(arg.Sort, arg.Cursor, arg.Limit, func(poll *repository.Poll) bool {
+		if arg.OrganizationID != nil {
+			if poll.OrganizationID == nil || *poll.OrganizationID != *arg.OrganizationID {
+				return false
+			}
+		} else if poll.UserID != arg.UserID && s.organizationRole(poll.OrganizationID, arg.UserID) == "" {
+			return false
+		}
+
+		switch {
+		case arg.Status == repository.PollStatusActive && !poll.ExpiresAt.After(now),
+			arg.Status == repository.PollStatusExpired && poll.ExpiresAt.After(now),
+			arg.CreatedAfter != nil && poll.CreatedAt.Before(*arg.CreatedAfter),
+			arg.CreatedBefore != nil && !poll.CreatedAt.Before(*arg.CreatedBefore),
+			search != "" && !strings.Contains(strings.ToLower(string(poll.Question)), search):
+			return false
+		}
+		return true
+	}), nil
+}
+
+func (s *Store) ListPublicPolls(_ context.Context, arg repository.ListPublicPollsParams) (*repository.PollPage, error) {
+	if arg.Cursor != nil && arg.Cursor.Sort != repository.PollSortCreatedAtDesc {
+		return nil, repository.ErrInvalidCursor
+	}
+
+	now := time.Now()
+
+	return s.pollPage(repository.PollSortCreatedAtDesc, arg.Cursor, arg.Limit, func(poll *repository.Poll) bool {
+		return poll.Visibility == repository.PollVisibilityPublic && poll.ExpiresAt.After(now)
+	}), nil
+}
+
+// pollPage returns the page of the polls matching keep, ordered by sort and
+// continuing after cursor.
+func (s *Store) pollPage(sort repository.PollSort, cursor *repository.PollCursor, limit int, keep func(*repository.Poll) bool) *repository.PollPage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		sortValue = func(poll *repository.Poll) time.Time {
+			if sort == repository.PollSortExpiresAtAsc || sort == repository.PollSortExpiresAtDesc {
+				return poll.ExpiresAt
+			}
+			return poll.CreatedAt
+		}
+		descending = sort == repository.PollSortCreatedAtDesc || sort == repository.PollSortExpiresAtDesc
+		compare    = func(a, b *repository.Poll) int {
+			c := sortValue(a).Compare(sortValue(b))
+			if c == 0 {
+				c = strings.Compare(a.ID.String(), b.ID.String())
+			}
+			if descending {
+				return -c
+			}
+			return c
+		}
+		polls []*repository.Poll
+	)
+
+	for _, record := range s.polls {
+		poll := &record.poll
+		if !keep(poll) {
+			continue
+		}
+
+		if cursor != nil {
+			cursorPoll := &repository.Poll{ID: cursor.ID, CreatedAt: cursor.Value, ExpiresAt: cursor.Value}
+			if compare(poll, cursorPoll) <= 0 {
+				continue
+			}
+		}
+
+		polls = append(polls, clonePoll(record, true))
+	}
+
+	slices.SortFunc(polls, compare)
+
+	page := repository.PollPage{Polls: make([]repository.Poll, 0, limit)}
+	for _, poll := range polls {
+		if len(page.Polls) == limit {
+			last := page.Polls[limit-1]
+			page.NextCursor = repository.PollCursor{Sort: sort, Value: sortValue(&last), ID: last.ID}.Encode()
+			break
+		}
+		page.Polls = append(page.Polls, *poll)
+	}
+
+	return &page
+}
+
+func (s *Store) GetPollAnalytics(_ context.Context, arg repository.GetPollAnalyticsParams) (*repository.PollAnalytics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.polls[arg.PollID]
+	if !ok {
+		return nil, repository.ErrPollNotFound
+	}
+
+	analytics := &repository.PollAnalytics{
+		PollID:    record.poll.ID,
+		ExpiresAt: record.poll.ExpiresAt,
+		Interval:  arg.Interval,
+		Totals:    []repository.VoteBucket{},
+		Options:   make([]repository.OptionTimeSeries, len(record.poll.Options)),
+	}
+
+	optionIndex := make(map[uuid.UUID]int, len(record.poll.Options))
+	for i, option := range record.poll.Options {
+		optionIndex[option.ID] = i
+		analytics.Options[i] = repository.OptionTimeSeries{
+			OptionID: option.ID,
+			Text:     option.Text,
+			Position: option.Position,
+			Buckets:  []repository.VoteBucket{},
+		}
+	}
+
+	votes := slices.Clone(record.votes)
+	slices.SortFunc(votes, func(a, b repository.Vote) int {
+		return a.VotedAt.Compare(b.VotedAt)
+	})
+
+	for _, vote := range votes {
+		var (
+			start   = vote.VotedAt.UTC().Truncate(arg.Interval.Duration())
+			votedAt = vote.VotedAt
+			series  = &analytics.Options[optionIndex[vote.OptionID]]
+		)
+
+		if analytics.FirstVoteAt == nil {
+			analytics.FirstVoteAt = &votedAt
+		}
+		analytics.LastVoteAt = &votedAt
+		analytics.TotalVotes++
+
+		if n := len(analytics.Totals); n == 0 || !analytics.Totals[n-1].Start.Equal(start) {
+			analytics.Totals = append(analytics.Totals, repository.VoteBucket{Start: start})
+		}
+		total := &analytics.Totals[len(analytics.Totals)-1]
+		total.Count++
+		total.Cumulative = analytics.TotalVotes
+
+		if n := len(series.Buckets); n == 0 || !series.Buckets[n-1].Start.Equal(start) {
+			series.Buckets = append(series.Buckets, repository.VoteBucket{Start: start})
+		}
+		bucket := &series.Buckets[len(series.Buckets)-1]
+		series.Total++
+		bucket.Count++
+		bucket.Cumulative = series.Total
+	}
+
+	for _, total := range analytics.Totals {
+		if analytics.Peak == nil || total.Count > analytics.Peak.Count {
+			analytics.Peak = &repository.PeakRate{
+				Start:          total.Start,
+				Count:          total.Count,
+				VotesPerMinute: float64(total.Count) / arg.Interval.Duration().Minutes(),
+			}
+		}
+	}
+
+	return analytics, nil
+}
+
+func (s *Store) CreateOrganization(_ context.Context, organization *repository.Organization, ownerID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.organizations[organization.ID]; ok {
+		return errors.New("error inserting organization: organization id already exists")
+	}
+
+	if _, ok := s.users[ownerID]; !ok {
+		return errors.New("error inserting organization owner: user does not exist")
+	}
+
+	stored := *organization
+	stored.CreatedAt = truncate(organization.CreatedAt)
+
+	s.organizations[organization.ID] = &organizationRecord{
+		organization: stored,
+		members:      map[uuid.UUID]repository.OrganizationRole{ownerID: repository.OrganizationRoleOwner},
+	}
+
+	return nil
+}
+
+func (s *Store) GetUserOrganizations(_ context.Context, userID uuid.UUID) ([]repository.OrganizationMembership, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	memberships := make([]repository.OrganizationMembership, 0)
+	for _, record := range s.organizations {
+		if role, ok := record.members[userID]; ok {
+			memberships = append(memberships, repository.OrganizationMembership{
+				Organization: record.organization,
+				Role:         role,
+			})
+		}
+	}
+
+	slices.SortFunc(memberships, func(a, b repository.OrganizationMembership) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+
+	return memberships, nil
+}
+
+func (s *Store) GetOrganizationRole(_ context.Context, organizationID, userID uuid.UUID) (repository.OrganizationRole, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.organizations[organizationID]
+	if !ok {
+		return "", repository.ErrOrganizationNotFound
+	}
+
+	role, ok := record.members[userID]
+	if !ok {
+		return "", repository.ErrNotOrganizationMember
+	}
+
+	return role, nil
+}
+
+func (s *Store) GetOrganizationMembers(_ context.Context, organizationID uuid.UUID) ([]repository.OrganizationMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	members := make([]repository.OrganizationMember, 0)
+	if record, ok := s.organizations[organizationID]; ok {
+		for userID, role := range record.members {
+			members = append(members, repository.OrganizationMember{
+				UserID:   userID,
+				Username: s.users[userID].Username,
+				Role:     role,
+			})
+		}
+	}
+
+	slices.SortFunc(members, func(a, b repository.OrganizationMember) int {
+		return strings.Compare(string(a.Username), string(b.Username))
+	})
+
+	return members, nil
+}
+
+// owners counts the owners of an organization. s.mu must be held.
+func (record *organizationRecord) owners() int {
+	owners := 0
+	for _, role := range record.members {
+		if role == repository.OrganizationRoleOwner {
+			owners++
+		}
+	}
+	return owners
+}
+
+func (s *Store) SetOrganizationMember(_ context.Context, arg repository.SetOrganizationMemberParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.organizations[arg.OrganizationID]
+	if !ok {
+		return repository.ErrOrganizationNotFound
+	}
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return errors.New("error upserting organization member: user does not exist")
+	}
+
+	if !arg.Role.Valid() {
+		return fmt.Errorf("error upserting organization member: invalid role %q", arg.Role)
+	}
+
+	role := record.members[arg.UserID]
+	if role == repository.OrganizationRoleOwner && arg.Role != repository.OrganizationRoleOwner && record.owners() <= 1 {
+		return repository.ErrLastOrganizationOwner
+	}
+
+	record.members[arg.UserID] = arg.Role
+
+	return nil
+}
+
+func (s *Store) RemoveOrganizationMember(_ context.Context, organizationID, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.organizations[organizationID]
+	if !ok {
+		return repository.ErrOrganizationNotFound
+	}
+
+	role, ok := record.members[userID]
+	switch {
+	case !ok:
+		return repository.ErrNotOrganizationMember
+	case role == repository.OrganizationRoleOwner && record.owners() <= 1:
+		return repository.ErrLastOrganizationOwner
+	}
+
+	delete(record.members, userID)
+
+	return nil
+}
+
+func (s *Store) RecordVote(_ context.Context, vote *repository.Vote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.polls[vote.PollID]
+	switch {
+	case !ok:
+		return repository.ErrPollNotFound
+	case !record.poll.ExpiresAt.After(time.Now()):
+		return repository.ErrPollExpired
+	}
+
+	if _, ok := record.counts[vote.OptionID]; !ok {
+		return repository.ErrOptionBelongsToPoll
+	}
+
+	var ballot *repository.Ballot
+	if record.poll.Visibility == repository.PollVisibilityBallot {
+		if vote.BallotToken == "" {
+			return repository.ErrBallotRequired
+		}
+		if ballot = record.ballot(repository.HashBallotToken(vote.BallotToken)); ballot == nil {
+			return repository.ErrBallotNotFound
+		}
+	}
+
+	voterRequired := record.poll.Attribution != repository.PollAttributionAnonymous
+	switch {
+	case voterRequired && vote.VoterID == nil:
+		return repository.ErrVoterRequired
+	case voterRequired && record.voters[*vote.VoterID]:
+		return repository.ErrAlreadyVoted
+	case ballot != nil && ballot.Used:
+		return repository.ErrBallotUsed
+	}
+
+	if ballot != nil {
+		ballot.Used = true
+	}
+	if voterRequired {
+		record.voters[*vote.VoterID] = true
+	}
+
+	s.insertVote(record, vote)
+
+	return s.queueVotes([]*repository.Vote{vote})
+}
+
+func (s *Store) RecordVotes(_ context.Context, votes []*repository.Vote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The batch is all or nothing, so every vote is checked before any of
+	// them is inserted.
+	for _, vote := range votes {
+		record, ok := s.polls[vote.PollID]
+		if !ok {
+			return errors.New("error copying votes: poll does not exist")
+		}
+		if _, ok := record.counts[vote.OptionID]; !ok {
+			return errors.New("error copying votes: option does not exist")
+		}
+	}
+
+	for _, vote := range votes {
+		s.insertVote(s.polls[vote.PollID], vote)
+	}
+
+	return s.queueVotes(votes)
+}
+
+// queueVotes queues the vote.recorded deliveries of the votes; s.mu must be
+// held for writing.
+func (s *Store) queueVotes(votes []*repository.Vote) error {
+	webhookIDs := make(map[uuid.UUID][]uuid.UUID)
+	for _, vote := range votes {
+		record := s.polls[vote.PollID]
+		if _, ok := webhookIDs[vote.PollID]; ok || !record.poll.PublishesVotes() {
+			continue
+		}
+		webhookIDs[vote.PollID] = s.eventWebhookIDs(record.poll.UserID, vote.PollID, events.VoteRecorded)
+	}
+
+	deliveries, err := repository.VoteDeliveries(votes, webhookIDs)
+	if err != nil {
+		return err
+	}
+
+	return s.createWebhookDeliveries(deliveries)
+}
+
+func (s *Store) insertVote(record *pollRecord, vote *repository.Vote) {
+	stored := *vote
+	stored.VotedAt = truncate(vote.VotedAt)
+	stored.BallotToken = ""
+	if record.poll.Attribution != repository.PollAttributionAttributed {
+		stored.VoterID = nil
+	}
+
+	record.votes = append(record.votes, stored)
+	record.counts[vote.OptionID]++
+}
+
+// ballot returns the ballot of the poll with the token hash, nil if none.
+func (record *pollRecord) ballot(tokenHash []byte) *repository.Ballot {
+	for _, ballot := range record.ballots {
+		if bytes.Equal(ballot.TokenHash, tokenHash) {
+			return ballot
+		}
+	}
+	return nil
+}
+
+func (s *Store) CreateBallots(_ context.Context, ballots []*repository.Ballot) ([]*repository.Ballot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ballot := range ballots {
+		if _, ok := s.polls[ballot.PollID]; !ok {
+			return nil, errors.New("error inserting ballot: poll does not exist")
+		}
+	}
+
+	created := make([]*repository.Ballot, 0, len(ballots))
+	for _, ballot := range ballots {
+		record := s.polls[ballot.PollID]
+		if slices.ContainsFunc(record.ballots, func(b *repository.Ballot) bool {
+			return b.Recipient == ballot.Recipient
+		}) {
+			continue
+		}
+
+		stored := *ballot
+		stored.CreatedAt = truncate(ballot.CreatedAt)
+		record.ballots = append(record.ballots, &stored)
+		created = append(created, ballot)
+	}
+
+	return created, nil
+}
+
+func (s *Store) GetBallot(_ context.Context, pollID uuid.UUID, token string) (*repository.Ballot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.polls[pollID]
+	if !ok {
+		return nil, repository.ErrBallotNotFound
+	}
+
+	ballot := record.ballot(repository.HashBallotToken(token))
+	if ballot == nil {
+		return nil, repository.ErrBallotNotFound
+	}
+
+	found := *ballot
+	return &found, nil
+}
+
+func (s *Store) GetPollTurnout(_ context.Context, pollID uuid.UUID) (*repository.PollTurnout, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ballots := make([]repository.Ballot, 0)
+	if record, ok := s.polls[pollID]; ok {
+		for _, ballot := range record.ballots {
+			listed := *ballot
+			listed.TokenHash = nil
+			ballots = append(ballots, listed)
+		}
+	}
+
+	slices.SortFunc(ballots, func(a, b repository.Ballot) int {
+		return strings.Compare(a.Recipient, b.Recipient)
+	})
+
+	return repository.NewPollTurnout(pollID, ballots), nil
+}
+
+func (s *Store) GetPollVoters(_ context.Context, pollID uuid.UUID) (*repository.PollVoters, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.polls[pollID]
+	if !ok {
+		return nil, repository.ErrPollNotFound
+	}
+
+	voters := &repository.PollVoters{
+		PollID:      pollID,
+		Attribution: record.poll.Attribution,
+		Voters:      make([]repository.PollVoter, 0, len(record.voters)),
+	}
+
+	for userID := range record.voters {
+		voter := repository.PollVoter{UserID: userID, Username: s.users[userID].Username}
+		for _, vote := range record.votes {
+			if vote.VoterID != nil && *vote.VoterID == userID {
+				voter.OptionID = &vote.OptionID
+				voter.VotedAt = &vote.VotedAt
+			}
+		}
+		voters.Voters = append(voters.Voters, voter)
+	}
+
+	slices.SortFunc(voters.Voters, func(a, b repository.PollVoter) int {
+		return strings.Compare(string(a.Username), string(b.Username))
+	})
+
+	return voters, nil
+}
+
+func (s *Store) CreateImage(_ context.Context, arg repository.CreateImageParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	image := arg.Image
+
+	if _, ok := s.users[image.UserID]; !ok {
+		return errors.New("error inserting image: user does not exist")
+	}
+
+	if _, ok := s.images[image.ID]; ok {
+		return errors.New("error inserting image: image id already exists")
+	}
+
+	size := image.Size
+	for _, other := range s.images {
+		if other.UserID == image.UserID {
+			size += other.Size
+		}
+	}
+	if size > arg.Quota {
+		return repository.ErrImageQuotaExceeded
+	}
+
+	stored := *image
+	stored.CreatedAt = truncate(image.CreatedAt)
+	s.images[image.ID] = stored
+
+	return nil
+}
+
+func (s *Store) GetImage(_ context.Context, imageID uuid.UUID) (*repository.Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	image, ok := s.images[imageID]
+	if !ok {
+		return nil, repository.ErrImageNotFound
+	}
+
+	return &image, nil
+}
+
+func (s *Store) DeleteUnusedImages(_ context.Context, arg repository.DeleteUnusedImagesParams) ([]repository.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used := make(map[uuid.UUID]bool)
+	for _, record := range s.polls {
+		for _, option := range record.poll.Options {
+			if option.ImageID != nil {
+				used[*option.ImageID] = true
+			}
+		}
+	}
+
+	unused := make([]repository.Image, 0)
+	for _, image := range s.images {
+		if !used[image.ID] && image.CreatedAt.Before(arg.CreatedBefore) {
+			unused = append(unused, image)
+		}
+	}
+
+	slices.SortFunc(unused, func(a, b repository.Image) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	if len(unused) > arg.Limit {
+		unused = unused[:arg.Limit]
+	}
+
+	for _, image := range unused {
+		delete(s.images, image.ID)
+	}
+
+	return unused, nil
+}
+
+// otherOption returns the ID of the Other option of the poll, uuid.Nil if none.
+func (record *pollRecord) otherOption() uuid.UUID {
+	for _, option := range record.poll.Options {
+		if option.Other {
+			return option.ID
+		}
+	}
+	return uuid.Nil
+}
+
+func (s *Store) GetOtherAnswers(_ context.Context, arg repository.GetOtherAnswersParams) ([]repository.OtherAnswer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	answers := make([]repository.OtherAnswer, 0)
+
+	record, ok := s.polls[arg.PollID]
+	if !ok {
+		return answers, nil
+	}
+
+	otherID := record.otherOption()
+	for _, vote := range record.votes {
+		if vote.OptionID != otherID || (record.hidden[vote.ID] && !arg.IncludeHidden) {
+			continue
+		}
+		answers = append(answers, repository.OtherAnswer{
+			VoteID:  vote.ID,
+			Text:    vote.OtherText,
+			Hidden:  record.hidden[vote.ID],
+			VotedAt: vote.VotedAt,
+		})
+	}
+
+	slices.SortFunc(answers, func(a, b repository.OtherAnswer) int {
+		if c := a.VotedAt.Compare(b.VotedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.VoteID.String(), b.VoteID.String())
+	})
+
+	return answers, nil
+}
+
+func (s *Store) HideOtherAnswers(_ context.Context, arg repository.HideOtherAnswersParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.polls[arg.PollID]
+	if !ok {
+		return 0, nil
+	}
+
+	var (
+		otherID = record.otherOption()
+		matched int64
+	)
+	for _, vote := range record.votes {
+		if vote.OptionID != otherID || !slices.Contains(arg.VoteIDs, vote.ID) {
+			continue
+		}
+		if arg.Hidden {
+			record.hidden[vote.ID] = true
+		} else {
+			delete(record.hidden, vote.ID)
+		}
+		matched++
+	}
+
+	return matched, nil
+}
+
+func (s *Store) MergeOtherAnswers(_ context.Context, arg repository.MergeOtherAnswersParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.polls[arg.PollID]
+	if !ok {
+		return 0, repository.ErrOptionBelongsToPoll
+	}
+
+	otherID := record.otherOption()
+	if _, ok := record.counts[arg.OptionID]; !ok || arg.OptionID == otherID {
+		return 0, repository.ErrOptionBelongsToPoll
+	}
+
+	var merged int64
+	for i := range record.votes {
+		vote := &record.votes[i]
+		if vote.OptionID != otherID || !slices.Contains(arg.VoteIDs, vote.ID) {
+			continue
+		}
+		vote.OptionID = arg.OptionID
+		record.counts[otherID]--
+		record.counts[arg.OptionID]++
+		merged++
+	}
+
+	return merged, nil
+}
+
+func (s *Store) ReconcileVoteCounts(_ context.Context, pollID *uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var corrected int64
+	for id, record := range s.polls {
+		if pollID != nil && id != *pollID {
+			continue
+		}
+
+		actual := make(map[uuid.UUID]int, len(record.counts))
+		for _, vote := range record.votes {
+			actual[vote.OptionID]++
+		}
+
+		for optionID, count := range record.counts {
+			if actual[optionID] != count {
+				record.counts[optionID] = actual[optionID]
+				corrected++
+			}
+		}
+	}
+
+	return corrected, nil
+}
+
+func (s *Store) CreateWebhook(_ context.Context, webhook *repository.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[webhook.UserID]; !ok {
+		return errors.New("error inserting webhook: user does not exist")
+	}
+
+	if _, ok := s.webhooks[webhook.ID]; ok {
+		return errors.New("error inserting webhook: webhook id already exists")
+	}
+
+	if webhook.PollID != nil {
+		if _, ok := s.polls[*webhook.PollID]; !ok {
+			return repository.ErrPollNotFound
+		}
+	}
+
+	if len(webhook.Events) == 0 {
+		return errors.New("error inserting webhook: no events")
+	}
+
+	stored := *webhook
+	stored.Events = slices.Clone(webhook.Events)
+	stored.CreatedAt = truncate(webhook.CreatedAt)
+	s.webhooks[webhook.ID] = stored
+
+	return nil
+}
+
+func cloneWebhook(webhook repository.Webhook) repository.Webhook {
+	webhook.Events = slices.Clone(webhook.Events)
+	return webhook
+}
+
+func (s *Store) GetWebhook(_ context.Context, webhookID uuid.UUID) (*repository.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	webhook, ok := s.webhooks[webhookID]
+	if !ok {
+		return nil, repository.ErrWebhookNotFound
+	}
+
+	webhook = cloneWebhook(webhook)
+	return &webhook, nil
+}
+
+// sortWebhooks orders webhooks oldest first, as the Postgres repository.
+func sortWebhooks(webhooks []repository.Webhook) {
+	slices.SortFunc(webhooks, func(a, b repository.Webhook) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+}
+
+func (s *Store) GetUserWebhooks(_ context.Context, userID uuid.UUID) ([]repository.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	webhooks := make([]repository.Webhook, 0)
+	for _, webhook := range s.webhooks {
+		if webhook.UserID == userID {
+			webhooks = append(webhooks, cloneWebhook(webhook))
+		}
+	}
+
+	sortWebhooks(webhooks)
+
+	return webhooks, nil
+}
+
+func (s *Store) GetEventWebhooks(_ context.Context, arg repository.GetEventWebhooksParams) ([]repository.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.eventWebhooks(arg.UserID, arg.PollID, arg.EventType), nil
+}
+
+// eventWebhooks returns the webhooks subscribed to an event of the poll of the
+// user; s.mu must be held.
+func (s *Store) eventWebhooks(userID, pollID uuid.UUID, eventType string) []repository.Webhook {
+	webhooks := make([]repository.Webhook, 0)
+	for _, webhook := range s.webhooks {
+		matches := webhook.PollID == nil && webhook.UserID == userID ||
+			webhook.PollID != nil && *webhook.PollID == pollID
+		if matches && slices.Contains(webhook.Events, eventType) {
+			webhooks = append(webhooks, cloneWebhook(webhook))
+		}
+	}
+
+	sortWebhooks(webhooks)
+
+	return webhooks
+}
+
+// eventWebhookIDs returns the ids of eventWebhooks; s.mu must be held.
+func (s *Store) eventWebhookIDs(userID, pollID uuid.UUID, eventType events.Type) []uuid.UUID {
+	var webhookIDs []uuid.UUID
+	for _, webhook := range s.eventWebhooks(userID, pollID, string(eventType)) {
+		webhookIDs = append(webhookIDs, webhook.ID)
+	}
+	return webhookIDs
+}
+
+// queueEvent queues a delivery of the event to each webhook subscribed to it;
+// s.mu must be held for writing.
+func (s *Store) queueEvent(event events.Event) error {
+	deliveries, err := repository.EventDeliveries(event, s.eventWebhookIDs(event.UserID, event.PollID, event.Type))
+	if err != nil {
+		return err
+	}
+
+	return s.createWebhookDeliveries(deliveries)
+}
+
+func (s *Store) DeleteWebhook(_ context.Context, webhookID, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, ok := s.webhooks[webhookID]
+	if !ok || webhook.UserID != userID {
+		return repository.ErrWebhookNotFound
+	}
+
+	s.deleteWebhook(webhookID)
+
+	return nil
+}
+
+// deleteWebhook deletes a webhook with its deliveries. s.mu must be held for
+// writing.
+func (s *Store) deleteWebhook(webhookID uuid.UUID) {
+	delete(s.webhooks, webhookID)
+	s.deliveries = slices.DeleteFunc(s.deliveries, func(delivery *repository.WebhookDelivery) bool {
+		return delivery.WebhookID == webhookID
+	})
+}
+
+func (s *Store) CreateWebhookDeliveries(_ context.Context, deliveries []*repository.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createWebhookDeliveries(deliveries)
+}
+
+// createWebhookDeliveries queues the deliveries; s.mu must be held.
+func (s *Store) createWebhookDeliveries(deliveries []*repository.WebhookDelivery) error {
+	for _, delivery := range deliveries {
+		if _, ok := s.webhooks[delivery.WebhookID]; !ok {
+			return errors.New("error inserting webhook deliveries: webhook does not exist")
+		}
+	}
+
+	for _, delivery := range deliveries {
+		queued := slices.ContainsFunc(s.deliveries, func(d *repository.WebhookDelivery) bool {
+			return d.WebhookID == delivery.WebhookID && d.EventID == delivery.EventID
+		})
+		if queued {
+			continue
+		}
+
+		stored := *delivery
+		stored.NextAttemptAt = truncate(delivery.NextAttemptAt)
+		stored.CreatedAt = truncate(delivery.CreatedAt)
+		if delivery.LastAttemptAt != nil {
+			lastAttemptAt := truncate(*delivery.LastAttemptAt)
+			stored.LastAttemptAt = &lastAttemptAt
+		}
+		s.deliveries = append(s.deliveries, &stored)
+	}
+
+	return nil
+}
+
+func (s *Store) ClaimWebhookDeliveries(_ context.Context, arg repository.ClaimWebhookDeliveriesParams) ([]repository.ClaimedWebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*repository.WebhookDelivery
+	for _, delivery := range s.deliveries {
+		if delivery.Status == repository.WebhookDeliveryPending && !delivery.NextAttemptAt.After(arg.Now) {
+			due = append(due, delivery)
+		}
+	}
+
+	slices.SortStableFunc(due, func(a, b *repository.WebhookDelivery) int {
+		return a.NextAttemptAt.Compare(b.NextAttemptAt)
+	})
+
+	if len(due) > arg.Limit {
+		due = due[:arg.Limit]
+	}
+
+	var claimed []repository.ClaimedWebhookDelivery
+	for _, delivery := range due {
+		delivery.NextAttemptAt = truncate(arg.LeaseUntil)
+		webhook := s.webhooks[delivery.WebhookID]
+		claimed = append(claimed, repository.ClaimedWebhookDelivery{
+			WebhookDelivery: *delivery,
+			URL:             webhook.URL,
+			Secret:          webhook.Secret,
+		})
+	}
+
+	return claimed, nil
+}
+
+func (s *Store) CompleteWebhookDelivery(_ context.Context, arg repository.CompleteWebhookDeliveryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, delivery := range s.deliveries {
+		if delivery.ID != arg.DeliveryID {
+			continue
+		}
+
+		attemptedAt := truncate(arg.AttemptedAt)
+		delivery.Status = arg.Status
+		delivery.Attempts++
+		delivery.LastAttemptAt = &attemptedAt
+		delivery.ResponseStatus = arg.ResponseStatus
+		delivery.LastError = arg.Error
+		delivery.NextAttemptAt = truncate(arg.NextAttemptAt)
+	}
+
+	return nil
+}
+
+func (s *Store) GetWebhookDeliveries(_ context.Context, webhookID uuid.UUID, limit int) ([]repository.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deliveries := make([]repository.WebhookDelivery, 0)
+	for _, delivery := range s.deliveries {
+		if delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+
+	slices.SortFunc(deliveries, func(a, b repository.WebhookDelivery) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(b.ID[:], a.ID[:])
+	})
+
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,13 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/toramanomer/polly/repository"
+	"github.com/toramanomer/polly/repository/memory"
+	"github.com/toramanomer/polly/repository/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(*testing.T) repository.Store { return memory.New() })
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/primitives"
+)
+
+var (
+	ErrOrganizationNotFound  = errors.New("organization not found")
+	ErrNotOrganizationMember = errors.New("user is not a member of the organization")
+	ErrLastOrganizationOwner = errors.New("organization must keep at least one owner")
+)
+
+// OrganizationRole is the role of a member in an organization. Every role
+// grants the permissions of the roles below it:
+//
+//   - viewer sees the polls of the organization
+//   - member creates polls in the organization
+//   - admin deletes the polls of the organization and manages its members
+//   - owner also manages the other owners
+type OrganizationRole string
+
+const (
+	OrganizationRoleOwner  OrganizationRole = "owner"
+	OrganizationRoleAdmin  OrganizationRole = "admin"
+	OrganizationRoleMember OrganizationRole = "member"
+	OrganizationRoleViewer OrganizationRole = "viewer"
+)
+
+func (r OrganizationRole) Valid() bool {
+	return r.rank() > 0
+}
+
+func (r OrganizationRole) rank() int {
+	switch r {
+	case OrganizationRoleOwner:
+		return 4
+	case OrganizationRoleAdmin:
+		return 3
+	case OrganizationRoleMember:
+		return 2
+	case OrganizationRoleViewer:
+		return 1
+	}
+	return 0
+}
+
+// AtLeast reports whether r grants every permission of role.
+func (r OrganizationRole) AtLeast(role OrganizationRole) bool {
+	return r.Valid() && r.rank() >= role.rank()
+}
+
+type Organization struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func NewOrganization(name string) *Organization {
+	return &Organization{
+		ID:        uuid.New(),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+}
+
+// OrganizationMembership is an organization of a user with the user's role.
+type OrganizationMembership struct {
+	Organization
+	Role OrganizationRole `json:"role"`
+}
+
+type OrganizationMember struct {
+	UserID   uuid.UUID           `json:"userID"`
+	Username primitives.Username `json:"username"`
+	Role     OrganizationRole    `json:"role"`
+}
+
+type SetOrganizationMemberParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Role           OrganizationRole
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/primitives"
+)
+
+// OtherOptionText is the text of the Other option of a poll.
+const OtherOptionText primitives.OptionText = "Other"
+
+// OtherAnswer is the answer given with a vote for the Other option of a poll.
+type OtherAnswer struct {
+	VoteID  uuid.UUID             `json:"voteID"`
+	Text    primitives.AnswerText `json:"text"`
+	Hidden  bool                  `json:"hidden"`
+	VotedAt time.Time             `json:"votedAt"`
+}
+
+type GetOtherAnswersParams struct {
+	PollID        uuid.UUID
+	IncludeHidden bool
+}
+
+// HideOtherAnswersParams hides or shows answers of the Other option.
+type HideOtherAnswersParams struct {
+	PollID  uuid.UUID
+	VoteIDs []uuid.UUID
+	Hidden  bool
+}
+
+// MergeOtherAnswersParams moves votes of the Other option to another option of
+// the poll, e.g. because their answers name it.
+type MergeOtherAnswersParams struct {
+	PollID   uuid.UUID
+	VoteIDs  []uuid.UUID
+	OptionID uuid.UUID
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -13,54 +15,233 @@ var (
 	ErrNotPollOwner        = errors.New("user is not the owner of the poll")
 	ErrOptionBelongsToPoll = errors.New("option does not belong to the poll")
 	ErrPollExpired         = errors.New("poll expired")
+	ErrInvalidCursor       = errors.New("invalid cursor")
 )
 
+// MaxPollOptions is the most options a poll can have, the Other option
+// included, as bounded by the schema. Configured limits may only be lower.
+const MaxPollOptions = 50
+
 type PollOption struct {
-	ID       uuid.UUID `json:"id"`
-	PollID   uuid.UUID `json:"pollID"`
-	Text     string    `json:"text"`
-	Position int       `json:"position"`
-	Count    int       `json:"count"`
+	ID       uuid.UUID             `json:"id"`
+	PollID   uuid.UUID             `json:"pollID"`
+	Text     primitives.OptionText `json:"text"`
+	Position int                   `json:"position"`
+	Count    int                   `json:"count"`
+	// Other is set on the Other option, whose voters give their own answer.
+	Other bool `json:"other"`
+	// Description, ImageID and URL are optional. The image is served at
+	// /api/images/{imageID}.
+	Description string     `json:"description,omitempty"`
+	ImageID     *uuid.UUID `json:"imageID,omitempty"`
+	URL         string     `json:"url,omitempty"`
+}
+
+// PollVisibility controls who can see and vote on a poll. The owner always
+// can.
+type PollVisibility string
+
+const (
+	// PollVisibilityPublic polls are listed and open to everyone.
+	PollVisibilityPublic PollVisibility = "public"
+	// PollVisibilityUnlisted polls are open to everyone with the link.
+	PollVisibilityUnlisted PollVisibility = "unlisted"
+	// PollVisibilityPrivate polls are open to the invited users only.
+	PollVisibilityPrivate PollVisibility = "private"
+	// PollVisibilityPasscode polls are open to everyone with the passcode.
+	PollVisibilityPasscode PollVisibility = "passcode"
+	// PollVisibilityBallot polls are open to the holders of a ballot, which
+	// is used up by their vote.
+	PollVisibilityBallot PollVisibility = "ballot"
+)
+
+func (v PollVisibility) Valid() bool {
+	switch v {
+	case PollVisibilityPublic, PollVisibilityUnlisted, PollVisibilityPrivate, PollVisibilityPasscode, PollVisibilityBallot:
+		return true
+	}
+	return false
 }
 
 type Poll struct {
-	ID        uuid.UUID           `json:"id"`
-	UserID    uuid.UUID           `json:"userID"`
-	Question  primitives.Question `json:"question"`
-	CreatedAt time.Time           `json:"createdAt"`
-	ExpiresAt time.Time           `json:"expiresAt"`
-	Options   []PollOption        `json:"options"`
+	ID         uuid.UUID           `json:"id"`
+	UserID     uuid.UUID           `json:"userID"`
+	Question   primitives.Question `json:"question"`
+	Visibility PollVisibility      `json:"visibility"`
+	// Attribution tells voters whether their vote is linked to them.
+	Attribution PollAttribution `json:"attribution"`
+	// OrganizationID is set on polls owned by an organization. UserID is
+	// then the member who created it.
+	OrganizationID *uuid.UUID `json:"organizationID"`
+	// PasscodeHash and Invitees are only set on a new poll. They are never
+	// read back with the poll; see GetPollAccess.
+	PasscodeHash string      `json:"-"`
+	Invitees     []uuid.UUID `json:"-"`
+	// Version is incremented whenever the poll definition changes.
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// TimeZone is the IANA time zone the times of the poll are displayed in.
+	TimeZone string       `json:"timeZone"`
+	Options  []PollOption `json:"options"`
+}
+
+// NewPollOption is an option of a new poll. Only Text is required.
+type NewPollOption struct {
+	Text        primitives.OptionText
+	Description string
+	ImageID     *uuid.UUID
+	URL         string
 }
 
 type NewPollParams struct {
-	UserID    uuid.UUID
-	Question  primitives.Question
-	ExpiresAt time.Time
-	Options   []string
+	UserID     uuid.UUID
+	Question   primitives.Question
+	ExpiresAt  time.Time
+	Options    []NewPollOption
+	Visibility PollVisibility
+	// Attribution defaults to anonymous.
+	Attribution PollAttribution
+	// OrganizationID makes the poll owned by an organization.
+	OrganizationID *uuid.UUID
+	// Passcode is required by PollVisibilityPasscode and Invitees are the
+	// users invited to a PollVisibilityPrivate poll.
+	Passcode primitives.Passcode
+	Invitees []uuid.UUID
+	// AllowOther adds an Other option after Options.
+	AllowOther bool
+	// TimeZone defaults to DefaultTimeZone.
+	TimeZone string
 }
 
+// DefaultTimeZone is the time zone of polls created without one, as in the
+// polls table.
+const DefaultTimeZone = "UTC"
+
 func NewPoll(params NewPollParams) *Poll {
 
 	pollID := uuid.New()
-	pollOptions := make([]PollOption, len(params.Options))
+	pollOptions := make([]PollOption, len(params.Options), len(params.Options)+1)
 
 	for i, option := range params.Options {
 		pollOptions[i] = PollOption{
+			ID:          uuid.New(),
+			PollID:      pollID,
+			Text:        option.Text,
+			Position:    i,
+			Description: option.Description,
+			ImageID:     option.ImageID,
+			URL:         option.URL,
+		}
+	}
+
+	if params.AllowOther {
+		pollOptions = append(pollOptions, PollOption{
 			ID:       uuid.New(),
 			PollID:   pollID,
-			Text:     option,
-			Position: i,
-		}
+			Text:     OtherOptionText,
+			Position: len(params.Options),
+			Other:    true,
+		})
 	}
 
-	return &Poll{
-		ID:        pollID,
-		UserID:    params.UserID,
-		Question:  params.Question,
-		CreatedAt: time.Now(),
-		ExpiresAt: params.ExpiresAt,
-		Options:   pollOptions,
+	// Polls are unlisted and anonymous unless stated otherwise, as in the
+	// polls table.
+	if params.Visibility == "" {
+		params.Visibility = PollVisibilityUnlisted
+	}
+	if params.Attribution == "" {
+		params.Attribution = PollAttributionAnonymous
+	}
+	if params.TimeZone == "" {
+		params.TimeZone = DefaultTimeZone
 	}
+
+	now := time.Now()
+
+	poll := &Poll{
+		ID:             pollID,
+		UserID:         params.UserID,
+		Question:       params.Question,
+		Visibility:     params.Visibility,
+		Attribution:    params.Attribution,
+		OrganizationID: params.OrganizationID,
+		Version:        1,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ExpiresAt:      params.ExpiresAt,
+		TimeZone:       params.TimeZone,
+		Options:        pollOptions,
+	}
+
+	switch params.Visibility {
+	case PollVisibilityPasscode:
+		poll.PasscodeHash = params.Passcode.Hash()
+	case PollVisibilityPrivate:
+		poll.Invitees = params.Invitees
+	}
+
+	return poll
+}
+
+// ClosedPoll is a poll that expired and was closed by CloseExpiredPolls.
+type ClosedPoll struct {
+	PollID         uuid.UUID
+	UserID         uuid.UUID
+	OrganizationID *uuid.UUID
+	Question       primitives.Question
+	ExpiresAt      time.Time
+	ClosedAt       time.Time
+}
+
+type CloseExpiredPollsParams struct {
+	// Now closes the polls that expired at or before it.
+	Now time.Time
+	// Limit is the most polls closed at once.
+	Limit int
+}
+
+// PollAccess is what decides whether a user who does not own a private or
+// passcode protected poll can see it.
+type PollAccess struct {
+	PasscodeHash string
+	Invited      bool
+	// OrganizationRole is the role of the user in the organization owning
+	// the poll, empty if there is none.
+	OrganizationRole OrganizationRole
+}
+
+// VerifyPasscode reports whether passcode opens the poll.
+func (a *PollAccess) VerifyPasscode(passcode primitives.Passcode) bool {
+	return a.PasscodeHash != "" && passcode.Verify(a.PasscodeHash)
+}
+
+// PollTally is the current vote count of every option of a poll and the time
+// of the latest vote.
+type PollTally struct {
+	Counts     map[uuid.UUID]int
+	LastVoteAt *time.Time
+}
+
+// WithTally returns a copy of the poll with the counts of tally.
+func (p *Poll) WithTally(tally *PollTally) *Poll {
+	poll := *p
+	poll.Options = make([]PollOption, len(p.Options))
+
+	for i, option := range p.Options {
+		option.Count = tally.Counts[option.ID]
+		poll.Options[i] = option
+	}
+
+	return &poll
+}
+
+// PublishesVotes reports whether a vote.recorded event is published for every
+// vote on the poll. Verified and ballot polls keep their voters apart from
+// their choices, which the owner could match to the events as they come in.
+func (p *Poll) PublishesVotes() bool {
+	return p.Attribution != PollAttributionVerified && p.Visibility != PollVisibilityBallot
 }
 
 type Vote struct {
@@ -68,6 +249,14 @@ type Vote struct {
 	PollID   uuid.UUID `json:"pollID"`
 	OptionID uuid.UUID `json:"optionID"`
 	VotedAt  time.Time `json:"votedAt"`
+	// BallotToken is the ballot a vote on a PollVisibilityBallot poll uses
+	// up. It is not stored with the vote.
+	BallotToken string `json:"-"`
+	// OtherText is the answer of a vote for the Other option.
+	OtherText primitives.AnswerText `json:"otherText,omitempty"`
+	// VoterID is the signed in user voting, if any. Votes on anonymous polls
+	// never store it.
+	VoterID *uuid.UUID `json:"-"`
 }
 
 func NewVote(pollID uuid.UUID, optionID uuid.UUID) *Vote {
@@ -78,3 +267,95 @@ func NewVote(pollID uuid.UUID, optionID uuid.UUID) *Vote {
 		VotedAt:  time.Now(),
 	}
 }
+
+type PollStatus string
+
+const (
+	PollStatusActive  PollStatus = "active"
+	PollStatusExpired PollStatus = "expired"
+)
+
+type PollSort string
+
+const (
+	PollSortCreatedAtDesc PollSort = "-createdAt"
+	PollSortCreatedAtAsc  PollSort = "createdAt"
+	PollSortExpiresAtDesc PollSort = "-expiresAt"
+	PollSortExpiresAtAsc  PollSort = "expiresAt"
+)
+
+func (s PollSort) Valid() bool {
+	switch s {
+	case PollSortCreatedAtDesc, PollSortCreatedAtAsc, PollSortExpiresAtDesc, PollSortExpiresAtAsc:
+		return true
+	}
+	return false
+}
+
+// column returns the polls column the sort orders by and whether the order
+// is descending. Only these fixed identifiers are ever interpolated into SQL.
+func (s PollSort) column() (string, bool) {
+	switch s {
+	case PollSortCreatedAtAsc:
+		return "created_at", false
+	case PollSortExpiresAtDesc:
+		return "expires_at", true
+	case PollSortExpiresAtAsc:
+		return "expires_at", false
+	default:
+		return "created_at", true
+	}
+}
+
+// PollCursor points at the last poll of a page. It is only valid for the sort
+// it was issued for.
+type PollCursor struct {
+	Sort  PollSort  `json:"s"`
+	Value time.Time `json:"v"`
+	ID    uuid.UUID `json:"i"`
+}
+
+func (c PollCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodePollCursor(encoded string) (*PollCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor PollCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || !cursor.Sort.Valid() {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// GetUserPollsParams selects the polls a user created and those of the
+// organizations the user is a member of, or only those of OrganizationID.
+type GetUserPollsParams struct {
+	UserID         uuid.UUID
+	OrganizationID *uuid.UUID
+	Status         PollStatus
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	Search         string
+	Sort           PollSort
+	Cursor         *PollCursor
+	Limit          int
+}
+
+// ListPublicPollsParams selects a page of the active public polls, newest
+// first.
+type ListPublicPollsParams struct {
+	Cursor *PollCursor
+	Limit  int
+}
+
+type PollPage struct {
+	Polls      []Poll `json:"polls"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
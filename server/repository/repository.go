@@ -4,13 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/toramanomer/polly/events"
 	"github.com/toramanomer/polly/primitives"
 )
 
@@ -25,11 +28,11 @@ func NewRepository(db *pgxpool.Pool) *Repository {
 }
 
 const createUser = `
-	INSERT INTO users (id, username, email, password_hash)
-	VALUES ($1, $2, $3, $4)`
+	INSERT INTO users (id, username, email, password_hash, plan)
+	VALUES ($1, $2, $3, $4, $5)`
 
 func (r *Repository) CreateUser(ctx context.Context, user *User) error {
-	_, err := r.db.Exec(ctx, createUser, user.ID, user.Username, user.Email, user.PasswordHash)
+	_, err := r.db.Exec(ctx, createUser, user.ID, user.Username, user.Email, user.PasswordHash, user.Plan)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -44,13 +47,14 @@ func (r *Repository) CreateUser(ctx context.Context, user *User) error {
 				}
 			}
 		}
+		return wrapError(ctx, "error inserting user", err)
 	}
 
-	return err
+	return nil
 }
 
 const getUserByEmail = `
-	SELECT id, username, email, password_hash
+	SELECT id, username, email, password_hash, plan
 	FROM users
 	WHERE email = $1`
 
@@ -59,33 +63,157 @@ func (r *Repository) GetUserByEmail(ctx context.Context, email primitives.Email)
 
 	err := r.db.
 		QueryRow(ctx, getUserByEmail, email).
-		Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash)
+		Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Plan)
 
 	if err != nil {
-		return nil, err
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, wrapError(ctx, "error querying user", err)
+	}
+
+	return &user, nil
+}
+
+const getUserByID = `
+	SELECT id, username, email, password_hash, plan
+	FROM users
+	WHERE id = $1`
+
+func (r *Repository) GetUserByID(ctx context.Context, userID uuid.UUID) (*User, error) {
+	var user User
+
+	err := r.db.
+		QueryRow(ctx, getUserByID, userID).
+		Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Plan)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, wrapError(ctx, "error querying user", err)
+	}
+
+	return &user, nil
+}
+
+const getUsersByUsernames = `
+	SELECT id, username, email, password_hash, plan
+	FROM users
+	WHERE username = ANY($1)`
+
+// GetUsersByUsernames returns the users with the given usernames. Usernames
+// without a user are left out.
+func (r *Repository) GetUsersByUsernames(ctx context.Context, usernames []primitives.Username) ([]User, error) {
+	rows, err := r.db.Query(ctx, getUsersByUsernames, usernames)
+	if err != nil {
+		return nil, wrapError(ctx, "error querying users", err)
+	}
+	defer rows.Close()
+
+	users := make([]User, 0, len(usernames))
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Plan); err != nil {
+			return nil, wrapError(ctx, "error scanning user", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(ctx, "error iterating users", err)
+	}
+
+	return users, nil
+}
+
+const getChatUser = `
+	SELECT u.id, u.username, u.email, u.password_hash, u.plan
+	FROM chat_accounts a
+	JOIN users u ON u.id = a.user_id
+	WHERE a.provider = $1 AND a.team_id = $2 AND a.chat_user_id = $3`
+
+// createChatUser creates the user standing in for a chat account and links
+// it. A concurrent creation takes the username first, so nothing is created.
+const createChatUser = `
+	WITH new_user AS (
+		INSERT INTO users (id, username, email, password_hash, plan)
+		VALUES ($4, $5, $6, $7, $8)
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	)
+	INSERT INTO chat_accounts (provider, team_id, chat_user_id, user_id)
+	SELECT $1, $2, $3, id
+	FROM new_user`
+
+// GetOrCreateChatUser returns the user standing in for the chat account,
+// creating it on first use.
+func (r *Repository) GetOrCreateChatUser(ctx context.Context, account ChatAccount) (*User, error) {
+	user, err := r.getChatUser(ctx, account)
+	if !errors.Is(err, ErrUserNotFound) {
+		return user, err
+	}
+
+	user = account.NewUser()
+	if _, err := r.db.Exec(ctx, createChatUser,
+		account.Provider, account.TeamID, account.UserID,
+		user.ID, user.Username, user.Email, user.PasswordHash, user.Plan,
+	); err != nil {
+		return nil, wrapError(ctx, "error inserting chat user", err)
+	}
+
+	return r.getChatUser(ctx, account)
+}
+
+func (r *Repository) getChatUser(ctx context.Context, account ChatAccount) (*User, error) {
+	var user User
+
+	err := r.db.
+		QueryRow(ctx, getChatUser, account.Provider, account.TeamID, account.UserID).
+		Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Plan)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, wrapError(ctx, "error querying chat user", err)
 	}
 
 	return &user, nil
 }
 
 const insertPoll = `
-	INSERT INTO polls (id, user_id, question, created_at, expires_at)
-	VALUES ($1, $2, $3, $4, $5)`
+	INSERT INTO polls (id, user_id, organization_id, question, visibility, attribution, passcode_hash, version, created_at, updated_at, expires_at, time_zone)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11, $12)`
+
+const insertPollInvitees = `
+	INSERT INTO poll_invitees (poll_id, user_id)
+	SELECT $1, unnest($2::uuid[])
+	ON CONFLICT DO NOTHING`
 
-const insertPollOptions = "INSERT INTO poll_options (id, poll_id, text, position) VALUES"
+const insertPollOptions = "INSERT INTO poll_options (id, poll_id, text, position, is_other, description, image_id, url) VALUES"
 
 func (r *Repository) CreatePollWithOptions(ctx context.Context, poll *Poll) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %w", err)
+		return wrapError(ctx, "error starting transaction", err)
 	}
 	defer tx.Rollback(ctx)
 
 	//-------------------- Insert poll
 	_, err = tx.Exec(ctx, insertPoll,
-		poll.ID, poll.UserID, poll.Question, poll.CreatedAt, poll.ExpiresAt)
+		poll.ID, poll.UserID, poll.OrganizationID, poll.Question, poll.Visibility, poll.Attribution, poll.PasscodeHash,
+		poll.Version, poll.CreatedAt, poll.UpdatedAt, poll.ExpiresAt, poll.TimeZone)
 	if err != nil {
-		return fmt.Errorf("error inserting poll: %w", err)
+		return wrapError(ctx, "error inserting poll", err)
+	}
+	//--------------------
+
+	//-------------------- Insert poll invitees
+	if len(poll.Invitees) > 0 {
+		if _, err := tx.Exec(ctx, insertPollInvitees, poll.ID, poll.Invitees); err != nil {
+			return wrapError(ctx, "error inserting poll invitees", err)
+		}
 	}
 	//--------------------
 
@@ -97,14 +225,16 @@ func (r *Repository) CreatePollWithOptions(ctx context.Context, poll *Poll) erro
 	)
 
 	for i, option := range poll.Options {
-		baseIndex := i * 4
+		baseIndex := i * 8
 		valueStrings = append(valueStrings,
 			fmt.Sprintf(
-				"($%d, $%d, $%d, $%d)",
-				baseIndex+1, baseIndex+2, baseIndex+3, baseIndex+4),
+				"($%d, $%d, $%d, $%d, $%d, NULLIF($%d, ''), $%d, NULLIF($%d, ''))",
+				baseIndex+1, baseIndex+2, baseIndex+3, baseIndex+4, baseIndex+5,
+				baseIndex+6, baseIndex+7, baseIndex+8),
 		)
 		valueArgs = append(valueArgs,
-			option.ID, option.PollID, option.Text, option.Position)
+			option.ID, option.PollID, option.Text, option.Position, option.Other,
+			option.Description, option.ImageID, option.URL)
 	}
 
 	optionsQuery := fmt.Sprintf(
@@ -113,75 +243,245 @@ func (r *Repository) CreatePollWithOptions(ctx context.Context, poll *Poll) erro
 
 	_, err = tx.Exec(ctx, optionsQuery, valueArgs...)
 	if err != nil {
-		return fmt.Errorf("error inserting poll options: %w", err)
+		return wrapError(ctx, "error inserting poll options", err)
+	}
+	//--------------------
+
+	//-------------------- Queue poll.created deliveries
+	if err := queueEvent(ctx, tx, PollCreatedEvent(poll)); err != nil {
+		return wrapError(ctx, "error queueing poll.created deliveries", err)
 	}
 	//--------------------
 
 	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
+		return wrapError(ctx, "error committing transaction", err)
 	}
 
 	return nil
 }
 
+// deletePoll deletes a personal poll of the user or a poll of an organization
+// the user is an admin or owner of. Organization polls are decided by the role
+// alone, so their creators lose them along with their role.
 const deletePoll = `
 	WITH
 		to_delete AS (
-			SELECT true AS exists, (user_id = $2) AS is_owner
-			FROM polls
-			WHERE id = $1
+			SELECT
+				true AS exists,
+				(
+					(p.organization_id IS NULL AND p.user_id = $2) OR
+					EXISTS (
+						SELECT 1
+						FROM organization_members m
+						WHERE
+							m.organization_id = p.organization_id AND
+							m.user_id = $2 AND
+							m.role IN ('owner', 'admin')
+					)
+				) AS may_delete
+			FROM polls p
+			WHERE p.id = $1
 		),
 		deleted	AS (
 			DELETE FROM polls
-			WHERE id = $1 AND user_id = $2
-			RETURNING true AS deleted
+			WHERE id = $1 AND (SELECT may_delete FROM to_delete)
+			RETURNING user_id, organization_id, question
 		)
 	SELECT
 		COALESCE ((SELECT exists FROM to_delete), false) AS poll_exists,
-		COALESCE ((SELECT is_owner FROM to_delete), false) AS is_owner,
-		COALESCE ((SELECT deleted FROM deleted), false) AS deleted`
+		COALESCE ((SELECT may_delete FROM to_delete), false) AS may_delete,
+		EXISTS (SELECT 1 FROM deleted) AS deleted,
+		(SELECT user_id FROM deleted),
+		(SELECT organization_id FROM deleted),
+		(SELECT question FROM deleted)`
 
 type DeletePollParams struct {
 	PollID uuid.UUID
 	UserID uuid.UUID
 }
 
+// DeletePoll deletes the poll and queues its poll.deleted deliveries in the
+// same transaction. Webhooks of the poll alone are deleted along with it.
 func (r *Repository) DeletePoll(ctx context.Context, arg DeletePollParams) error {
-	row := r.db.QueryRow(ctx, deletePoll, arg.PollID, arg.UserID)
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return wrapError(ctx, "error starting transaction", err)
+	}
+	defer tx.Rollback(ctx)
 
-	var pollExists, isOwner, deleted bool
-	err := row.Scan(&pollExists, &isOwner, &deleted)
+	var (
+		pollExists, mayDelete, deleted bool
+		userID, organizationID         *uuid.UUID
+		question                       *primitives.Question
+	)
+	err = tx.QueryRow(ctx, deletePoll, arg.PollID, arg.UserID).
+		Scan(&pollExists, &mayDelete, &deleted, &userID, &organizationID, &question)
 
 	if err != nil {
-		return fmt.Errorf("error deleting poll: %w", err)
+		return wrapError(ctx, "error deleting poll", err)
 	}
 
 	switch {
 	case !pollExists:
 		return ErrPollNotFound
-	case !isOwner:
+	case !mayDelete:
 		return ErrNotPollOwner
 	case !deleted:
 		return errors.New("unknown error occurred while deleting poll")
 	}
 
+	if err := queueEvent(ctx, tx, PollDeletedEvent(arg.PollID, *userID, organizationID, *question)); err != nil {
+		return wrapError(ctx, "error queueing poll.deleted deliveries", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapError(ctx, "error committing transaction", err)
+	}
+
 	return nil
 }
 
+// closeExpiredPolls closes the polls that expired and are not closed yet,
+// oldest first. Polls locked by a concurrent sweep are left to it.
+const closeExpiredPolls = `
+	WITH expired AS (
+		SELECT id
+		FROM polls
+		WHERE closed_at IS NULL AND expires_at <= $1
+		ORDER BY expires_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	UPDATE polls p
+	SET closed_at = $1
+	FROM expired
+	WHERE p.id = expired.id
+	RETURNING p.id, p.user_id, p.organization_id, p.question, p.expires_at, p.closed_at`
+
+// getClosedPollWebhooks pairs every closed poll, given as parallel arrays of
+// poll and owner ids, with the webhooks subscribed to its poll.closed event.
+const getClosedPollWebhooks = `
+	SELECT closed.id, webhooks.id
+	FROM unnest($1::uuid[], $2::uuid[]) AS closed(id, user_id)
+	JOIN webhooks ON webhooks.poll_id = closed.id OR (webhooks.poll_id IS NULL AND webhooks.user_id = closed.user_id)
+	WHERE $3 = ANY(webhooks.events)`
+
+// CloseExpiredPolls marks up to arg.Limit expired polls closed and returns
+// them. The poll.closed deliveries to the webhooks subscribed to them are
+// queued in the same transaction, so no closing goes unannounced.
+func (r *Repository) CloseExpiredPolls(ctx context.Context, arg CloseExpiredPollsParams) ([]ClosedPoll, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, wrapError(ctx, "error starting transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, closeExpiredPolls, arg.Now, arg.Limit)
+	if err != nil {
+		return nil, wrapError(ctx, "error closing expired polls", err)
+	}
+
+	var closed []ClosedPoll
+	for rows.Next() {
+		var poll ClosedPoll
+		if err := rows.Scan(
+			&poll.PollID,
+			&poll.UserID,
+			&poll.OrganizationID,
+			&poll.Question,
+			&poll.ExpiresAt,
+			&poll.ClosedAt,
+		); err != nil {
+			rows.Close()
+			return nil, wrapError(ctx, "error scanning closed poll", err)
+		}
+		closed = append(closed, poll)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(ctx, "error iterating closed polls", err)
+	}
+
+	if len(closed) == 0 {
+		return nil, nil
+	}
+
+	var (
+		pollIDs = make([]uuid.UUID, 0, len(closed))
+		userIDs = make([]uuid.UUID, 0, len(closed))
+	)
+	for _, poll := range closed {
+		pollIDs, userIDs = append(pollIDs, poll.PollID), append(userIDs, poll.UserID)
+	}
+
+	rows, err = tx.Query(ctx, getClosedPollWebhooks, pollIDs, userIDs, string(events.PollClosed))
+	if err != nil {
+		return nil, wrapError(ctx, "error querying closed poll webhooks", err)
+	}
+
+	webhookIDs, err := scanPollWebhookIDs(rows)
+	if err != nil {
+		return nil, wrapError(ctx, "error scanning closed poll webhooks", err)
+	}
+
+	deliveries, err := ClosedPollDeliveries(closed, webhookIDs)
+	if err != nil {
+		return nil, wrapError(ctx, "error encoding poll.closed events", err)
+	}
+
+	if err := tx.SendBatch(ctx, webhookDeliveriesBatch(deliveries)).Close(); err != nil {
+		return nil, wrapError(ctx, "error inserting webhook deliveries", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, wrapError(ctx, "error committing transaction", err)
+	}
+
+	return closed, nil
+}
+
+// scanPollWebhookIDs collects pairs of poll and webhook ids by poll and closes
+// the rows.
+func scanPollWebhookIDs(rows pgx.Rows) (map[uuid.UUID][]uuid.UUID, error) {
+	defer rows.Close()
+
+	webhookIDs := make(map[uuid.UUID][]uuid.UUID)
+	for rows.Next() {
+		var pollID, webhookID uuid.UUID
+		if err := rows.Scan(&pollID, &webhookID); err != nil {
+			return nil, err
+		}
+		webhookIDs[pollID] = append(webhookIDs[pollID], webhookID)
+	}
+
+	return webhookIDs, rows.Err()
+}
+
 const getPollWithOptions = `
 	SELECT
 		p.id,
 		p.user_id,
+		p.organization_id,
 		p.question,
+		p.visibility,
+		p.attribution,
+		p.version,
 		p.created_at,
+		p.updated_at,
 		p.expires_at,
+		p.time_zone,
 		COALESCE(
 		(
 			SELECT jsonb_agg(json_build_object(
 				'id', o.id,
 				'poll_id', o.poll_id,
 				'text', o.text,
-				'position', o.position
+				'position', o.position,
+				'other', o.is_other,
+				'description', o.description,
+				'imageID', o.image_id,
+				'url', o.url
 			) ORDER BY o.position)
 			FROM poll_options o
 			WHERE o.poll_id = p.id
@@ -198,19 +498,113 @@ func (r *Repository) GetPollWithOptions(ctx context.Context, pollID uuid.UUID) (
 	err := row.Scan(
 		&poll.ID,
 		&poll.UserID,
+		&poll.OrganizationID,
 		&poll.Question,
+		&poll.Visibility,
+		&poll.Attribution,
+		&poll.Version,
 		&poll.CreatedAt,
+		&poll.UpdatedAt,
 		&poll.ExpiresAt,
+		&poll.TimeZone,
 		&poll.Options,
 	)
 
-	return &poll, err
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPollNotFound
+		}
+		return nil, wrapError(ctx, "error querying poll", err)
+	}
+
+	return &poll, nil
 }
 
+// getPollTally reads the maintained vote counters of a poll and its latest
+// vote, which the (poll_id, voted_at) index answers without a scan.
+const getPollTally = `
+	SELECT
+		COALESCE(
+			jsonb_object_agg(o.id, o.vote_count) FILTER (WHERE o.id IS NOT NULL),
+			'{}'
+		) AS counts,
+		(SELECT MAX(voted_at) FROM votes WHERE poll_id = p.id) AS last_vote_at
+	FROM polls p
+	LEFT JOIN poll_options o ON o.poll_id = p.id
+	WHERE p.id = $1
+	GROUP BY p.id`
+
+func (r *Repository) GetPollTally(ctx context.Context, pollID uuid.UUID) (*PollTally, error) {
+	var tally PollTally
+
+	err := r.db.
+		QueryRow(ctx, getPollTally, pollID).
+		Scan(&tally.Counts, &tally.LastVoteAt)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPollNotFound
+		}
+		return nil, wrapError(ctx, "error querying poll tally", err)
+	}
+
+	return &tally, nil
+}
+
+const getPollAccess = `
+	SELECT
+		COALESCE(p.passcode_hash, ''),
+		EXISTS (
+			SELECT 1
+			FROM poll_invitees
+			WHERE poll_id = p.id AND user_id = $2
+		) AS invited,
+		COALESCE(
+			(
+				SELECT role
+				FROM organization_members
+				WHERE organization_id = p.organization_id AND user_id = $2
+			),
+			''
+		) AS organization_role
+	FROM polls p
+	WHERE p.id = $1`
+
+// GetPollAccess reads the passcode hash of a poll, whether userID is invited
+// to it and the role of userID in the organization owning it. userID may be
+// uuid.Nil for anonymous users.
+func (r *Repository) GetPollAccess(ctx context.Context, pollID, userID uuid.UUID) (*PollAccess, error) {
+	var access PollAccess
+
+	err := r.db.
+		QueryRow(ctx, getPollAccess, pollID, userID).
+		Scan(&access.PasscodeHash, &access.Invited, &access.OrganizationRole)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPollNotFound
+		}
+		return nil, wrapError(ctx, "error querying poll access", err)
+	}
+
+	return &access, nil
+}
+
+// recordVote inserts a vote if the poll is active and the option belongs to
+// it. A vote on a poll that is not anonymous also registers its voter, which
+// fails if they voted before, and a vote on a ballot poll has to use up an
+// unused ballot of the poll. Concurrent votes of the same voter or with the
+// same ballot serialise on its row and only the first one succeeds. Each step
+// only runs if the previous one succeeded; RecordVote rolls back the
+// registered voter or used ballot of a vote that was not inserted.
 const recordVote = `
 	WITH
 		poll_found AS (
-			SELECT expires_at
+			SELECT
+				expires_at,
+				visibility = 'ballot' AS ballot_required,
+				attribution,
+				attribution <> 'anonymous' AS voter_required
 			FROM polls
 			WHERE id = $2
 		),
@@ -224,95 +618,1479 @@ const recordVote = `
 			FROM poll_options
 			WHERE id = $3 AND poll_id = $2
 		),
-		insert_vote AS (
-			INSERT INTO votes (id, poll_id, option_id, voted_at)
-			SELECT $1, $2, $3, $4
+		register_voter AS (
+			INSERT INTO poll_voters (poll_id, user_id)
+			SELECT $2, $7
 			WHERE
+				$7::uuid IS NOT NULL AND
+				(SELECT voter_required FROM poll_found) AND
 				EXISTS (SELECT 1 FROM poll_active) AND
 				EXISTS (SELECT 1 FROM option_valid)
+			ON CONFLICT DO NOTHING
+			RETURNING 1
+		),
+		voter_valid AS (
+			SELECT 1 AS ok
+			FROM poll_found
+			WHERE NOT voter_required OR EXISTS (SELECT 1 FROM register_voter)
+		),
+		ballot_found AS (
+			SELECT id
+			FROM poll_ballots
+			WHERE poll_id = $2 AND token_hash = $6
+		),
+		use_ballot AS (
+			UPDATE poll_ballots
+			SET used = true
+			WHERE
+				id = (SELECT id FROM ballot_found) AND
+				NOT used AND
+				EXISTS (SELECT 1 FROM poll_active) AND
+				EXISTS (SELECT 1 FROM option_valid) AND
+				EXISTS (SELECT 1 FROM voter_valid)
+			RETURNING 1
+		),
+		insert_vote AS (
+			INSERT INTO votes (id, poll_id, option_id, voted_at, voter_id, other_text)
+			SELECT $1, $2, $3, $4, CASE WHEN attribution = 'attributed' THEN $7::uuid END, NULLIF($8, '')
+			FROM poll_found
+			WHERE
+				EXISTS (SELECT 1 FROM poll_active) AND
+				EXISTS (SELECT 1 FROM option_valid) AND
+				EXISTS (SELECT 1 FROM voter_valid) AND
+				(NOT ballot_required OR EXISTS (SELECT 1 FROM use_ballot))
+			RETURNING 1
+		),
+		increment_count AS (
+			UPDATE poll_options
+			SET vote_count = vote_count + 1
+			WHERE id = $3 AND EXISTS (SELECT 1 FROM insert_vote)
 			RETURNING 1
 		)
 	SELECT
 		EXISTS (SELECT 1 FROM poll_found)	AS poll_exists,
 		EXISTS (SELECT 1 FROM poll_active)	AS poll_active,
 		EXISTS (SELECT 1 FROM option_valid)	AS option_valid,
-		EXISTS (SELECT 1 FROM insert_vote)	AS inserted`
+		COALESCE ((SELECT voter_required FROM poll_found), false) AS voter_required,
+		EXISTS (SELECT 1 FROM register_voter)	AS voter_registered,
+		COALESCE ((SELECT ballot_required FROM poll_found), false) AS ballot_required,
+		EXISTS (SELECT 1 FROM ballot_found)	AS ballot_found,
+		EXISTS (SELECT 1 FROM use_ballot)	AS ballot_used,
+		EXISTS (SELECT 1 FROM insert_vote)	AS inserted,
+		EXISTS (SELECT 1 FROM increment_count)	AS counted`
 
 func (r *Repository) RecordVote(ctx context.Context, vote *Vote) error {
-	row := r.db.QueryRow(ctx, recordVote,
-		vote.ID, vote.PollID, vote.OptionID, vote.VotedAt, time.Now())
+	var tokenHash []byte
+	if vote.BallotToken != "" {
+		tokenHash = HashBallotToken(vote.BallotToken)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return wrapError(ctx, "error starting transaction", err)
+	}
+	defer tx.Rollback(ctx)
 
-	var pollExists, pollActive, optionValid, inserted bool
-	err := row.Scan(&pollExists, &pollActive, &optionValid, &inserted)
+	row := tx.QueryRow(ctx, recordVote,
+		vote.ID, vote.PollID, vote.OptionID, vote.VotedAt, time.Now(), tokenHash, vote.VoterID, vote.OtherText)
+
+	var (
+		pollExists, pollActive, optionValid     bool
+		voterRequired, voterRegistered          bool
+		ballotRequired, ballotFound, ballotUsed bool
+		inserted, counted                       bool
+	)
+	err = row.Scan(&pollExists, &pollActive, &optionValid,
+		&voterRequired, &voterRegistered,
+		&ballotRequired, &ballotFound, &ballotUsed, &inserted, &counted)
 
 	switch {
 	case err != nil:
-		return fmt.Errorf("error inserting vote: %w", err)
+		return wrapError(ctx, "error inserting vote", err)
 	case !pollExists:
 		return ErrPollNotFound
 	case !pollActive:
 		return ErrPollExpired
 	case !optionValid:
 		return ErrOptionBelongsToPoll
+	case ballotRequired && vote.BallotToken == "":
+		return ErrBallotRequired
+	case ballotRequired && !ballotFound:
+		return ErrBallotNotFound
+	case voterRequired && vote.VoterID == nil:
+		return ErrVoterRequired
+	case voterRequired && !voterRegistered:
+		return ErrAlreadyVoted
+	case ballotRequired && !ballotUsed:
+		return ErrBallotUsed
 	case !inserted:
 		return errors.New("unknown error occurred while inserting vote")
+	case !counted:
+		return errors.New("unknown error occurred while counting vote")
+	}
+
+	if err := queueVotes(ctx, tx, []*Vote{vote}); err != nil {
+		return wrapError(ctx, "error queueing vote.recorded deliveries", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapError(ctx, "error committing transaction", err)
+	}
+
+	return nil
+}
+
+// getVoteWebhooks pairs every poll, given as an array of ids, that publishes
+// its votes with the webhooks subscribed to its vote.recorded event. It
+// mirrors Poll.PublishesVotes.
+const getVoteWebhooks = `
+	SELECT polls.id, webhooks.id
+	FROM polls
+	JOIN webhooks ON webhooks.poll_id = polls.id OR (webhooks.poll_id IS NULL AND webhooks.user_id = polls.user_id)
+	WHERE
+		polls.id = ANY($1) AND
+		polls.attribution <> 'verified' AND
+		polls.visibility <> 'ballot' AND
+		$2 = ANY(webhooks.events)`
+
+// queueVotes queues the vote.recorded deliveries of the votes in tx.
+func queueVotes(ctx context.Context, tx pgx.Tx, votes []*Vote) error {
+	pollIDs := make([]uuid.UUID, 0, len(votes))
+	for _, vote := range votes {
+		if !slices.Contains(pollIDs, vote.PollID) {
+			pollIDs = append(pollIDs, vote.PollID)
+		}
+	}
+
+	rows, err := tx.Query(ctx, getVoteWebhooks, pollIDs, string(events.VoteRecorded))
+	if err != nil {
+		return err
+	}
+
+	webhookIDs, err := scanPollWebhookIDs(rows)
+	if err != nil || len(webhookIDs) == 0 {
+		return err
+	}
+
+	deliveries, err := VoteDeliveries(votes, webhookIDs)
+	if err != nil {
+		return err
+	}
+
+	return tx.SendBatch(ctx, webhookDeliveriesBatch(deliveries)).Close()
+}
+
+const incrementVoteCounts = `
+	UPDATE poll_options
+	SET vote_count = vote_count + counts.vote_count
+	FROM unnest($1::uuid[], $2::integer[]) AS counts(option_id, vote_count)
+	WHERE poll_options.id = counts.option_id`
+
+// RecordVotes inserts a batch of already validated votes with a single COPY and
+// bumps the vote counters of their options and queues their vote.recorded
+// deliveries in the same transaction. Unlike RecordVote it does not check that
+// the polls are active.
+func (r *Repository) RecordVotes(ctx context.Context, votes []*Vote) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return wrapError(ctx, "error starting transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"votes"},
+		[]string{"id", "poll_id", "option_id", "voted_at", "other_text"},
+		pgx.CopyFromSlice(len(votes), func(i int) ([]any, error) {
+			vote := votes[i]
+
+			var otherText *string
+			if vote.OtherText != "" {
+				otherText = (*string)(&vote.OtherText)
+			}
+
+			return []any{vote.ID, vote.PollID, vote.OptionID, vote.VotedAt, otherText}, nil
+		}),
+	)
+	if err != nil {
+		return wrapError(ctx, "error copying votes", err)
+	}
+
+	counts := make(map[uuid.UUID]int)
+	for _, vote := range votes {
+		counts[vote.OptionID]++
+	}
+
+	var (
+		optionIDs  = make([]uuid.UUID, 0, len(counts))
+		voteCounts = make([]int, 0, len(counts))
+	)
+	for optionID, count := range counts {
+		optionIDs = append(optionIDs, optionID)
+		voteCounts = append(voteCounts, count)
+	}
+
+	if _, err := tx.Exec(ctx, incrementVoteCounts, optionIDs, voteCounts); err != nil {
+		return wrapError(ctx, "error incrementing vote counts", err)
+	}
+
+	if err := queueVotes(ctx, tx, votes); err != nil {
+		return wrapError(ctx, "error queueing vote.recorded deliveries", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapError(ctx, "error committing transaction", err)
 	}
 
 	return nil
 }
 
-const getUserPollsWithStats = `
+// pollPageWithStats selects a single page of polls and reads the maintained
+// vote counts of their options. The %s placeholders are filled with the WHERE
+// conditions and the sort column/direction; $1 is the page size.
+const pollPageWithStats = `
+	WITH
+		page AS (
+			SELECT id, user_id, organization_id, question, visibility, attribution, version, created_at, updated_at, expires_at, time_zone
+			FROM polls
+			WHERE %[1]s
+			ORDER BY %[2]s %[3]s, id %[3]s
+			LIMIT $1
+		)
 	SELECT
-		polls.id,
-		polls.user_id,
-		polls.question,
-		polls.created_at,
-		polls.expires_at,
+		page.id,
+		page.user_id,
+		page.organization_id,
+		page.question,
+		page.visibility,
+		page.attribution,
+		page.version,
+		page.created_at,
+		page.updated_at,
+		page.expires_at,
+		page.time_zone,
 		jsonb_agg(json_build_object(
 			'id', poll_options.id,
 			'poll_id', poll_options.poll_id,
 			'text', poll_options.text,
 			'position', poll_options.position,
-			'count', COALESCE(vs.vote_count, 0)
-		) ORDER BY position ASC) AS options
-	FROM polls
-	JOIN poll_options ON poll_options.poll_id = polls.id
-	LEFT JOIN (
-		SELECT option_id, COUNT(*) AS vote_count
-		FROM votes
-		GROUP BY option_id
-	) vs ON vs.option_id = poll_options.id
-	WHERE user_id = $1
-	GROUP BY polls.id
-	ORDER BY polls.created_at DESC`
+			'count', poll_options.vote_count,
+			'other', poll_options.is_other,
+			'description', poll_options.description,
+			'imageID', poll_options.image_id,
+			'url', poll_options.url
+		) ORDER BY poll_options.position ASC) AS options
+	FROM page
+	JOIN poll_options ON poll_options.poll_id = page.id
+	GROUP BY page.id, page.user_id, page.organization_id, page.question, page.visibility, page.attribution, page.version,
+		page.created_at, page.updated_at, page.expires_at, page.time_zone
+	ORDER BY page.%[2]s %[3]s, page.id %[3]s`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// pollPageQuery collects the conditions of a pollPageWithStats query.
+type pollPageQuery struct {
+	conditions []string
+	args       []any
+}
+
+func newPollPageQuery(limit int) *pollPageQuery {
+	// One extra row is fetched to find out whether there is a next page.
+	return &pollPageQuery{args: []any{limit + 1}}
+}
 
-func (r *Repository) GetUserPollsWithStats(ctx context.Context, userID uuid.UUID) ([]Poll, error) {
-	rows, err := r.db.Query(ctx, getUserPollsWithStats, userID)
+// where adds a condition; every %d of format is replaced by the placeholder
+// of the matching value.
+func (q *pollPageQuery) where(format string, values ...any) {
+	placeholders := make([]any, len(values))
+	for i, value := range values {
+		q.args = append(q.args, value)
+		placeholders[i] = len(q.args)
+	}
+	q.conditions = append(q.conditions, fmt.Sprintf(format, placeholders...))
+}
+
+// queryPollPage reads the page of polls matching q, ordered by sort and
+// continuing after cursor.
+func (r *Repository) queryPollPage(ctx context.Context, q *pollPageQuery, sort PollSort, cursor *PollCursor, limit int) (*PollPage, error) {
+	var (
+		column, descending = sort.column()
+		direction          = "ASC"
+	)
+
+	if descending {
+		direction = "DESC"
+	}
+
+	if cursor != nil {
+		operator := ">"
+		if descending {
+			operator = "<"
+		}
+		q.where("("+column+", id) "+operator+" ($%d, $%d)", cursor.Value, cursor.ID)
+	}
+
+	query := fmt.Sprintf(pollPageWithStats,
+		strings.Join(q.conditions, " AND "), column, direction)
+
+	rows, err := r.db.Query(ctx, query, q.args...)
 	if err != nil {
-		return nil, fmt.Errorf("error querying user polls: %w", err)
+		return nil, wrapError(ctx, "error querying polls", err)
 	}
 	defer rows.Close()
 
-	var polls []Poll
+	page := PollPage{Polls: make([]Poll, 0, limit)}
 	for rows.Next() {
 		var poll Poll
 		err := rows.Scan(
 			&poll.ID,
 			&poll.UserID,
+			&poll.OrganizationID,
 			&poll.Question,
+			&poll.Visibility,
+			&poll.Attribution,
+			&poll.Version,
 			&poll.CreatedAt,
+			&poll.UpdatedAt,
 			&poll.ExpiresAt,
+			&poll.TimeZone,
 			&poll.Options,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("error scanning poll: %w", err)
+			return nil, wrapError(ctx, "error scanning poll", err)
+		}
+		page.Polls = append(page.Polls, poll)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(ctx, "error iterating polls", err)
+	}
+
+	if len(page.Polls) > limit {
+		page.Polls = page.Polls[:limit]
+
+		last := page.Polls[limit-1]
+		next := PollCursor{Sort: sort, Value: last.CreatedAt, ID: last.ID}
+		if column == "expires_at" {
+			next.Value = last.ExpiresAt
+		}
+		page.NextCursor = next.Encode()
+	}
+
+	return &page, nil
+}
+
+func (r *Repository) GetUserPollsWithStats(ctx context.Context, arg GetUserPollsParams) (*PollPage, error) {
+	if !arg.Sort.Valid() {
+		arg.Sort = PollSortCreatedAtDesc
+	}
+
+	if arg.Cursor != nil && arg.Cursor.Sort != arg.Sort {
+		return nil, ErrInvalidCursor
+	}
+
+	q := newPollPageQuery(arg.Limit)
+	if arg.OrganizationID != nil {
+		q.where("organization_id = $%d", *arg.OrganizationID)
+	} else {
+		q.where(`(
+			user_id = $%[1]d OR
+			organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $%[1]d)
+		)`, arg.UserID)
+	}
+
+	switch arg.Status {
+	case PollStatusActive:
+		q.where("expires_at > $%d", time.Now())
+	case PollStatusExpired:
+		q.where("expires_at <= $%d", time.Now())
+	}
+
+	if arg.CreatedAfter != nil {
+		q.where("created_at >= $%d", *arg.CreatedAfter)
+	}
+
+	if arg.CreatedBefore != nil {
+		q.where("created_at < $%d", *arg.CreatedBefore)
+	}
+
+	if arg.Search != "" {
+		q.where("question ILIKE '%%' || $%d || '%%'", likeEscaper.Replace(arg.Search))
+	}
+
+	return r.queryPollPage(ctx, q, arg.Sort, arg.Cursor, arg.Limit)
+}
+
+// ListPublicPolls returns a page of the active public polls, newest first.
+func (r *Repository) ListPublicPolls(ctx context.Context, arg ListPublicPollsParams) (*PollPage, error) {
+	if arg.Cursor != nil && arg.Cursor.Sort != PollSortCreatedAtDesc {
+		return nil, ErrInvalidCursor
+	}
+
+	q := newPollPageQuery(arg.Limit)
+	q.where("visibility = $%d", PollVisibilityPublic)
+	q.where("expires_at > $%d", time.Now())
+
+	return r.queryPollPage(ctx, q, PollSortCreatedAtDesc, arg.Cursor, arg.Limit)
+}
+
+// reconcileVoteCounts re-derives poll_options.vote_count from the votes table
+// and corrects the options that drifted. Passing a NULL poll id reconciles
+// every poll. The drift is measured in the statement snapshot, where every
+// counted vote and its increment were committed together, and added to the
+// counter rather than overwriting it: an option incremented by a concurrent
+// vote meanwhile keeps that increment.
+const reconcileVoteCounts = `
+	UPDATE poll_options
+	SET vote_count = poll_options.vote_count + (actual.vote_count - actual.counted)
+	FROM (
+		SELECT poll_options.id, poll_options.vote_count AS counted, COUNT(votes.id) AS vote_count
+		FROM poll_options
+		LEFT JOIN votes ON votes.option_id = poll_options.id
+		WHERE $1::uuid IS NULL OR poll_options.poll_id = $1
+		GROUP BY poll_options.id
+	) actual
+	WHERE
+		poll_options.id = actual.id AND
+		actual.counted <> actual.vote_count`
+
+// ReconcileVoteCounts fixes the maintained vote counters of the given poll, or
+// of every poll when pollID is nil, and returns the number of corrected options.
+func (r *Repository) ReconcileVoteCounts(ctx context.Context, pollID *uuid.UUID) (int64, error) {
+	tag, err := r.db.Exec(ctx, reconcileVoteCounts, pollID)
+	if err != nil {
+		return 0, wrapError(ctx, "error reconciling vote counts", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+const getPollVoteTimeSeries = `
+	WITH
+		per_option AS (
+			SELECT
+				option_id,
+				date_trunc($2::text, voted_at, 'UTC')	AS bucket_start,
+				COUNT(*)								AS vote_count,
+				MIN(voted_at)							AS first_vote_at,
+				MAX(voted_at)							AS last_vote_at
+			FROM votes
+			WHERE poll_id = $1
+			GROUP BY option_id, bucket_start
+		)
+	SELECT
+		option_id,
+		bucket_start,
+		vote_count,
+		SUM(vote_count) OVER (PARTITION BY option_id ORDER BY bucket_start)::bigint	AS option_cumulative,
+		SUM(vote_count) OVER (PARTITION BY bucket_start)::bigint					AS bucket_total,
+		SUM(vote_count) OVER (ORDER BY bucket_start)::bigint						AS running_total,
+		MIN(first_vote_at) OVER ()													AS first_vote_at,
+		MAX(last_vote_at) OVER ()													AS last_vote_at
+	FROM per_option
+	ORDER BY bucket_start, option_id`
+
+func (r *Repository) GetPollAnalytics(ctx context.Context, arg GetPollAnalyticsParams) (*PollAnalytics, error) {
+	poll, err := r.GetPollWithOptions(ctx, arg.PollID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, getPollVoteTimeSeries, arg.PollID, arg.Interval)
+	if err != nil {
+		return nil, wrapError(ctx, "error querying vote time series", err)
+	}
+	defer rows.Close()
+
+	analytics := &PollAnalytics{
+		PollID:    poll.ID,
+		ExpiresAt: poll.ExpiresAt,
+		Interval:  arg.Interval,
+		Totals:    []VoteBucket{},
+		Options:   make([]OptionTimeSeries, len(poll.Options)),
+	}
+
+	optionIndex := make(map[uuid.UUID]int, len(poll.Options))
+	for i, option := range poll.Options {
+		optionIndex[option.ID] = i
+		analytics.Options[i] = OptionTimeSeries{
+			OptionID: option.ID,
+			Text:     option.Text,
+			Position: option.Position,
+			Buckets:  []VoteBucket{},
+		}
+	}
+
+	for rows.Next() {
+		var (
+			optionID                  uuid.UUID
+			bucket                    VoteBucket
+			bucketTotal, runningTotal int
+			firstVoteAt, lastVoteAt   time.Time
+		)
+		err := rows.Scan(
+			&optionID,
+			&bucket.Start,
+			&bucket.Count,
+			&bucket.Cumulative,
+			&bucketTotal,
+			&runningTotal,
+			&firstVoteAt,
+			&lastVoteAt,
+		)
+		if err != nil {
+			return nil, wrapError(ctx, "error scanning vote bucket", err)
+		}
+
+		analytics.FirstVoteAt, analytics.LastVoteAt = &firstVoteAt, &lastVoteAt
+
+		// Rows are ordered by bucket, so a bucket is only appended to the
+		// totals the first time one of its options is seen.
+		if n := len(analytics.Totals); n == 0 || !analytics.Totals[n-1].Start.Equal(bucket.Start) {
+			analytics.Totals = append(analytics.Totals, VoteBucket{
+				Start:      bucket.Start,
+				Count:      bucketTotal,
+				Cumulative: runningTotal,
+			})
+			analytics.TotalVotes = runningTotal
+
+			if analytics.Peak == nil || bucketTotal > analytics.Peak.Count {
+				analytics.Peak = &PeakRate{
+					Start:          bucket.Start,
+					Count:          bucketTotal,
+					VotesPerMinute: float64(bucketTotal) / arg.Interval.Duration().Minutes(),
+				}
+			}
+		}
+
+		if i, ok := optionIndex[optionID]; ok {
+			analytics.Options[i].Buckets = append(analytics.Options[i].Buckets, bucket)
+			analytics.Options[i].Total = bucket.Cumulative
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(ctx, "error iterating vote buckets", err)
+	}
+
+	return analytics, nil
+}
+
+const insertOrganization = `
+	INSERT INTO organizations (id, name, created_at)
+	VALUES ($1, $2, $3)`
+
+const upsertOrganizationMember = `
+	INSERT INTO organization_members (organization_id, user_id, role)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (organization_id, user_id) DO UPDATE SET role = EXCLUDED.role`
+
+// CreateOrganization inserts an organization with ownerID as its first owner.
+func (r *Repository) CreateOrganization(ctx context.Context, organization *Organization, ownerID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return wrapError(ctx, "error starting transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, insertOrganization, organization.ID, organization.Name, organization.CreatedAt)
+	if err != nil {
+		return wrapError(ctx, "error inserting organization", err)
+	}
+
+	_, err = tx.Exec(ctx, upsertOrganizationMember, organization.ID, ownerID, OrganizationRoleOwner)
+	if err != nil {
+		return wrapError(ctx, "error inserting organization owner", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapError(ctx, "error committing transaction", err)
+	}
+
+	return nil
+}
+
+const getUserOrganizations = `
+	SELECT o.id, o.name, o.created_at, m.role
+	FROM organization_members m
+	JOIN organizations o ON o.id = m.organization_id
+	WHERE m.user_id = $1
+	ORDER BY o.name, o.id`
+
+func (r *Repository) GetUserOrganizations(ctx context.Context, userID uuid.UUID) ([]OrganizationMembership, error) {
+	rows, err := r.db.Query(ctx, getUserOrganizations, userID)
+	if err != nil {
+		return nil, wrapError(ctx, "error querying organizations", err)
+	}
+	defer rows.Close()
+
+	memberships := make([]OrganizationMembership, 0)
+	for rows.Next() {
+		var membership OrganizationMembership
+		err := rows.Scan(
+			&membership.ID,
+			&membership.Name,
+			&membership.CreatedAt,
+			&membership.Role,
+		)
+		if err != nil {
+			return nil, wrapError(ctx, "error scanning organization", err)
+		}
+		memberships = append(memberships, membership)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(ctx, "error iterating organizations", err)
+	}
+
+	return memberships, nil
+}
+
+const getOrganizationRole = `
+	SELECT COALESCE(
+		(
+			SELECT role
+			FROM organization_members
+			WHERE organization_id = o.id AND user_id = $2
+		),
+		''
+	)
+	FROM organizations o
+	WHERE o.id = $1`
+
+// GetOrganizationRole returns the role of a user in an organization, or
+// ErrNotOrganizationMember if the user is not a member.
+func (r *Repository) GetOrganizationRole(ctx context.Context, organizationID, userID uuid.UUID) (OrganizationRole, error) {
+	var role OrganizationRole
+
+	err := r.db.QueryRow(ctx, getOrganizationRole, organizationID, userID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrOrganizationNotFound
+		}
+		return "", wrapError(ctx, "error querying organization role", err)
+	}
+
+	if role == "" {
+		return "", ErrNotOrganizationMember
+	}
+
+	return role, nil
+}
+
+const getOrganizationMembers = `
+	SELECT u.id, u.username, m.role
+	FROM organization_members m
+	JOIN users u ON u.id = m.user_id
+	WHERE m.organization_id = $1
+	ORDER BY u.username`
+
+func (r *Repository) GetOrganizationMembers(ctx context.Context, organizationID uuid.UUID) ([]OrganizationMember, error) {
+	rows, err := r.db.Query(ctx, getOrganizationMembers, organizationID)
+	if err != nil {
+		return nil, wrapError(ctx, "error querying organization members", err)
+	}
+	defer rows.Close()
+
+	members := make([]OrganizationMember, 0)
+	for rows.Next() {
+		var member OrganizationMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.Role); err != nil {
+			return nil, wrapError(ctx, "error scanning organization member", err)
+		}
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(ctx, "error iterating organization members", err)
+	}
+
+	return members, nil
+}
+
+// lockOrganizationMember locks an organization against concurrent membership
+// changes and reads the role of a user in it, empty if the user is not a
+// member, and the number of its owners.
+const lockOrganizationMember = `
+	SELECT
+		COALESCE(
+			(
+				SELECT role
+				FROM organization_members
+				WHERE organization_id = o.id AND user_id = $2
+			),
+			''
+		) AS role,
+		(
+			SELECT COUNT(*)
+			FROM organization_members
+			WHERE organization_id = o.id AND role = 'owner'
+		) AS owners
+	FROM organizations o
+	WHERE o.id = $1
+	FOR UPDATE`
+
+func lockOrganizationMemberTx(ctx context.Context, tx pgx.Tx, organizationID, userID uuid.UUID) (OrganizationRole, int, error) {
+	var (
+		role   OrganizationRole
+		owners int
+	)
+
+	err := tx.QueryRow(ctx, lockOrganizationMember, organizationID, userID).Scan(&role, &owners)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", 0, ErrOrganizationNotFound
+		}
+		return "", 0, wrapError(ctx, "error locking organization", err)
+	}
+
+	return role, owners, nil
+}
+
+// SetOrganizationMember adds a user to an organization or changes the role of
+// a member. The last owner cannot be demoted.
+func (r *Repository) SetOrganizationMember(ctx context.Context, arg SetOrganizationMemberParams) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return wrapError(ctx, "error starting transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	role, owners, err := lockOrganizationMemberTx(ctx, tx, arg.OrganizationID, arg.UserID)
+	if err != nil {
+		return err
+	}
+
+	if role == OrganizationRoleOwner && arg.Role != OrganizationRoleOwner && owners <= 1 {
+		return ErrLastOrganizationOwner
+	}
+
+	_, err = tx.Exec(ctx, upsertOrganizationMember, arg.OrganizationID, arg.UserID, arg.Role)
+	if err != nil {
+		return wrapError(ctx, "error upserting organization member", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapError(ctx, "error committing transaction", err)
+	}
+
+	return nil
+}
+
+const deleteOrganizationMember = `
+	DELETE FROM organization_members
+	WHERE organization_id = $1 AND user_id = $2`
+
+// RemoveOrganizationMember removes a member from an organization. The last
+// owner cannot be removed.
+func (r *Repository) RemoveOrganizationMember(ctx context.Context, organizationID, userID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return wrapError(ctx, "error starting transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	role, owners, err := lockOrganizationMemberTx(ctx, tx, organizationID, userID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case role == "":
+		return ErrNotOrganizationMember
+	case role == OrganizationRoleOwner && owners <= 1:
+		return ErrLastOrganizationOwner
+	}
+
+	if _, err := tx.Exec(ctx, deleteOrganizationMember, organizationID, userID); err != nil {
+		return wrapError(ctx, "error deleting organization member", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapError(ctx, "error committing transaction", err)
+	}
+
+	return nil
+}
+
+const insertBallot = `
+	INSERT INTO poll_ballots (id, poll_id, recipient, email, token_hash, created_at)
+	VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+	ON CONFLICT (poll_id, recipient) DO NOTHING`
+
+// CreateBallots inserts the ballots and returns those that were inserted.
+// Ballots for a recipient who already has one for the poll are skipped.
+func (r *Repository) CreateBallots(ctx context.Context, ballots []*Ballot) ([]*Ballot, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, wrapError(ctx, "error starting transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, ballot := range ballots {
+		batch.Queue(insertBallot,
+			ballot.ID, ballot.PollID, ballot.Recipient, ballot.Email, ballot.TokenHash, ballot.CreatedAt)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+
+	created := make([]*Ballot, 0, len(ballots))
+	for _, ballot := range ballots {
+		tag, err := results.Exec()
+		if err != nil {
+			results.Close()
+			return nil, wrapError(ctx, "error inserting ballot", err)
+		}
+		if tag.RowsAffected() == 1 {
+			created = append(created, ballot)
+		}
+	}
+
+	if err := results.Close(); err != nil {
+		return nil, wrapError(ctx, "error inserting ballots", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, wrapError(ctx, "error committing transaction", err)
+	}
+
+	return created, nil
+}
+
+const getBallot = `
+	SELECT id, poll_id, recipient, COALESCE(email, ''), token_hash, created_at, used
+	FROM poll_ballots
+	WHERE poll_id = $1 AND token_hash = $2`
+
+// GetBallot returns the ballot of a poll with the given token.
+func (r *Repository) GetBallot(ctx context.Context, pollID uuid.UUID, token string) (*Ballot, error) {
+	var ballot Ballot
+
+	err := r.db.
+		QueryRow(ctx, getBallot, pollID, HashBallotToken(token)).
+		Scan(
+			&ballot.ID,
+			&ballot.PollID,
+			&ballot.Recipient,
+			&ballot.Email,
+			&ballot.TokenHash,
+			&ballot.CreatedAt,
+			&ballot.Used,
+		)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrBallotNotFound
+		}
+		return nil, wrapError(ctx, "error querying ballot", err)
+	}
+
+	return &ballot, nil
+}
+
+const getPollBallots = `
+	SELECT id, poll_id, recipient, COALESCE(email, ''), created_at, used
+	FROM poll_ballots
+	WHERE poll_id = $1
+	ORDER BY recipient`
+
+// GetPollTurnout lists the ballots of a poll and how many of them were used.
+func (r *Repository) GetPollTurnout(ctx context.Context, pollID uuid.UUID) (*PollTurnout, error) {
+	rows, err := r.db.Query(ctx, getPollBallots, pollID)
+	if err != nil {
+		return nil, wrapError(ctx, "error querying ballots", err)
+	}
+	defer rows.Close()
+
+	ballots := make([]Ballot, 0)
+	for rows.Next() {
+		var ballot Ballot
+		err := rows.Scan(
+			&ballot.ID,
+			&ballot.PollID,
+			&ballot.Recipient,
+			&ballot.Email,
+			&ballot.CreatedAt,
+			&ballot.Used,
+		)
+		if err != nil {
+			return nil, wrapError(ctx, "error scanning ballot", err)
+		}
+		ballots = append(ballots, ballot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(ctx, "error iterating ballots", err)
+	}
+
+	return NewPollTurnout(pollID, ballots), nil
+}
+
+const getPollVoters = `
+	SELECT
+		p.attribution,
+		u.id,
+		u.username,
+		v.option_id,
+		v.voted_at
+	FROM polls p
+	LEFT JOIN poll_voters pv ON pv.poll_id = p.id
+	LEFT JOIN users u ON u.id = pv.user_id
+	LEFT JOIN votes v ON v.poll_id = p.id AND v.voter_id = pv.user_id AND p.attribution = 'attributed'
+	WHERE p.id = $1
+	ORDER BY u.username`
+
+// GetPollVoters lists who voted on a poll and, if it is attributed, for what.
+func (r *Repository) GetPollVoters(ctx context.Context, pollID uuid.UUID) (*PollVoters, error) {
+	rows, err := r.db.Query(ctx, getPollVoters, pollID)
+	if err != nil {
+		return nil, wrapError(ctx, "error querying voters", err)
+	}
+	defer rows.Close()
+
+	voters := &PollVoters{PollID: pollID, Voters: make([]PollVoter, 0)}
+	found := false
+	for rows.Next() {
+		var (
+			userID   *uuid.UUID
+			username *primitives.Username
+			voter    PollVoter
+		)
+		err := rows.Scan(&voters.Attribution, &userID, &username, &voter.OptionID, &voter.VotedAt)
+		if err != nil {
+			return nil, wrapError(ctx, "error scanning voter", err)
+		}
+
+		found = true
+		if userID == nil {
+			continue
+		}
+
+		voter.UserID, voter.Username = *userID, *username
+		voters.Voters = append(voters.Voters, voter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(ctx, "error iterating voters", err)
+	}
+
+	if !found {
+		return nil, ErrPollNotFound
+	}
+
+	return voters, nil
+}
+
+const getOtherAnswers = `
+	SELECT v.id, COALESCE(v.other_text, ''), v.other_hidden, v.voted_at
+	FROM votes v
+	JOIN poll_options o ON o.id = v.option_id
+	WHERE v.poll_id = $1 AND o.is_other AND (NOT v.other_hidden OR $2)
+	ORDER BY v.voted_at, v.id`
+
+// GetOtherAnswers lists the answers given with the votes for the Other option
+// of a poll, oldest first.
+func (r *Repository) GetOtherAnswers(ctx context.Context, arg GetOtherAnswersParams) ([]OtherAnswer, error) {
+	rows, err := r.db.Query(ctx, getOtherAnswers, arg.PollID, arg.IncludeHidden)
+	if err != nil {
+		return nil, wrapError(ctx, "error querying other answers", err)
+	}
+	defer rows.Close()
+
+	answers := make([]OtherAnswer, 0)
+	for rows.Next() {
+		var answer OtherAnswer
+		if err := rows.Scan(&answer.VoteID, &answer.Text, &answer.Hidden, &answer.VotedAt); err != nil {
+			return nil, wrapError(ctx, "error scanning other answer", err)
+		}
+		answers = append(answers, answer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(ctx, "error iterating other answers", err)
+	}
+
+	return answers, nil
+}
+
+const hideOtherAnswers = `
+	UPDATE votes v
+	SET other_hidden = $3
+	FROM poll_options o
+	WHERE v.id = ANY($2) AND v.poll_id = $1 AND o.id = v.option_id AND o.is_other`
+
+// HideOtherAnswers hides or shows answers of the Other option and returns how
+// many votes matched. Votes that are not for the Other option are ignored.
+func (r *Repository) HideOtherAnswers(ctx context.Context, arg HideOtherAnswersParams) (int64, error) {
+	tag, err := r.db.Exec(ctx, hideOtherAnswers, arg.PollID, arg.VoteIDs, arg.Hidden)
+	if err != nil {
+		return 0, wrapError(ctx, "error hiding other answers", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// mergeOtherAnswers moves the votes to the target option and moves their
+// count along with them.
+const mergeOtherAnswers = `
+	WITH
+		target AS (
+			SELECT id
+			FROM poll_options
+			WHERE id = $3 AND poll_id = $1 AND NOT is_other
+		),
+		moved AS (
+			UPDATE votes v
+			SET option_id = (SELECT id FROM target)
+			FROM poll_options o
+			WHERE
+				v.id = ANY($2) AND
+				v.poll_id = $1 AND
+				o.id = v.option_id AND
+				o.is_other AND
+				EXISTS (SELECT 1 FROM target)
+			RETURNING o.id AS other_id
+		),
+		decrement_count AS (
+			UPDATE poll_options
+			SET vote_count = vote_count - (SELECT count(*) FROM moved)
+			WHERE id = (SELECT other_id FROM moved LIMIT 1)
+			RETURNING 1
+		),
+		increment_count AS (
+			UPDATE poll_options
+			SET vote_count = vote_count + (SELECT count(*) FROM moved)
+			WHERE id = (SELECT id FROM target) AND EXISTS (SELECT 1 FROM moved)
+			RETURNING 1
+		)
+	SELECT
+		EXISTS (SELECT 1 FROM target)	AS target_valid,
+		(SELECT count(*) FROM moved)	AS merged`
+
+// MergeOtherAnswers moves votes for the Other option of a poll to one of its
+// other options and returns how many were moved. Votes that are not for the
+// Other option are ignored.
+func (r *Repository) MergeOtherAnswers(ctx context.Context, arg MergeOtherAnswersParams) (int64, error) {
+	var (
+		targetValid bool
+		merged      int64
+	)
+
+	err := r.db.
+		QueryRow(ctx, mergeOtherAnswers, arg.PollID, arg.VoteIDs, arg.OptionID).
+		Scan(&targetValid, &merged)
+
+	switch {
+	case err != nil:
+		return 0, wrapError(ctx, "error merging other answers", err)
+	case !targetValid:
+		return 0, ErrOptionBelongsToPoll
+	}
+
+	return merged, nil
+}
+
+// lockUserImages serialises the uploads of a user, so that concurrent ones
+// cannot exceed the quota together.
+const lockUserImages = `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`
+
+const getUserImagesSize = `SELECT COALESCE(SUM(size), 0) FROM images WHERE user_id = $1`
+
+const insertImage = `
+	INSERT INTO images (id, user_id, content_type, size, created_at)
+	VALUES ($1, $2, $3, $4, $5)`
+
+func (r *Repository) CreateImage(ctx context.Context, arg CreateImageParams) error {
+	image := arg.Image
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return wrapError(ctx, "error starting transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, lockUserImages, image.UserID); err != nil {
+		return wrapError(ctx, "error locking user images", err)
+	}
+
+	var size int64
+	if err := tx.QueryRow(ctx, getUserImagesSize, image.UserID).Scan(&size); err != nil {
+		return wrapError(ctx, "error querying user images size", err)
+	}
+	if size+image.Size > arg.Quota {
+		return ErrImageQuotaExceeded
+	}
+
+	_, err = tx.Exec(ctx, insertImage,
+		image.ID, image.UserID, image.ContentType, image.Size, image.CreatedAt)
+	if err != nil {
+		return wrapError(ctx, "error inserting image", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapError(ctx, "error committing transaction", err)
+	}
+
+	return nil
+}
+
+const getImage = `
+	SELECT id, user_id, content_type, size, created_at
+	FROM images
+	WHERE id = $1`
+
+func (r *Repository) GetImage(ctx context.Context, imageID uuid.UUID) (*Image, error) {
+	var image Image
+
+	err := r.db.
+		QueryRow(ctx, getImage, imageID).
+		Scan(&image.ID, &image.UserID, &image.ContentType, &image.Size, &image.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, wrapError(ctx, "error querying image", err)
+	}
+
+	return &image, nil
+}
+
+// deleteUnusedImages deletes the oldest images created before $1 that no poll
+// option uses. Images locked by a concurrent sweep are left to it.
+const deleteUnusedImages = `
+	DELETE FROM images
+	WHERE id IN (
+		SELECT id
+		FROM images
+		WHERE
+			created_at < $1 AND
+			NOT EXISTS (SELECT 1 FROM poll_options WHERE poll_options.image_id = images.id)
+		ORDER BY created_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, user_id, content_type, size, created_at`
+
+func (r *Repository) DeleteUnusedImages(ctx context.Context, arg DeleteUnusedImagesParams) ([]Image, error) {
+	rows, err := r.db.Query(ctx, deleteUnusedImages, arg.CreatedBefore, arg.Limit)
+	if err != nil {
+		return nil, wrapError(ctx, "error deleting unused images", err)
+	}
+	defer rows.Close()
+
+	images := make([]Image, 0)
+	for rows.Next() {
+		var image Image
+		if err := rows.Scan(&image.ID, &image.UserID, &image.ContentType, &image.Size, &image.CreatedAt); err != nil {
+			return nil, wrapError(ctx, "error scanning deleted image", err)
+		}
+		images = append(images, image)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(ctx, "error iterating deleted images", err)
+	}
+
+	return images, nil
+}
+
+const insertWebhook = `
+	INSERT INTO webhooks (id, user_id, poll_id, url, secret, events, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+// CreateWebhook inserts the webhook. A webhook of a poll that does not exist
+// is ErrPollNotFound.
+func (r *Repository) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	_, err := r.db.Exec(ctx, insertWebhook,
+		webhook.ID, webhook.UserID, webhook.PollID, webhook.URL, webhook.Secret, webhook.Events, webhook.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation && strings.Contains(pgErr.ConstraintName, "poll_id") {
+			return ErrPollNotFound
+		}
+		return wrapError(ctx, "error inserting webhook", err)
+	}
+
+	return nil
+}
+
+const webhookColumns = "id, user_id, poll_id, url, secret, events, created_at"
+
+func scanWebhooks(rows pgx.Rows) ([]Webhook, error) {
+	webhooks := make([]Webhook, 0)
+	for rows.Next() {
+		var webhook Webhook
+		if err := rows.Scan(
+			&webhook.ID,
+			&webhook.UserID,
+			&webhook.PollID,
+			&webhook.URL,
+			&webhook.Secret,
+			&webhook.Events,
+			&webhook.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+const getWebhook = `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+
+func (r *Repository) GetWebhook(ctx context.Context, webhookID uuid.UUID) (*Webhook, error) {
+	rows, err := r.db.Query(ctx, getWebhook, webhookID)
+	if err != nil {
+		return nil, wrapError(ctx, "error querying webhook", err)
+	}
+	defer rows.Close()
+
+	webhooks, err := scanWebhooks(rows)
+	if err != nil {
+		return nil, wrapError(ctx, "error scanning webhook", err)
+	}
+	if len(webhooks) == 0 {
+		return nil, ErrWebhookNotFound
+	}
+
+	return &webhooks[0], nil
+}
+
+const getUserWebhooks = `
+	SELECT ` + webhookColumns + `
+	FROM webhooks
+	WHERE user_id = $1
+	ORDER BY created_at, id`
+
+// GetUserWebhooks lists the webhooks of the user, those of single polls
+// included, oldest first.
+func (r *Repository) GetUserWebhooks(ctx context.Context, userID uuid.UUID) ([]Webhook, error) {
+	rows, err := r.db.Query(ctx, getUserWebhooks, userID)
+	if err != nil {
+		return nil, wrapError(ctx, "error querying webhooks", err)
+	}
+	defer rows.Close()
+
+	webhooks, err := scanWebhooks(rows)
+	if err != nil {
+		return nil, wrapError(ctx, "error scanning webhooks", err)
+	}
+
+	return webhooks, nil
+}
+
+// getEventWebhooks selects the webhooks subscribed to an event: those of the
+// poll and those of the user for all of their polls.
+const getEventWebhooks = `
+	SELECT ` + webhookColumns + `
+	FROM webhooks
+	WHERE
+		(poll_id = $2 OR (poll_id IS NULL AND user_id = $1)) AND
+		$3 = ANY(events)`
+
+func (r *Repository) GetEventWebhooks(ctx context.Context, arg GetEventWebhooksParams) ([]Webhook, error) {
+	rows, err := r.db.Query(ctx, getEventWebhooks, arg.UserID, arg.PollID, arg.EventType)
+	if err != nil {
+		return nil, wrapError(ctx, "error querying event webhooks", err)
+	}
+	defer rows.Close()
+
+	webhooks, err := scanWebhooks(rows)
+	if err != nil {
+		return nil, wrapError(ctx, "error scanning event webhooks", err)
+	}
+
+	return webhooks, nil
+}
+
+const deleteWebhook = `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`
+
+// DeleteWebhook deletes a webhook of the user with its deliveries. Webhooks
+// of other users are not found.
+func (r *Repository) DeleteWebhook(ctx context.Context, webhookID, userID uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, deleteWebhook, webhookID, userID)
+	if err != nil {
+		return wrapError(ctx, "error deleting webhook", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+const insertWebhookDelivery = `
+	INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+		last_attempt_at, response_status, last_error, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12)
+	ON CONFLICT (webhook_id, event_id) DO NOTHING`
+
+// CreateWebhookDeliveries queues the deliveries. An event already queued for
+// a webhook is skipped.
+func (r *Repository) CreateWebhookDeliveries(ctx context.Context, deliveries []*WebhookDelivery) error {
+	if err := r.db.SendBatch(ctx, webhookDeliveriesBatch(deliveries)).Close(); err != nil {
+		return wrapError(ctx, "error inserting webhook deliveries", err)
+	}
+
+	return nil
+}
+
+// queueEvent queues a delivery of the event to each webhook subscribed to it
+// in tx.
+func queueEvent(ctx context.Context, tx pgx.Tx, event events.Event) error {
+	rows, err := tx.Query(ctx, getEventWebhooks, event.UserID, event.PollID, string(event.Type))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	webhooks, err := scanWebhooks(rows)
+	if err != nil {
+		return err
+	}
+	rows.Close()
+
+	webhookIDs := make([]uuid.UUID, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		webhookIDs = append(webhookIDs, webhook.ID)
+	}
+
+	deliveries, err := EventDeliveries(event, webhookIDs)
+	if err != nil || len(deliveries) == 0 {
+		return err
+	}
+
+	return tx.SendBatch(ctx, webhookDeliveriesBatch(deliveries)).Close()
+}
+
+func webhookDeliveriesBatch(deliveries []*WebhookDelivery) *pgx.Batch {
+	batch := &pgx.Batch{}
+	for _, delivery := range deliveries {
+		batch.Queue(insertWebhookDelivery,
+			delivery.ID, delivery.WebhookID, delivery.EventID, delivery.EventType, delivery.Payload, delivery.Status,
+			delivery.Attempts, delivery.NextAttemptAt, delivery.LastAttemptAt, delivery.ResponseStatus,
+			delivery.LastError, delivery.CreatedAt)
+	}
+	return batch
+}
+
+const webhookDeliveryColumns = `
+	d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+	d.last_attempt_at, d.response_status, COALESCE(d.last_error, ''), d.created_at`
+
+func webhookDeliveryFields(delivery *WebhookDelivery) []any {
+	return []any{
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastAttemptAt,
+		&delivery.ResponseStatus,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+	}
+}
+
+// claimWebhookDeliveries leases the pending deliveries that are due, oldest
+// first, by pushing them back to $3. Deliveries leased by a concurrent
+// worker are left to it.
+const claimWebhookDeliveries = `
+	WITH due AS (
+		SELECT id
+		FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	UPDATE webhook_deliveries d
+	SET next_attempt_at = $3
+	FROM due, webhooks w
+	WHERE d.id = due.id AND w.id = d.webhook_id
+	RETURNING ` + webhookDeliveryColumns + `, w.url, w.secret`
+
+// ClaimWebhookDeliveries returns up to arg.Limit due deliveries for the
+// caller to attempt. They are not due again before arg.LeaseUntil.
+func (r *Repository) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimedWebhookDelivery, error) {
+	rows, err := r.db.Query(ctx, claimWebhookDeliveries, arg.Now, arg.Limit, arg.LeaseUntil)
+	if err != nil {
+		return nil, wrapError(ctx, "error claiming webhook deliveries", err)
+	}
+	defer rows.Close()
+
+	var claimed []ClaimedWebhookDelivery
+	for rows.Next() {
+		var delivery ClaimedWebhookDelivery
+		fields := append(webhookDeliveryFields(&delivery.WebhookDelivery), &delivery.URL, &delivery.Secret)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, wrapError(ctx, "error scanning webhook delivery", err)
+		}
+		claimed = append(claimed, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(ctx, "error iterating webhook deliveries", err)
+	}
+
+	return claimed, nil
+}
+
+const completeWebhookDelivery = `
+	UPDATE webhook_deliveries
+	SET
+		status = $2,
+		attempts = attempts + 1,
+		last_attempt_at = $3,
+		response_status = $4,
+		last_error = NULLIF($5, ''),
+		next_attempt_at = $6
+	WHERE id = $1`
+
+// CompleteWebhookDelivery records an attempt of a delivery.
+func (r *Repository) CompleteWebhookDelivery(ctx context.Context, arg CompleteWebhookDeliveryParams) error {
+	_, err := r.db.Exec(ctx, completeWebhookDelivery,
+		arg.DeliveryID, arg.Status, arg.AttemptedAt, arg.ResponseStatus, arg.Error, arg.NextAttemptAt)
+	if err != nil {
+		return wrapError(ctx, "error completing webhook delivery", err)
+	}
+
+	return nil
+}
+
+const getWebhookDeliveries = `
+	SELECT ` + webhookDeliveryColumns + `
+	FROM webhook_deliveries d
+	WHERE d.webhook_id = $1
+	ORDER BY d.created_at DESC, d.id DESC
+	LIMIT $2`
+
+// GetWebhookDeliveries lists the latest deliveries of a webhook, newest
+// first.
+func (r *Repository) GetWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]WebhookDelivery, error) {
+	rows, err := r.db.Query(ctx, getWebhookDeliveries, webhookID, limit)
+	if err != nil {
+		return nil, wrapError(ctx, "error querying webhook deliveries", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]WebhookDelivery, 0)
+	for rows.Next() {
+		var delivery WebhookDelivery
+		if err := rows.Scan(webhookDeliveryFields(&delivery)...); err != nil {
+			return nil, wrapError(ctx, "error scanning webhook delivery", err)
 		}
-		polls = append(polls, poll)
+		deliveries = append(deliveries, delivery)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating polls: %w", err)
+		return nil, wrapError(ctx, "error iterating webhook deliveries", err)
 	}
 
-	return polls, nil
+	return deliveries, nil
 }
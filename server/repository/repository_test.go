@@ -0,0 +1,74 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/toramanomer/polly/migrations"
+	"github.com/toramanomer/polly/repository"
+	"github.com/toramanomer/polly/repository/storetest"
+)
+
+// TestRepository runs the conformance suite against the Postgres database at
+// DATABASE_URL. Every table of the database is emptied before each test, so
+// it must not be one whose data matters.
+func TestRepository(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+
+	db, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		t.Fatalf("connecting to the database: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+
+	tables, err := tableNames(ctx, db)
+	if err != nil {
+		t.Fatalf("listing tables: %v", err)
+	}
+
+	storetest.Run(t, func(t *testing.T) repository.Store {
+		if _, err := db.Exec(ctx, "TRUNCATE "+strings.Join(tables, ", ")+" CASCADE"); err != nil {
+			t.Fatalf("truncating tables: %v", err)
+		}
+		return repository.NewRepository(db)
+	})
+}
+
+// tableNames returns the quoted names of the tables the migrations created.
+func tableNames(ctx context.Context, db *pgxpool.Pool) ([]string, error) {
+	rows, err := db.Query(ctx, `
+		SELECT tablename
+		FROM pg_tables
+		WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'`)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	for i, name := range names {
+		names[i] = pgx.Identifier{name}.Sanitize()
+	}
+
+	return names, nil
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/primitives"
+)
+
+// The store interfaces are implemented by the Postgres Repository and by the
+// in-memory store in package memory. Both return the sentinel errors of this
+// package for the same conditions.
+type UserStore interface {
+	CreateUser(ctx context.Context, user *User) error
+	GetUserByEmail(ctx context.Context, email primitives.Email) (*User, error)
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*User, error)
+	GetUsersByUsernames(ctx context.Context, usernames []primitives.Username) ([]User, error)
+	GetOrCreateChatUser(ctx context.Context, account ChatAccount) (*User, error)
+}
+
+type PollStore interface {
+	CreatePollWithOptions(ctx context.Context, poll *Poll) error
+	DeletePoll(ctx context.Context, arg DeletePollParams) error
+	GetPollWithOptions(ctx context.Context, pollID uuid.UUID) (*Poll, error)
+	GetPollTally(ctx context.Context, pollID uuid.UUID) (*PollTally, error)
+	GetPollAccess(ctx context.Context, pollID, userID uuid.UUID) (*PollAccess, error)
+	GetUserPollsWithStats(ctx context.Context, arg GetUserPollsParams) (*PollPage, error)
+	ListPublicPolls(ctx context.Context, arg ListPublicPollsParams) (*PollPage, error)
+	GetPollAnalytics(ctx context.Context, arg GetPollAnalyticsParams) (*PollAnalytics, error)
+	CloseExpiredPolls(ctx context.Context, arg CloseExpiredPollsParams) ([]ClosedPoll, error)
+}
+
+type OrganizationStore interface {
+	CreateOrganization(ctx context.Context, organization *Organization, ownerID uuid.UUID) error
+	GetUserOrganizations(ctx context.Context, userID uuid.UUID) ([]OrganizationMembership, error)
+	GetOrganizationRole(ctx context.Context, organizationID, userID uuid.UUID) (OrganizationRole, error)
+	GetOrganizationMembers(ctx context.Context, organizationID uuid.UUID) ([]OrganizationMember, error)
+	SetOrganizationMember(ctx context.Context, arg SetOrganizationMemberParams) error
+	RemoveOrganizationMember(ctx context.Context, organizationID, userID uuid.UUID) error
+}
+
+type BallotStore interface {
+	CreateBallots(ctx context.Context, ballots []*Ballot) ([]*Ballot, error)
+	GetBallot(ctx context.Context, pollID uuid.UUID, token string) (*Ballot, error)
+	GetPollTurnout(ctx context.Context, pollID uuid.UUID) (*PollTurnout, error)
+}
+
+type ImageStore interface {
+	// CreateImage records an uploaded image, ErrImageQuotaExceeded if it
+	// does not fit in the quota of its user.
+	CreateImage(ctx context.Context, arg CreateImageParams) error
+	GetImage(ctx context.Context, imageID uuid.UUID) (*Image, error)
+	// DeleteUnusedImages deletes up to arg.Limit images no poll option uses
+	// and returns them, so that their content can be deleted too.
+	DeleteUnusedImages(ctx context.Context, arg DeleteUnusedImagesParams) ([]Image, error)
+}
+
+type VoteStore interface {
+	RecordVote(ctx context.Context, vote *Vote) error
+	RecordVotes(ctx context.Context, votes []*Vote) error
+	ReconcileVoteCounts(ctx context.Context, pollID *uuid.UUID) (int64, error)
+	GetPollVoters(ctx context.Context, pollID uuid.UUID) (*PollVoters, error)
+	GetOtherAnswers(ctx context.Context, arg GetOtherAnswersParams) ([]OtherAnswer, error)
+	HideOtherAnswers(ctx context.Context, arg HideOtherAnswersParams) (int64, error)
+	MergeOtherAnswers(ctx context.Context, arg MergeOtherAnswersParams) (int64, error)
+}
+
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	GetWebhook(ctx context.Context, webhookID uuid.UUID) (*Webhook, error)
+	GetUserWebhooks(ctx context.Context, userID uuid.UUID) ([]Webhook, error)
+	GetEventWebhooks(ctx context.Context, arg GetEventWebhooksParams) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID, userID uuid.UUID) error
+	CreateWebhookDeliveries(ctx context.Context, deliveries []*WebhookDelivery) error
+	ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimedWebhookDelivery, error)
+	CompleteWebhookDelivery(ctx context.Context, arg CompleteWebhookDeliveryParams) error
+	GetWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]WebhookDelivery, error)
+}
+
+type Store interface {
+	UserStore
+	PollStore
+	OrganizationStore
+	BallotStore
+	ImageStore
+	VoteStore
+	WebhookStore
+}
+
+var _ Store = (*Repository)(nil)
@@ -0,0 +1,1720 @@
+// Package storetest is a conformance suite for repository.Store
+// implementations. Every implementation is expected to pass Run, which keeps
+// the in-memory store and the Postgres repository interchangeable.
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/primitives"
+	"github.com/toramanomer/polly/repository"
+)
+
+// Run runs the conformance suite. newStore is called once per subtest and
+// must return an empty store.
+func Run(t *testing.T, newStore func(t *testing.T) repository.Store) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store repository.Store)
+	}{
+		{"Users", testUsers},
+		{"ChatUsers", testChatUsers},
+		{"Polls", testPolls},
+		{"PollAccess", testPollAccess},
+		{"PublicPolls", testPublicPolls},
+		{"DeletePoll", testDeletePoll},
+		{"CloseExpiredPolls", testCloseExpiredPolls},
+		{"PollTally", testPollTally},
+		{"RecordVote", testRecordVote},
+		{"RecordVotes", testRecordVotes},
+		{"Ballots", testBallots},
+		{"VoteAttribution", testVoteAttribution},
+		{"OtherAnswers", testOtherAnswers},
+		{"RichOptions", testRichOptions},
+		{"UnusedImages", testUnusedImages},
+		{"Webhooks", testWebhooks},
+		{"WebhookDeliveries", testWebhookDeliveries},
+		{"EventDeliveries", testEventDeliveries},
+		{"UserPollsPagination", testUserPollsPagination},
+		{"UserPollsFilters", testUserPollsFilters},
+		{"Analytics", testAnalytics},
+		{"Organizations", testOrganizations},
+		{"OrganizationPolls", testOrganizationPolls},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, newStore(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, store repository.Store, name string) *repository.User {
+	t.Helper()
+
+	user := &repository.User{
+		ID:           uuid.New(),
+		Username:     primitives.Username(name),
+		Email:        primitives.Email(name + "@example.com"),
+		PasswordHash: "hash",
+		Plan:         repository.DefaultPlan,
+	}
+
+	if err := store.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser(%s): %v", name, err)
+	}
+
+	return user
+}
+
+func createPoll(t *testing.T, store repository.Store, userID uuid.UUID, question string, createdAt, expiresAt time.Time) *repository.Poll {
+	t.Helper()
+
+	poll := repository.NewPoll(repository.NewPollParams{
+		UserID:    userID,
+		Question:  primitives.Question(question),
+		ExpiresAt: expiresAt,
+		Options:   []repository.NewPollOption{{Text: "Yes"}, {Text: "No"}, {Text: "Maybe"}},
+	})
+	poll.CreatedAt = createdAt
+
+	if err := store.CreatePollWithOptions(context.Background(), poll); err != nil {
+		t.Fatalf("CreatePollWithOptions(%s): %v", question, err)
+	}
+
+	return poll
+}
+
+func expectError(t *testing.T, what string, got, want error) {
+	t.Helper()
+
+	if !errors.Is(got, want) {
+		t.Fatalf("%s: got error %v, want %v", what, got, want)
+	}
+}
+
+func testUsers(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := createUser(t, store, "alice")
+
+	got, err := store.GetUserByEmail(ctx, user.Email)
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %v", err)
+	}
+	if got.ID != user.ID || got.Username != user.Username || got.PasswordHash != user.PasswordHash {
+		t.Fatalf("GetUserByEmail: got %+v, want %+v", got, user)
+	}
+
+	_, err = store.GetUserByEmail(ctx, "nobody@example.com")
+	expectError(t, "GetUserByEmail unknown", err, repository.ErrUserNotFound)
+
+	sameEmail := &repository.User{ID: uuid.New(), Username: "bob", Email: user.Email}
+	expectError(t, "CreateUser same email", store.CreateUser(ctx, sameEmail), repository.ErrEmailAlreadyExists)
+
+	sameUsername := &repository.User{ID: uuid.New(), Username: user.Username, Email: "bob@example.com"}
+	expectError(t, "CreateUser same username", store.CreateUser(ctx, sameUsername), repository.ErrUsernameAlreadyExists)
+
+	users, err := store.GetUsersByUsernames(ctx, []primitives.Username{user.Username, "nobody"})
+	if err != nil {
+		t.Fatalf("GetUsersByUsernames: %v", err)
+	}
+	if len(users) != 1 || users[0].ID != user.ID {
+		t.Fatalf("GetUsersByUsernames: got %+v, want only %s", users, user.Username)
+	}
+
+	got, err = store.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if got.Username != user.Username || got.Plan != user.Plan {
+		t.Fatalf("GetUserByID: got %+v, want %+v", got, user)
+	}
+
+	_, err = store.GetUserByID(ctx, uuid.New())
+	expectError(t, "GetUserByID unknown", err, repository.ErrUserNotFound)
+}
+
+func testChatUsers(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	account := repository.ChatAccount{Provider: repository.ChatProviderSlack, TeamID: "T0001", UserID: "U0001"}
+
+	// Of concurrent first uses exactly one user is created.
+	var (
+		wg    sync.WaitGroup
+		users = make(chan *repository.User, 8)
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := store.GetOrCreateChatUser(ctx, account)
+			if err != nil {
+				t.Errorf("GetOrCreateChatUser: %v", err)
+			}
+			users <- user
+		}()
+	}
+	wg.Wait()
+	close(users)
+
+	var user *repository.User
+	for got := range users {
+		switch {
+		case got == nil:
+			t.FailNow()
+		case user == nil:
+			user = got
+		case got.ID != user.ID:
+			t.Fatalf("GetOrCreateChatUser: got users %s and %s for the same account", user.ID, got.ID)
+		}
+	}
+	if user.Username != "slack:T0001:U0001" || user.Plan != repository.DefaultPlan {
+		t.Fatalf("GetOrCreateChatUser: got %+v", user)
+	}
+
+	got, err := store.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if got.Username != user.Username || got.Email != user.Email {
+		t.Fatalf("GetUserByID: got %+v, want %+v", got, user)
+	}
+
+	other, err := store.GetOrCreateChatUser(ctx, repository.ChatAccount{
+		Provider: repository.ChatProviderSlack, TeamID: "T0002", UserID: "U0001",
+	})
+	if err != nil {
+		t.Fatalf("GetOrCreateChatUser other team: %v", err)
+	}
+	if other.ID == user.ID {
+		t.Fatalf("GetOrCreateChatUser: got the same user for accounts of different teams")
+	}
+}
+
+func testPolls(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := createUser(t, store, "alice")
+	poll := createPoll(t, store, user.ID, "Lunch?", time.Now(), time.Now().Add(time.Hour))
+
+	got, err := store.GetPollWithOptions(ctx, poll.ID)
+	if err != nil {
+		t.Fatalf("GetPollWithOptions: %v", err)
+	}
+	if got.ID != poll.ID || got.UserID != user.ID || got.Question != poll.Question {
+		t.Fatalf("GetPollWithOptions: got %+v, want %+v", got, poll)
+	}
+	if got.Version != 1 || !got.UpdatedAt.Equal(poll.UpdatedAt.Truncate(time.Microsecond)) {
+		t.Fatalf("GetPollWithOptions: got version %d updated at %v, want 1 and %v", got.Version, got.UpdatedAt, poll.UpdatedAt)
+	}
+	if !got.ExpiresAt.Equal(poll.ExpiresAt.Truncate(time.Microsecond)) {
+		t.Fatalf("GetPollWithOptions: got expiresAt %v, want %v", got.ExpiresAt, poll.ExpiresAt)
+	}
+	if len(got.Options) != len(poll.Options) {
+		t.Fatalf("GetPollWithOptions: got %d options, want %d", len(got.Options), len(poll.Options))
+	}
+	for i, option := range got.Options {
+		if option.ID != poll.Options[i].ID || option.Text != poll.Options[i].Text || option.Position != i {
+			t.Fatalf("GetPollWithOptions: option %d is %+v, want %+v", i, option, poll.Options[i])
+		}
+	}
+
+	if got.Visibility != repository.PollVisibilityUnlisted {
+		t.Fatalf("GetPollWithOptions: got visibility %q, want %q", got.Visibility, repository.PollVisibilityUnlisted)
+	}
+
+	if got.TimeZone != repository.DefaultTimeZone {
+		t.Fatalf("GetPollWithOptions: got time zone %q, want %q", got.TimeZone, repository.DefaultTimeZone)
+	}
+
+	_, err = store.GetPollWithOptions(ctx, uuid.New())
+	expectError(t, "GetPollWithOptions unknown", err, repository.ErrPollNotFound)
+
+	// Polls may have up to MaxPollOptions options.
+	options := make([]repository.NewPollOption, repository.MaxPollOptions)
+	for i := range options {
+		options[i].Text = primitives.OptionText(fmt.Sprintf("Option %d", i+1))
+	}
+	large := repository.NewPoll(repository.NewPollParams{
+		UserID: user.ID, Question: "Which?", ExpiresAt: time.Now().Add(time.Hour), Options: options,
+	})
+	if err := store.CreatePollWithOptions(ctx, large); err != nil {
+		t.Fatalf("CreatePollWithOptions with %d options: %v", len(options), err)
+	}
+
+	options = append(options, repository.NewPollOption{Text: "One too many"})
+	err = store.CreatePollWithOptions(ctx, repository.NewPoll(repository.NewPollParams{
+		UserID: user.ID, Question: "Which?", ExpiresAt: time.Now().Add(time.Hour), Options: options,
+	}))
+	if err == nil {
+		t.Fatalf("CreatePollWithOptions with %d options: got no error", len(options))
+	}
+}
+
+func testPollAccess(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	owner := createUser(t, store, "alice")
+	invitee := createUser(t, store, "bob")
+	other := createUser(t, store, "carol")
+
+	private := repository.NewPoll(repository.NewPollParams{
+		UserID:     owner.ID,
+		Question:   "Private?",
+		ExpiresAt:  time.Now().Add(time.Hour),
+		Options:    []repository.NewPollOption{{Text: "Yes"}, {Text: "No"}},
+		Visibility: repository.PollVisibilityPrivate,
+		Invitees:   []uuid.UUID{invitee.ID},
+	})
+	if err := store.CreatePollWithOptions(ctx, private); err != nil {
+		t.Fatalf("CreatePollWithOptions private: %v", err)
+	}
+
+	for _, test := range []struct {
+		userID  uuid.UUID
+		invited bool
+	}{{invitee.ID, true}, {other.ID, false}, {uuid.Nil, false}} {
+		access, err := store.GetPollAccess(ctx, private.ID, test.userID)
+		if err != nil {
+			t.Fatalf("GetPollAccess: %v", err)
+		}
+		if access.Invited != test.invited || access.PasscodeHash != "" {
+			t.Fatalf("GetPollAccess(%s): got %+v, want invited %v", test.userID, access, test.invited)
+		}
+	}
+
+	protected := repository.NewPoll(repository.NewPollParams{
+		UserID:     owner.ID,
+		Question:   "Protected?",
+		ExpiresAt:  time.Now().Add(time.Hour),
+		Options:    []repository.NewPollOption{{Text: "Yes"}, {Text: "No"}},
+		Visibility: repository.PollVisibilityPasscode,
+		Passcode:   "open sesame",
+	})
+	if err := store.CreatePollWithOptions(ctx, protected); err != nil {
+		t.Fatalf("CreatePollWithOptions passcode: %v", err)
+	}
+
+	got, err := store.GetPollWithOptions(ctx, protected.ID)
+	if err != nil {
+		t.Fatalf("GetPollWithOptions: %v", err)
+	}
+	if got.Visibility != repository.PollVisibilityPasscode {
+		t.Fatalf("GetPollWithOptions: got visibility %q, want %q", got.Visibility, repository.PollVisibilityPasscode)
+	}
+
+	access, err := store.GetPollAccess(ctx, protected.ID, other.ID)
+	if err != nil {
+		t.Fatalf("GetPollAccess: %v", err)
+	}
+	if !access.VerifyPasscode("open sesame") || access.VerifyPasscode("open says me") {
+		t.Fatalf("GetPollAccess: passcode hash %q does not verify", access.PasscodeHash)
+	}
+
+	_, err = store.GetPollAccess(ctx, uuid.New(), other.ID)
+	expectError(t, "GetPollAccess unknown", err, repository.ErrPollNotFound)
+}
+
+func testPublicPolls(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := createUser(t, store, "alice")
+	now := time.Now()
+
+	var public []*repository.Poll
+	for i := range 3 {
+		poll := repository.NewPoll(repository.NewPollParams{
+			UserID:     user.ID,
+			Question:   "Public?",
+			ExpiresAt:  now.Add(time.Hour),
+			Options:    []repository.NewPollOption{{Text: "Yes"}, {Text: "No"}},
+			Visibility: repository.PollVisibilityPublic,
+		})
+		poll.CreatedAt = now.Add(time.Duration(i) * time.Minute)
+		if err := store.CreatePollWithOptions(ctx, poll); err != nil {
+			t.Fatalf("CreatePollWithOptions: %v", err)
+		}
+		public = append(public, poll)
+	}
+
+	expired := repository.NewPoll(repository.NewPollParams{
+		UserID:     user.ID,
+		Question:   "Expired?",
+		ExpiresAt:  now.Add(-time.Minute),
+		Options:    []repository.NewPollOption{{Text: "Yes"}, {Text: "No"}},
+		Visibility: repository.PollVisibilityPublic,
+	})
+	if err := store.CreatePollWithOptions(ctx, expired); err != nil {
+		t.Fatalf("CreatePollWithOptions: %v", err)
+	}
+	createPoll(t, store, user.ID, "Unlisted?", now.Add(time.Hour), now.Add(time.Hour))
+
+	var got []uuid.UUID
+	params := repository.ListPublicPollsParams{Limit: 2}
+	for {
+		page, err := store.ListPublicPolls(ctx, params)
+		if err != nil {
+			t.Fatalf("ListPublicPolls: %v", err)
+		}
+		for _, poll := range page.Polls {
+			got = append(got, poll.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if params.Cursor, err = repository.DecodePollCursor(page.NextCursor); err != nil {
+			t.Fatalf("DecodePollCursor: %v", err)
+		}
+	}
+
+	want := []uuid.UUID{public[2].ID, public[1].ID, public[0].ID}
+	if len(got) != len(want) {
+		t.Fatalf("ListPublicPolls: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ListPublicPolls: got %v, want %v", got, want)
+		}
+	}
+
+	cursor := &repository.PollCursor{Sort: repository.PollSortExpiresAtAsc, Value: now, ID: uuid.New()}
+	_, err := store.ListPublicPolls(ctx, repository.ListPublicPollsParams{Limit: 2, Cursor: cursor})
+	expectError(t, "ListPublicPolls foreign cursor", err, repository.ErrInvalidCursor)
+}
+
+func testPollTally(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := createUser(t, store, "alice")
+	poll := createPoll(t, store, user.ID, "Lunch?", time.Now(), time.Now().Add(time.Hour))
+
+	tally, err := store.GetPollTally(ctx, poll.ID)
+	if err != nil {
+		t.Fatalf("GetPollTally: %v", err)
+	}
+	if tally.LastVoteAt != nil || len(tally.Counts) != len(poll.Options) {
+		t.Fatalf("GetPollTally without votes: got %+v", tally)
+	}
+
+	var last *repository.Vote
+	for _, option := range []int{1, 1, 0} {
+		last = repository.NewVote(poll.ID, poll.Options[option].ID)
+		if err := store.RecordVote(ctx, last); err != nil {
+			t.Fatalf("RecordVote: %v", err)
+		}
+	}
+
+	tally, err = store.GetPollTally(ctx, poll.ID)
+	if err != nil {
+		t.Fatalf("GetPollTally: %v", err)
+	}
+	counts := make([]int, len(poll.Options))
+	for i, option := range poll.Options {
+		counts[i] = tally.Counts[option.ID]
+	}
+	expectCounts(t, counts, 1, 2, 0)
+	if tally.LastVoteAt == nil || !tally.LastVoteAt.Equal(last.VotedAt.Truncate(time.Microsecond)) {
+		t.Fatalf("GetPollTally: got last vote at %v, want %v", tally.LastVoteAt, last.VotedAt)
+	}
+
+	_, err = store.GetPollTally(ctx, uuid.New())
+	expectError(t, "GetPollTally unknown", err, repository.ErrPollNotFound)
+}
+
+func testDeletePoll(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	owner := createUser(t, store, "alice")
+	other := createUser(t, store, "bob")
+	poll := createPoll(t, store, owner.ID, "Lunch?", time.Now(), time.Now().Add(time.Hour))
+
+	err := store.DeletePoll(ctx, repository.DeletePollParams{PollID: uuid.New(), UserID: owner.ID})
+	expectError(t, "DeletePoll unknown", err, repository.ErrPollNotFound)
+
+	err = store.DeletePoll(ctx, repository.DeletePollParams{PollID: poll.ID, UserID: other.ID})
+	expectError(t, "DeletePoll not owner", err, repository.ErrNotPollOwner)
+
+	if err := store.DeletePoll(ctx, repository.DeletePollParams{PollID: poll.ID, UserID: owner.ID}); err != nil {
+		t.Fatalf("DeletePoll: %v", err)
+	}
+
+	_, err = store.GetPollWithOptions(ctx, poll.ID)
+	expectError(t, "GetPollWithOptions deleted", err, repository.ErrPollNotFound)
+}
+
+func testCloseExpiredPolls(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := createUser(t, store, "alice")
+	now := time.Now()
+
+	older := createPoll(t, store, user.ID, "Older?", now.Add(-3*time.Hour), now.Add(-2*time.Hour))
+	newer := createPoll(t, store, user.ID, "Newer?", now.Add(-3*time.Hour), now.Add(-time.Hour))
+	active := createPoll(t, store, user.ID, "Active?", now, now.Add(time.Hour))
+
+	subscribed := createWebhook(t, store, user.ID, nil, "poll.closed")
+	unsubscribed := createWebhook(t, store, user.ID, &older.ID, "vote.recorded")
+
+	closed, err := store.CloseExpiredPolls(ctx, repository.CloseExpiredPollsParams{Now: now, Limit: 1})
+	if err != nil {
+		t.Fatalf("CloseExpiredPolls: %v", err)
+	}
+	if len(closed) != 1 || closed[0].PollID != older.ID || closed[0].UserID != user.ID || closed[0].Question != older.Question {
+		t.Fatalf("CloseExpiredPolls: got %+v, want only %s", closed, older.ID)
+	}
+	if !closed[0].ClosedAt.Equal(now.Truncate(time.Microsecond)) {
+		t.Fatalf("CloseExpiredPolls: got closed at %v, want %v", closed[0].ClosedAt, now)
+	}
+
+	// Closing a poll queues its poll.closed deliveries along with it.
+	deliveries, err := store.GetWebhookDeliveries(ctx, subscribed.ID, 10)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].EventType != "poll.closed" || deliveries[0].Status != repository.WebhookDeliveryPending ||
+		!strings.Contains(deliveries[0].Payload, older.ID.String()) {
+		t.Fatalf("GetWebhookDeliveries: got %+v, want a pending poll.closed delivery of %s", deliveries, older.ID)
+	}
+
+	deliveries, err = store.GetWebhookDeliveries(ctx, unsubscribed.ID, 10)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 0 {
+		t.Fatalf("GetWebhookDeliveries of unsubscribed webhook: got %+v, want none", deliveries)
+	}
+
+	closed, err = store.CloseExpiredPolls(ctx, repository.CloseExpiredPollsParams{Now: now, Limit: 10})
+	if err != nil {
+		t.Fatalf("CloseExpiredPolls: %v", err)
+	}
+	if len(closed) != 1 || closed[0].PollID != newer.ID {
+		t.Fatalf("CloseExpiredPolls: got %+v, want only %s", closed, newer.ID)
+	}
+
+	// Closed polls are not closed again, and active ones once they expire.
+	closed, err = store.CloseExpiredPolls(ctx, repository.CloseExpiredPollsParams{Now: now, Limit: 10})
+	if err != nil {
+		t.Fatalf("CloseExpiredPolls: %v", err)
+	}
+	if len(closed) != 0 {
+		t.Fatalf("CloseExpiredPolls: got %+v, want none", closed)
+	}
+
+	closed, err = store.CloseExpiredPolls(ctx, repository.CloseExpiredPollsParams{Now: now.Add(2 * time.Hour), Limit: 10})
+	if err != nil {
+		t.Fatalf("CloseExpiredPolls: %v", err)
+	}
+	if len(closed) != 1 || closed[0].PollID != active.ID {
+		t.Fatalf("CloseExpiredPolls: got %+v, want only %s", closed, active.ID)
+	}
+}
+
+func userPollCounts(t *testing.T, store repository.Store, userID, pollID uuid.UUID) []int {
+	t.Helper()
+
+	page, err := store.GetUserPollsWithStats(context.Background(), repository.GetUserPollsParams{
+		UserID: userID,
+		Limit:  100,
+	})
+	if err != nil {
+		t.Fatalf("GetUserPollsWithStats: %v", err)
+	}
+
+	for _, poll := range page.Polls {
+		if poll.ID == pollID {
+			counts := make([]int, len(poll.Options))
+			for i, option := range poll.Options {
+				counts[i] = option.Count
+			}
+			return counts
+		}
+	}
+
+	t.Fatalf("GetUserPollsWithStats: poll %s not listed", pollID)
+	return nil
+}
+
+func expectCounts(t *testing.T, got []int, want ...int) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got counts %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got counts %v, want %v", got, want)
+		}
+	}
+}
+
+func testRecordVote(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := createUser(t, store, "alice")
+	active := createPoll(t, store, user.ID, "Lunch?", time.Now(), time.Now().Add(time.Hour))
+	expired := createPoll(t, store, user.ID, "Breakfast?", time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+
+	for _, option := range []int{0, 0, 2} {
+		vote := repository.NewVote(active.ID, active.Options[option].ID)
+		if err := store.RecordVote(ctx, vote); err != nil {
+			t.Fatalf("RecordVote: %v", err)
+		}
+	}
+	expectCounts(t, userPollCounts(t, store, user.ID, active.ID), 2, 0, 1)
+
+	err := store.RecordVote(ctx, repository.NewVote(uuid.New(), active.Options[0].ID))
+	expectError(t, "RecordVote unknown poll", err, repository.ErrPollNotFound)
+
+	err = store.RecordVote(ctx, repository.NewVote(expired.ID, expired.Options[0].ID))
+	expectError(t, "RecordVote expired poll", err, repository.ErrPollExpired)
+
+	err = store.RecordVote(ctx, repository.NewVote(active.ID, expired.Options[0].ID))
+	expectError(t, "RecordVote foreign option", err, repository.ErrOptionBelongsToPoll)
+
+	expectCounts(t, userPollCounts(t, store, user.ID, active.ID), 2, 0, 1)
+
+	corrected, err := store.ReconcileVoteCounts(ctx, nil)
+	if err != nil {
+		t.Fatalf("ReconcileVoteCounts: %v", err)
+	}
+	if corrected != 0 {
+		t.Fatalf("ReconcileVoteCounts: corrected %d options of consistent counters", corrected)
+	}
+}
+
+func testRecordVotes(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := createUser(t, store, "alice")
+	poll := createPoll(t, store, user.ID, "Lunch?", time.Now(), time.Now().Add(time.Hour))
+
+	votes := []*repository.Vote{
+		repository.NewVote(poll.ID, poll.Options[1].ID),
+		repository.NewVote(poll.ID, poll.Options[1].ID),
+		repository.NewVote(poll.ID, poll.Options[2].ID),
+	}
+	if err := store.RecordVotes(ctx, votes); err != nil {
+		t.Fatalf("RecordVotes: %v", err)
+	}
+	expectCounts(t, userPollCounts(t, store, user.ID, poll.ID), 0, 2, 1)
+
+	invalid := []*repository.Vote{
+		repository.NewVote(poll.ID, poll.Options[0].ID),
+		repository.NewVote(uuid.New(), poll.Options[0].ID),
+	}
+	if err := store.RecordVotes(ctx, invalid); err == nil {
+		t.Fatal("RecordVotes: expected an error for a vote of an unknown poll")
+	}
+	expectCounts(t, userPollCounts(t, store, user.ID, poll.ID), 0, 2, 1)
+}
+
+func testBallots(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := createUser(t, store, "alice")
+
+	poll := repository.NewPoll(repository.NewPollParams{
+		UserID:     user.ID,
+		Question:   "Board election?",
+		ExpiresAt:  time.Now().Add(time.Hour),
+		Options:    []repository.NewPollOption{{Text: "Yes"}, {Text: "No"}},
+		Visibility: repository.PollVisibilityBallot,
+	})
+	if err := store.CreatePollWithOptions(ctx, poll); err != nil {
+		t.Fatalf("CreatePollWithOptions: %v", err)
+	}
+
+	bob, bobToken := repository.NewBallot(poll.ID, "bob", "bob@example.com")
+	carol, carolToken := repository.NewBallot(poll.ID, "carol@example.com", "carol@example.com")
+	created, err := store.CreateBallots(ctx, []*repository.Ballot{bob, carol})
+	if err != nil {
+		t.Fatalf("CreateBallots: %v", err)
+	}
+	if len(created) != 2 {
+		t.Fatalf("CreateBallots: created %d ballots, want 2", len(created))
+	}
+
+	again, _ := repository.NewBallot(poll.ID, "bob", "bob@example.com")
+	created, err = store.CreateBallots(ctx, []*repository.Ballot{again})
+	if err != nil {
+		t.Fatalf("CreateBallots again: %v", err)
+	}
+	if len(created) != 0 {
+		t.Fatal("CreateBallots: issued a second ballot to the same recipient")
+	}
+
+	got, err := store.GetBallot(ctx, poll.ID, bobToken)
+	if err != nil {
+		t.Fatalf("GetBallot: %v", err)
+	}
+	if got.ID != bob.ID || got.Used {
+		t.Fatalf("GetBallot: got %+v, want unused ballot %s", got, bob.ID)
+	}
+
+	_, err = store.GetBallot(ctx, poll.ID, "forged")
+	expectError(t, "GetBallot forged", err, repository.ErrBallotNotFound)
+
+	err = store.RecordVote(ctx, repository.NewVote(poll.ID, poll.Options[0].ID))
+	expectError(t, "RecordVote without ballot", err, repository.ErrBallotRequired)
+
+	forged := repository.NewVote(poll.ID, poll.Options[0].ID)
+	forged.BallotToken = "forged"
+	err = store.RecordVote(ctx, forged)
+	expectError(t, "RecordVote forged ballot", err, repository.ErrBallotNotFound)
+
+	// Of concurrent votes with the same ballot exactly one is recorded.
+	var (
+		wg       sync.WaitGroup
+		errs     = make(chan error, 8)
+		recorded = 0
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vote := repository.NewVote(poll.ID, poll.Options[0].ID)
+			vote.BallotToken = bobToken
+			errs <- store.RecordVote(ctx, vote)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		switch {
+		case err == nil:
+			recorded++
+		case !errors.Is(err, repository.ErrBallotUsed):
+			t.Fatalf("RecordVote used ballot: got %v, want %v", err, repository.ErrBallotUsed)
+		}
+	}
+	if recorded != 1 {
+		t.Fatalf("RecordVote: recorded %d votes with one ballot", recorded)
+	}
+	expectCounts(t, userPollCounts(t, store, user.ID, poll.ID), 1, 0)
+
+	turnout, err := store.GetPollTurnout(ctx, poll.ID)
+	if err != nil {
+		t.Fatalf("GetPollTurnout: %v", err)
+	}
+	if turnout.Invited != 2 || turnout.Voted != 1 || turnout.Turnout != 0.5 {
+		t.Fatalf("GetPollTurnout: got %d of %d (%v), want 1 of 2", turnout.Voted, turnout.Invited, turnout.Turnout)
+	}
+	if turnout.Ballots[0].Recipient != "bob" || !turnout.Ballots[0].Used || turnout.Ballots[1].Used {
+		t.Fatalf("GetPollTurnout: got ballots %+v", turnout.Ballots)
+	}
+
+	// Turnout tells who voted but not when, so that it cannot be joined to
+	// the times of the votes: the only time of a ballot is when it was issued.
+	data, err := json.Marshal(turnout)
+	if err != nil {
+		t.Fatalf("Marshal turnout: %v", err)
+	}
+	var fields struct {
+		Ballots []map[string]any `json:"ballots"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Unmarshal turnout: %v", err)
+	}
+	for _, ballot := range fields.Ballots {
+		for key := range ballot {
+			if !slices.Contains([]string{"id", "pollID", "recipient", "email", "createdAt", "used"}, key) {
+				t.Fatalf("GetPollTurnout: ballot has field %q", key)
+			}
+		}
+	}
+	if !turnout.Ballots[0].CreatedAt.Equal(got.CreatedAt) {
+		t.Fatalf("GetPollTurnout: ballot issued at %v, was %v", turnout.Ballots[0].CreatedAt, got.CreatedAt)
+	}
+
+	// Ballots of another poll are not valid.
+	other := createPoll(t, store, user.ID, "Lunch?", time.Now(), time.Now().Add(time.Hour))
+	_, err = store.GetBallot(ctx, other.ID, carolToken)
+	expectError(t, "GetBallot other poll", err, repository.ErrBallotNotFound)
+}
+
+func createPollWith(t *testing.T, store repository.Store, params repository.NewPollParams) *repository.Poll {
+	t.Helper()
+
+	params.ExpiresAt = time.Now().Add(time.Hour)
+	params.Options = []repository.NewPollOption{{Text: "Yes"}, {Text: "No"}}
+
+	poll := repository.NewPoll(params)
+	if err := store.CreatePollWithOptions(context.Background(), poll); err != nil {
+		t.Fatalf("CreatePollWithOptions: %v", err)
+	}
+
+	return poll
+}
+
+func voteAs(store repository.Store, poll *repository.Poll, option int, voterID *uuid.UUID, ballotToken string) error {
+	vote := repository.NewVote(poll.ID, poll.Options[option].ID)
+	vote.VoterID = voterID
+	vote.BallotToken = ballotToken
+	return store.RecordVote(context.Background(), vote)
+}
+
+func testVoteAttribution(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	owner := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+	carol := createUser(t, store, "carol")
+
+	attributed := createPollWith(t, store, repository.NewPollParams{
+		UserID: owner.ID, Question: "Attributed?", Attribution: repository.PollAttributionAttributed,
+	})
+
+	got, err := store.GetPollWithOptions(ctx, attributed.ID)
+	if err != nil {
+		t.Fatalf("GetPollWithOptions: %v", err)
+	}
+	if got.Attribution != repository.PollAttributionAttributed {
+		t.Fatalf("GetPollWithOptions: got attribution %q, want %q", got.Attribution, repository.PollAttributionAttributed)
+	}
+
+	expectError(t, "RecordVote without voter", voteAs(store, attributed, 0, nil, ""), repository.ErrVoterRequired)
+	if err := voteAs(store, attributed, 1, &bob.ID, ""); err != nil {
+		t.Fatalf("RecordVote bob: %v", err)
+	}
+	expectError(t, "RecordVote bob again", voteAs(store, attributed, 0, &bob.ID, ""), repository.ErrAlreadyVoted)
+	if err := voteAs(store, attributed, 0, &carol.ID, ""); err != nil {
+		t.Fatalf("RecordVote carol: %v", err)
+	}
+	expectCounts(t, userPollCounts(t, store, owner.ID, attributed.ID), 1, 1)
+
+	voters, err := store.GetPollVoters(ctx, attributed.ID)
+	if err != nil {
+		t.Fatalf("GetPollVoters: %v", err)
+	}
+	if len(voters.Voters) != 2 ||
+		voters.Voters[0].UserID != bob.ID || voters.Voters[1].UserID != carol.ID ||
+		voters.Voters[0].OptionID == nil || *voters.Voters[0].OptionID != attributed.Options[1].ID ||
+		voters.Voters[1].VotedAt == nil {
+		t.Fatalf("GetPollVoters attributed: got %+v", voters.Voters)
+	}
+
+	verified := createPollWith(t, store, repository.NewPollParams{
+		UserID: owner.ID, Question: "Verified?", Attribution: repository.PollAttributionVerified,
+	})
+	if err := voteAs(store, verified, 0, &bob.ID, ""); err != nil {
+		t.Fatalf("RecordVote verified: %v", err)
+	}
+	expectError(t, "RecordVote verified again", voteAs(store, verified, 1, &bob.ID, ""), repository.ErrAlreadyVoted)
+
+	voters, err = store.GetPollVoters(ctx, verified.ID)
+	if err != nil {
+		t.Fatalf("GetPollVoters: %v", err)
+	}
+	if len(voters.Voters) != 1 || voters.Voters[0].Username != bob.Username ||
+		voters.Voters[0].OptionID != nil || voters.Voters[0].VotedAt != nil {
+		t.Fatalf("GetPollVoters verified: got %+v, want bob without a choice", voters.Voters)
+	}
+
+	// Anonymous polls ignore the voter.
+	anonymous := createPollWith(t, store, repository.NewPollParams{UserID: owner.ID, Question: "Anonymous?"})
+	for range 2 {
+		if err := voteAs(store, anonymous, 0, &bob.ID, ""); err != nil {
+			t.Fatalf("RecordVote anonymous: %v", err)
+		}
+	}
+	voters, err = store.GetPollVoters(ctx, anonymous.ID)
+	if err != nil {
+		t.Fatalf("GetPollVoters: %v", err)
+	}
+	if voters.Attribution != repository.PollAttributionAnonymous || len(voters.Voters) != 0 {
+		t.Fatalf("GetPollVoters anonymous: got %+v", voters)
+	}
+
+	// A second vote of the same voter does not use up their other ballot.
+	ballot := createPollWith(t, store, repository.NewPollParams{
+		UserID: owner.ID, Question: "Ballot?", Visibility: repository.PollVisibilityBallot,
+		Attribution: repository.PollAttributionVerified,
+	})
+	first, firstToken := repository.NewBallot(ballot.ID, "first", "")
+	second, secondToken := repository.NewBallot(ballot.ID, "second", "")
+	if _, err := store.CreateBallots(ctx, []*repository.Ballot{first, second}); err != nil {
+		t.Fatalf("CreateBallots: %v", err)
+	}
+	if err := voteAs(store, ballot, 0, &bob.ID, firstToken); err != nil {
+		t.Fatalf("RecordVote ballot: %v", err)
+	}
+	expectError(t, "RecordVote second ballot", voteAs(store, ballot, 0, &bob.ID, secondToken), repository.ErrAlreadyVoted)
+
+	turnout, err := store.GetPollTurnout(ctx, ballot.ID)
+	if err != nil {
+		t.Fatalf("GetPollTurnout: %v", err)
+	}
+	if turnout.Voted != 1 {
+		t.Fatalf("GetPollTurnout: %d ballots used, want 1", turnout.Voted)
+	}
+
+	_, err = store.GetPollVoters(ctx, uuid.New())
+	expectError(t, "GetPollVoters unknown", err, repository.ErrPollNotFound)
+}
+
+func testOtherAnswers(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	owner := createUser(t, store, "alice")
+	poll := createPollWith(t, store, repository.NewPollParams{UserID: owner.ID, Question: "Lunch?", AllowOther: true})
+
+	got, err := store.GetPollWithOptions(ctx, poll.ID)
+	if err != nil {
+		t.Fatalf("GetPollWithOptions: %v", err)
+	}
+	if len(got.Options) != 3 || !got.Options[2].Other || got.Options[0].Other {
+		t.Fatalf("GetPollWithOptions: got options %+v, want the last to be other", got.Options)
+	}
+
+	var votes []*repository.Vote
+	for i, text := range []primitives.AnswerText{"Pizza", "Sushi", "Tacos"} {
+		vote := repository.NewVote(poll.ID, poll.Options[2].ID)
+		vote.OtherText = text
+		vote.VotedAt = vote.VotedAt.Add(time.Duration(i) * time.Second)
+		if err := store.RecordVote(ctx, vote); err != nil {
+			t.Fatalf("RecordVote: %v", err)
+		}
+		votes = append(votes, vote)
+	}
+	if err := voteAs(store, poll, 0, nil, ""); err != nil {
+		t.Fatalf("RecordVote: %v", err)
+	}
+
+	answers, err := store.GetOtherAnswers(ctx, repository.GetOtherAnswersParams{PollID: poll.ID})
+	if err != nil {
+		t.Fatalf("GetOtherAnswers: %v", err)
+	}
+	if len(answers) != 3 || answers[0].Text != "Pizza" || answers[2].Text != "Tacos" {
+		t.Fatalf("GetOtherAnswers: got %+v, want Pizza, Sushi and Tacos", answers)
+	}
+
+	// Votes for regular options are not answers.
+	hidden, err := store.HideOtherAnswers(ctx, repository.HideOtherAnswersParams{
+		PollID: poll.ID, VoteIDs: []uuid.UUID{votes[1].ID, uuid.New()}, Hidden: true,
+	})
+	if err != nil || hidden != 1 {
+		t.Fatalf("HideOtherAnswers: got %d, %v, want 1", hidden, err)
+	}
+
+	answers, err = store.GetOtherAnswers(ctx, repository.GetOtherAnswersParams{PollID: poll.ID})
+	if err != nil || len(answers) != 2 {
+		t.Fatalf("GetOtherAnswers without hidden: got %+v, %v, want 2 answers", answers, err)
+	}
+	answers, err = store.GetOtherAnswers(ctx, repository.GetOtherAnswersParams{PollID: poll.ID, IncludeHidden: true})
+	if err != nil || len(answers) != 3 || !answers[1].Hidden {
+		t.Fatalf("GetOtherAnswers with hidden: got %+v, %v, want Sushi hidden", answers, err)
+	}
+
+	// Hidden answers are still counted.
+	expectCounts(t, userPollCounts(t, store, owner.ID, poll.ID), 1, 0, 3)
+
+	_, err = store.MergeOtherAnswers(ctx, repository.MergeOtherAnswersParams{
+		PollID: poll.ID, VoteIDs: []uuid.UUID{votes[0].ID}, OptionID: poll.Options[2].ID,
+	})
+	expectError(t, "MergeOtherAnswers into other", err, repository.ErrOptionBelongsToPoll)
+	_, err = store.MergeOtherAnswers(ctx, repository.MergeOtherAnswersParams{
+		PollID: poll.ID, VoteIDs: []uuid.UUID{votes[0].ID}, OptionID: uuid.New(),
+	})
+	expectError(t, "MergeOtherAnswers into unknown", err, repository.ErrOptionBelongsToPoll)
+
+	merged, err := store.MergeOtherAnswers(ctx, repository.MergeOtherAnswersParams{
+		PollID: poll.ID, VoteIDs: []uuid.UUID{votes[0].ID, votes[2].ID}, OptionID: poll.Options[1].ID,
+	})
+	if err != nil || merged != 2 {
+		t.Fatalf("MergeOtherAnswers: got %d, %v, want 2", merged, err)
+	}
+	expectCounts(t, userPollCounts(t, store, owner.ID, poll.ID), 1, 2, 1)
+
+	answers, err = store.GetOtherAnswers(ctx, repository.GetOtherAnswersParams{PollID: poll.ID, IncludeHidden: true})
+	if err != nil || len(answers) != 1 || answers[0].VoteID != votes[1].ID {
+		t.Fatalf("GetOtherAnswers after merge: got %+v, %v, want Sushi", answers, err)
+	}
+
+	// Merged votes are regular votes now and stay reconciled.
+	corrected, err := store.ReconcileVoteCounts(ctx, &poll.ID)
+	if err != nil || corrected != 0 {
+		t.Fatalf("ReconcileVoteCounts: got %d, %v, want 0", corrected, err)
+	}
+}
+
+func testRichOptions(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	owner := createUser(t, store, "alice")
+
+	image := repository.NewImage(owner.ID)
+	image.ContentType, image.Size = "image/png", 1024
+	if err := store.CreateImage(ctx, repository.CreateImageParams{Image: image, Quota: 1 << 20}); err != nil {
+		t.Fatalf("CreateImage: %v", err)
+	}
+
+	got, err := store.GetImage(ctx, image.ID)
+	if err != nil {
+		t.Fatalf("GetImage: %v", err)
+	}
+	if got.UserID != owner.ID || got.ContentType != "image/png" || got.Size != 1024 ||
+		!got.CreatedAt.Equal(image.CreatedAt.Truncate(time.Microsecond)) {
+		t.Fatalf("GetImage: got %+v, want %+v", got, image)
+	}
+
+	_, err = store.GetImage(ctx, uuid.New())
+	expectError(t, "GetImage unknown", err, repository.ErrImageNotFound)
+
+	poll := repository.NewPoll(repository.NewPollParams{
+		UserID:    owner.ID,
+		Question:  "Where to?",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Options: []repository.NewPollOption{
+			{Text: "Beach", Description: "Sun and sand", ImageID: &image.ID, URL: "https://example.com/beach"},
+			{Text: "Mountains"},
+		},
+	})
+	if err := store.CreatePollWithOptions(ctx, poll); err != nil {
+		t.Fatalf("CreatePollWithOptions: %v", err)
+	}
+
+	created, err := store.GetPollWithOptions(ctx, poll.ID)
+	if err != nil {
+		t.Fatalf("GetPollWithOptions: %v", err)
+	}
+	rich, plain := created.Options[0], created.Options[1]
+	if rich.Description != "Sun and sand" || rich.ImageID == nil || *rich.ImageID != image.ID ||
+		rich.URL != "https://example.com/beach" {
+		t.Fatalf("GetPollWithOptions: got option %+v, want its description, image and URL", rich)
+	}
+	if plain.Description != "" || plain.ImageID != nil || plain.URL != "" {
+		t.Fatalf("GetPollWithOptions: got option %+v, want only its text", plain)
+	}
+
+	unknown := uuid.New()
+	err = store.CreatePollWithOptions(ctx, repository.NewPoll(repository.NewPollParams{
+		UserID:    owner.ID,
+		Question:  "Where to?",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Options:   []repository.NewPollOption{{Text: "Beach", ImageID: &unknown}, {Text: "Mountains"}},
+	}))
+	if err == nil {
+		t.Fatal("CreatePollWithOptions with an unknown image: got no error")
+	}
+}
+
+func testUnusedImages(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	owner := createUser(t, store, "alice")
+	other := createUser(t, store, "bob")
+
+	createImage := func(userID uuid.UUID, createdAt time.Time) *repository.Image {
+		t.Helper()
+
+		image := repository.NewImage(userID)
+		image.ContentType, image.Size, image.CreatedAt = "image/png", 400, createdAt
+		if err := store.CreateImage(ctx, repository.CreateImageParams{Image: image, Quota: 1300}); err != nil {
+			t.Fatalf("CreateImage: %v", err)
+		}
+		return image
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	used := createImage(owner.ID, old)
+	ofDeleted := createImage(owner.ID, old.Add(time.Minute))
+	createImage(other.ID, old)
+
+	// The images of other users do not count towards the quota.
+	image := repository.NewImage(owner.ID)
+	image.ContentType, image.Size = "image/png", 501
+	err := store.CreateImage(ctx, repository.CreateImageParams{Image: image, Quota: 1300})
+	expectError(t, "CreateImage over the quota", err, repository.ErrImageQuotaExceeded)
+	if _, err := store.GetImage(ctx, image.ID); !errors.Is(err, repository.ErrImageNotFound) {
+		t.Fatalf("GetImage over the quota: got %v, want %v", err, repository.ErrImageNotFound)
+	}
+	recent := createImage(owner.ID, time.Now())
+
+	kept := repository.NewPoll(repository.NewPollParams{
+		UserID:    owner.ID,
+		Question:  "Where to?",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Options:   []repository.NewPollOption{{Text: "Beach", ImageID: &used.ID}, {Text: "Mountains"}},
+	})
+	deleted := repository.NewPoll(repository.NewPollParams{
+		UserID:    owner.ID,
+		Question:  "Where else?",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Options:   []repository.NewPollOption{{Text: "Lake", ImageID: &ofDeleted.ID}, {Text: "City"}},
+	})
+	for _, poll := range []*repository.Poll{kept, deleted} {
+		if err := store.CreatePollWithOptions(ctx, poll); err != nil {
+			t.Fatalf("CreatePollWithOptions: %v", err)
+		}
+	}
+	if err := store.DeletePoll(ctx, repository.DeletePollParams{PollID: deleted.ID, UserID: owner.ID}); err != nil {
+		t.Fatalf("DeletePoll: %v", err)
+	}
+
+	createdBefore := time.Now().Add(-24 * time.Hour)
+	first, err := store.DeleteUnusedImages(ctx, repository.DeleteUnusedImagesParams{CreatedBefore: createdBefore, Limit: 1})
+	if err != nil || len(first) != 1 {
+		t.Fatalf("DeleteUnusedImages: got %+v, %v, want 1 image", first, err)
+	}
+	rest, err := store.DeleteUnusedImages(ctx, repository.DeleteUnusedImagesParams{CreatedBefore: createdBefore, Limit: 10})
+	if err != nil || len(rest) != 1 {
+		t.Fatalf("DeleteUnusedImages: got %+v, %v, want 1 image", rest, err)
+	}
+	if first[0].UserID != other.ID || rest[0].ID != ofDeleted.ID {
+		t.Fatalf("DeleteUnusedImages: got %v and %v, want the image of bob, then of the deleted poll", first[0].ID, rest[0].ID)
+	}
+
+	for _, image := range []*repository.Image{used, recent} {
+		if _, err := store.GetImage(ctx, image.ID); err != nil {
+			t.Fatalf("GetImage %v: %v", image.ID, err)
+		}
+	}
+	if _, err := store.GetImage(ctx, ofDeleted.ID); !errors.Is(err, repository.ErrImageNotFound) {
+		t.Fatalf("GetImage of the deleted poll: got %v, want %v", err, repository.ErrImageNotFound)
+	}
+
+	// Deleted images no longer count towards the quota.
+	createImage(owner.ID, time.Now())
+}
+
+func testUserPollsPagination(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := createUser(t, store, "alice")
+	other := createUser(t, store, "bob")
+
+	var (
+		base = time.Now().Add(-time.Hour)
+		ids  []uuid.UUID
+	)
+	for i := range 5 {
+		poll := createPoll(t, store, user.ID, "Poll", base.Add(time.Duration(i)*time.Minute), time.Now().Add(time.Hour))
+		ids = append(ids, poll.ID)
+	}
+	createPoll(t, store, other.ID, "Poll", base, time.Now().Add(time.Hour))
+
+	var (
+		got    []uuid.UUID
+		cursor *repository.PollCursor
+	)
+	for range 3 {
+		page, err := store.GetUserPollsWithStats(ctx, repository.GetUserPollsParams{
+			UserID: user.ID,
+			Sort:   repository.PollSortCreatedAtDesc,
+			Cursor: cursor,
+			Limit:  2,
+		})
+		if err != nil {
+			t.Fatalf("GetUserPollsWithStats: %v", err)
+		}
+
+		for _, poll := range page.Polls {
+			got = append(got, poll.ID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		if cursor, err = repository.DecodePollCursor(page.NextCursor); err != nil {
+			t.Fatalf("DecodePollCursor: %v", err)
+		}
+	}
+
+	if len(got) != len(ids) {
+		t.Fatalf("paginated %d polls, want %d", len(got), len(ids))
+	}
+	for i, id := range got {
+		if id != ids[len(ids)-1-i] {
+			t.Fatalf("poll %d is %s, want %s", i, id, ids[len(ids)-1-i])
+		}
+	}
+
+	_, err := store.GetUserPollsWithStats(ctx, repository.GetUserPollsParams{
+		UserID: user.ID,
+		Sort:   repository.PollSortExpiresAtAsc,
+		Cursor: cursor,
+		Limit:  2,
+	})
+	expectError(t, "GetUserPollsWithStats mismatched cursor", err, repository.ErrInvalidCursor)
+}
+
+func testUserPollsFilters(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := createUser(t, store, "alice")
+
+	var (
+		now     = time.Now()
+		active  = createPoll(t, store, user.ID, "Where to eat lunch?", now.Add(-time.Hour), now.Add(time.Hour))
+		expired = createPoll(t, store, user.ID, "Team 100% offsite?", now.Add(-3*time.Hour), now.Add(-time.Hour))
+	)
+
+	list := func(params repository.GetUserPollsParams) []uuid.UUID {
+		t.Helper()
+
+		params.UserID, params.Limit = user.ID, 10
+		page, err := store.GetUserPollsWithStats(ctx, params)
+		if err != nil {
+			t.Fatalf("GetUserPollsWithStats(%+v): %v", params, err)
+		}
+
+		ids := make([]uuid.UUID, len(page.Polls))
+		for i, poll := range page.Polls {
+			ids[i] = poll.ID
+		}
+		return ids
+	}
+
+	expectIDs := func(what string, got []uuid.UUID, want ...uuid.UUID) {
+		t.Helper()
+
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %v, want %v", what, got, want)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("%s: got %v, want %v", what, got, want)
+			}
+		}
+	}
+
+	createdBefore := now.Add(-2 * time.Hour)
+
+	expectIDs("active", list(repository.GetUserPollsParams{Status: repository.PollStatusActive}), active.ID)
+	expectIDs("expired", list(repository.GetUserPollsParams{Status: repository.PollStatusExpired}), expired.ID)
+	expectIDs("search", list(repository.GetUserPollsParams{Search: "LUNCH"}), active.ID)
+	expectIDs("search wildcard", list(repository.GetUserPollsParams{Search: "100%"}), expired.ID)
+	expectIDs("created before", list(repository.GetUserPollsParams{CreatedBefore: &createdBefore}), expired.ID)
+	expectIDs("created after", list(repository.GetUserPollsParams{CreatedAfter: &createdBefore}), active.ID)
+	expectIDs("expires asc", list(repository.GetUserPollsParams{Sort: repository.PollSortExpiresAtAsc}), expired.ID, active.ID)
+}
+
+func testAnalytics(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	owner := createUser(t, store, "alice")
+	poll := createPoll(t, store, owner.ID, "Lunch?", time.Now(), time.Now().Add(time.Hour))
+
+	start := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	votes := []*repository.Vote{
+		{ID: uuid.New(), PollID: poll.ID, OptionID: poll.Options[0].ID, VotedAt: start.Add(time.Minute)},
+		{ID: uuid.New(), PollID: poll.ID, OptionID: poll.Options[1].ID, VotedAt: start.Add(2 * time.Minute)},
+		{ID: uuid.New(), PollID: poll.ID, OptionID: poll.Options[0].ID, VotedAt: start.Add(2*time.Hour + time.Minute)},
+		{ID: uuid.New(), PollID: poll.ID, OptionID: poll.Options[0].ID, VotedAt: start.Add(2*time.Hour + 2*time.Minute)},
+		{ID: uuid.New(), PollID: poll.ID, OptionID: poll.Options[0].ID, VotedAt: start.Add(2*time.Hour + 3*time.Minute)},
+	}
+	if err := store.RecordVotes(ctx, votes); err != nil {
+		t.Fatalf("RecordVotes: %v", err)
+	}
+
+	_, err := store.GetPollAnalytics(ctx, repository.GetPollAnalyticsParams{
+		PollID: uuid.New(), Interval: repository.AnalyticsIntervalHour,
+	})
+	expectError(t, "GetPollAnalytics unknown", err, repository.ErrPollNotFound)
+
+	analytics, err := store.GetPollAnalytics(ctx, repository.GetPollAnalyticsParams{
+		PollID: poll.ID, Interval: repository.AnalyticsIntervalHour,
+	})
+	if err != nil {
+		t.Fatalf("GetPollAnalytics: %v", err)
+	}
+
+	if analytics.TotalVotes != len(votes) {
+		t.Fatalf("total votes is %d, want %d", analytics.TotalVotes, len(votes))
+	}
+	if analytics.FirstVoteAt == nil || !analytics.FirstVoteAt.Equal(votes[0].VotedAt) {
+		t.Fatalf("first vote at %v, want %v", analytics.FirstVoteAt, votes[0].VotedAt)
+	}
+	if analytics.LastVoteAt == nil || !analytics.LastVoteAt.Equal(votes[4].VotedAt) {
+		t.Fatalf("last vote at %v, want %v", analytics.LastVoteAt, votes[4].VotedAt)
+	}
+
+	if len(analytics.Totals) != 2 ||
+		!analytics.Totals[0].Start.Equal(start) || analytics.Totals[0].Count != 2 || analytics.Totals[0].Cumulative != 2 ||
+		!analytics.Totals[1].Start.Equal(start.Add(2*time.Hour)) || analytics.Totals[1].Count != 3 || analytics.Totals[1].Cumulative != 5 {
+		t.Fatalf("unexpected totals %+v", analytics.Totals)
+	}
+
+	if analytics.Peak == nil || !analytics.Peak.Start.Equal(start.Add(2*time.Hour)) || analytics.Peak.Count != 3 {
+		t.Fatalf("unexpected peak %+v", analytics.Peak)
+	}
+
+	first := analytics.Options[0]
+	if first.Total != 4 || len(first.Buckets) != 2 || first.Buckets[1].Count != 3 || first.Buckets[1].Cumulative != 4 {
+		t.Fatalf("unexpected series of the first option %+v", first)
+	}
+	if third := analytics.Options[2]; third.Total != 0 || len(third.Buckets) != 0 {
+		t.Fatalf("unexpected series of the third option %+v", third)
+	}
+}
+
+func createOrganization(t *testing.T, store repository.Store, ownerID uuid.UUID, members map[uuid.UUID]repository.OrganizationRole) *repository.Organization {
+	t.Helper()
+
+	ctx := context.Background()
+	organization := repository.NewOrganization("Acme")
+	if err := store.CreateOrganization(ctx, organization, ownerID); err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+
+	for userID, role := range members {
+		err := store.SetOrganizationMember(ctx, repository.SetOrganizationMemberParams{
+			OrganizationID: organization.ID,
+			UserID:         userID,
+			Role:           role,
+		})
+		if err != nil {
+			t.Fatalf("SetOrganizationMember(%s): %v", role, err)
+		}
+	}
+
+	return organization
+}
+
+func testOrganizations(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	owner := createUser(t, store, "alice")
+	admin := createUser(t, store, "bob")
+	outsider := createUser(t, store, "carol")
+
+	organization := createOrganization(t, store, owner.ID, map[uuid.UUID]repository.OrganizationRole{
+		admin.ID: repository.OrganizationRoleAdmin,
+	})
+
+	memberships, err := store.GetUserOrganizations(ctx, admin.ID)
+	if err != nil {
+		t.Fatalf("GetUserOrganizations: %v", err)
+	}
+	if len(memberships) != 1 || memberships[0].ID != organization.ID || memberships[0].Role != repository.OrganizationRoleAdmin {
+		t.Fatalf("GetUserOrganizations: got %+v, want admin of %s", memberships, organization.ID)
+	}
+
+	role, err := store.GetOrganizationRole(ctx, organization.ID, owner.ID)
+	if err != nil || role != repository.OrganizationRoleOwner {
+		t.Fatalf("GetOrganizationRole owner: got %q, %v", role, err)
+	}
+
+	_, err = store.GetOrganizationRole(ctx, organization.ID, outsider.ID)
+	expectError(t, "GetOrganizationRole outsider", err, repository.ErrNotOrganizationMember)
+
+	_, err = store.GetOrganizationRole(ctx, uuid.New(), owner.ID)
+	expectError(t, "GetOrganizationRole unknown", err, repository.ErrOrganizationNotFound)
+
+	members, err := store.GetOrganizationMembers(ctx, organization.ID)
+	if err != nil {
+		t.Fatalf("GetOrganizationMembers: %v", err)
+	}
+	if len(members) != 2 || members[0].Username != owner.Username || members[1].Role != repository.OrganizationRoleAdmin {
+		t.Fatalf("GetOrganizationMembers: got %+v", members)
+	}
+
+	demote := repository.SetOrganizationMemberParams{
+		OrganizationID: organization.ID,
+		UserID:         owner.ID,
+		Role:           repository.OrganizationRoleMember,
+	}
+	expectError(t, "SetOrganizationMember last owner", store.SetOrganizationMember(ctx, demote), repository.ErrLastOrganizationOwner)
+	expectError(t, "RemoveOrganizationMember last owner",
+		store.RemoveOrganizationMember(ctx, organization.ID, owner.ID), repository.ErrLastOrganizationOwner)
+	expectError(t, "RemoveOrganizationMember outsider",
+		store.RemoveOrganizationMember(ctx, organization.ID, outsider.ID), repository.ErrNotOrganizationMember)
+
+	promote := repository.SetOrganizationMemberParams{
+		OrganizationID: organization.ID,
+		UserID:         admin.ID,
+		Role:           repository.OrganizationRoleOwner,
+	}
+	if err := store.SetOrganizationMember(ctx, promote); err != nil {
+		t.Fatalf("SetOrganizationMember promote: %v", err)
+	}
+	if err := store.SetOrganizationMember(ctx, demote); err != nil {
+		t.Fatalf("SetOrganizationMember demote: %v", err)
+	}
+	if err := store.RemoveOrganizationMember(ctx, organization.ID, owner.ID); err != nil {
+		t.Fatalf("RemoveOrganizationMember: %v", err)
+	}
+
+	_, err = store.GetOrganizationRole(ctx, organization.ID, owner.ID)
+	expectError(t, "GetOrganizationRole removed", err, repository.ErrNotOrganizationMember)
+}
+
+func testOrganizationPolls(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	owner := createUser(t, store, "alice")
+	admin := createUser(t, store, "bob")
+	member := createUser(t, store, "carol")
+	viewer := createUser(t, store, "dave")
+	outsider := createUser(t, store, "erin")
+
+	organization := createOrganization(t, store, owner.ID, map[uuid.UUID]repository.OrganizationRole{
+		admin.ID:  repository.OrganizationRoleAdmin,
+		member.ID: repository.OrganizationRoleMember,
+		viewer.ID: repository.OrganizationRoleViewer,
+	})
+
+	createOrganizationPoll := func() *repository.Poll {
+		t.Helper()
+
+		poll := repository.NewPoll(repository.NewPollParams{
+			UserID:         member.ID,
+			OrganizationID: &organization.ID,
+			Question:       "Standup?",
+			ExpiresAt:      time.Now().Add(time.Hour),
+			Options:        []repository.NewPollOption{{Text: "Yes"}, {Text: "No"}},
+			Visibility:     repository.PollVisibilityPrivate,
+		})
+		if err := store.CreatePollWithOptions(ctx, poll); err != nil {
+			t.Fatalf("CreatePollWithOptions: %v", err)
+		}
+		return poll
+	}
+
+	poll := createOrganizationPoll()
+	personal := createPoll(t, store, viewer.ID, "Lunch?", time.Now(), time.Now().Add(time.Hour))
+
+	got, err := store.GetPollWithOptions(ctx, poll.ID)
+	if err != nil {
+		t.Fatalf("GetPollWithOptions: %v", err)
+	}
+	if got.OrganizationID == nil || *got.OrganizationID != organization.ID {
+		t.Fatalf("GetPollWithOptions: got organization %v, want %s", got.OrganizationID, organization.ID)
+	}
+
+	access, err := store.GetPollAccess(ctx, poll.ID, viewer.ID)
+	if err != nil || access.OrganizationRole != repository.OrganizationRoleViewer {
+		t.Fatalf("GetPollAccess viewer: got %+v, %v", access, err)
+	}
+
+	list := func(params repository.GetUserPollsParams) int {
+		t.Helper()
+
+		params.Limit = 10
+		page, err := store.GetUserPollsWithStats(ctx, params)
+		if err != nil {
+			t.Fatalf("GetUserPollsWithStats: %v", err)
+		}
+		return len(page.Polls)
+	}
+
+	if n := list(repository.GetUserPollsParams{UserID: viewer.ID}); n != 2 {
+		t.Fatalf("GetUserPollsWithStats viewer: got %d polls, want own and organization poll", n)
+	}
+	if n := list(repository.GetUserPollsParams{UserID: viewer.ID, OrganizationID: &organization.ID}); n != 1 {
+		t.Fatalf("GetUserPollsWithStats organization: got %d polls, want 1", n)
+	}
+	if n := list(repository.GetUserPollsParams{UserID: outsider.ID}); n != 0 {
+		t.Fatalf("GetUserPollsWithStats outsider: got %d polls, want 0", n)
+	}
+
+	// Organization polls are decided by the role alone, so their creator
+	// may not delete them without being an admin.
+	for _, userID := range []uuid.UUID{member.ID, viewer.ID, outsider.ID} {
+		err := store.DeletePoll(ctx, repository.DeletePollParams{PollID: poll.ID, UserID: userID})
+		expectError(t, "DeletePoll without permission", err, repository.ErrNotPollOwner)
+	}
+
+	err = store.DeletePoll(ctx, repository.DeletePollParams{PollID: personal.ID, UserID: admin.ID})
+	expectError(t, "DeletePoll personal poll", err, repository.ErrNotPollOwner)
+
+	if err := store.DeletePoll(ctx, repository.DeletePollParams{PollID: poll.ID, UserID: admin.ID}); err != nil {
+		t.Fatalf("DeletePoll admin: %v", err)
+	}
+
+	poll = createOrganizationPoll()
+	if err := store.DeletePoll(ctx, repository.DeletePollParams{PollID: poll.ID, UserID: owner.ID}); err != nil {
+		t.Fatalf("DeletePoll organization owner: %v", err)
+	}
+
+	if err := store.DeletePoll(ctx, repository.DeletePollParams{PollID: personal.ID, UserID: viewer.ID}); err != nil {
+		t.Fatalf("DeletePoll personal poll: %v", err)
+	}
+}
+
+func createWebhook(t *testing.T, store repository.Store, userID uuid.UUID, pollID *uuid.UUID, events ...string) *repository.Webhook {
+	t.Helper()
+
+	webhook := repository.NewWebhook(repository.NewWebhookParams{
+		UserID: userID,
+		PollID: pollID,
+		URL:    "https://hooks.example.com/polly",
+		Events: events,
+	})
+
+	if err := store.CreateWebhook(context.Background(), webhook); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	return webhook
+}
+
+func webhookIDs(webhooks []repository.Webhook) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		ids = append(ids, webhook.ID)
+	}
+	return ids
+}
+
+func testWebhooks(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	owner := createUser(t, store, "alice")
+	other := createUser(t, store, "bob")
+	poll := createPoll(t, store, owner.ID, "Lunch?", time.Now(), time.Now().Add(time.Hour))
+	otherPoll := createPoll(t, store, other.ID, "Dinner?", time.Now(), time.Now().Add(time.Hour))
+
+	all := createWebhook(t, store, owner.ID, nil, "poll.created", "poll.closed")
+	ofPoll := createWebhook(t, store, owner.ID, &poll.ID, "vote.recorded", "poll.closed")
+	// Managers other than the owner may subscribe to a poll as well.
+	ofPollByOther := createWebhook(t, store, other.ID, &poll.ID, "poll.closed")
+	createWebhook(t, store, other.ID, nil, "poll.closed")
+
+	got, err := store.GetWebhook(ctx, ofPoll.ID)
+	if err != nil {
+		t.Fatalf("GetWebhook: %v", err)
+	}
+	if got.UserID != owner.ID || got.PollID == nil || *got.PollID != poll.ID || got.URL != ofPoll.URL ||
+		got.Secret != ofPoll.Secret || !slices.Equal(got.Events, ofPoll.Events) {
+		t.Fatalf("GetWebhook: got %+v, want %+v", got, ofPoll)
+	}
+
+	_, err = store.GetWebhook(ctx, uuid.New())
+	expectError(t, "GetWebhook unknown", err, repository.ErrWebhookNotFound)
+
+	missing := uuid.New()
+	err = store.CreateWebhook(ctx, repository.NewWebhook(repository.NewWebhookParams{
+		UserID: owner.ID, PollID: &missing, URL: "https://hooks.example.com", Events: []string{"poll.closed"},
+	}))
+	expectError(t, "CreateWebhook unknown poll", err, repository.ErrPollNotFound)
+
+	webhooks, err := store.GetUserWebhooks(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("GetUserWebhooks: %v", err)
+	}
+	if ids := webhookIDs(webhooks); !slices.Equal(ids, []uuid.UUID{all.ID, ofPoll.ID}) {
+		t.Fatalf("GetUserWebhooks: got %v, want %v", ids, []uuid.UUID{all.ID, ofPoll.ID})
+	}
+
+	for _, tc := range []struct {
+		eventType string
+		pollID    uuid.UUID
+		userID    uuid.UUID
+		want      []uuid.UUID
+	}{
+		{"poll.closed", poll.ID, owner.ID, []uuid.UUID{all.ID, ofPoll.ID, ofPollByOther.ID}},
+		{"poll.created", poll.ID, owner.ID, []uuid.UUID{all.ID}},
+		{"vote.recorded", poll.ID, owner.ID, []uuid.UUID{ofPoll.ID}},
+		{"poll.deleted", poll.ID, owner.ID, []uuid.UUID{}},
+		{"vote.recorded", otherPoll.ID, other.ID, []uuid.UUID{}},
+	} {
+		webhooks, err := store.GetEventWebhooks(ctx, repository.GetEventWebhooksParams{
+			UserID: tc.userID, PollID: tc.pollID, EventType: tc.eventType,
+		})
+		if err != nil {
+			t.Fatalf("GetEventWebhooks(%s): %v", tc.eventType, err)
+		}
+		ids := webhookIDs(webhooks)
+		slices.SortFunc(ids, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+		slices.SortFunc(tc.want, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+		if !slices.Equal(ids, tc.want) {
+			t.Fatalf("GetEventWebhooks(%s of %s): got %v, want %v", tc.eventType, tc.pollID, ids, tc.want)
+		}
+	}
+
+	err = store.DeleteWebhook(ctx, all.ID, other.ID)
+	expectError(t, "DeleteWebhook of another user", err, repository.ErrWebhookNotFound)
+
+	if err := store.DeleteWebhook(ctx, all.ID, owner.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+
+	_, err = store.GetWebhook(ctx, all.ID)
+	expectError(t, "GetWebhook deleted", err, repository.ErrWebhookNotFound)
+
+	// The webhooks of a poll go with it.
+	if err := store.DeletePoll(ctx, repository.DeletePollParams{PollID: poll.ID, UserID: owner.ID}); err != nil {
+		t.Fatalf("DeletePoll: %v", err)
+	}
+
+	_, err = store.GetWebhook(ctx, ofPoll.ID)
+	expectError(t, "GetWebhook of deleted poll", err, repository.ErrWebhookNotFound)
+}
+
+func testWebhookDeliveries(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := createUser(t, store, "alice")
+	webhook := createWebhook(t, store, user.ID, nil, "poll.closed")
+	now := time.Now()
+
+	first := repository.NewWebhookDelivery(webhook.ID, uuid.New(), "poll.closed", `{"n":1}`)
+	first.NextAttemptAt = now.Add(-2 * time.Minute)
+	first.CreatedAt = now.Add(-3 * time.Second)
+	second := repository.NewWebhookDelivery(webhook.ID, uuid.New(), "poll.closed", `{"n":2}`)
+	second.NextAttemptAt = now.Add(-time.Minute)
+	second.CreatedAt = now.Add(-2 * time.Second)
+	later := repository.NewWebhookDelivery(webhook.ID, uuid.New(), "poll.closed", `{"n":3}`)
+	later.NextAttemptAt = now.Add(time.Hour)
+	later.CreatedAt = now.Add(-time.Second)
+
+	if err := store.CreateWebhookDeliveries(ctx, []*repository.WebhookDelivery{first, second, later}); err != nil {
+		t.Fatalf("CreateWebhookDeliveries: %v", err)
+	}
+
+	// An event is queued for a webhook once.
+	again := repository.NewWebhookDelivery(webhook.ID, first.EventID, "poll.closed", `{"n":1}`)
+	if err := store.CreateWebhookDeliveries(ctx, []*repository.WebhookDelivery{again}); err != nil {
+		t.Fatalf("CreateWebhookDeliveries again: %v", err)
+	}
+
+	claimed, err := store.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesParams{
+		Now: now, Limit: 1, LeaseUntil: now.Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("ClaimWebhookDeliveries: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != first.ID || claimed[0].URL != webhook.URL ||
+		claimed[0].Secret != webhook.Secret || claimed[0].Payload != first.Payload {
+		t.Fatalf("ClaimWebhookDeliveries: got %+v, want only %s", claimed, first.ID)
+	}
+
+	// Claimed deliveries are leased.
+	claimed, err = store.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesParams{
+		Now: now, Limit: 10, LeaseUntil: now.Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("ClaimWebhookDeliveries: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != second.ID {
+		t.Fatalf("ClaimWebhookDeliveries: got %+v, want only %s", claimed, second.ID)
+	}
+
+	status := 500
+	if err := store.CompleteWebhookDelivery(ctx, repository.CompleteWebhookDeliveryParams{
+		DeliveryID:     first.ID,
+		Status:         repository.WebhookDeliveryPending,
+		AttemptedAt:    now,
+		ResponseStatus: &status,
+		Error:          "unexpected status 500",
+		NextAttemptAt:  now.Add(30 * time.Second),
+	}); err != nil {
+		t.Fatalf("CompleteWebhookDelivery: %v", err)
+	}
+
+	if err := store.CompleteWebhookDelivery(ctx, repository.CompleteWebhookDeliveryParams{
+		DeliveryID:    second.ID,
+		Status:        repository.WebhookDeliverySucceeded,
+		AttemptedAt:   now,
+		NextAttemptAt: now,
+	}); err != nil {
+		t.Fatalf("CompleteWebhookDelivery: %v", err)
+	}
+
+	claimed, err = store.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesParams{
+		Now: now.Add(time.Minute), Limit: 10, LeaseUntil: now.Add(2 * time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("ClaimWebhookDeliveries: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != first.ID || claimed[0].Attempts != 1 {
+		t.Fatalf("ClaimWebhookDeliveries retry: got %+v, want only %s after 1 attempt", claimed, first.ID)
+	}
+
+	deliveries, err := store.GetWebhookDeliveries(ctx, webhook.ID, 2)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 2 || deliveries[0].ID != later.ID || deliveries[1].ID != second.ID {
+		t.Fatalf("GetWebhookDeliveries: got %+v, want %s and %s", deliveries, later.ID, second.ID)
+	}
+	if deliveries[1].Status != repository.WebhookDeliverySucceeded || deliveries[1].Attempts != 1 ||
+		deliveries[1].LastAttemptAt == nil || deliveries[1].ResponseStatus != nil {
+		t.Fatalf("GetWebhookDeliveries: got %+v, want a succeeded delivery", deliveries[1])
+	}
+
+	deliveries, err = store.GetWebhookDeliveries(ctx, webhook.ID, 10)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 3 || deliveries[2].ResponseStatus == nil || *deliveries[2].ResponseStatus != 500 ||
+		deliveries[2].LastError != "unexpected status 500" {
+		t.Fatalf("GetWebhookDeliveries: got %+v, want the failed attempt of %s last", deliveries, first.ID)
+	}
+}
+
+func testEventDeliveries(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	owner := createUser(t, store, "alice")
+	webhook := createWebhook(t, store, owner.ID, nil, "poll.created", "vote.recorded", "poll.deleted")
+
+	anonymous := createPollWith(t, store, repository.NewPollParams{UserID: owner.ID, Question: "Anonymous?"})
+	attributed := createPollWith(t, store, repository.NewPollParams{
+		UserID: owner.ID, Question: "Attributed?", Attribution: repository.PollAttributionAttributed,
+	})
+	verified := createPollWith(t, store, repository.NewPollParams{
+		UserID: owner.ID, Question: "Verified?", Attribution: repository.PollAttributionVerified,
+	})
+	ballot := createPollWith(t, store, repository.NewPollParams{
+		UserID: owner.ID, Question: "Ballot?", Visibility: repository.PollVisibilityBallot,
+	})
+
+	issued, token := repository.NewBallot(ballot.ID, "bob", "bob@example.com")
+	if _, err := store.CreateBallots(ctx, []*repository.Ballot{issued}); err != nil {
+		t.Fatalf("CreateBallots: %v", err)
+	}
+
+	var (
+		published = repository.NewVote(anonymous.ID, anonymous.Options[0].ID)
+		batch     = []*repository.Vote{
+			repository.NewVote(anonymous.ID, anonymous.Options[1].ID),
+			repository.NewVote(anonymous.ID, anonymous.Options[1].ID),
+		}
+	)
+	if err := store.RecordVote(ctx, published); err != nil {
+		t.Fatalf("RecordVote: %v", err)
+	}
+	if err := store.RecordVotes(ctx, batch); err != nil {
+		t.Fatalf("RecordVotes: %v", err)
+	}
+	if err := voteAs(store, attributed, 0, &owner.ID, ""); err != nil {
+		t.Fatalf("RecordVote attributed: %v", err)
+	}
+	// Votes on verified and ballot polls are not announced.
+	if err := voteAs(store, verified, 0, &owner.ID, ""); err != nil {
+		t.Fatalf("RecordVote verified: %v", err)
+	}
+	if err := voteAs(store, ballot, 0, nil, token); err != nil {
+		t.Fatalf("RecordVote ballot: %v", err)
+	}
+
+	if err := store.DeletePoll(ctx, repository.DeletePollParams{PollID: anonymous.ID, UserID: owner.ID}); err != nil {
+		t.Fatalf("DeletePoll: %v", err)
+	}
+
+	deliveries, err := store.GetWebhookDeliveries(ctx, webhook.ID, 100)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveries: %v", err)
+	}
+
+	// Every change is announced by a delivery queued along with it.
+	got := make(map[string]int)
+	for _, delivery := range deliveries {
+		var payload struct {
+			Type   string    `json:"type"`
+			PollID uuid.UUID `json:"pollID"`
+		}
+		if err := json.Unmarshal([]byte(delivery.Payload), &payload); err != nil {
+			t.Fatalf("delivery %s: decoding payload: %v", delivery.ID, err)
+		}
+		if payload.Type != delivery.EventType || delivery.Status != repository.WebhookDeliveryPending {
+			t.Fatalf("delivery %s: got %s %s of a %s event", delivery.ID, delivery.Status, delivery.EventType, payload.Type)
+		}
+		got[fmt.Sprintf("%s %s", delivery.EventType, payload.PollID)]++
+	}
+
+	want := map[string]int{
+		"poll.created " + anonymous.ID.String():   1,
+		"poll.created " + attributed.ID.String():  1,
+		"poll.created " + verified.ID.String():    1,
+		"poll.created " + ballot.ID.String():      1,
+		"vote.recorded " + anonymous.ID.String():  3,
+		"vote.recorded " + attributed.ID.String(): 1,
+		"poll.deleted " + anonymous.ID.String():   1,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("GetWebhookDeliveries: got %v, want %v", got, want)
+	}
+}
@@ -10,13 +10,19 @@ import (
 var (
 	ErrEmailAlreadyExists    = errors.New("email already exists")
 	ErrUsernameAlreadyExists = errors.New("username already exists")
+	ErrUserNotFound          = errors.New("user not found")
 )
 
+// DefaultPlan is the plan of new users.
+const DefaultPlan = "free"
+
 type User struct {
 	ID           uuid.UUID           `json:"id"`
 	Username     primitives.Username `json:"username"`
 	Email        primitives.Email    `json:"email"`
 	PasswordHash string              `json:"-"`
+	// Plan may override limits such as the number of poll options.
+	Plan string `json:"plan"`
 }
 
 type NewUserParams struct {
@@ -31,6 +37,7 @@ func NewUser(params NewUserParams) *User {
 		Username:     params.Username,
 		Email:        params.Email,
 		PasswordHash: params.Password.Hash(),
+		Plan:         DefaultPlan,
 	}
 }
 
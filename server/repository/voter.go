@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/primitives"
+)
+
+var (
+	ErrVoterRequired = errors.New("poll requires a signed in voter")
+	ErrAlreadyVoted  = errors.New("voter already voted on the poll")
+)
+
+// PollAttribution controls whether the owner learns who voted on a poll. Polls
+// that are not anonymous take a single vote per signed in user.
+type PollAttribution string
+
+const (
+	// PollAttributionAnonymous polls store no voter at all.
+	PollAttributionAnonymous PollAttribution = "anonymous"
+	// PollAttributionAttributed polls store who voted for what.
+	PollAttributionAttributed PollAttribution = "attributed"
+	// PollAttributionVerified polls store who voted, but not for what.
+	PollAttributionVerified PollAttribution = "verified"
+)
+
+func (a PollAttribution) Valid() bool {
+	switch a {
+	case PollAttributionAnonymous, PollAttributionAttributed, PollAttributionVerified:
+		return true
+	}
+	return false
+}
+
+// PollVoter is a user who voted on a poll. OptionID and VotedAt are only known
+// for attributed polls.
+type PollVoter struct {
+	UserID   uuid.UUID           `json:"userID"`
+	Username primitives.Username `json:"username"`
+	OptionID *uuid.UUID          `json:"optionID"`
+	VotedAt  *time.Time          `json:"votedAt"`
+}
+
+// PollVoters lists the voters of a poll by username. It is always empty for
+// anonymous polls.
+type PollVoters struct {
+	PollID      uuid.UUID       `json:"pollID"`
+	Attribution PollAttribution `json:"attribution"`
+	Voters      []PollVoter     `json:"voters"`
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/events"
+	"github.com/toramanomer/polly/primitives"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Webhook subscribes a URL to events of the polls of a user, or of a single
+// poll if PollID is set. The secret signs the payloads sent to the URL.
+type Webhook struct {
+	ID     uuid.UUID  `json:"id"`
+	UserID uuid.UUID  `json:"userID"`
+	PollID *uuid.UUID `json:"pollID"`
+	URL    string     `json:"url"`
+	Secret string     `json:"-"`
+	// Events are the event types the webhook is subscribed to.
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type NewWebhookParams struct {
+	UserID uuid.UUID
+	PollID *uuid.UUID
+	URL    string
+	Events []string
+}
+
+// NewWebhook returns a webhook with a new secret.
+func NewWebhook(params NewWebhookParams) *Webhook {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+
+	return &Webhook{
+		ID:        uuid.New(),
+		UserID:    params.UserID,
+		PollID:    params.PollID,
+		URL:       params.URL,
+		Secret:    "whsec_" + base64.RawURLEncoding.EncodeToString(secret),
+		Events:    params.Events,
+		CreatedAt: time.Now(),
+	}
+}
+
+// WebhookDeliveryStatus is where a delivery is in its lifecycle.
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending deliveries are attempted when they are due.
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending"
+	// WebhookDeliverySucceeded deliveries were answered with a 2xx status.
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDeliveryFailed deliveries ran out of attempts.
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an event queued for, and the log of sending it to, a
+// webhook.
+type WebhookDelivery struct {
+	ID        uuid.UUID `json:"id"`
+	WebhookID uuid.UUID `json:"webhookID"`
+	EventID   uuid.UUID `json:"eventID"`
+	EventType string    `json:"eventType"`
+	// Payload is the body sent to the webhook.
+	Payload       string                `json:"payload"`
+	Status        WebhookDeliveryStatus `json:"status"`
+	Attempts      int                   `json:"attempts"`
+	NextAttemptAt time.Time             `json:"nextAttemptAt"`
+	// LastAttemptAt, ResponseStatus and LastError describe the latest
+	// attempt, if there was one. ResponseStatus is not set if the webhook
+	// could not be reached.
+	LastAttemptAt  *time.Time `json:"lastAttemptAt"`
+	ResponseStatus *int       `json:"responseStatus"`
+	LastError      string     `json:"lastError,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// NewWebhookDelivery returns a delivery of an event to a webhook that is due
+// right away.
+func NewWebhookDelivery(webhookID, eventID uuid.UUID, eventType, payload string) *WebhookDelivery {
+	now := time.Now()
+
+	return &WebhookDelivery{
+		ID:            uuid.New(),
+		WebhookID:     webhookID,
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        WebhookDeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+type GetEventWebhooksParams struct {
+	// UserID is the owner of the poll the event is about, whose webhooks
+	// for all polls are included.
+	UserID    uuid.UUID
+	PollID    uuid.UUID
+	EventType string
+}
+
+type ClaimWebhookDeliveriesParams struct {
+	// Now claims the pending deliveries due at or before it.
+	Now   time.Time
+	Limit int
+	// LeaseUntil is when claimed deliveries are due again should their
+	// attempt never complete, e.g. because the process stopped.
+	LeaseUntil time.Time
+}
+
+// ClaimedWebhookDelivery is a delivery to attempt with where to send it.
+type ClaimedWebhookDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// CompleteWebhookDeliveryParams records an attempt of a delivery.
+type CompleteWebhookDeliveryParams struct {
+	DeliveryID     uuid.UUID
+	Status         WebhookDeliveryStatus
+	AttemptedAt    time.Time
+	ResponseStatus *int
+	Error          string
+	// NextAttemptAt is when a delivery that is still pending is due again.
+	NextAttemptAt time.Time
+}
+
+// EventDeliveries returns a delivery of the event to each of the webhooks.
+// Stores queue the deliveries of an event in the same transaction as the
+// change it announces, so that none is lost.
+func EventDeliveries(event events.Event, webhookIDs []uuid.UUID) ([]*WebhookDelivery, error) {
+	if len(webhookIDs) == 0 {
+		return nil, nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*WebhookDelivery, 0, len(webhookIDs))
+	for _, webhookID := range webhookIDs {
+		deliveries = append(deliveries, NewWebhookDelivery(webhookID, event.ID, string(event.Type), string(payload)))
+	}
+
+	return deliveries, nil
+}
+
+// PollCreatedEvent returns the poll.created event of the poll.
+func PollCreatedEvent(poll *Poll) events.Event {
+	return events.New(events.PollCreated, poll.ID, poll.UserID, poll.OrganizationID, events.PollCreatedData{
+		Question:  string(poll.Question),
+		ExpiresAt: poll.ExpiresAt,
+	})
+}
+
+// PollDeletedEvent returns the poll.deleted event of the poll.
+func PollDeletedEvent(pollID, userID uuid.UUID, organizationID *uuid.UUID, question primitives.Question) events.Event {
+	return events.New(events.PollDeleted, pollID, userID, organizationID, events.PollDeletedData{
+		Question: string(question),
+	})
+}
+
+// ClosedPollDeliveries returns a poll.closed delivery of every closed poll to
+// each of its webhooks, given by poll.
+func ClosedPollDeliveries(closed []ClosedPoll, webhookIDs map[uuid.UUID][]uuid.UUID) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	for _, poll := range closed {
+		event := events.New(events.PollClosed, poll.PollID, poll.UserID, poll.OrganizationID, events.PollClosedData{
+			Question:  string(poll.Question),
+			ExpiresAt: poll.ExpiresAt,
+			ClosedAt:  poll.ClosedAt,
+		})
+
+		pollDeliveries, err := EventDeliveries(event, webhookIDs[poll.PollID])
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, pollDeliveries...)
+	}
+
+	return deliveries, nil
+}
+
+// VoteDeliveries returns a vote.recorded delivery of every vote to each of the
+// webhooks of its poll, given by poll. Polls that do not publish their votes,
+// see Poll.PublishesVotes, are left out of webhookIDs.
+func VoteDeliveries(votes []*Vote, webhookIDs map[uuid.UUID][]uuid.UUID) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	for _, vote := range votes {
+		// The owner only decides who is told about the event, which the
+		// webhooks already tell.
+		event := events.New(events.VoteRecorded, vote.PollID, uuid.Nil, nil, events.VoteRecordedData{
+			VoteID:   vote.ID,
+			OptionID: vote.OptionID,
+			VotedAt:  vote.VotedAt,
+		})
+
+		voteDeliveries, err := EventDeliveries(event, webhookIDs[vote.PollID])
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, voteDeliveries...)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,55 @@
+// Package requestid carries the ID of the HTTP request being served through
+// contexts, so that logs and errors from any layer can be correlated with it.
+package requestid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const Header = "X-Request-ID"
+
+// maxLength bounds IDs accepted from clients or proxies.
+const maxLength = 128
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID stored in ctx, or "" if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// valid accepts printable ASCII IDs without spaces, as set by proxies and
+// load balancers.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware reuses a valid incoming X-Request-ID or generates a new one,
+// stores it in the request context and echoes it in the response.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/toramanomer/polly/config"
+)
+
+func newHTTPServer(cfg *config.Config, handler http.Handler) (*http.Server, error) {
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	if cfg.TLSCertFile != "" {
+		reloader, err := newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+	}
+
+	return server, nil
+}
+
+// newMetricsServer returns a plain HTTP server on the metrics address, with
+// the timeouts of the API server.
+func newMetricsServer(cfg *config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.MetricsAddr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+// serve runs the server until it is shut down, over TLS if it is configured.
+func serve(server *http.Server) error {
+	if server.TLSConfig != nil {
+		// The certificate comes from TLSConfig.GetCertificate.
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+// certReloadInterval is how often the certificate files are checked for
+// changes.
+const certReloadInterval = 10 * time.Second
+
+// certReloader serves a TLS certificate and reloads it once its files change
+// on disk, so renewed certificates are picked up without a restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+func (c *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (c *certReloader) reload() error {
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return fmt.Errorf("error reading TLS certificate: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("error loading TLS certificate: %w", err)
+	}
+
+	c.cert, c.modTime, c.checkedAt = &cert, modTime, time.Now()
+	return nil
+}
+
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.checkedAt) < certReloadInterval {
+		return c.cert, nil
+	}
+	c.checkedAt = time.Now()
+
+	// A certificate being replaced may be briefly unreadable or mismatched
+	// with its key. Keep serving the previous one until the new pair loads.
+	if modTime, err := c.latestModTime(); err == nil && modTime.After(c.modTime) {
+		if err := c.reload(); err != nil {
+			log.Printf("Keeping the current TLS certificate: %v", err)
+		}
+	}
+
+	return c.cert, nil
+}
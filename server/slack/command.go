@@ -0,0 +1,87 @@
+package slack
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+var ErrUnterminatedQuote = errors.New("unterminated quote")
+
+// Command is a slash command a user ran, as posted by Slack.
+type Command struct {
+	// Command is the command itself, e.g. /polly.
+	Command   string
+	Text      string
+	TeamID    string
+	ChannelID string
+	UserID    string
+	UserName  string
+	// ResponseURL accepts messages answering the command for 30 minutes.
+	ResponseURL string
+}
+
+// ParseCommand reads a command from the form Slack posts.
+func ParseCommand(form url.Values) Command {
+	return Command{
+		Command:     form.Get("command"),
+		Text:        form.Get("text"),
+		TeamID:      form.Get("team_id"),
+		ChannelID:   form.Get("channel_id"),
+		UserID:      form.Get("user_id"),
+		UserName:    form.Get("user_name"),
+		ResponseURL: form.Get("response_url"),
+	}
+}
+
+// quotes maps every opening double quote to its closing one. Slack clients
+// may turn straight quotes into curly ones as they are typed.
+var quotes = map[rune]rune{
+	'"': '"',
+	'“': '”',
+	'„': '“',
+}
+
+// Arguments splits the text of a command into its arguments, e.g.
+// "Lunch?" Pizza "Fish and chips" into Lunch?, Pizza and Fish and chips.
+// Arguments are separated by spaces and may be quoted to contain them.
+func Arguments(text string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		closing rune
+		inArg   bool
+	)
+
+	for _, r := range text {
+		switch {
+		case closing != 0 && r == closing:
+			args = append(args, current.String())
+			current.Reset()
+			closing, inArg = 0, false
+		case closing != 0:
+			current.WriteRune(r)
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		case !inArg && quotes[r] != 0:
+			closing = quotes[r]
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if closing != 0 {
+		return nil, ErrUnterminatedQuote
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
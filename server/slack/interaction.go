@@ -0,0 +1,50 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+)
+
+// InteractionBlockActions is the type of the interaction sent when a user
+// clicks a button of a message.
+const InteractionBlockActions = "block_actions"
+
+// Interaction is an interactive payload, e.g. of a clicked button.
+type Interaction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Team struct {
+		ID string `json:"id"`
+	} `json:"team"`
+	Actions []Action `json:"actions"`
+	// ResponseURL accepts messages answering the interaction for 30
+	// minutes.
+	ResponseURL string `json:"response_url"`
+}
+
+// Action is an element of a message the user interacted with.
+type Action struct {
+	ActionID string `json:"action_id"`
+	BlockID  string `json:"block_id"`
+	Value    string `json:"value"`
+}
+
+// ParseInteraction reads an interaction from the form Slack posts, which has
+// it as JSON in the payload field.
+func ParseInteraction(form url.Values) (*Interaction, error) {
+	payload := form.Get("payload")
+	if payload == "" {
+		return nil, errors.New("missing interaction payload")
+	}
+
+	var interaction Interaction
+	if err := json.Unmarshal([]byte(payload), &interaction); err != nil {
+		return nil, err
+	}
+
+	return &interaction, nil
+}
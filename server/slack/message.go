@@ -0,0 +1,92 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ResponseInChannel messages are posted for everyone in the channel.
+	ResponseInChannel = "in_channel"
+	// ResponseEphemeral messages are only shown to the user who ran the
+	// command or interacted.
+	ResponseEphemeral = "ephemeral"
+)
+
+// Message answers a command or an interaction. Text is shown where blocks
+// cannot be, e.g. in notifications.
+type Message struct {
+	ResponseType    string  `json:"response_type,omitempty"`
+	Text            string  `json:"text"`
+	Blocks          []Block `json:"blocks,omitempty"`
+	ReplaceOriginal bool    `json:"replace_original,omitempty"`
+}
+
+// Block is a Block Kit layout block; only the fields of the section and
+// actions blocks are supported.
+type Block struct {
+	Type    string `json:"type"`
+	BlockID string `json:"block_id,omitempty"`
+	// Text is the text of a section block.
+	Text *Text `json:"text,omitempty"`
+	// Elements are the buttons of an actions block.
+	Elements []Button `json:"elements,omitempty"`
+}
+
+// Text is a text object, formatted if its type is mrkdwn.
+type Text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Button is a button element. Clicking it sends an interaction with its
+// action ID and value.
+type Button struct {
+	Type     string `json:"type"`
+	ActionID string `json:"action_id"`
+	Text     Text   `json:"text"`
+	Value    string `json:"value"`
+}
+
+// Ephemeral returns a plain message only shown to the user.
+func Ephemeral(text string) Message {
+	return Message{ResponseType: ResponseEphemeral, Text: text}
+}
+
+// Escape escapes the characters mrkdwn texts give a meaning to, so that user
+// input is shown as is.
+func Escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// Respond posts a message to the response URL of a command or interaction.
+func Respond(ctx context.Context, client *http.Client, responseURL string, message Message) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/repository"
+)
+
+const (
+	// voteActionPrefix prefixes the action IDs of vote buttons, which end
+	// in the option voted for. Their value is the poll.
+	voteActionPrefix = "vote:"
+	// buttonsPerBlock keeps rows of buttons short.
+	buttonsPerBlock = 5
+	// maxButtonTextLength is the longest label Slack accepts for a button.
+	maxButtonTextLength = 75
+)
+
+// PollMessage returns a message posting the poll to the channel with a vote
+// button per option. link is where the results are shown.
+func PollMessage(poll *repository.Poll, link string) Message {
+	header := fmt.Sprintf("*%s*\nCloses <!date^%d^{date_short_pretty} at {time}|%s>. <%s|View results>",
+		Escape(string(poll.Question)),
+		poll.ExpiresAt.Unix(), poll.ExpiresAt.UTC().Format("2006-01-02 15:04 UTC"),
+		link)
+
+	blocks := []Block{{Type: "section", Text: &Text{Type: "mrkdwn", Text: header}}}
+
+	for start := 0; start < len(poll.Options); start += buttonsPerBlock {
+		block := Block{Type: "actions", BlockID: fmt.Sprintf("poll:%s:%d", poll.ID, start/buttonsPerBlock)}
+
+		for _, option := range poll.Options[start:min(start+buttonsPerBlock, len(poll.Options))] {
+			block.Elements = append(block.Elements, Button{
+				Type:     "button",
+				ActionID: voteActionPrefix + option.ID.String(),
+				Text:     Text{Type: "plain_text", Text: truncate(string(option.Text), maxButtonTextLength)},
+				Value:    poll.ID.String(),
+			})
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return Message{
+		ResponseType: ResponseInChannel,
+		Text:         string(poll.Question),
+		Blocks:       blocks,
+	}
+}
+
+// ParseVote returns the poll and option of a clicked vote button. ok is false
+// for any other action.
+func ParseVote(action Action) (pollID, optionID uuid.UUID, ok bool) {
+	option, found := strings.CutPrefix(action.ActionID, voteActionPrefix)
+	if !found {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	optionID, err := uuid.Parse(option)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	pollID, err = uuid.Parse(action.Value)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return pollID, optionID, true
+}
+
+// truncate shortens s to at most n runes, ending it with an ellipsis if it
+// was longer.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,67 @@
+// Package slack speaks the protocol of Slack apps: slash commands, the
+// interactive payloads of clicked buttons and the messages answering them.
+// Every request from Slack is signed with the signing secret of the app; see
+// Sign and Verify.
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// TimestampHeader is when Slack sent the request, in Unix seconds.
+	TimestampHeader = "X-Slack-Request-Timestamp"
+	// SignatureHeader carries the signature of the request, e.g.
+	// v0=a2114d57...
+	SignatureHeader = "X-Slack-Signature"
+)
+
+// Tolerance is how old a request may be. Older requests are rejected, so
+// captured requests cannot be replayed later.
+const Tolerance = 5 * time.Minute
+
+var (
+	ErrInvalidSignature = errors.New("invalid slack signature")
+	ErrSignatureExpired = errors.New("slack signature expired")
+)
+
+// Sign returns the SignatureHeader value of a body sent at timestamp: v0= and
+// the hex encoded HMAC-SHA256, keyed with the signing secret, of v0:, the Unix
+// timestamp, a colon and the body.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	return signature(secret, strconv.FormatInt(timestamp.Unix(), 10), body)
+}
+
+func signature(secret, unix string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + unix + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a request with the given headers and body.
+// Without a signing secret no request is valid.
+func Verify(secret string, header http.Header, body []byte, now time.Time) error {
+	unix := header.Get(TimestampHeader)
+
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if secret == "" || err != nil {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(signature(secret, unix, body))) {
+		return ErrInvalidSignature
+	}
+
+	if age := now.Sub(time.Unix(seconds, 0)); age > Tolerance || age < -Tolerance {
+		return ErrSignatureExpired
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryTracer creates a client span for every query, COPY and pool acquire.
+// Install it as pgxpool.Config.ConnConfig.Tracer.
+type QueryTracer struct{}
+
+var (
+	_ pgx.QueryTracer       = QueryTracer{}
+	_ pgx.CopyFromTracer    = QueryTracer{}
+	_ pgxpool.AcquireTracer = QueryTracer{}
+)
+
+var dbSystem = attribute.String("db.system.name", "postgresql")
+
+// operation returns the leading keyword of a statement, e.g. SELECT or WITH.
+func operation(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if i := strings.IndexAny(sql, " \t\n("); i != -1 {
+		sql = sql[:i]
+	}
+	return strings.ToUpper(sql)
+}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	op := operation(data.SQL)
+
+	ctx, _ = Start(ctx, "postgres "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			dbSystem,
+			attribute.String("db.operation.name", op),
+			attribute.String("db.query.text", data.SQL),
+		),
+	)
+
+	return ctx
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("db.response.returned_rows", data.CommandTag.RowsAffected()))
+	End(span, data.Err)
+}
+
+func (QueryTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	ctx, _ = Start(ctx, "postgres COPY "+data.TableName.Sanitize(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			dbSystem,
+			attribute.String("db.operation.name", "COPY"),
+			attribute.String("db.collection.name", data.TableName.Sanitize()),
+		),
+	)
+
+	return ctx
+}
+
+func (QueryTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("db.response.returned_rows", data.CommandTag.RowsAffected()))
+	End(span, data.Err)
+}
+
+// TraceAcquireStart and TraceAcquireEnd time waiting for a pool connection,
+// which dominates query latency once the pool is exhausted under load.
+func (QueryTracer) TraceAcquireStart(ctx context.Context, _ *pgxpool.Pool, _ pgxpool.TraceAcquireStartData) context.Context {
+	ctx, _ = Start(ctx, "pgxpool acquire", trace.WithAttributes(dbSystem))
+	return ctx
+}
+
+func (QueryTracer) TraceAcquireEnd(ctx context.Context, _ *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	End(trace.SpanFromContext(ctx), data.Err)
+}
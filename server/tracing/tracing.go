@@ -0,0 +1,95 @@
+// Package tracing sets up OpenTelemetry tracing and instruments the HTTP
+// router and the database driver.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/toramanomer/polly"
+
+// Exporters accepted by Setup.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// tracer returns the tracer of the globally registered provider, so spans are
+// no-ops until Setup installs an exporter.
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span with the server's tracer.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, opts...)
+}
+
+// End ends span, marking it as failed if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Setup installs the global tracer provider and W3C trace context propagation.
+// The OTLP exporter is configured through the standard OTEL_EXPORTER_OTLP_*
+// environment variables. The returned function flushes pending spans.
+func Setup(ctx context.Context, exporter string, sampleRatio float64) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		spanExporter sdktrace.SpanExporter
+		err          error
+	)
+
+	switch exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s trace exporter: %w", exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName("polly"),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error creating trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,173 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/toramanomer/polly/repository"
+)
+
+type Config struct {
+	// PollInterval is how often due deliveries are looked for.
+	PollInterval time.Duration
+	// BatchSize is the most deliveries claimed at once.
+	BatchSize int
+	// MaxAttempts is how often a delivery is attempted before it fails.
+	MaxAttempts int
+	// BaseBackoff is the wait after the first failed attempt. It doubles
+	// with every further one, up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Lease is how long a claimed delivery is left alone before it is
+	// attempted again, should its attempt never complete.
+	Lease time.Duration
+}
+
+var DefaultConfig = Config{
+	PollInterval: 5 * time.Second,
+	BatchSize:    50,
+	MaxAttempts:  8,
+	BaseBackoff:  time.Minute,
+	MaxBackoff:   6 * time.Hour,
+	Lease:        5 * time.Minute,
+}
+
+// Store is the part of repository.Store webhooks are delivered from.
+type Store interface {
+	ClaimWebhookDeliveries(ctx context.Context, arg repository.ClaimWebhookDeliveriesParams) ([]repository.ClaimedWebhookDelivery, error)
+	CompleteWebhookDelivery(ctx context.Context, arg repository.CompleteWebhookDeliveryParams) error
+}
+
+// Dispatcher sends the queued deliveries once they are due and records every
+// attempt. Several dispatchers may share a store; each delivery is claimed by
+// one of them at a time.
+type Dispatcher struct {
+	store  Store
+	sender *Sender
+	config Config
+}
+
+func NewDispatcher(store Store, sender *Sender, config Config) *Dispatcher {
+	return &Dispatcher{store: store, sender: sender, config: config}
+}
+
+// Run dispatches deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			dispatched, err := d.Dispatch(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "error dispatching webhook deliveries", "error", err)
+				break
+			}
+			if dispatched < d.config.BatchSize {
+				break
+			}
+		}
+	}
+}
+
+// Dispatch attempts a batch of due deliveries and returns how many it
+// attempted.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	claimed, err := d.store.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesParams{
+		Now:        now,
+		Limit:      d.config.BatchSize,
+		LeaseUntil: now.Add(d.config.Lease),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// The deliveries of a batch are sent concurrently, so one slow webhook
+	// does not hold up the others past their lease.
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(claimed))
+	)
+	for i, delivery := range claimed {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := d.sender.Send(ctx, Message{
+				URL:        delivery.URL,
+				Secret:     delivery.Secret,
+				DeliveryID: delivery.ID.String(),
+				EventType:  delivery.EventType,
+				Payload:    []byte(delivery.Payload),
+			})
+
+			if err := d.store.CompleteWebhookDelivery(ctx, d.complete(delivery, result, time.Now())); err != nil {
+				slog.ErrorContext(ctx, "error recording webhook delivery attempt",
+					"webhook_id", delivery.WebhookID,
+					"delivery_id", delivery.ID,
+					"event_type", delivery.EventType,
+					"error", err,
+				)
+				errs[i] = err
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return 0, err
+	}
+
+	return len(claimed), nil
+}
+
+// complete decides what becomes of a delivery after an attempt.
+func (d *Dispatcher) complete(delivery repository.ClaimedWebhookDelivery, result Result, attemptedAt time.Time) repository.CompleteWebhookDeliveryParams {
+	params := repository.CompleteWebhookDeliveryParams{
+		DeliveryID:     delivery.ID,
+		Status:         repository.WebhookDeliverySucceeded,
+		AttemptedAt:    attemptedAt,
+		ResponseStatus: result.ResponseStatus,
+		NextAttemptAt:  attemptedAt,
+	}
+
+	if result.Err == nil {
+		return params
+	}
+
+	params.Error = result.Err.Error()
+
+	attempts := delivery.Attempts + 1
+	if attempts >= d.config.MaxAttempts {
+		params.Status = repository.WebhookDeliveryFailed
+		return params
+	}
+
+	params.Status = repository.WebhookDeliveryPending
+	params.NextAttemptAt = attemptedAt.Add(d.backoff(attempts))
+
+	return params
+}
+
+// backoff is the wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.config.BaseBackoff
+	for range attempts - 1 {
+		wait *= 2
+		if wait >= d.config.MaxBackoff {
+			return d.config.MaxBackoff
+		}
+	}
+	return wait
+}
@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned for webhook URLs resolving to loopback,
+// private or otherwise internal addresses, which are not reached unless they
+// are allowed.
+var ErrBlockedAddress = errors.New("webhook address is not public")
+
+// maxResponseBytes is how much of a response is read before the connection
+// is closed; only its status matters.
+const maxResponseBytes = 64 << 10
+
+// Sender sends signed payloads to webhooks.
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender returns a Sender whose requests time out after timeout. Unless
+// allowPrivateNetworks is set, it refuses to connect to internal addresses,
+// whatever the URL resolves to at the time.
+func NewSender(timeout time.Duration, allowPrivateNetworks bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateNetworks {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublic(addrPort.Addr()) {
+				return ErrBlockedAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would connect on our behalf, past the address check.
+	transport.Proxy = nil
+
+	return &Sender{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			// Redirects are not followed; the webhook URL has to be
+			// the one that takes the delivery.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() &&
+		!addr.IsLoopback() && !addr.IsLinkLocalUnicast()
+}
+
+// Message is a payload to send to a webhook.
+type Message struct {
+	URL        string
+	Secret     string
+	DeliveryID string
+	EventType  string
+	Payload    []byte
+}
+
+// Result is the outcome of sending a message. ResponseStatus is not set if
+// the webhook could not be reached, and Err is set unless the webhook
+// answered with a 2xx status.
+type Result struct {
+	ResponseStatus *int
+	Err            error
+}
+
+// Send posts the message to its webhook, signed as of now.
+func (s *Sender) Send(ctx context.Context, message Message) Result {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, message.URL, bytes.NewReader(message.Payload))
+	if err != nil {
+		return Result{Err: err}
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Polly-Webhooks/1")
+	request.Header.Set(EventHeader, message.EventType)
+	request.Header.Set(DeliveryHeader, message.DeliveryID)
+	request.Header.Set(SignatureHeader, Sign(message.Secret, time.Now(), message.Payload))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return Result{Err: err}
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxResponseBytes))
+
+	result := Result{ResponseStatus: &response.StatusCode}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		result.Err = fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	return result
+}
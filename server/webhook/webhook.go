@@ -0,0 +1,90 @@
+// Package webhook delivers events to the URLs users subscribed to them. The
+// store queues the deliveries of an event along with the change it announces;
+// Dispatcher sends them and retries failed ones with exponential backoff.
+// Every payload is signed with the secret of its webhook; see Sign and Verify.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// EventHeader names the type of the event sent.
+	EventHeader = "X-Polly-Event"
+	// DeliveryHeader identifies the delivery; it is the same for every
+	// attempt of it.
+	DeliveryHeader = "X-Polly-Delivery"
+	// SignatureHeader carries the signature of the payload, e.g.
+	// t=1700000000,v1=5257a869...
+	SignatureHeader = "X-Polly-Signature"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrSignatureExpired = errors.New("webhook signature expired")
+)
+
+// Sign returns the SignatureHeader value of a payload sent at timestamp: the
+// hex encoded HMAC-SHA256, keyed with the secret, of the Unix timestamp, a
+// period and the payload.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", unix, signature(secret, unix, payload))
+}
+
+func signature(secret, unix string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the SignatureHeader value of a received payload. Signatures
+// older than tolerance are rejected, so captured requests cannot be replayed
+// later.
+func Verify(secret, header string, payload []byte, now time.Time, tolerance time.Duration) error {
+	var unix, signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			unix = append(unix, value)
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	if len(unix) != 1 || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	seconds, err := strconv.ParseInt(unix[0], 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	expected := signature(secret, unix[0], payload)
+	valid := false
+	for _, candidate := range signatures {
+		if hmac.Equal([]byte(candidate), []byte(expected)) {
+			valid = true
+		}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	return nil
+}
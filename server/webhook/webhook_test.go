@@ -0,0 +1,227 @@
+package webhook_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toramanomer/polly/events"
+	"github.com/toramanomer/polly/repository"
+	"github.com/toramanomer/polly/repository/memory"
+	"github.com/toramanomer/polly/webhook"
+)
+
+// receiver is a webhook answering with the given statuses in turn, repeating
+// the last one, and keeping the requests it received.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	status := rc.statuses[min(len(rc.requests), len(rc.statuses)-1)]
+	rc.requests = append(rc.requests, receivedRequest{header: r.Header.Clone(), body: body})
+
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) received() []receivedRequest {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return slices.Clone(rc.requests)
+}
+
+var testConfig = webhook.Config{
+	PollInterval: time.Hour,
+	BatchSize:    10,
+	MaxAttempts:  3,
+	BaseBackoff:  20 * time.Millisecond,
+	MaxBackoff:   20 * time.Millisecond,
+	Lease:        time.Minute,
+}
+
+// publish subscribes a webhook at url to poll.created and creates a poll.
+func publish(t *testing.T, store *memory.Store, url string) *repository.Webhook {
+	t.Helper()
+	ctx := context.Background()
+
+	user := &repository.User{
+		ID:           uuid.New(),
+		Username:     "alice",
+		Email:        "alice@example.com",
+		PasswordHash: "hash",
+		Plan:         repository.DefaultPlan,
+	}
+	if err := store.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	hook := repository.NewWebhook(repository.NewWebhookParams{
+		UserID: user.ID,
+		URL:    url,
+		Events: []string{string(events.PollCreated)},
+	})
+	if err := store.CreateWebhook(ctx, hook); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	// Creating the poll queues its poll.created delivery.
+	poll := repository.NewPoll(repository.NewPollParams{
+		UserID:    user.ID,
+		Question:  "Lunch?",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Options:   []repository.NewPollOption{{Text: "Pizza"}, {Text: "Sushi"}},
+	})
+	if err := store.CreatePollWithOptions(ctx, poll); err != nil {
+		t.Fatalf("CreatePollWithOptions: %v", err)
+	}
+
+	return hook
+}
+
+// dispatch runs the dispatcher once and checks how many deliveries it
+// attempted.
+func dispatch(t *testing.T, dispatcher *webhook.Dispatcher, want int) {
+	t.Helper()
+
+	dispatched, err := dispatcher.Dispatch(context.Background())
+	if err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if dispatched != want {
+		t.Fatalf("Dispatch: attempted %d deliveries, want %d", dispatched, want)
+	}
+}
+
+func delivery(t *testing.T, store *memory.Store, webhookID uuid.UUID) repository.WebhookDelivery {
+	t.Helper()
+
+	deliveries, err := store.GetWebhookDeliveries(context.Background(), webhookID, 10)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("GetWebhookDeliveries: got %d deliveries, want 1", len(deliveries))
+	}
+
+	return deliveries[0]
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusNoContent}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	store := memory.New()
+	hook := publish(t, store, server.URL)
+	dispatcher := webhook.NewDispatcher(store, webhook.NewSender(time.Second, true), testConfig)
+
+	dispatch(t, dispatcher, 1)
+
+	got := delivery(t, store, hook.ID)
+	if got.Status != repository.WebhookDeliveryPending || got.Attempts != 1 ||
+		got.ResponseStatus == nil || *got.ResponseStatus != http.StatusInternalServerError || got.LastError == "" {
+		t.Fatalf("after a 500: got %+v, want a pending delivery with 1 attempt", got)
+	}
+	if wait := got.NextAttemptAt.Sub(*got.LastAttemptAt); wait < testConfig.BaseBackoff-time.Millisecond {
+		t.Fatalf("after a 500: retried after %v, want %v", wait, testConfig.BaseBackoff)
+	}
+
+	// The retry is not due before the backoff passed.
+	dispatch(t, dispatcher, 0)
+	time.Sleep(2 * testConfig.BaseBackoff)
+	dispatch(t, dispatcher, 1)
+
+	got = delivery(t, store, hook.ID)
+	if got.Status != repository.WebhookDeliverySucceeded || got.Attempts != 2 ||
+		got.ResponseStatus == nil || *got.ResponseStatus != http.StatusNoContent || got.LastError != "" {
+		t.Fatalf("after a 204: got %+v, want a succeeded delivery with 2 attempts", got)
+	}
+
+	requests := rc.received()
+	if len(requests) != 2 {
+		t.Fatalf("webhook received %d requests, want 2", len(requests))
+	}
+
+	for i, request := range requests {
+		if err := webhook.Verify(hook.Secret, request.header.Get(webhook.SignatureHeader), request.body, time.Now(), time.Minute); err != nil {
+			t.Errorf("request %d: Verify: %v", i, err)
+		}
+		if err := webhook.Verify("whsec_other", request.header.Get(webhook.SignatureHeader), request.body, time.Now(), time.Minute); !errors.Is(err, webhook.ErrInvalidSignature) {
+			t.Errorf("request %d: Verify with another secret: got %v, want %v", i, err, webhook.ErrInvalidSignature)
+		}
+		if event := request.header.Get(webhook.EventHeader); event != string(events.PollCreated) {
+			t.Errorf("request %d: got event %q, want %q", i, event, events.PollCreated)
+		}
+		if id := request.header.Get(webhook.DeliveryHeader); id != got.ID.String() {
+			t.Errorf("request %d: got delivery %q, want %q", i, id, got.ID)
+		}
+		if string(request.body) != got.Payload {
+			t.Errorf("request %d: got body %s, want %s", i, request.body, got.Payload)
+		}
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	store := memory.New()
+	hook := publish(t, store, server.URL)
+	dispatcher := webhook.NewDispatcher(store, webhook.NewSender(time.Second, true), testConfig)
+
+	for range testConfig.MaxAttempts {
+		dispatch(t, dispatcher, 1)
+		time.Sleep(2 * testConfig.BaseBackoff)
+	}
+	dispatch(t, dispatcher, 0)
+
+	got := delivery(t, store, hook.ID)
+	if got.Status != repository.WebhookDeliveryFailed || got.Attempts != testConfig.MaxAttempts ||
+		got.ResponseStatus == nil || *got.ResponseStatus != http.StatusServiceUnavailable {
+		t.Fatalf("got %+v, want a failed delivery with %d attempts", got, testConfig.MaxAttempts)
+	}
+
+	if requests := rc.received(); len(requests) != testConfig.MaxAttempts {
+		t.Fatalf("webhook received %d requests, want %d", len(requests), testConfig.MaxAttempts)
+	}
+}
+
+func TestSenderBlocksPrivateNetworks(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusNoContent}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	result := webhook.NewSender(time.Second, false).Send(context.Background(), webhook.Message{
+		URL:     server.URL,
+		Secret:  "whsec_test",
+		Payload: []byte(`{}`),
+	})
+	if !errors.Is(result.Err, webhook.ErrBlockedAddress) || result.ResponseStatus != nil {
+		t.Fatalf("Send to loopback: got %+v, want %v", result, webhook.ErrBlockedAddress)
+	}
+
+	if requests := rc.received(); len(requests) != 0 {
+		t.Fatalf("webhook received %d requests, want none", len(requests))
+	}
+}
@@ -48,12 +48,12 @@ export const Signin = () => {
 			navigate('/home', { replace: true })
 		},
 		onError: (error: SigninError) => {
-			if (error.type === 'invalid_credentials') {
+			if (error.type === '/problems/invalid-credentials') {
 				form.setError('root', {
 					type: 'manual',
 					message: 'Invalid email or password'
 				})
-			} else if (error.type === 'validation_error' && error.errors) {
+			} else if (error.type === '/problems/validation-error' && error.errors) {
 				Object.entries(error.errors).forEach(([field, messages]) => {
 					form.setError(field as any, {
 						type: 'manual',
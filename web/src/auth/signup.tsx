@@ -55,17 +55,17 @@ export const Signup = () => {
 			navigate('/signin')
 		},
 		onError: (error: SignupError) => {
-			if (error.type === 'email_already_exists') {
+			if (error.type === '/problems/email-already-exists') {
 				form.setError('email', {
 					type: 'manual',
 					message: 'Email already exists'
 				})
-			} else if (error.type === 'username_already_exists') {
+			} else if (error.type === '/problems/username-already-exists') {
 				form.setError('username', {
 					type: 'manual',
 					message: 'Username already exists'
 				})
-			} else if (error.type === 'validation_error' && error.errors) {
+			} else if (error.type === '/problems/validation-error' && error.errors) {
 				Object.entries(error.errors).forEach(([field, messages]) => {
 					form.setError(field as any, {
 						type: 'manual',
@@ -1,5 +1,5 @@
 import { useForm, useFieldArray } from 'react-hook-form'
-import { useMutation } from '@tanstack/react-query'
+import { useMutation, useQuery } from '@tanstack/react-query'
 import {
 	Button,
 	Divider,
@@ -18,18 +18,39 @@ type CreatePollForm = {
 	question: string
 	options: { value: string }[]
 	expiresAt: string
+	// duration is a preset used instead of expiresAt, e.g. 1d.
+	duration: string
 }
 
+type PollLimits = {
+	minOptions: number
+	maxOptions: number
+	maxDurationSeconds: number
+	durations: string[]
+}
+
+const durationLabels: Record<string, string> = {
+	'1h': '1 hour',
+	'1d': '1 day',
+	'1w': '1 week'
+}
+
+// optionKey is what tells options apart: the server ignores letter case and
+// whitespace as well.
+const optionKey = (text: string) =>
+	text.trim().replace(/\s+/g, ' ').toLowerCase()
+
 type CreatePollProps = {
 	onSuccess: () => void
 }
 
 export const CreatePoll = ({ onSuccess }: CreatePollProps) => {
-	const { register, handleSubmit, formState, control } =
+	const { register, handleSubmit, formState, control, watch, setValue } =
 		useForm<CreatePollForm>({
 			defaultValues: {
 				question: '',
 				options: [{ value: '' }, { value: '' }],
+				duration: '',
 				expiresAt: new Date(Date.now() + 24 * 60 * 60 * 1000)
 					.toISOString()
 					.slice(0, 16) // Default to 24 hours from now
@@ -41,6 +62,18 @@ export const CreatePoll = ({ onSuccess }: CreatePollProps) => {
 		name: 'options'
 	})
 
+	const { data: limits } = useQuery<PollLimits>({
+		queryKey: ['pollLimits'],
+		queryFn: async () => {
+			const response = await fetch('/api/polls/limits')
+			if (!response.ok) throw new Error('Failed to fetch poll limits')
+			return response.json()
+		}
+	})
+	const minOptions = limits?.minOptions ?? 2
+	const maxOptions = limits?.maxOptions ?? 6
+	const duration = watch('duration')
+
 	const { mutate } = useMutation({
 		mutationFn: async (data: CreatePollForm) => {
 			const response = await fetch('/api/polls', {
@@ -49,7 +82,10 @@ export const CreatePoll = ({ onSuccess }: CreatePollProps) => {
 				body: JSON.stringify({
 					question: data.question,
 					options: data.options.map(opt => opt.value),
-					expiresAt: new Date(data.expiresAt).toISOString()
+					...(data.duration
+						? { duration: data.duration }
+						: { expiresAt: new Date(data.expiresAt).toISOString() }),
+					timeZone: Intl.DateTimeFormat().resolvedOptions().timeZone
 				})
 			})
 
@@ -88,20 +124,30 @@ export const CreatePoll = ({ onSuccess }: CreatePollProps) => {
 				<Divider>Options</Divider>
 
 				{fields.map((field, index) => (
-					<FormControl key={field.id}>
+					<FormControl
+						key={field.id}
+						error={!!formState.errors.options?.[index]}
+					>
 						<Stack direction='row' spacing={1}>
 							<Input
 								{...register(
 									`options.${index}.value` as const,
 									{
-										required: 'Option is required'
+										required: 'Option is required',
+										validate: (value, form) =>
+											form.options.findIndex(
+												option =>
+													optionKey(option.value) ===
+													optionKey(value)
+											) === index ||
+											'Option is a duplicate'
 									}
 								)}
 								placeholder={`Option ${index + 1}`}
 								size='lg'
 								sx={{ flex: 1 }}
 							/>
-							{fields.length > 2 && (
+							{fields.length > minOptions && (
 								<IconButton
 									variant='soft'
 									color='danger'
@@ -111,10 +157,13 @@ export const CreatePoll = ({ onSuccess }: CreatePollProps) => {
 								</IconButton>
 							)}
 						</Stack>
+						<FormHelperText>
+							{formState.errors.options?.[index]?.value?.message}
+						</FormHelperText>
 					</FormControl>
 				))}
 
-				{fields.length < 6 && (
+				{fields.length < maxOptions && (
 					<Button
 						variant='outlined'
 						startDecorator={<AddIcon />}
@@ -126,17 +175,52 @@ export const CreatePoll = ({ onSuccess }: CreatePollProps) => {
 
 				<Divider>Expiration</Divider>
 
-				<FormControl>
+				<Stack direction='row' spacing={1}>
+					{limits?.durations.map(preset => (
+						<Button
+							key={preset}
+							variant={duration === preset ? 'solid' : 'outlined'}
+							onClick={() =>
+								setValue(
+									'duration',
+									duration === preset ? '' : preset
+								)
+							}
+						>
+							{durationLabels[preset] ?? preset}
+						</Button>
+					))}
+				</Stack>
+
+				<FormControl error={!!formState.errors.expiresAt}>
 					<FormLabel>When should this poll expire?</FormLabel>
 					<Input
 						{...register('expiresAt', {
-							required: 'Expiration time is required'
+							validate: (value, form) => {
+								if (form.duration) return true
+								if (!value) return 'Expiration time is required'
+
+								const expiresAt = new Date(value).getTime()
+								if (expiresAt <= Date.now())
+									return 'Expiration time must be in the future'
+								if (
+									limits &&
+									expiresAt - Date.now() >
+										limits.maxDurationSeconds * 1000
+								)
+									return 'Expiration time is too far away'
+								return true
+							}
 						})}
 						type='datetime-local'
 						size='lg'
+						disabled={!!duration}
 					/>
 					<FormHelperText>
-						The poll will automatically close at this time
+						{formState.errors.expiresAt?.message ??
+							(duration
+								? `The poll will close ${durationLabels[duration] ?? duration} after it is created`
+								: 'The poll will automatically close at this time')}
 					</FormHelperText>
 				</FormControl>
 
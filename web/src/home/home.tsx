@@ -71,7 +71,8 @@ export const Home = () => {
 		queryFn: async () => {
 			const response = await fetch('/api/polls')
 			if (!response.ok) throw new Error('Failed to fetch polls')
-			return response.json()
+			const body = await response.json()
+			return body.polls
 		},
 		retry: false
 	})
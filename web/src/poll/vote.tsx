@@ -1,5 +1,5 @@
 import { useState, useEffect, useRef } from 'react'
-import { useParams } from 'react-router'
+import { useParams, useSearchParams } from 'react-router'
 import { Turnstile } from '@marsidev/react-turnstile'
 import {
 	Box,
@@ -11,8 +11,10 @@ import {
 	RadioGroup,
 	FormControl,
 	FormLabel,
+	Input,
 	Alert,
-	CircularProgress
+	CircularProgress,
+	Link
 } from '@mui/joy'
 import { useMutation, useQuery } from '@tanstack/react-query'
 
@@ -20,6 +22,11 @@ type PollOption = {
 	id: string
 	text: string
 	position: number
+	// The Other option takes a free-text answer.
+	other: boolean
+	description?: string
+	imageID?: string
+	url?: string
 }
 
 type Poll = {
@@ -27,11 +34,52 @@ type Poll = {
 	question: string
 	options: PollOption[]
 	expiresAt: string
+	timeZone: string
+	// display has the times in the time zone of the poll.
+	display: {
+		expiresAt: string
+		timeZoneAbbreviation: string
+	}
 }
 
+const OptionLabel = ({ option }: { option: PollOption }) => (
+	<Stack spacing={0.5}>
+		<Typography>{option.text}</Typography>
+		{option.description && (
+			<Typography level='body-sm'>{option.description}</Typography>
+		)}
+		{option.imageID && (
+			<Box
+				component='img'
+				src={`/api/images/${option.imageID}`}
+				alt={option.text}
+				sx={{ maxWidth: '100%', maxHeight: 200, borderRadius: 'sm' }}
+			/>
+		)}
+		{option.url && (
+			<Link
+				href={option.url}
+				target='_blank'
+				rel='noopener noreferrer'
+				level='body-sm'
+				onClick={e => e.stopPropagation()}
+			>
+				{option.url}
+			</Link>
+		)}
+	</Stack>
+)
+
 export const Vote = () => {
 	const { pollId } = useParams<{ pollId: string }>()
+	// Invitation links of ballot polls carry the voter's ballot token.
+	const [searchParams] = useSearchParams()
+	const ballot = searchParams.get('ballot')
+	const ballotHeaders: Record<string, string> = ballot
+		? { 'X-Ballot-Token': ballot }
+		: {}
 	const [selectedOption, setSelectedOption] = useState<string>('')
+	const [otherText, setOtherText] = useState<string>('')
 	const [token, setToken] = useState<string>('')
 	const {
 		data: poll,
@@ -40,7 +88,9 @@ export const Vote = () => {
 	} = useQuery<Poll>({
 		queryKey: ['poll', pollId],
 		queryFn: async () => {
-			const response = await fetch(`/api/polls/${pollId}`)
+			const response = await fetch(`/api/polls/${pollId}`, {
+				headers: ballotHeaders
+			})
 
 			if (!response.ok) throw new Error('Failed to fetch poll')
 			return response.json()
@@ -54,17 +104,25 @@ export const Vote = () => {
 				method: 'POST',
 				headers: {
 					'Content-Type': 'application/json',
-					'X-CF-Turnstile-Token': token
+					'X-CF-Turnstile-Token': token,
+					...ballotHeaders
 				},
-				body: JSON.stringify({ optionId })
+				body: JSON.stringify(
+					isOtherSelected ? { optionId, otherText } : { optionId }
+				)
 			})
 			if (!response.ok) throw new Error('Failed to vote')
 			return response.json()
 		}
 	})
 
+	const isOtherSelected = !!poll?.options.find(
+		option => option.id === selectedOption && option.other
+	)
+
 	const handleVote = () => {
 		if (!selectedOption || !token) return
+		if (isOtherSelected && !otherText.trim()) return
 		mutate(selectedOption)
 	}
 
@@ -127,6 +185,12 @@ export const Vote = () => {
 						{poll.question}
 					</Typography>
 
+					<Typography level='body-sm' sx={{ textAlign: 'center' }}>
+						{isExpired ? 'Closed' : 'Closes'}{' '}
+						{poll.display.expiresAt.slice(0, 16).replace('T', ' ')}{' '}
+						{poll.display.timeZoneAbbreviation} ({poll.timeZone})
+					</Typography>
+
 					{isExpired ? (
 						<Alert color='warning' variant='soft'>
 							This poll has expired and is no longer accepting
@@ -152,18 +216,33 @@ export const Vote = () => {
 											<Radio
 												key={option.id}
 												value={option.id}
-												label={option.text}
+												label={<OptionLabel option={option} />}
 												sx={{ mb: 1 }}
 											/>
 										))}
 								</RadioGroup>
+								{isOtherSelected && (
+									<Input
+										placeholder='Your answer'
+										value={otherText}
+										onChange={e =>
+											setOtherText(e.target.value)
+										}
+										slotProps={{
+											input: { maxLength: 100 }
+										}}
+									/>
+								)}
 							</FormControl>
 
 							<Button
 								size='lg'
 								onClick={handleVote}
 								disabled={
-									!selectedOption || isPending || !token
+									!selectedOption ||
+									(isOtherSelected && !otherText.trim()) ||
+									isPending ||
+									!token
 								}
 								loading={isPending}
 							>